@@ -7,14 +7,26 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/channelsync"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/driver"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/emails"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/handlers"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/loginguard"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/mailer"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/outbox"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/scheduler"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/sessionstore"
+	dbrepository "github.com/LuisBarroso37/bed-and-breakfast/internal/repository/db-repository"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/usercommand"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/webhooks"
 	"github.com/alexedwards/scs/v2"
 )
 
@@ -24,20 +36,57 @@ const portNumber = ":8080"
 var app config.AppConfig
 var session *scs.SessionManager
 
+// Sends the emails of the outbox
+var mailSender mailer.Mailer
+
 func main() {
+	// `bed-and-breakfast user ...` manages admin users instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "user" {
+		err := runUserCommand(os.Args[2:], os.Stdin, os.Stdout)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	// Setup global configuration
 	pool, err := run()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// Close SQL connection pool and mail channel
+	// Close SQL connection pool and mail connection
 	defer pool.SQL.Close()
-	defer close(app.MailChan)
+	defer mailSender.Close()
+
+	// Send the emails of the outbox in the background
+	log.Println("Starting mail workers...")
+	outbox.NewWorker(dbrepository.NewPostgresRepository(pool.SQL, &app), &app, mailSender.Send).Start(app.MailWorkers, 5 * time.Second)
+
+	// Import the calendars of external booking sites in the background
+	log.Println("Starting calendar sync...")
+	channelsync.NewSyncer(dbrepository.NewPostgresRepository(pool.SQL, &app), &app).Start(app.CalendarSyncInterval)
 
-	// Run mail server to listen for email messages
-	log.Println("Starting mail server...")
-	listenForMail()
+	// Send queued webhook deliveries in the background
+	log.Println("Starting webhook worker...")
+	webhooks.NewWorker(dbrepository.NewPostgresRepository(pool.SQL, &app), &app).Start(5 * time.Second)
+
+	// Queue the scheduled guest and owner emails in the background
+	log.Println("Starting scheduler...")
+	jobs := scheduler.NewScheduler(&app)
+	scheduler.NewGuestEmails(dbrepository.NewPostgresRepository(pool.SQL, &app), &app).Register(jobs)
+	jobs.Start(time.Minute)
+
+	// Forget the failed logins which no longer count in the background
+	handlers.Repo.LoginGuard.StartCleanup(5 * time.Minute, app.ErrorLog)
+
+	// Delete the expired sessions in the background
+	if store, ok := session.Store.(*sessionstore.DatabaseStore); ok {
+		log.Println("Starting session cleanup...")
+		store.StartCleanup(5 * time.Minute, app.ErrorLog)
+	}
 
   // Create server
 	server := &http.Server{
@@ -56,30 +105,53 @@ func main() {
 func run() (*driver.DB, error) {
 	// Register the data types that we will store in the `Session` object
 	gob.Register(models.Reservation{})
+	gob.Register(models.Booking{})
 	gob.Register(models.Room{})
 	gob.Register(models.RoomRestriction{})
 	gob.Register(models.User{})
 	gob.Register(models.Restriction{})
 	gob.Register(map[string]int{})
 
-	// Instantiate and store a channel to send email messages
-	mailChan := make(chan models.MailData)
-	app.MailChan = mailChan
-
 	// Get configuration from env variables
 	inProduction := flag.Bool("production", true, "Aplication is in production")
 	useCache := flag.Bool("cache", true, "Use template cache")
-	dbHost := flag.String("dbhost", "localhost", "Database host")
-	dbName := flag.String("dbname", "", "Database name")
-	dbUser := flag.String("dbuser", "", "Database user")
-	dbPassword := flag.String("dbpassword", "", "Database password")
-	dbPort := flag.String("dbport", "5432", "Database port")
-	dbSSL := flag.String("dbssl", "disable", "Database SSL settings (disable, prefer, require)")
+	database := addDatabaseFlags(flag.CommandLine)
+	cancellationWindow := flag.Duration("cancellationwindow", 48 * time.Hour, "How long before arrival guests can still change or cancel a reservation")
+	syncInterval := flag.Duration("syncinterval", 15 * time.Minute, "How often calendar imports are synced (0 disables syncing)")
+	mailWorkers := flag.Int("mailworkers", 2, "Number of workers sending queued emails")
+	reminderDays := flag.Int("reminderdays", 3, "Days before arrival guests are reminded of their stay (0 disables reminders)")
+	followUpDays := flag.Int("followupdays", 1, "Days after departure guests are asked about their stay (0 disables follow-ups)")
+	digestHour := flag.Int("digesthour", 7, "Hour of the day the owner digest of arrivals and departures is sent (-1 disables the digest)")
+	resetLifetime := flag.Duration("resetlifetime", time.Hour, "How long password reset links sent by email can be used")
+	twoFactorRoles := flag.String("require2fa", "", "Comma separated roles which have to use two-factor authentication, e.g. owner,front-desk")
+	loginStore := flag.String("loginstore", loginguard.StorePostgres, "Store of failed logins (postgres, memory)")
+	maxLoginFailures := flag.Int("maxloginfailures", loginguard.DefaultConfig.MaxAccountFailures, "Failed logins after which an account is locked")
+	lockout := flag.Duration("lockout", loginguard.DefaultConfig.LockoutDuration, "How long accounts and IP addresses stay locked after too many failed logins")
+	sessionStore := flag.String("sessionstore", sessionstore.StorePostgres, "Store of sessions (postgres, memory)")
+	sessionLifetime := flag.Duration("sessionlifetime", 24 * time.Hour, "How long a session lasts at most, however active it is")
+	sessionIdle := flag.Duration("sessionidle", 0, "How long a session lasts without requests (0 disables the idle timeout)")
+	baseURL := flag.String("baseurl", getEnv("BASE_URL", "http://localhost:8080"), "Address the site is reached at, used for links in emails")
+	propertyName := flag.String("propertyname", "Bed and Breakfast", "Name of the property shown in calendar invites")
+	propertyAddress := flag.String("address", "", "Address of the property shown in calendar invites")
+	checkIn := flag.String("checkin", "15:00", "Check-in time (HH:MM)")
+	checkOut := flag.String("checkout", "11:00", "Check-out time (HH:MM)")
+
+	// Mail settings can also be given as env variables, so the SMTP password doesn't have to be a flag
+	mailBackend := flag.String("mailer", getEnv("MAIL_BACKEND", mailer.BackendSMTP), "Mail backend (smtp, file, memory)")
+	mailHost := flag.String("mailhost", getEnv("MAIL_HOST", "localhost"), "SMTP host")
+	mailPort := flag.String("mailport", getEnv("MAIL_PORT", "1025"), "SMTP port")
+	mailUsername := flag.String("mailusername", getEnv("MAIL_USERNAME", ""), "SMTP username")
+	mailPassword := flag.String("mailpassword", getEnv("MAIL_PASSWORD", ""), "SMTP password")
+	mailEncryption := flag.String("mailencryption", getEnv("MAIL_ENCRYPTION", mailer.EncryptionNone), "SMTP encryption (none, starttls, tls)")
+	mailFrom := flag.String("mailfrom", getEnv("MAIL_FROM", "me@here.com"), "Sender of emails")
+	mailReplyTo := flag.String("mailreplyto", getEnv("MAIL_REPLY_TO", ""), "Reply-To address of emails")
+	ownerEmail := flag.String("owneremail", getEnv("OWNER_EMAIL", "me@here.com"), "Address the owner emails are sent to")
+	mailDir := flag.String("maildir", getEnv("MAIL_DIR", "./tmp/mail"), "Directory the file mail backend writes emails to")
 
 	flag.Parse()
 
 	// Make sure all required env variables are set
-	if *dbName == "" || *dbUser == "" || *dbPassword == "" {
+	if database.missing() {
 		fmt.Println("Missing required flags")
 		os.Exit(1)
 	}
@@ -87,13 +159,80 @@ func run() (*driver.DB, error) {
 	// Change this to true when in production
 	app.InProduction = *inProduction
 
+	// Guests can change or cancel a reservation until this long before arrival
+	app.CancellationWindow = *cancellationWindow
+
+	// Calendars of external booking sites are imported this often
+	app.CalendarSyncInterval = *syncInterval
+
+	// Emails are sent by this many workers at the same time
+	app.MailWorkers = *mailWorkers
+
+	// Timing of the scheduled emails
+	app.ReminderDaysBefore = *reminderDays
+	app.FollowUpDaysAfter = *followUpDays
+	app.OwnerDigestHour = *digestHour
+	app.OwnerEmail = *ownerEmail
+	app.BaseURL = *baseURL
+
+	// Password reset links stop working after this long
+	app.PasswordResetLifetime = *resetLifetime
+
+	// Setup the mail backend
+	port, err := strconv.Atoi(*mailPort)
+	if err != nil {
+		fmt.Println("Invalid mail port")
+		os.Exit(1)
+	}
+
+	mailSender, err = mailer.New(mailer.Config{
+		Backend: *mailBackend,
+		Host: *mailHost,
+		Port: port,
+		Username: *mailUsername,
+		Password: *mailPassword,
+		Encryption: *mailEncryption,
+		From: *mailFrom,
+		ReplyTo: *mailReplyTo,
+		Dir: *mailDir,
+	})
+	if err != nil {
+		fmt.Println("Invalid mail settings:", err)
+		os.Exit(1)
+	}
+
+	// Details of the property used in calendar invites
+	app.PropertyName = *propertyName
+	app.PropertyAddress = *propertyAddress
+	app.PropertyEmail = *mailFrom
+
+	app.CheckInTime, err = parseTimeOfDay(*checkIn)
+	if err != nil {
+		fmt.Println("Invalid check-in time")
+		os.Exit(1)
+	}
+
+	app.CheckOutTime, err = parseTimeOfDay(*checkOut)
+	if err != nil {
+		fmt.Println("Invalid check-out time")
+		os.Exit(1)
+	}
+
+	// Users of these roles can't use the admin dashboard without two-factor authentication
+	app.TwoFactorRoles, err = parseRoles(*twoFactorRoles)
+	if err != nil {
+		fmt.Println("Invalid -require2fa roles:", err)
+		os.Exit(1)
+	}
+
 	// Setup info and error loggers
 	app.InfoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	app.ErrorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
 	// Session management
 	session = scs.New()
-	session.Lifetime = 24 * time.Hour
+	session.Lifetime = *sessionLifetime
+	session.IdleTimeout = *sessionIdle
 	session.Cookie.Persist = true
 	session.Cookie.SameSite = http.SameSiteLaxMode
 	session.Cookie.Secure = app.InProduction
@@ -103,16 +242,7 @@ func run() (*driver.DB, error) {
 
 	// Connect to database
 	log.Println("Connecting to database...")
-	connectionString := fmt.Sprintf(
-		"host=%s port=%s dbname=%s user=%s password=%s sslmode=%s",
-		*dbHost, 
-		*dbPort, 
-		*dbName, 
-		*dbUser, 
-		*dbPassword,
-		*dbSSL,
-	)
-	pool, err := driver.ConnectSQL(connectionString)
+	pool, err := driver.ConnectSQL(database.connectionString())
 	if err != nil {
 		log.Fatal("Cannot connect to database")
 	}
@@ -133,8 +263,43 @@ func run() (*driver.DB, error) {
 	// Store app configuration in 'render' package
 	render.StoreAppConfig(&app)
 
+	// Get all email templates
+	err = emails.LoadTemplates("./email-templates")
+	if err != nil {
+		log.Fatal("Cannot get email templates")
+
+		return nil, err
+	}
+
+	// Store app configuration in 'emails' package
+	emails.StoreAppConfig(&app)
+
 	// Create a repository and set it in the 'handlers' package
 	repo := handlers.NewRepository(&app, pool)
+
+	// Failed logins are counted in the configured store. The memory store isn't shared by several servers
+	loginGuardStore, err := loginguard.NewStore(*loginStore, repo.DB)
+	if err != nil {
+		log.Fatal("Invalid login store: ", err)
+
+		return nil, err
+	}
+
+	loginGuardConfig := loginguard.DefaultConfig
+	loginGuardConfig.MaxAccountFailures = *maxLoginFailures
+	loginGuardConfig.LockoutDuration = *lockout
+	repo.LoginGuard = loginguard.New(loginGuardStore, loginGuardConfig)
+
+	// Sessions are kept in the configured store. The memory store loses them on restart and isn't shared by several servers
+	session.Store, err = sessionstore.New(*sessionStore, repo.DB, session.Codec)
+	if err != nil {
+		log.Fatal("Invalid session store: ", err)
+
+		return nil, err
+	}
+
+	app.SessionStore = *sessionStore
+
 	handlers.SetRepository(repo)
 
 	// Store app configuration in 'helpers' package
@@ -142,3 +307,84 @@ func run() (*driver.DB, error) {
 
 	return pool, nil
 }
+
+// Database connection settings given as flags
+type databaseFlags struct {
+	host *string
+	name *string
+	user *string
+	password *string
+	port *string
+	ssl *string
+}
+
+// Defines the database connection flags, which the server and the `user` subcommand share
+func addDatabaseFlags(flags *flag.FlagSet) databaseFlags {
+	return databaseFlags{
+		host: flags.String("dbhost", "localhost", "Database host"),
+		name: flags.String("dbname", "", "Database name"),
+		user: flags.String("dbuser", "", "Database user"),
+		password: flags.String("dbpassword", "", "Database password"),
+		port: flags.String("dbport", "5432", "Database port"),
+		ssl: flags.String("dbssl", "disable", "Database SSL settings (disable, prefer, require)"),
+	}
+}
+
+// Reports whether any of the required database flags is missing
+func (database databaseFlags) missing() bool {
+	return *database.name == "" || *database.user == "" || *database.password == ""
+}
+
+// Returns the connection string of the database
+func (database databaseFlags) connectionString() string {
+	return fmt.Sprintf(
+		"host=%s port=%s dbname=%s user=%s password=%s sslmode=%s",
+		*database.host,
+		*database.port,
+		*database.name,
+		*database.user,
+		*database.password,
+		*database.ssl,
+	)
+}
+
+// Returns the value of an env variable, or the fallback if it is not set
+func getEnv(key, fallback string) string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	return value
+}
+
+// Converts a time of day given as HH:MM into the time since midnight
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(t.Hour()) * time.Hour + time.Duration(t.Minute()) * time.Minute, nil
+}
+
+// Converts comma separated role names, as accepted by the -role flag of the `user` subcommand, into access levels
+func parseRoles(value string) ([]int, error) {
+	var accessLevels []int
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		accessLevel, ok := usercommand.Roles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown role %q", name)
+		}
+
+		accessLevels = append(accessLevels, accessLevel)
+	}
+
+	return accessLevels, nil
+}
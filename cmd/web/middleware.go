@@ -2,12 +2,14 @@ package main
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
 	"github.com/justinas/nosurf"
 )
 
-// Adds CSRF protection to all POST requests
+// Adds CSRF protection to all POST requests except the ones to the JSON API,
+// whose clients don't use cookies
 func CreateCsrfHandler(next http.Handler) http.Handler {
 	// If CSRF check is successful, `csrfHandler` calls `next`
 	csrfHandler := nosurf.New(next)
@@ -19,7 +21,14 @@ func CreateCsrfHandler(next http.Handler) http.Handler {
 		SameSite: http.SameSiteLaxMode,
 	})
 		
-	return csrfHandler
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		csrfHandler.ServeHTTP(w, r)
+	})
 }
 
 // Loads and saves the session on every request
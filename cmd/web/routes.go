@@ -5,6 +5,7 @@ import (
 
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/handlers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -22,8 +23,10 @@ func routes(app *config.AppConfig) http.Handler {
 	// Routes
 	mux.Get("/", handlers.Repo.Home)
 	mux.Get("/about", handlers.Repo.About)
-	mux.Get("/generals-quarters", handlers.Repo.Generals)
-	mux.Get("/majors-suite", handlers.Repo.Majors)
+	mux.Get("/rooms", handlers.Repo.Rooms)
+	mux.Get("/rooms/{slug}", handlers.Repo.Room)
+	mux.Get("/generals-quarters", handlers.Repo.RedirectToRoom("generals-quarters"))
+	mux.Get("/majors-suite", handlers.Repo.RedirectToRoom("majors-suite"))
 	mux.Get("/contact", handlers.Repo.Contact)
 
 	mux.Get("/search-availability", handlers.Repo.SearchAvailability)
@@ -35,23 +38,141 @@ func routes(app *config.AppConfig) http.Handler {
 	mux.Get("/make-reservation", handlers.Repo.MakeReservation)
 	mux.Post("/make-reservation", handlers.Repo.PostMakeReservation)
 	mux.Get("/reservation-summary", handlers.Repo.ReservationSummary)
+	mux.Get("/booking-summary", handlers.Repo.BookingSummary)
+
+	mux.Get("/cart", handlers.Repo.Cart)
+	mux.Post("/cart", handlers.Repo.PostAddToCart)
+	mux.Post("/cart/{index}/remove", handlers.Repo.RemoveFromCart)
+	mux.Post("/cart/checkout", handlers.Repo.PostCheckout)
+
+	mux.Get("/my-reservation", handlers.Repo.MyReservation)
+	mux.Post("/my-reservation", handlers.Repo.PostMyReservation)
+	mux.Get("/my-reservation/details", handlers.Repo.MyReservationDetails)
+	mux.Post("/my-reservation/change-dates", handlers.Repo.PostMyReservationChangeDates)
+	mux.Post("/my-reservation/cancel", handlers.Repo.PostMyReservationCancel)
+	mux.Get("/my-reservation/email-opt-out", handlers.Repo.EmailOptOut)
+	mux.Post("/my-reservation/email-opt-out", handlers.Repo.PostEmailOptOut)
+
+	mux.Get("/calendar/all.ics", handlers.Repo.AllRoomsCalendarFeed)
+	mux.Get("/calendar/rooms/{id}.ics", handlers.Repo.RoomCalendarFeed)
+
+	mux.Route("/api/v1", func(mux chi.Router) {
+		mux.NotFound(handlers.Repo.ApiNotFound)
+		mux.MethodNotAllowed(handlers.Repo.ApiMethodNotAllowed)
+
+		mux.Get("/rooms", handlers.Repo.ApiRooms)
+		mux.Get("/availability", handlers.Repo.ApiAvailability)
+		mux.Post("/reservations", handlers.Repo.ApiPostReservation)
+		mux.Get("/reservations/{code}", handlers.Repo.ApiReservation)
+
+		mux.Route("/admin", func(mux chi.Router) {
+			mux.With(handlers.Repo.RequireApiKey(handlers.ScopeReservationsRead)).Get("/reservations", handlers.Repo.AdminApiReservations)
+			mux.With(handlers.Repo.RequireApiKey(handlers.ScopeReservationsRead)).Get("/reservations/{id}", handlers.Repo.AdminApiReservation)
+			mux.With(handlers.Repo.RequireApiKey(handlers.ScopeReservationsWrite)).Post("/reservations/{id}/process", handlers.Repo.AdminApiProcessReservation)
+			mux.With(handlers.Repo.RequireApiKey(handlers.ScopeReservationsWrite)).Delete("/reservations/{id}", handlers.Repo.AdminApiDeleteReservation)
+			mux.With(handlers.Repo.RequireApiKey(handlers.ScopeCalendarWrite)).Post("/blocks", handlers.Repo.AdminApiPostBlock)
+			mux.With(handlers.Repo.RequireApiKey(handlers.ScopeCalendarWrite)).Delete("/blocks/{id}", handlers.Repo.AdminApiDeleteBlock)
+			mux.With(handlers.Repo.RequireApiKey(handlers.ScopeRoomsRead)).Get("/rooms", handlers.Repo.AdminApiRooms)
+		})
+	})
 
 	mux.Get("/auth/login", handlers.Repo.ShowLogin)
 	mux.Post("/auth/login", handlers.Repo.PostShowLogin)
 	mux.Get("/auth/logout", handlers.Repo.Logout)
+	mux.Get("/auth/forgot-password", handlers.Repo.ForgotPassword)
+	mux.Post("/auth/forgot-password", handlers.Repo.PostForgotPassword)
+	mux.Get("/auth/reset-password", handlers.Repo.ResetPassword)
+	mux.Post("/auth/reset-password", handlers.Repo.PostResetPassword)
+	mux.Get("/auth/two-factor", handlers.Repo.TwoFactor)
+	mux.Post("/auth/two-factor", handlers.Repo.PostTwoFactor)
 
 	mux.Route("/admin", func(mux chi.Router) {
 		mux.Use(Auth)
+		mux.Use(handlers.Repo.LoadUser)
 
 		mux.Get("/dashboard", handlers.Repo.AdminDashboard)
-		mux.Get("/new-reservations", handlers.Repo.AdminNewReservations)
-		mux.Get("/all-reservations", handlers.Repo.AdminAllReservations)
-		mux.Get("/reservations-calendar", handlers.Repo.AdminReservationsCalendar)
-		mux.Post("/reservations-calendar", handlers.Repo.AdminPostReservationsCalendar)
-		mux.Get("/reservations/{src}/{id}", handlers.Repo.AdminShowReservation)
-		mux.Post("/reservations/{src}/{id}", handlers.Repo.AdminPostShowReservation)
-		mux.Get("/process-reservation/{src}/{id}", handlers.Repo.AdminProcessReservation)
-		mux.Get("/delete-reservation/{src}/{id}", handlers.Repo.AdminDeleteReservation)
+		mux.Get("/choose-password", handlers.Repo.AdminChoosePassword)
+		mux.Post("/choose-password", handlers.Repo.AdminPostChoosePassword)
+		mux.Get("/change-password", handlers.Repo.AdminChangePassword)
+		mux.Post("/change-password", handlers.Repo.AdminPostChangePassword)
+		mux.Get("/two-factor", handlers.Repo.AdminTwoFactor)
+		mux.Post("/two-factor", handlers.Repo.AdminPostTwoFactor)
+		mux.Post("/two-factor/recovery-codes", handlers.Repo.AdminPostRecoveryCodes)
+		mux.Post("/two-factor/disable", handlers.Repo.AdminPostDisableTwoFactor)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionViewReservations)).Get("/new-reservations", handlers.Repo.AdminNewReservations)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionViewReservations)).Get("/all-reservations", handlers.Repo.AdminAllReservations)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionViewReservations)).Get("/bookings", handlers.Repo.AdminBookings)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionViewReservations)).Get("/bookings/{id}", handlers.Repo.AdminShowBooking)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionViewReservations)).Get("/reservations-calendar", handlers.Repo.AdminReservationsCalendar)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionEditReservations)).Post("/reservations-calendar", handlers.Repo.AdminPostReservationsCalendar)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionEditReservations)).Get("/blocks", handlers.Repo.AdminBlocks)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionEditReservations)).Get("/blocks/new", handlers.Repo.AdminNewBlock)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionEditReservations)).Post("/blocks/new", handlers.Repo.AdminPostNewBlock)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionEditReservations)).Get("/blocks/{id}", handlers.Repo.AdminShowBlock)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionEditReservations)).Post("/blocks/{id}", handlers.Repo.AdminPostShowBlock)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionEditReservations)).Get("/blocks/{id}/delete", handlers.Repo.AdminDeleteBlock)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionViewReservations)).Get("/reservations/{src}/{id}", handlers.Repo.AdminShowReservation)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionEditReservations)).Post("/reservations/{src}/{id}", handlers.Repo.AdminPostShowReservation)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionEditReservations)).Get("/process-reservation/{src}/{id}", handlers.Repo.AdminProcessReservation)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionDeleteReservations)).Get("/delete-reservation/{src}/{id}", handlers.Repo.AdminDeleteReservation)
+
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageRooms)).Get("/rooms", handlers.Repo.AdminRooms)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageRooms)).Get("/rooms/new", handlers.Repo.AdminNewRoom)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageRooms)).Post("/rooms/new", handlers.Repo.AdminPostNewRoom)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageRooms)).Post("/rooms/order", handlers.Repo.AdminPostRoomsOrder)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageRooms)).Get("/rooms/{id}", handlers.Repo.AdminShowRoom)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageRooms)).Post("/rooms/{id}", handlers.Repo.AdminPostShowRoom)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageRooms)).Get("/rooms/{id}/activate", handlers.Repo.AdminActivateRoom)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageRooms)).Get("/rooms/{id}/deactivate", handlers.Repo.AdminDeactivateRoom)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageRooms)).Get("/stay-rules", handlers.Repo.AdminStayRules)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageRooms)).Get("/stay-rules/new", handlers.Repo.AdminNewStayRule)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageRooms)).Post("/stay-rules/new", handlers.Repo.AdminPostNewStayRule)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageRooms)).Get("/stay-rules/{id}", handlers.Repo.AdminShowStayRule)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageRooms)).Post("/stay-rules/{id}", handlers.Repo.AdminPostShowStayRule)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageRooms)).Get("/stay-rules/{id}/delete", handlers.Repo.AdminDeleteStayRule)
+
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/calendar-feeds", handlers.Repo.AdminCalendarFeeds)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Post("/calendar-feeds", handlers.Repo.AdminPostCalendarFeeds)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/calendar-feeds/{id}/revoke", handlers.Repo.AdminRevokeCalendarFeed)
+
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/calendar-imports", handlers.Repo.AdminCalendarImports)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Post("/calendar-imports", handlers.Repo.AdminPostCalendarImports)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/calendar-imports/{id}/sync", handlers.Repo.AdminSyncCalendarImport)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/calendar-imports/{id}/delete", handlers.Repo.AdminDeleteCalendarImport)
+
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/api-keys", handlers.Repo.AdminApiKeys)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Post("/api-keys", handlers.Repo.AdminPostApiKeys)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Post("/api-keys/{id}/revoke", handlers.Repo.AdminRevokeApiKey)
+
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/webhooks", handlers.Repo.AdminWebhooks)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Post("/webhooks", handlers.Repo.AdminPostWebhooks)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/webhooks/{id}", handlers.Repo.AdminWebhook)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/webhooks/{id}/activate", handlers.Repo.AdminActivateWebhook)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/webhooks/{id}/deactivate", handlers.Repo.AdminDeactivateWebhook)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/webhooks/{id}/delete", handlers.Repo.AdminDeleteWebhook)
+
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageEmails)).Get("/emails", handlers.Repo.AdminEmails)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageEmails)).Get("/emails/{id}", handlers.Repo.AdminEmail)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageEmails)).Get("/emails/{id}/resend", handlers.Repo.AdminResendEmail)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageEmails)).Get("/emails/{id}/cancel", handlers.Repo.AdminCancelEmail)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageEmails)).Get("/email-templates", handlers.Repo.AdminEmailTemplates)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageEmails)).Get("/email-templates/{name}", handlers.Repo.AdminEmailTemplate)
+
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageUsers)).Get("/users", handlers.Repo.AdminUsers)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageUsers)).Get("/users/new", handlers.Repo.AdminNewUser)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/new", handlers.Repo.AdminPostNewUser)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageUsers)).Get("/users/{id}", handlers.Repo.AdminShowUser)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/{id}", handlers.Repo.AdminPostShowUser)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/{id}/enable", handlers.Repo.AdminEnableUser)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/{id}/disable", handlers.Repo.AdminDisableUser)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/{id}/reset-password", handlers.Repo.AdminResetUserPassword)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/{id}/reset-two-factor", handlers.Repo.AdminResetUserTwoFactor)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/{id}/delete", handlers.Repo.AdminDeleteUser)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageUsers)).Get("/users/{id}/sessions", handlers.Repo.AdminUserSessions)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/{id}/sessions/revoke", handlers.Repo.AdminPostRevokeUserSession)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageUsers)).Get("/login-security", handlers.Repo.AdminLoginSecurity)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionManageUsers)).Post("/login-security/unlock", handlers.Repo.AdminPostUnlockLogin)
+		mux.With(handlers.Repo.RequirePermission(models.PermissionViewAuditLog)).Get("/audit-log", handlers.Repo.AdminAuditLog)
 	})
 
 	// Serve static files
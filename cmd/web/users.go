@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/driver"
+	dbrepository "github.com/LuisBarroso37/bed-and-breakfast/internal/repository/db-repository"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/usercommand"
+)
+
+// Runs the `user` subcommand, which manages admin users without touching SQL,
+// e.g. to create the first owner of a new installation
+func runUserCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usercommand.Usage)
+	}
+
+	flags := flag.NewFlagSet("user "+args[0], flag.ContinueOnError)
+	database := addDatabaseFlags(flags)
+
+	var options usercommand.Options
+	flags.StringVar(&options.Email, "email", "", "Email of the user")
+	flags.StringVar(&options.FirstName, "first", "", "First name of the user (create)")
+	flags.StringVar(&options.LastName, "last", "", "Last name of the user (create)")
+	flags.StringVar(&options.Role, "role", "owner", "Role of the user: owner, front-desk or housekeeping (create)")
+
+	err := flags.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+
+	if database.missing() {
+		return errors.New("missing required database flags")
+	}
+
+	pool, err := driver.ConnectSQL(database.connectionString())
+	if err != nil {
+		return fmt.Errorf("cannot connect to database: %w", err)
+	}
+
+	defer pool.SQL.Close()
+
+	app.InfoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+	app.ErrorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+
+	return usercommand.NewRunner(dbrepository.NewPostgresRepository(pool.SQL, &app), &app).Run(args[0], options, stdin, stdout)
+}
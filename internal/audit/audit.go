@@ -0,0 +1,156 @@
+// Package audit keeps a log of who changed reservations, blocks, stay rules, rooms, users, login locks,
+// integrations and emails, when, and what the old and new values were. Changes made by guests, background jobs
+// or the command line have no user
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+)
+
+// Entities whose changes are recorded
+const (
+	EntityReservation = "reservation"
+	EntityBlock = "block"
+	EntityStayRule = "stay_rule"
+	EntityRoom = "room"
+	EntityUser = "user"
+	// Locks of IP addresses after failed logins. Locks of accounts are recorded under their user
+	EntityLogin = "login"
+	EntityApiKey = "api_key"
+	EntityWebhook = "webhook"
+	EntityCalendarFeed = "calendar_feed"
+	EntityCalendarImport = "calendar_import"
+	EntityEmail = "email"
+)
+
+// All entities, in the order they are offered when filtering the log
+var Entities = []string{
+	EntityReservation,
+	EntityBlock,
+	EntityStayRule,
+	EntityRoom,
+	EntityUser,
+	EntityLogin,
+	EntityApiKey,
+	EntityWebhook,
+	EntityCalendarFeed,
+	EntityCalendarImport,
+	EntityEmail,
+}
+
+// Changes which are recorded
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionDeleted = "deleted"
+	ActionProcessed = "processed"
+	ActionCancelled = "cancelled"
+	ActionEnabled = "enabled"
+	ActionDisabled = "disabled"
+	ActionPasswordReset = "password_reset"
+	ActionPasswordChanged = "password_changed"
+	ActionTwoFactorEnabled = "two_factor_enabled"
+	ActionTwoFactorDisabled = "two_factor_disabled"
+	ActionRecoveryCodesReplaced = "recovery_codes_replaced"
+	ActionTwoFactorReset = "two_factor_reset"
+	ActionSessionRevoked = "session_revoked"
+	ActionUnlocked = "unlocked"
+	ActionRevoked = "revoked"
+	ActionResent = "resent"
+)
+
+// Records a change of an entity. `before` is nil for created entities and `after` is nil for deleted ones.
+// Both are compared as JSON, so only the fields which changed are stored. Updates which changed nothing aren't recorded
+func Record(db repository.DatabaseRepository, entry models.AuditEntry, before, after interface{}) error {
+	changes, err := Diff(before, after)
+	if err != nil {
+		return err
+	}
+
+	if entry.Action == ActionUpdated && len(changes) == 0 {
+		return nil
+	}
+
+	entry.Changes = changes
+
+	return db.InsertAuditEntry(entry)
+}
+
+// Compares two values by the fields of their JSON encoding. Returns the fields which differ, sorted by name
+func Diff(before, after interface{}) ([]models.AuditChange, error) {
+	beforeFields, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for name := range beforeFields {
+		names[name] = true
+	}
+
+	for name := range afterFields {
+		names[name] = true
+	}
+
+	changes := []models.AuditChange{}
+	for name := range names {
+		if reflect.DeepEqual(beforeFields[name], afterFields[name]) {
+			continue
+		}
+
+		changes = append(changes, models.AuditChange{
+			Field: name,
+			Before: formatValue(beforeFields[name]),
+			After: formatValue(afterFields[name]),
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes, nil
+}
+
+// Returns the fields of the JSON encoding of a value, which must encode as an object or null
+func jsonFields(value interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if value == nil {
+		return fields, nil
+	}
+
+	out, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(out, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// Formats a decoded JSON value for the log. Strings are shown without quotes and missing values as empty strings
+func formatValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		out, _ := json.Marshal(value)
+		return string(out)
+	}
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+type testEntity struct {
+	Name string `json:"name"`
+	Count int `json:"count"`
+	Active bool `json:"active"`
+}
+
+var diffTests = []struct {
+	name     string
+	before   interface{}
+	after    interface{}
+	expected []models.AuditChange
+}{
+	{
+		"Updated",
+		testEntity{Name: "Jane", Count: 1, Active: true},
+		testEntity{Name: "John", Count: 1, Active: false},
+		[]models.AuditChange{
+			{Field: "active", Before: "true", After: "false"},
+			{Field: "name", Before: "Jane", After: "John"},
+		},
+	},
+	{
+		"Created",
+		nil,
+		testEntity{Name: "Jane", Count: 2},
+		[]models.AuditChange{
+			{Field: "active", Before: "", After: "false"},
+			{Field: "count", Before: "", After: "2"},
+			{Field: "name", Before: "", After: "Jane"},
+		},
+	},
+	{
+		"Deleted",
+		map[string]int{"count": 3},
+		nil,
+		[]models.AuditChange{
+			{Field: "count", Before: "3", After: ""},
+		},
+	},
+	{
+		"Unchanged",
+		testEntity{Name: "Jane"},
+		testEntity{Name: "Jane"},
+		[]models.AuditChange{},
+	},
+}
+
+func TestDiff(t *testing.T) {
+	for _, test := range diffTests {
+		changes, err := Diff(test.before, test.after)
+		if err != nil {
+			t.Errorf("Test %s returned error: %s", test.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(changes, test.expected) {
+			t.Errorf("Test %s returned wrong changes: got %+v, wanted %+v", test.name, changes, test.expected)
+		}
+	}
+}
+
+func TestDiff_NotAnObject(t *testing.T) {
+	_, err := Diff("text", nil)
+	if err == nil {
+		t.Error("Values which don't encode as JSON objects should return an error")
+	}
+}
@@ -0,0 +1,216 @@
+package channelsync
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/ical"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+)
+
+// Imports the calendars of external booking sites as "External booking" room restrictions
+type Syncer struct {
+	DB repository.DatabaseRepository
+	App *config.AppConfig
+	Client *http.Client
+}
+
+// Number of external bookings changed by a sync
+type Result struct {
+	Added int
+	Moved int
+	Deleted int
+	Conflicts int
+}
+
+// Summary stored as the status of an import source
+func (result Result) String() string {
+	return fmt.Sprintf("%d added, %d moved, %d deleted, %d conflicts", result.Added, result.Moved, result.Deleted, result.Conflicts)
+}
+
+// Creates a new syncer
+func NewSyncer(db repository.DatabaseRepository, app *config.AppConfig) *Syncer {
+	return &Syncer{
+		DB: db,
+		App: app,
+		Client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Syncs all import sources right away and then once every interval.
+// Runs in the background; an interval of 0 disables syncing
+func (syncer *Syncer) Start(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			syncer.SyncAll()
+			<-ticker.C
+		}
+	}()
+}
+
+// Syncs all import sources. Failures are logged and don't stop the other sources from syncing
+func (syncer *Syncer) SyncAll() {
+	sources, err := syncer.DB.GetAllCalendarImportSources()
+	if err != nil {
+		syncer.App.ErrorLog.Println("Can't get calendar import sources:", err)
+		return
+	}
+
+	for _, source := range sources {
+		syncer.SyncSource(source)
+	}
+}
+
+// Fetches the calendar of an import source and adds, moves and deletes its external bookings to match.
+// Bookings which overlap a reservation or another block of the room are logged as conflicts and skipped
+func (syncer *Syncer) SyncSource(source models.CalendarImportSource) (Result, error) {
+	var result Result
+
+	calendar, err := syncer.fetch(source.URL)
+	if err != nil {
+		return result, syncer.fail(source, err)
+	}
+
+	existing, err := syncer.DB.GetRestrictionsForSource(source.ID)
+	if err != nil {
+		return result, syncer.fail(source, err)
+	}
+
+	changes := Diff(source, existing, calendar.Events)
+
+	// Delete first, so that the freed dates can be used by moved and added bookings
+	for _, restriction := range changes.Delete {
+		err := syncer.DB.DeleteBlockByID(restriction.ID)
+		if err != nil {
+			return result, syncer.fail(source, err)
+		}
+
+		result.Deleted++
+	}
+
+	for _, restriction := range changes.Move {
+		err := syncer.DB.UpdateExternalBlockDates(restriction.ID, restriction.StartDate, restriction.EndDate)
+		if errors.Is(err, repository.ErrRoomNotAvailable) {
+			syncer.logConflict(source, restriction)
+			result.Conflicts++
+			continue
+		}
+
+		if err != nil {
+			return result, syncer.fail(source, err)
+		}
+
+		result.Moved++
+	}
+
+	for _, restriction := range changes.Add {
+		err := syncer.DB.InsertExternalBlock(restriction)
+		if errors.Is(err, repository.ErrRoomNotAvailable) {
+			syncer.logConflict(source, restriction)
+			result.Conflicts++
+			continue
+		}
+
+		if err != nil {
+			return result, syncer.fail(source, err)
+		}
+
+		result.Added++
+	}
+
+	syncer.updateStatus(source, result.String())
+
+	return result, nil
+}
+
+// Reads a calendar over HTTP or, for URLs starting with file://, from a local file
+func (syncer *Syncer) fetch(url string) (ical.Calendar, error) {
+	if strings.HasPrefix(url, "file://") {
+		file, err := os.Open(strings.TrimPrefix(url, "file://"))
+		if err != nil {
+			return ical.Calendar{}, err
+		}
+
+		defer file.Close()
+
+		return ical.Decode(file)
+	}
+
+	response, err := syncer.Client.Get(url)
+	if err != nil {
+		return ical.Calendar{}, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		// Drain the body so the connection can be reused
+		io.Copy(io.Discard, response.Body)
+		return ical.Calendar{}, fmt.Errorf("calendar responded with status %d", response.StatusCode)
+	}
+
+	return ical.Decode(response.Body)
+}
+
+// Logs a failed sync and stores it as the status of the import source
+func (syncer *Syncer) fail(source models.CalendarImportSource, err error) error {
+	syncer.App.ErrorLog.Printf("Can't sync calendar import source %q: %s", source.Name, err)
+	syncer.updateStatus(source, fmt.Sprintf("Failed: %s", err))
+
+	return err
+}
+
+// Logs an external booking which overlaps a reservation or another block of the room
+func (syncer *Syncer) logConflict(source models.CalendarImportSource, restriction models.RoomRestriction) {
+	conflicts := "another block"
+
+	// The restrictions overlapping a stay are the ones starting before the day of departure
+	overlapping, err := syncer.DB.GetRestrictionsForRoomByDate(restriction.RoomID, restriction.StartDate, restriction.EndDate.AddDate(0, 0, -1))
+	if err == nil {
+		var reservations []string
+
+		for _, other := range overlapping {
+			if other.ReservationID > 0 {
+				reservations = append(reservations, fmt.Sprintf("%d", other.ReservationID))
+			}
+		}
+
+		if len(reservations) > 0 {
+			conflicts = fmt.Sprintf("reservation %s", strings.Join(reservations, ", "))
+		}
+	}
+
+	syncer.App.ErrorLog.Printf(
+		"Calendar import source %q: external booking %s from %s to %s of room %d conflicts with %s",
+		source.Name,
+		restriction.ExternalUID,
+		restriction.StartDate.Format("2006-01-02"),
+		restriction.EndDate.Format("2006-01-02"),
+		restriction.RoomID,
+		conflicts,
+	)
+}
+
+// Stores the time and the outcome of the last sync of an import source
+func (syncer *Syncer) updateStatus(source models.CalendarImportSource, status string) {
+	err := syncer.DB.UpdateCalendarImportSourceStatus(source.ID, time.Now(), status)
+	if err != nil {
+		syncer.App.ErrorLog.Printf("Can't update status of calendar import source %q: %s", source.Name, err)
+	}
+}
@@ -0,0 +1,94 @@
+package channelsync
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+	dbrepository "github.com/LuisBarroso37/bed-and-breakfast/internal/repository/db-repository"
+)
+
+func newTestSyncer() *Syncer {
+	app := &config.AppConfig{
+		InfoLog: log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime),
+		ErrorLog: log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile),
+	}
+
+	return NewSyncer(dbrepository.NewTestRepository(app), app)
+}
+
+func TestSyncSource(t *testing.T) {
+	syncer := newTestSyncer()
+
+	source, err := syncer.DB.GetCalendarImportSourceByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := syncer.SyncSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The test calendar keeps one booking, moves one, adds one, adds one which conflicts
+	// with a reservation and drops one, while its cancelled event is ignored
+	expected := Result{Added: 1, Moved: 1, Deleted: 1, Conflicts: 1}
+	if result != expected {
+		t.Errorf("Wrong sync result: got %s, wanted %s", result, expected)
+	}
+}
+
+func TestSyncSource_MissingFile(t *testing.T) {
+	syncer := newTestSyncer()
+
+	source, err := syncer.DB.GetCalendarImportSourceByID(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = syncer.SyncSource(source)
+	if err == nil {
+		t.Error("Expected an error for a calendar file which does not exist")
+	}
+}
+
+func TestSyncSource_HTTP(t *testing.T) {
+	feed, err := os.ReadFile("testdata/external.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/listing.ics" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/calendar")
+		w.Write(feed)
+	}))
+	defer server.Close()
+
+	syncer := newTestSyncer()
+
+	source, _ := syncer.DB.GetCalendarImportSourceByID(1)
+
+	source.URL = server.URL + "/listing.ics"
+	result, err := syncer.SyncSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Added != 1 || result.Moved != 1 || result.Deleted != 1 {
+		t.Errorf("Wrong sync result: got %s", result)
+	}
+
+	source.URL = server.URL + "/missing.ics"
+	_, err = syncer.SyncSource(source)
+	if err == nil {
+		t.Error("Expected an error when the calendar responds with 404")
+	}
+}
@@ -0,0 +1,68 @@
+package channelsync
+
+import (
+	"strings"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/ical"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+// Changes needed to bring the external bookings of an import source in line with its calendar
+type Changes struct {
+	// External bookings to insert
+	Add []models.RoomRestriction
+	// Existing external bookings with new dates
+	Move []models.RoomRestriction
+	// Existing external bookings which are no longer in the calendar
+	Delete []models.RoomRestriction
+}
+
+// Compares the external bookings already imported from a source with the events of its calendar.
+// Events are matched by UID. Cancelled events, events without a UID and events which don't last
+// at least a day are ignored, so the bookings they match are deleted
+func Diff(source models.CalendarImportSource, existing []models.RoomRestriction, events []ical.Event) Changes {
+	var changes Changes
+
+	existingByUID := make(map[string]models.RoomRestriction)
+	for _, restriction := range existing {
+		existingByUID[restriction.ExternalUID] = restriction
+	}
+
+	seen := make(map[string]bool)
+
+	for _, event := range events {
+		if event.UID == "" || seen[event.UID] || strings.EqualFold(event.Status, "CANCELLED") || !event.End.After(event.Start) {
+			continue
+		}
+
+		// Recurring events repeat their UID; only the first occurrence is imported
+		seen[event.UID] = true
+
+		restriction, ok := existingByUID[event.UID]
+		if !ok {
+			changes.Add = append(changes.Add, models.RoomRestriction{
+				StartDate: event.Start,
+				EndDate: event.End,
+				RoomID: source.RoomID,
+				RestrictionID: models.RestrictionExternalBooking,
+				SourceID: source.ID,
+				ExternalUID: event.UID,
+			})
+			continue
+		}
+
+		if !restriction.StartDate.Equal(event.Start) || !restriction.EndDate.Equal(event.End) {
+			restriction.StartDate = event.Start
+			restriction.EndDate = event.End
+			changes.Move = append(changes.Move, restriction)
+		}
+	}
+
+	for _, restriction := range existing {
+		if !seen[restriction.ExternalUID] {
+			changes.Delete = append(changes.Delete, restriction)
+		}
+	}
+
+	return changes
+}
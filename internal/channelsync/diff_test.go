@@ -0,0 +1,75 @@
+package channelsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/ical"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+func day(month time.Month, dayOfMonth int) time.Time {
+	return time.Date(2050, month, dayOfMonth, 0, 0, 0, 0, time.UTC)
+}
+
+func TestDiff(t *testing.T) {
+	source := models.CalendarImportSource{ID: 7, RoomID: 2}
+
+	existing := []models.RoomRestriction{
+		{ID: 1, StartDate: day(time.January, 3), EndDate: day(time.January, 5), ExternalUID: "unchanged"},
+		{ID: 2, StartDate: day(time.February, 1), EndDate: day(time.February, 3), ExternalUID: "moved"},
+		{ID: 3, StartDate: day(time.March, 1), EndDate: day(time.March, 3), ExternalUID: "removed"},
+		{ID: 4, StartDate: day(time.April, 1), EndDate: day(time.April, 3), ExternalUID: "cancelled"},
+	}
+
+	events := []ical.Event{
+		{UID: "unchanged", Start: day(time.January, 3), End: day(time.January, 5)},
+		{UID: "moved", Start: day(time.February, 2), End: day(time.February, 6)},
+		{UID: "added", Start: day(time.May, 1), End: day(time.May, 2)},
+		{UID: "cancelled", Start: day(time.April, 1), End: day(time.April, 3), Status: "CANCELLED"},
+		// Ignored events
+		{UID: "added", Start: day(time.June, 1), End: day(time.June, 2)},
+		{UID: "", Start: day(time.July, 1), End: day(time.July, 2)},
+		{UID: "empty", Start: day(time.August, 1), End: day(time.August, 1)},
+	}
+
+	changes := Diff(source, existing, events)
+
+	if len(changes.Add) != 1 {
+		t.Fatalf("Wrong number of added bookings: got %d, wanted 1", len(changes.Add))
+	}
+
+	added := changes.Add[0]
+	if added.ExternalUID != "added" || !added.StartDate.Equal(day(time.May, 1)) || !added.EndDate.Equal(day(time.May, 2)) {
+		t.Errorf("Wrong added booking: %+v", added)
+	}
+
+	if added.RoomID != 2 || added.SourceID != 7 || added.RestrictionID != models.RestrictionExternalBooking {
+		t.Errorf("Added booking does not belong to the source: %+v", added)
+	}
+
+	if len(changes.Move) != 1 {
+		t.Fatalf("Wrong number of moved bookings: got %d, wanted 1", len(changes.Move))
+	}
+
+	moved := changes.Move[0]
+	if moved.ID != 2 || !moved.StartDate.Equal(day(time.February, 2)) || !moved.EndDate.Equal(day(time.February, 6)) {
+		t.Errorf("Wrong moved booking: %+v", moved)
+	}
+
+	if len(changes.Delete) != 2 || changes.Delete[0].ID != 3 || changes.Delete[1].ID != 4 {
+		t.Errorf("Wrong deleted bookings: %+v", changes.Delete)
+	}
+}
+
+func TestDiff_EmptyCalendar(t *testing.T) {
+	existing := []models.RoomRestriction{
+		{ID: 1, StartDate: day(time.January, 3), EndDate: day(time.January, 5), ExternalUID: "booking"},
+	}
+
+	changes := Diff(models.CalendarImportSource{}, existing, nil)
+
+	if len(changes.Add) != 0 || len(changes.Move) != 0 || len(changes.Delete) != 1 {
+		t.Errorf("Every booking should be deleted: %+v", changes)
+	}
+}
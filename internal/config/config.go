@@ -3,8 +3,8 @@ package config
 import (
 	"html/template"
 	"log"
+	"time"
 
-	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
 	"github.com/alexedwards/scs/v2"
 )
 
@@ -16,5 +16,33 @@ type AppConfig struct {
 	InfoLog 			*log.Logger
 	ErrorLog 			*log.Logger
 	Session 			*scs.SessionManager
-	MailChan 			chan models.MailData
+	// Store the sessions are kept in, one of the `sessionstore` stores
+	SessionStore	string
+	// Number of workers sending the emails of the outbox
+	MailWorkers	int
+	// Guests can change or cancel a reservation until this long before arrival
+	CancellationWindow	time.Duration
+	// Calendar imports are synced this often
+	CalendarSyncInterval	time.Duration
+	// Guests are reminded of their stay this many days before arrival (0 disables the reminders)
+	ReminderDaysBefore	int
+	// Guests are asked about their stay this many days after departure (0 disables the follow-ups)
+	FollowUpDaysAfter	int
+	// The owner digest of the day's arrivals and departures is sent from this hour on (-1 disables the digest)
+	OwnerDigestHour	int
+	// Password reset links sent by email stop working after this long
+	PasswordResetLifetime	time.Duration
+	// Users of these roles have to set up two-factor authentication before using the admin dashboard
+	TwoFactorRoles	[]int
+	// Address the site is reached at, used for links in emails which aren't sent from a request
+	BaseURL	string
+	// Name, address and contact email of the property, used in calendar invites
+	PropertyName	string
+	PropertyAddress	string
+	PropertyEmail	string
+	// Address the owner notifications and the daily digest are sent to
+	OwnerEmail	string
+	// Check-in and check-out times, as time since midnight in the local time zone
+	CheckInTime	time.Duration
+	CheckOutTime	time.Duration
 }
\ No newline at end of file
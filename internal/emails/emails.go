@@ -0,0 +1,320 @@
+package emails
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/pricing"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+)
+
+// Names of the email templates. Every name has an HTML template `<name>.html.tmpl` and a
+// plain-text template `<name>.txt.tmpl`, which also defines the subject of the email
+const (
+	ConfirmationGuest = "confirmation-guest"
+	ConfirmationOwner = "confirmation-owner"
+	BookingConfirmationGuest = "booking-confirmation-guest"
+	BookingConfirmationOwner = "booking-confirmation-owner"
+	ChangeGuest = "change-guest"
+	ChangeOwner = "change-owner"
+	Cancellation = "cancellation"
+	CancellationOwner = "cancellation-owner"
+	Reminder = "reminder"
+	FollowUp = "follow-up"
+	OwnerDigest = "owner-digest"
+	Invitation = "invitation"
+	PasswordReset = "password-reset"
+	AccountLocked = "account-locked"
+)
+
+// Data of the confirmation emails
+type ConfirmationData struct {
+	Reservation models.Reservation
+	Quote models.Quote
+}
+
+// Data of the confirmation emails of a booking of several rooms.
+// The price of each room is the total price of its reservation
+type BookingConfirmationData struct {
+	Booking models.Booking
+}
+
+// Data of the emails sent when a reservation is moved to new dates
+type ChangeData struct {
+	Reservation models.Reservation
+	PreviousStartDate time.Time
+	PreviousEndDate time.Time
+}
+
+// Data of the emails sent when a reservation is cancelled
+type CancellationData struct {
+	Reservation models.Reservation
+}
+
+// Data of the email reminding a guest of an upcoming stay
+type ReminderData struct {
+	Reservation models.Reservation
+	// Link the guest follows to stop receiving scheduled emails
+	OptOutURL string
+}
+
+// Data of the email asking a guest how the stay was
+type FollowUpData struct {
+	Reservation models.Reservation
+	// Link the guest follows to stop receiving scheduled emails
+	OptOutURL string
+}
+
+// Data of the daily email telling the owner who arrives and departs
+type OwnerDigestData struct {
+	Date time.Time
+	Arrivals []models.Reservation
+	Departures []models.Reservation
+}
+
+// Data of the email telling a new staff member about their account
+type InvitationData struct {
+	User models.User
+	// Name of the user who created the account
+	InvitedBy string
+	LoginURL string
+}
+
+// Data of the email with a link to choose a new password
+type PasswordResetData struct {
+	User models.User
+	ResetURL string
+	// How long the link can be used, e.g. "1 hour"
+	ValidFor string
+}
+
+// Data of the email telling users their account has been locked after too many failed logins
+type AccountLockedData struct {
+	User models.User
+	// How long the account stays locked, e.g. "15 minutes"
+	LockedFor string
+	// IP address the last failed login came from
+	IPAddress string
+	// Link to choose a new password, in case someone else knows the current one
+	ResetURL string
+}
+
+// Rendered email
+type Message struct {
+	Subject string
+	HTML string
+	Text string
+}
+
+// HTML and plain-text templates of an email
+type Template struct {
+	HTML *htmltemplate.Template
+	Text *template.Template
+}
+
+// Custom functions passed to the email templates
+var functions = map[string]interface{}{
+	"formatDate": render.FormatDate,
+	"convertDateToFormat": render.ConvertDateToFormat,
+	"formatAmount": pricing.FormatAmount,
+}
+
+var app *config.AppConfig
+var pathToTemplates = "./email-templates"
+
+var (
+	mutex sync.RWMutex
+	templateCache map[string]Template
+)
+
+// Store app configuration
+func StoreAppConfig(appConfig *config.AppConfig) {
+	app = appConfig
+}
+
+// Parses the email templates of the given directory and caches them
+func LoadTemplates(dir string) error {
+	templates, err := parseTemplates(dir)
+	if err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	pathToTemplates = dir
+	templateCache = templates
+
+	return nil
+}
+
+// Returns the names of all email templates, sorted alphabetically
+func Names() ([]string, error) {
+	templates, err := getTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range templates {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Renders the subject, HTML and plain-text body of the email template with the given name
+func Render(name string, data interface{}) (Message, error) {
+	var message Message
+
+	templates, err := getTemplates()
+	if err != nil {
+		return message, err
+	}
+
+	tmpl, ok := templates[name]
+	if !ok {
+		return message, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var buffer bytes.Buffer
+
+	err = tmpl.Text.ExecuteTemplate(&buffer, "subject", data)
+	if err != nil {
+		return message, err
+	}
+	message.Subject = strings.TrimSpace(buffer.String())
+
+	buffer.Reset()
+	err = tmpl.Text.Execute(&buffer, data)
+	if err != nil {
+		return message, err
+	}
+	message.Text = strings.TrimSpace(buffer.String()) + "\n"
+
+	buffer.Reset()
+	err = tmpl.HTML.Execute(&buffer, data)
+	if err != nil {
+		return message, err
+	}
+	message.HTML = strings.TrimSpace(buffer.String())
+
+	return message, nil
+}
+
+// Renders the email template with the given name into an email to the given address
+func NewMail(name string, to string, data interface{}) (models.MailData, error) {
+	message, err := Render(name, data)
+	if err != nil {
+		return models.MailData{}, err
+	}
+
+	return models.MailData{
+		To: to,
+		Subject: message.Subject,
+		Content: message.HTML,
+		TextContent: message.Text,
+		Template: name,
+	}, nil
+}
+
+// Returns the cached templates, or parses them again if the cache is disabled
+// so that changes to the templates show up in development mode
+func getTemplates() (map[string]Template, error) {
+	mutex.RLock()
+	templates, dir := templateCache, pathToTemplates
+	mutex.RUnlock()
+
+	if templates != nil && (app == nil || app.UseCache) {
+		return templates, nil
+	}
+
+	return parseTemplates(dir)
+}
+
+// Parses every email template of a directory together with the layout and partial files of the same type
+func parseTemplates(dir string) (map[string]Template, error) {
+	templates := map[string]Template{}
+
+	pages, err := filepath.Glob(filepath.Join(dir, "*.html.tmpl"))
+	if err != nil {
+		return templates, err
+	}
+
+	for _, page := range pages {
+		name := strings.TrimSuffix(filepath.Base(page), ".html.tmpl")
+
+		// Layout and partial files are only parsed together with the templates
+		if strings.HasSuffix(name, ".layout") || strings.HasSuffix(name, ".partial") {
+			continue
+		}
+
+		htmlTemplate, err := htmltemplate.New(filepath.Base(page)).Funcs(functions).ParseFiles(page)
+		if err != nil {
+			return templates, err
+		}
+
+		htmlTemplate, err = htmlTemplate.ParseGlob(filepath.Join(dir, "*.layout.html.tmpl"))
+		if err != nil {
+			return templates, err
+		}
+
+		partials, err := filepath.Glob(filepath.Join(dir, "*.partial.html.tmpl"))
+		if err != nil {
+			return templates, err
+		}
+
+		if len(partials) > 0 {
+			htmlTemplate, err = htmlTemplate.ParseFiles(partials...)
+			if err != nil {
+				return templates, err
+			}
+		}
+
+		textPage := filepath.Join(dir, name + ".txt.tmpl")
+
+		textTemplate, err := template.New(filepath.Base(textPage)).Funcs(functions).ParseFiles(textPage)
+		if err != nil {
+			return templates, err
+		}
+
+		textTemplate, err = textTemplate.ParseGlob(filepath.Join(dir, "*.layout.txt.tmpl"))
+		if err != nil {
+			return templates, err
+		}
+
+		partials, err = filepath.Glob(filepath.Join(dir, "*.partial.txt.tmpl"))
+		if err != nil {
+			return templates, err
+		}
+
+		if len(partials) > 0 {
+			textTemplate, err = textTemplate.ParseFiles(partials...)
+			if err != nil {
+				return templates, err
+			}
+		}
+
+		if textTemplate.Lookup("subject") == nil {
+			return templates, fmt.Errorf("email template %s has no subject", textPage)
+		}
+
+		templates[name] = Template{
+			HTML: htmlTemplate,
+			Text: textTemplate,
+		}
+	}
+
+	return templates, nil
+}
@@ -0,0 +1,206 @@
+package emails
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+var testApp config.AppConfig
+
+func TestMain(m *testing.M) {
+	testApp.UseCache = true
+	StoreAppConfig(&testApp)
+
+	err := LoadTemplates("../../email-templates")
+	if err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestNames(t *testing.T) {
+	names, err := Names()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{AccountLocked, BookingConfirmationGuest, BookingConfirmationOwner, Cancellation, CancellationOwner, ChangeGuest, ChangeOwner, ConfirmationGuest, ConfirmationOwner, FollowUp, Invitation, OwnerDigest, PasswordReset, Reminder}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("Wrong template names: got %v, wanted %v", names, expected)
+	}
+}
+
+func TestRender(t *testing.T) {
+	names, err := Names()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+		data, ok := SampleData(name)
+		if !ok {
+			t.Errorf("Template %s has no sample data", name)
+			continue
+		}
+
+		message, err := Render(name, data)
+		if err != nil {
+			t.Errorf("Template %s returned error: %s", name, err)
+			continue
+		}
+
+		if message.Subject == "" || strings.Contains(message.Subject, "\n") {
+			t.Errorf("Template %s has an invalid subject: %q", name, message.Subject)
+		}
+
+		// Emails about reservations mention the confirmation code, the emails to staff link to the site
+		marker := "ABCD2345"
+		switch name {
+		case Invitation:
+			marker = "http://localhost:8080/auth/login"
+		case AccountLocked:
+			marker = "http://localhost:8080/auth/forgot-password"
+		case PasswordReset:
+			marker = "http://localhost:8080/auth/reset-password?token=0123456789abcdef"
+		}
+
+		if !strings.Contains(message.HTML, "<html") || !strings.Contains(message.HTML, marker) {
+			t.Errorf("Template %s has an invalid HTML body", name)
+		}
+
+		if strings.Contains(message.Text, "<") || !strings.Contains(message.Text, marker) {
+			t.Errorf("Template %s has an invalid plain-text body: %s", name, message.Text)
+		}
+	}
+}
+
+func TestRender_Escaping(t *testing.T) {
+	data, _ := SampleData(ConfirmationGuest)
+	confirmation := data.(ConfirmationData)
+	confirmation.Reservation.FirstName = "<b>John</b>"
+
+	message, err := Render(ConfirmationGuest, confirmation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(message.HTML, "<b>John</b>") || !strings.Contains(message.HTML, "&lt;b&gt;John&lt;/b&gt;") {
+		t.Error("Guest name was not escaped in the HTML body")
+	}
+
+	if !strings.Contains(message.Text, "Dear <b>John</b>,") {
+		t.Error("Guest name was escaped in the plain-text body")
+	}
+}
+
+func TestRender_UnknownTemplate(t *testing.T) {
+	_, err := Render("welcome", nil)
+	if err == nil {
+		t.Error("Rendering an unknown template should fail")
+	}
+
+	// Data of the wrong type fails instead of rendering an incomplete email
+	_, err = Render(ConfirmationGuest, CancellationData{Reservation: models.Reservation{}})
+	if err == nil {
+		t.Error("Rendering with data of the wrong type should fail")
+	}
+}
+
+func TestNewMail(t *testing.T) {
+	data, _ := SampleData(Reminder)
+
+	mailData, err := NewMail(Reminder, "jane@doe.com", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mailData.To != "jane@doe.com" || mailData.Template != Reminder {
+		t.Errorf("Wrong email: %+v", mailData)
+	}
+
+	if mailData.Subject == "" || mailData.Content == "" || mailData.TextContent == "" {
+		t.Errorf("Email is missing its subject or content: %+v", mailData)
+	}
+}
+
+func TestCalendarInvite(t *testing.T) {
+	app := testApp
+	app.PropertyName = "Fort Smythe"
+	app.PropertyAddress = "1 Main Street, Springfield"
+	app.PropertyEmail = "me@here.com"
+	app.CheckInTime = 15 * time.Hour
+	app.CheckOutTime = 11 * time.Hour
+	StoreAppConfig(&app)
+	defer StoreAppConfig(&testApp)
+
+	data, _ := SampleData(ConfirmationGuest)
+	reservation := data.(ConfirmationData).Reservation
+	reservation.StartDate = time.Date(2050, time.January, 3, 0, 0, 0, 0, time.UTC)
+	reservation.EndDate = time.Date(2050, time.January, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		method string
+		status string
+	}{
+		{MethodRequest, "STATUS:CONFIRMED"},
+		{MethodCancel, "STATUS:CANCELLED"},
+	}
+
+	for _, test := range tests {
+		attachment, err := CalendarInvite(reservation, test.method)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if attachment.Name != "reservation.ics" || attachment.ContentType != "text/calendar; charset=utf-8; method=" + test.method {
+			t.Errorf("Wrong attachment for %s: %s %s", test.method, attachment.Name, attachment.ContentType)
+		}
+
+		checkIn := time.Date(2050, time.January, 3, 15, 0, 0, 0, time.Local).UTC().Format("20060102T150405Z")
+		checkOut := time.Date(2050, time.January, 5, 11, 0, 0, 0, time.Local).UTC().Format("20060102T150405Z")
+
+		for _, expected := range []string{
+			"METHOD:" + test.method,
+			"UID:reservation-ABCD2345@bed-and-breakfast",
+			"DTSTART:" + checkIn,
+			"DTEND:" + checkOut,
+			"SUMMARY:Stay at Fort Smythe (General's Quarters)",
+			`LOCATION:1 Main Street\, Springfield`,
+			test.status,
+			"mailto:jane@doe.com",
+		} {
+			if !strings.Contains(string(attachment.Data), expected) {
+				t.Errorf("Invite for %s is missing %q:\n%s", test.method, expected, attachment.Data)
+			}
+		}
+	}
+}
+
+func TestBookingCalendarInvite(t *testing.T) {
+	data, _ := SampleData(BookingConfirmationGuest)
+	booking := data.(BookingConfirmationData).Booking
+
+	attachment, err := BookingCalendarInvite(booking, MethodRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invite := string(attachment.Data)
+
+	// One event for the stay in every room, each belonging to its own reservation
+	if strings.Count(invite, "BEGIN:VEVENT") != 2 {
+		t.Errorf("Invite should have an event for each room:\n%s", invite)
+	}
+
+	for _, expected := range []string{"UID:reservation-JKLM2345@bed-and-breakfast", "UID:reservation-EFGH6789@bed-and-breakfast"} {
+		if !strings.Contains(invite, expected) {
+			t.Errorf("Invite is missing %q:\n%s", expected, invite)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package emails
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/ical"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+// Methods of calendar invites
+const (
+	// Adds the stay to the calendar of the guest or updates it
+	MethodRequest = "REQUEST"
+	// Removes the stay from the calendar of the guest
+	MethodCancel = "CANCEL"
+)
+
+// Name of the calendar invite attached to emails
+const inviteFileName = "reservation.ics"
+
+// Creates a calendar invite for the stay of a reservation, going from check-in on the arrival date
+// to check-out on the departure date. Invites of the same reservation update each other in the calendar
+// of the guest, so changed dates move the event and a cancellation removes it
+func CalendarInvite(reservation models.Reservation, method string) (models.Attachment, error) {
+	return calendarInvite([]models.Reservation{reservation}, method)
+}
+
+// Creates a single calendar invite with an event for the stay in every room of a booking.
+// Each event belongs to its reservation, so that later invites of one of the rooms update only that event
+func BookingCalendarInvite(booking models.Booking, method string) (models.Attachment, error) {
+	return calendarInvite(booking.Reservations, method)
+}
+
+// Creates a calendar invite with an event for each of the given reservations
+func calendarInvite(reservations []models.Reservation, method string) (models.Attachment, error) {
+	var propertyName, propertyAddress, propertyEmail string
+	var checkInTime, checkOutTime time.Duration
+
+	if app != nil {
+		propertyName = app.PropertyName
+		propertyAddress = app.PropertyAddress
+		propertyEmail = app.PropertyEmail
+		checkInTime = app.CheckInTime
+		checkOutTime = app.CheckOutTime
+	}
+
+	now := time.Now()
+
+	var events []ical.Event
+
+	for _, reservation := range reservations {
+		event := ical.Event{
+			// The confirmation code is used since the confirmation emails are built before the reservation has an id
+			UID: fmt.Sprintf("reservation-%s@bed-and-breakfast", reservation.ConfirmationCode),
+			Start: localDate(reservation.StartDate).Add(checkInTime),
+			End: localDate(reservation.EndDate).Add(checkOutTime),
+			Timed: true,
+			Summary: fmt.Sprintf("Stay in the %s", reservation.Room.RoomName),
+			Description: fmt.Sprintf("Reservation %s", reservation.ConfirmationCode),
+			Location: propertyAddress,
+			Status: "CONFIRMED",
+			// The sequence has to grow with every update of the invite, which the time of the update does
+			Sequence: int(now.Unix()),
+			Organizer: propertyEmail,
+			OrganizerName: propertyName,
+			Attendee: reservation.Email,
+			AttendeeName: fmt.Sprintf("%s %s", reservation.FirstName, reservation.LastName),
+			Stamp: now,
+		}
+
+		if propertyName != "" {
+			event.Summary = fmt.Sprintf("Stay at %s (%s)", propertyName, reservation.Room.RoomName)
+		}
+
+		if method == MethodCancel {
+			event.Status = "CANCELLED"
+		}
+
+		events = append(events, event)
+	}
+
+	var out bytes.Buffer
+
+	err := ical.Encode(&out, ical.Calendar{
+		Method: method,
+		Events: events,
+	})
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	return models.Attachment{
+		Name: inviteFileName,
+		ContentType: fmt.Sprintf("text/calendar; charset=utf-8; method=%s", method),
+		Data: out.Bytes(),
+	}, nil
+}
+
+// Reservation dates are stored as dates in UTC, while check-in and check-out happen in the local time zone
+func localDate(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+}
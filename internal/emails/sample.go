@@ -0,0 +1,133 @@
+package emails
+
+import (
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+// Returns made-up data for the email template with the given name, used to preview the templates
+func SampleData(name string) (interface{}, bool) {
+	startDate := time.Now().AddDate(0, 0, 14).Truncate(24 * time.Hour)
+	endDate := startDate.AddDate(0, 0, 3)
+
+	reservation := models.Reservation{
+		ID: 1,
+		FirstName: "Jane",
+		LastName: "Doe",
+		Email: "jane@doe.com",
+		Phone: "555-555-5555",
+		StartDate: startDate,
+		EndDate: endDate,
+		RoomID: 1,
+		Adults: 2,
+		TotalPrice: 33000,
+		ConfirmationCode: "ABCD2345",
+		Room: models.Room{
+			ID: 1,
+			RoomName: "General's Quarters",
+		},
+	}
+
+	quote := models.Quote{
+		Subtotal: 36000,
+		DiscountPercent: 10,
+		Discount: 3000,
+		Total: 33000,
+	}
+
+	optOutURL := "http://localhost:8080/my-reservation/email-opt-out?code=ABCD2345&email=jane%40doe.com"
+
+	for date := startDate; date.Before(endDate); date = date.AddDate(0, 0, 1) {
+		quote.Nights = append(quote.Nights, models.NightlyPrice{
+			Date: date,
+			Label: "Standard rate",
+			Amount: 12000,
+		})
+	}
+
+	switch name {
+	case ConfirmationGuest, ConfirmationOwner:
+		return ConfirmationData{Reservation: reservation, Quote: quote}, true
+	case BookingConfirmationGuest, BookingConfirmationOwner:
+		first := reservation
+		first.ConfirmationCode = "JKLM2345"
+
+		second := reservation
+		second.ID = 2
+		second.RoomID = 2
+		second.ConfirmationCode = "EFGH6789"
+		second.Room = models.Room{ID: 2, RoomName: "Major's Suite"}
+
+		return BookingConfirmationData{Booking: models.Booking{
+			ID: 1,
+			ConfirmationCode: "ABCD2345",
+			FirstName: reservation.FirstName,
+			LastName: reservation.LastName,
+			Email: reservation.Email,
+			Phone: reservation.Phone,
+			TotalPrice: 2 * reservation.TotalPrice,
+			Reservations: []models.Reservation{first, second},
+		}}, true
+	case ChangeGuest, ChangeOwner:
+		return ChangeData{
+			Reservation: reservation,
+			PreviousStartDate: startDate.AddDate(0, 0, -7),
+			PreviousEndDate: endDate.AddDate(0, 0, -7),
+		}, true
+	case Cancellation, CancellationOwner:
+		return CancellationData{Reservation: reservation}, true
+	case Reminder:
+		return ReminderData{Reservation: reservation, OptOutURL: optOutURL}, true
+	case FollowUp:
+		return FollowUpData{Reservation: reservation, OptOutURL: optOutURL}, true
+	case OwnerDigest:
+		departure := reservation
+		departure.ID = 2
+		departure.FirstName = "John"
+		departure.LastName = "Smith"
+		departure.Email = "john@smith.com"
+		departure.ConfirmationCode = "EFGH6789"
+		departure.Room = models.Room{ID: 2, RoomName: "Major's Suite"}
+
+		return OwnerDigestData{
+			Date: startDate,
+			Arrivals: []models.Reservation{reservation},
+			Departures: []models.Reservation{departure},
+		}, true
+	case Invitation:
+		return InvitationData{
+			User: models.User{
+				FirstName: "Jane",
+				LastName: "Doe",
+				Email: "jane@doe.com",
+				AccessLevel: models.AccessFrontDesk,
+			},
+			InvitedBy: "John Smith",
+			LoginURL: "http://localhost:8080/auth/login",
+		}, true
+	case PasswordReset:
+		return PasswordResetData{
+			User: models.User{
+				FirstName: "Jane",
+				LastName: "Doe",
+				Email: "jane@doe.com",
+			},
+			ResetURL: "http://localhost:8080/auth/reset-password?token=0123456789abcdef",
+			ValidFor: "1 hour",
+		}, true
+	case AccountLocked:
+		return AccountLockedData{
+			User: models.User{
+				FirstName: "Jane",
+				LastName: "Doe",
+				Email: "jane@doe.com",
+			},
+			LockedFor: "15 minutes",
+			IPAddress: "192.0.2.1",
+			ResetURL: "http://localhost:8080/auth/forgot-password",
+		}, true
+	default:
+		return nil, false
+	}
+}
@@ -3,11 +3,17 @@ package forms
 import (
 	"fmt"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/asaskevich/govalidator"
 )
 
+// Lowercase words separated by single hyphens, e.g. "generals-quarters"
+var slugRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
 // Creates a custom form struct used for server side validation of forms
 type Form struct {
 	url.Values
@@ -49,6 +55,79 @@ func (form *Form) RequiredFields(fields ...string) {
 	}
 }
 
+// Minimum length of the passwords chosen by users
+const MinPasswordLength = 8
+
+// Passwords of at least this length don't have to mix kinds of characters, e.g. passphrases
+const MinPassphraseLength = 16
+
+// Passwords which are guessed first, compared in lowercase
+var commonPasswords = map[string]bool{
+	"password": true,
+	"password1": true,
+	"password123": true,
+	"12345678": true,
+	"123456789": true,
+	"1234567890": true,
+	"qwertyuiop": true,
+	"iloveyou": true,
+	"sunshine": true,
+	"welcome1": true,
+	"letmein1": true,
+	"bedandbreakfast": true,
+}
+
+// Returns why a password is too weak to be used, or an empty string if it is strong enough.
+// Passwords need at least `MinPasswordLength` characters mixing three of lowercase letters, uppercase letters,
+// numbers and symbols, unless they have at least `MinPassphraseLength` characters
+func PasswordProblem(password string) string {
+	if len(password) < MinPasswordLength {
+		return fmt.Sprintf("The password must be at least %d characters long", MinPasswordLength)
+	}
+
+	if commonPasswords[strings.ToLower(password)] {
+		return "This password is too common, choose another one"
+	}
+
+	if len(password) >= MinPassphraseLength {
+		return ""
+	}
+
+	var lower, upper, digit, symbol int
+	for _, char := range password {
+		switch {
+		case unicode.IsLower(char):
+			lower = 1
+		case unicode.IsUpper(char):
+			upper = 1
+		case unicode.IsDigit(char):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+
+	if lower + upper + digit + symbol < 3 {
+		return fmt.Sprintf(
+			"Mix at least three of lowercase letters, uppercase letters, numbers and symbols, or use at least %d characters",
+			MinPassphraseLength,
+		)
+	}
+
+	return ""
+}
+
+// Checks if field is a password which is hard to guess
+func (form *Form) IsStrongPassword(field string) bool {
+	problem := PasswordProblem(form.Get(field))
+	if problem != "" {
+		form.Errors.Add(field, problem)
+		return false
+	}
+
+	return true
+}
+
 // Checks if field (string) has the required minimum length
 func (form *Form) MinLength(field string, length int) bool {
 	// Fetch field from the request's form data
@@ -68,4 +147,27 @@ func (form *Form) IsEmail(field string) {
 	if !govalidator.IsEmail(form.Get(field)) {
 		form.Errors.Add(field, "Invalid email address")
 	}
+}
+
+// Checks if field is a valid URL slug (lowercase letters, digits and hyphens)
+func (form *Form) IsSlug(field string) {
+	if !slugRegexp.MatchString(form.Get(field)) {
+		form.Errors.Add(field, "Only lowercase letters, numbers and hyphens are allowed")
+	}
+}
+
+// Checks if field is a whole number greater than or equal to `min`
+func (form *Form) MinValue(field string, min int) bool {
+	value, err := strconv.Atoi(form.Get(field))
+	if err != nil {
+		form.Errors.Add(field, "This field must be a whole number")
+		return false
+	}
+
+	if value < min {
+		form.Errors.Add(field, fmt.Sprintf("This field must be at least %d", min))
+		return false
+	}
+
+	return true
 }
\ No newline at end of file
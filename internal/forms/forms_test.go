@@ -143,4 +143,76 @@ func TestForm_Has(t *testing.T) {
 	if !form.Has("a") {
 		t.Error("Got invalid when it should have been valid - field exists")
 	}
-}
\ No newline at end of file
+}
+
+func TestForm_IsSlug(t *testing.T) {
+	slugs := map[string]bool{
+		"generals-quarters": true,
+		"room-2": true,
+		"": false,
+		"Generals-Quarters": false,
+		"generals--quarters": false,
+		"-generals": false,
+		"generals quarters": false,
+	}
+
+	for slug, expected := range slugs {
+		postedData := url.Values{}
+		postedData.Add("slug", slug)
+
+		form := New(postedData)
+		form.IsSlug("slug")
+
+		if form.IsValid() != expected {
+			t.Errorf("Wrong validation for slug %q: got %t, wanted %t", slug, form.IsValid(), expected)
+		}
+	}
+}
+
+func TestForm_MinValue(t *testing.T) {
+	values := map[string]bool{
+		"1": true,
+		"12": true,
+		"0": false,
+		"-1": false,
+		"two": false,
+		"": false,
+	}
+
+	for value, expected := range values {
+		postedData := url.Values{}
+		postedData.Add("capacity", value)
+
+		form := New(postedData)
+		form.MinValue("capacity", 1)
+
+		if form.IsValid() != expected {
+			t.Errorf("Wrong validation for value %q: got %t, wanted %t", value, form.IsValid(), expected)
+		}
+	}
+}
+
+func TestForm_IsStrongPassword(t *testing.T) {
+	values := map[string]bool{
+		"Sunny room 4": true,
+		"correct horse battery": true,
+		"Tr0ub4dor": true,
+		"Sh0rt!": false,
+		"alllowercase": false,
+		"Password1": false,
+		"12345678": false,
+		"": false,
+	}
+
+	for value, expected := range values {
+		postedData := url.Values{}
+		postedData.Add("password", value)
+
+		form := New(postedData)
+		form.IsStrongPassword("password")
+
+		if form.IsValid() != expected {
+			t.Errorf("Wrong validation for password %q: got %t, wanted %t", value, form.IsValid(), expected)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/ratelimit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/webhooks"
+	"github.com/go-chi/chi/v5"
+)
+
+// Scopes which can be granted to API keys
+const (
+	ScopeReservationsRead = "reservations:read"
+	ScopeReservationsWrite = "reservations:write"
+	ScopeCalendarWrite = "calendar:write"
+	ScopeRoomsRead = "rooms:read"
+)
+
+// Scope shown on the API keys page of the admin dashboard
+type apiScope struct {
+	Name string
+	Description string
+}
+
+// All scopes, in the order they are shown on the API keys page
+var apiScopes = []apiScope{
+	{ScopeReservationsRead, "List and view reservations"},
+	{ScopeReservationsWrite, "Mark reservations as processed and delete them"},
+	{ScopeCalendarWrite, "Create and delete owner blocks"},
+	{ScopeRoomsRead, "List rooms, including inactive ones"},
+}
+
+// Prefix of API keys, so they are easy to recognise (e.g. in leaked logs)
+const apiKeyPrefix = "bnb_"
+
+// The last-used timestamp of a key is updated at most once per interval
+const apiKeyLastUsedInterval = time.Minute
+
+// Limits the number of requests of each API key
+var apiKeyLimiter = ratelimit.New()
+
+// Reservation as returned by the admin API
+type apiAdminReservation struct {
+	ID int `json:"id"`
+	Processed bool `json:"processed"`
+	apiReservation
+}
+
+// Room as returned by the admin API
+type apiAdminRoom struct {
+	apiRoom
+	Active bool `json:"active"`
+	SortOrder int `json:"sort_order"`
+}
+
+// Owner block as returned by the admin API and sent to webhooks. The end date is the day after the last blocked day
+type apiBlock struct {
+	ID int `json:"id,omitempty"`
+	RoomID int `json:"room_id"`
+	StartDate string `json:"start_date"`
+	EndDate string `json:"end_date"`
+	Reason string `json:"reason,omitempty"`
+	Note string `json:"note,omitempty"`
+}
+
+// Body of a request to block a day of a room
+type apiBlockRequest struct {
+	RoomID int `json:"room_id"`
+	Date string `json:"date"`
+}
+
+// Middleware authenticating admin API requests with an API key sent as `Authorization: Bearer <key>`.
+// The key must not be revoked, must have the given scope and must be within its rate limit
+func (repo *Repository) RequireApiKey(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := repo.getRequestApiKey(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+				sendApiError(w, http.StatusUnauthorized, apiError{
+					Code: "unauthorized",
+					Message: "A valid API key is required",
+				})
+				return
+			}
+
+			allowed, wait := apiKeyLimiter.Allow(fmt.Sprintf("api-key-%d", key.ID), key.RateLimit)
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				sendApiError(w, http.StatusTooManyRequests, apiError{
+					Code: "rate_limited",
+					Message: fmt.Sprintf("This API key is limited to %d requests per minute", key.RateLimit),
+				})
+				return
+			}
+
+			if !key.HasScope(scope) {
+				sendApiError(w, http.StatusForbidden, apiError{
+					Code: "insufficient_scope",
+					Message: fmt.Sprintf("This API key does not have the %s scope", scope),
+				})
+				return
+			}
+
+			if time.Since(key.LastUsedAt) > apiKeyLastUsedInterval {
+				err := repo.DB.UpdateLastUsedForApiKey(key.ID, time.Now())
+				if err != nil {
+					repo.App.ErrorLog.Println(err)
+				}
+			}
+
+			// Changes made with the key are recorded in the audit log under it
+			next.ServeHTTP(w, r.WithContext(helpers.ContextWithApiKey(r.Context(), key)))
+		})
+	}
+}
+
+// Lists all reservations, or only the new ones with `?filter=new`
+func (repo *Repository) AdminApiReservations(w http.ResponseWriter, r *http.Request) {
+	var reservations []models.Reservation
+	var err error
+
+	if r.URL.Query().Get("filter") == "new" {
+		reservations, err = repo.DB.GetNewReservations()
+	} else {
+		reservations, err = repo.DB.GetAllReservations()
+	}
+
+	if err != nil {
+		repo.sendApiServerError(w, err)
+		return
+	}
+
+	apiReservations := make([]apiAdminReservation, 0, len(reservations))
+	for _, reservation := range reservations {
+		apiReservations = append(apiReservations, newApiAdminReservation(reservation))
+	}
+
+	sendApiResponse(w, http.StatusOK, apiReservations)
+}
+
+// Gets a reservation by id
+func (repo *Repository) AdminApiReservation(w http.ResponseWriter, r *http.Request) {
+	reservation, ok := repo.getApiReservation(w, r)
+	if !ok {
+		return
+	}
+
+	sendApiResponse(w, http.StatusOK, newApiAdminReservation(reservation))
+}
+
+// Marks a reservation as processed
+func (repo *Repository) AdminApiProcessReservation(w http.ResponseWriter, r *http.Request) {
+	reservation, ok := repo.getApiReservation(w, r)
+	if !ok {
+		return
+	}
+
+	err := repo.DB.UpdateProcessedForReservation(reservation.ID, true)
+	if err != nil {
+		repo.sendApiServerError(w, err)
+		return
+	}
+
+	before := newApiAdminReservation(reservation)
+	reservation.Processed = true
+
+	repo.sendWebhook(webhooks.EventReservationProcessed, newApiAdminReservation(reservation))
+	repo.audit(r, audit.ActionProcessed, audit.EntityReservation, reservation.ID, before, newApiAdminReservation(reservation))
+
+	sendApiResponse(w, http.StatusOK, newApiAdminReservation(reservation))
+}
+
+// Deletes a reservation
+func (repo *Repository) AdminApiDeleteReservation(w http.ResponseWriter, r *http.Request) {
+	reservation, ok := repo.getApiReservation(w, r)
+	if !ok {
+		return
+	}
+
+	err := repo.DB.DeleteReservation(reservation.ID)
+	if err != nil {
+		repo.sendApiServerError(w, err)
+		return
+	}
+
+	repo.sendWebhook(webhooks.EventReservationDeleted, newApiAdminReservation(reservation))
+	repo.audit(r, audit.ActionDeleted, audit.EntityReservation, reservation.ID, newApiAdminReservation(reservation), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Blocks a day of a room. Responds with 409 Conflict if the day is already booked or blocked
+func (repo *Repository) AdminApiPostBlock(w http.ResponseWriter, r *http.Request) {
+	var request apiBlockRequest
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxApiBodySize))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(&request)
+	if err != nil {
+		sendApiError(w, http.StatusBadRequest, apiError{
+			Code: "invalid_json",
+			Message: fmt.Sprintf("Request body is not valid JSON: %s", err),
+		})
+		return
+	}
+
+	fields := make(map[string][]string)
+
+	date, err := time.Parse("2006-01-02", request.Date)
+	if err != nil {
+		fields["date"] = append(fields["date"], "Date must be in the format YYYY-MM-DD")
+	}
+
+	if request.RoomID <= 0 {
+		fields["room_id"] = append(fields["room_id"], "This field cannot be empty")
+	}
+
+	if len(fields) > 0 {
+		sendApiError(w, http.StatusUnprocessableEntity, apiError{
+			Code: "validation_failed",
+			Message: "Some fields are invalid",
+			Fields: fields,
+		})
+		return
+	}
+
+	_, err = repo.DB.GetRoomByID(request.RoomID)
+	if err != nil {
+		sendApiError(w, http.StatusNotFound, apiError{
+			Code: "room_not_found",
+			Message: "Room not found",
+		})
+		return
+	}
+
+	blockID, err := repo.DB.InsertBlockForRoom(request.RoomID, date)
+	if errors.Is(err, repository.ErrRoomNotAvailable) {
+		sendApiError(w, http.StatusConflict, apiError{
+			Code: "room_not_available",
+			Message: "The room is already booked or blocked on this date",
+		})
+		return
+	}
+
+	if err != nil {
+		repo.sendApiServerError(w, err)
+		return
+	}
+
+	block := newApiBlock(blockID, request.RoomID, date)
+
+	repo.sendWebhook(webhooks.EventBlockCreated, block)
+	repo.audit(r, audit.ActionCreated, audit.EntityBlock, blockID, nil, block)
+
+	sendApiResponse(w, http.StatusCreated, block)
+}
+
+// Deletes an owner block. Restrictions of reservations and external bookings can't be deleted this way
+func (repo *Repository) AdminApiDeleteBlock(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		repo.ApiNotFound(w, r)
+		return
+	}
+
+	restriction, err := repo.DB.GetRoomRestrictionByID(id)
+	if err != nil || restriction.RestrictionID != models.RestrictionOwnerBlock {
+		sendApiError(w, http.StatusNotFound, apiError{
+			Code: "block_not_found",
+			Message: "Owner block not found",
+		})
+		return
+	}
+
+	err = repo.DB.DeleteBlockByID(restriction.ID)
+	if err != nil {
+		repo.sendApiServerError(w, err)
+		return
+	}
+
+	repo.sendWebhook(webhooks.EventBlockDeleted, newApiBlockFromRestriction(restriction))
+	repo.audit(r, audit.ActionDeleted, audit.EntityBlock, restriction.ID, newApiBlockFromRestriction(restriction), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Lists all rooms, including the ones which are not shown to guests
+func (repo *Repository) AdminApiRooms(w http.ResponseWriter, r *http.Request) {
+	rooms, err := repo.DB.GetAllRooms()
+	if err != nil {
+		repo.sendApiServerError(w, err)
+		return
+	}
+
+	apiRooms := make([]apiAdminRoom, 0, len(rooms))
+	for _, room := range rooms {
+		apiRooms = append(apiRooms, newApiAdminRoom(room))
+	}
+
+	sendApiResponse(w, http.StatusOK, apiRooms)
+}
+
+// Gets the API key sent with a request, if it exists and has not been revoked
+func (repo *Repository) getRequestApiKey(r *http.Request) (models.ApiKey, bool) {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return models.ApiKey{}, false
+	}
+
+	token := strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+	if token == "" {
+		return models.ApiKey{}, false
+	}
+
+	key, err := repo.DB.GetApiKeyByHash(helpers.HashToken(token))
+	if err != nil || key.Revoked {
+		return models.ApiKey{}, false
+	}
+
+	return key, true
+}
+
+// Gets the reservation with the id given in the URL. Sends a 404 response if there is none
+func (repo *Repository) getApiReservation(w http.ResponseWriter, r *http.Request) (models.Reservation, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err == nil {
+		reservation, err := repo.DB.GetReservationByID(id)
+		if err == nil {
+			return reservation, true
+		}
+	}
+
+	sendApiError(w, http.StatusNotFound, apiError{
+		Code: "reservation_not_found",
+		Message: "Reservation not found",
+	})
+
+	return models.Reservation{}, false
+}
+
+// Converts a reservation into its admin API representation
+func newApiAdminReservation(reservation models.Reservation) apiAdminReservation {
+	return apiAdminReservation{
+		ID: reservation.ID,
+		Processed: reservation.Processed,
+		apiReservation: newApiReservation(reservation),
+	}
+}
+
+// Converts an owner block of one day into its admin API representation
+func newApiBlock(id int, roomID int, date time.Time) apiBlock {
+	return apiBlock{
+		ID: id,
+		RoomID: roomID,
+		StartDate: date.Format("2006-01-02"),
+		EndDate: date.AddDate(0, 0, 1).Format("2006-01-02"),
+	}
+}
+
+// Converts a room into its admin API representation
+func newApiAdminRoom(room models.Room) apiAdminRoom {
+	return apiAdminRoom{
+		apiRoom: newApiRoom(room),
+		Active: room.Active,
+		SortOrder: room.SortOrder,
+	}
+}
+
+// Converts an owner block of any number of days into its admin API representation
+func newApiBlockFromRestriction(restriction models.RoomRestriction) apiBlock {
+	return apiBlock{
+		ID: restriction.ID,
+		RoomID: restriction.RoomID,
+		StartDate: restriction.StartDate.Format("2006-01-02"),
+		EndDate: restriction.EndDate.Format("2006-01-02"),
+		Reason: restriction.Reason,
+		Note: restriction.Note,
+	}
+}
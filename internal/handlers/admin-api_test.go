@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/ratelimit"
+)
+
+var adminApiTests = []struct {
+	name               string
+	method             string
+	url                string
+	key                string
+	body               string
+	expectedStatusCode int
+	expectedErrorCode  string
+}{
+	{"Without key", "GET", "/api/v1/admin/reservations", "", "", http.StatusUnauthorized, "unauthorized"},
+	{"Unknown key", "GET", "/api/v1/admin/reservations", "unknown-key", "", http.StatusUnauthorized, "unauthorized"},
+	{"Revoked key", "GET", "/api/v1/admin/reservations", "revoked-key", "", http.StatusUnauthorized, "unauthorized"},
+	{"Key without scope", "POST", "/api/v1/admin/reservations/1/process", "read-key", "", http.StatusForbidden, "insufficient_scope"},
+	{"Lists reservations", "GET", "/api/v1/admin/reservations", "read-key", "", http.StatusOK, ""},
+	{"Lists new reservations", "GET", "/api/v1/admin/reservations?filter=new", "read-key", "", http.StatusOK, ""},
+	{"Gets reservation", "GET", "/api/v1/admin/reservations/2", "read-key", "", http.StatusOK, ""},
+	{"Gets non-existent reservation", "GET", "/api/v1/admin/reservations/11", "read-key", "", http.StatusNotFound, "reservation_not_found"},
+	{"Processes reservation", "POST", "/api/v1/admin/reservations/2/process", "write-key", "", http.StatusOK, ""},
+	{"Processes non-existent reservation", "POST", "/api/v1/admin/reservations/11/process", "write-key", "", http.StatusNotFound, "reservation_not_found"},
+	{"Deletes reservation", "DELETE", "/api/v1/admin/reservations/2", "write-key", "", http.StatusNoContent, ""},
+	{"Creates block", "POST", "/api/v1/admin/blocks", "write-key", `{"room_id": 1, "date": "2050-01-01"}`, http.StatusCreated, ""},
+	{"Creates block on booked day", "POST", "/api/v1/admin/blocks", "write-key", `{"room_id": 1, "date": "2100-01-01"}`, http.StatusConflict, "room_not_available"},
+	{"Creates block for non-existent room", "POST", "/api/v1/admin/blocks", "write-key", `{"room_id": 3, "date": "2050-01-01"}`, http.StatusNotFound, "room_not_found"},
+	{"Creates block with invalid date", "POST", "/api/v1/admin/blocks", "write-key", `{"room_id": 1, "date": "tomorrow"}`, http.StatusUnprocessableEntity, "validation_failed"},
+	{"Creates block with invalid JSON", "POST", "/api/v1/admin/blocks", "write-key", `room_id=1`, http.StatusBadRequest, "invalid_json"},
+	{"Deletes block", "DELETE", "/api/v1/admin/blocks/1", "write-key", "", http.StatusNoContent, ""},
+	// Restriction 2 belongs to a reservation
+	{"Deletes restriction of reservation", "DELETE", "/api/v1/admin/blocks/2", "write-key", "", http.StatusNotFound, "block_not_found"},
+	{"Lists rooms", "GET", "/api/v1/admin/rooms", "read-key", "", http.StatusOK, ""},
+	// The limited key allows a single request per minute
+	{"Within rate limit", "GET", "/api/v1/admin/rooms", "limited-key", "", http.StatusOK, ""},
+	{"Over rate limit", "GET", "/api/v1/admin/rooms", "limited-key", "", http.StatusTooManyRequests, "rate_limited"},
+}
+
+func TestAdminApi(t *testing.T) {
+	// Start with full rate limit buckets
+	apiKeyLimiter = ratelimit.New()
+
+	routes := getRoutes()
+
+	for _, test := range adminApiTests {
+		req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.body))
+		if err != nil {
+			log.Println(err)
+		}
+
+		if test.key != "" {
+			req.Header.Set("Authorization", "Bearer " + test.key)
+		}
+
+		responseRecorder := httptest.NewRecorder()
+		routes.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf(
+				"Test %s returns wrong response status code: got %d, wanted %d",
+				test.name,
+				responseRecorder.Code,
+				test.expectedStatusCode,
+			)
+		}
+
+		if responseRecorder.Code == http.StatusNoContent {
+			continue
+		}
+
+		var body apiErrorResponse
+		err = json.NewDecoder(responseRecorder.Body).Decode(&body)
+		if err != nil {
+			t.Errorf("Test %s did not return JSON: %s", test.name, err)
+			continue
+		}
+
+		if body.Error.Code != test.expectedErrorCode {
+			t.Errorf("Test %s returns wrong error code: got %q, wanted %q", test.name, body.Error.Code, test.expectedErrorCode)
+		}
+
+		if test.expectedStatusCode == http.StatusTooManyRequests && responseRecorder.Header().Get("Retry-After") == "" {
+			t.Errorf("Test %s did not set the Retry-After header", test.name)
+		}
+	}
+}
+
+func TestAdminApiPostBlock(t *testing.T) {
+	apiKeyLimiter = ratelimit.New()
+
+	req, err := http.NewRequest("POST", "/api/v1/admin/blocks", strings.NewReader(`{"room_id": 1, "date": "2050-01-01"}`))
+	if err != nil {
+		log.Println(err)
+	}
+	req.Header.Set("Authorization", "Bearer write-key")
+
+	responseRecorder := httptest.NewRecorder()
+	getRoutes().ServeHTTP(responseRecorder, req)
+
+	var body struct {
+		Data apiBlock `json:"data"`
+	}
+	err = json.NewDecoder(responseRecorder.Body).Decode(&body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The id has to match the one sent when the block is deleted
+	if body.Data.ID != 4 {
+		t.Errorf("Created block has wrong id: got %d, wanted 4", body.Data.ID)
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/go-chi/chi/v5"
+)
+
+// Requests per minute allowed by default for a new API key
+const defaultApiKeyRateLimit = 60
+
+// AdminApiKeys is the API keys page handler in the admin dashboard
+func (repo *Repository) AdminApiKeys(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(nil)
+
+	repo.renderApiKeys(w, r, form)
+}
+
+// Handler to issue an API key with received form data
+func (repo *Repository) AdminPostApiKeys(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.RequiredFields("name", "rate_limit")
+	form.MinValue("rate_limit", 1)
+
+	// Only known scopes can be granted
+	var scopes []string
+	for _, scope := range r.PostForm["scopes"] {
+		if !isApiScope(scope) {
+			form.Errors.Add("scopes", "Unknown scope")
+			break
+		}
+
+		scopes = append(scopes, scope)
+	}
+
+	if len(scopes) == 0 {
+		form.Errors.Add("scopes", "Grant at least one scope")
+	}
+
+	// Rerender page with updated error information
+	if !form.IsValid() {
+		repo.renderApiKeys(w, r, form)
+		return
+	}
+
+	key, err := helpers.GenerateToken()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	key = apiKeyPrefix + key
+	rateLimit, _ := strconv.Atoi(r.Form.Get("rate_limit"))
+
+	apiKey := models.ApiKey{
+		Name: strings.TrimSpace(r.Form.Get("name")),
+		KeyHash: helpers.HashToken(key),
+		Scopes: strings.Join(scopes, " "),
+		RateLimit: rateLimit,
+	}
+
+	apiKey.ID, err = repo.DB.InsertApiKey(apiKey)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionCreated, audit.EntityApiKey, apiKey.ID, nil, newAuditApiKey(apiKey))
+
+	repo.App.Session.Put(r.Context(), "api_key", key)
+	repo.App.Session.Put(r.Context(), "success", "API key created")
+	http.Redirect(w, r, "/admin/api-keys", http.StatusSeeOther)
+}
+
+// Handler to revoke an API key
+func (repo *Repository) AdminRevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	key, err := repo.DB.GetApiKeyByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.RevokeApiKey(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if !key.Revoked {
+		before := newAuditApiKey(key)
+		key.Revoked = true
+		repo.audit(r, audit.ActionRevoked, audit.EntityApiKey, id, before, newAuditApiKey(key))
+	}
+
+	repo.App.Session.Put(r.Context(), "success", "API key revoked")
+	http.Redirect(w, r, "/admin/api-keys", http.StatusSeeOther)
+}
+
+// Renders the API keys page of the admin dashboard
+func (repo *Repository) renderApiKeys(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	keys, err := repo.DB.GetAllApiKeys()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// Check the scopes picked before the form was rerendered
+	checkedScopes := make(map[string]bool)
+	for _, scope := range form.Values["scopes"] {
+		checkedScopes[scope] = true
+	}
+
+	data := make(map[string]interface{})
+	data["keys"] = keys
+	data["scopes"] = apiScopes
+	data["checked_scopes"] = checkedScopes
+
+	// A newly created key is only shown once, right after it has been created
+	stringMap := make(map[string]string)
+	stringMap["new_key"] = repo.App.Session.PopString(r.Context(), "api_key")
+	stringMap["rate_limit"] = strconv.Itoa(defaultApiKeyRateLimit)
+	if form.Has("rate_limit") {
+		stringMap["rate_limit"] = form.Get("rate_limit")
+	}
+
+	render.RenderTemplate(w, r, "admin-api-keys.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data: data,
+		Form: form,
+	})
+}
+
+// Reports whether a scope can be granted to API keys
+func isApiScope(name string) bool {
+	for _, scope := range apiScopes {
+		if scope.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var adminPostApiKeysTests = []struct {
+	name               string
+	body               url.Values
+	expectedStatusCode int
+	expectedFlash      string
+}{
+	{
+		"Creates API key",
+		url.Values{"name": {"Reports"}, "rate_limit": {"60"}, "scopes": {"reservations:read", "rooms:read"}},
+		http.StatusSeeOther,
+		"api_key",
+	},
+	{"Without name", url.Values{"name": {""}, "rate_limit": {"60"}, "scopes": {"reservations:read"}}, http.StatusOK, ""},
+	{"Without scopes", url.Values{"name": {"Reports"}, "rate_limit": {"60"}}, http.StatusOK, ""},
+	{"Unknown scope", url.Values{"name": {"Reports"}, "rate_limit": {"60"}, "scopes": {"users:write"}}, http.StatusOK, ""},
+	{"Invalid rate limit", url.Values{"name": {"Reports"}, "rate_limit": {"0"}, "scopes": {"rooms:read"}}, http.StatusOK, ""},
+	{"Insert failed", url.Values{"name": {"Invalid"}, "rate_limit": {"60"}, "scopes": {"rooms:read"}}, http.StatusInternalServerError, ""},
+}
+
+func TestRepository_AdminPostApiKeys(t *testing.T) {
+	for _, test := range adminPostApiKeysTests {
+		req, err := http.NewRequest("POST", "/admin/api-keys", strings.NewReader(test.body.Encode()))
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminPostApiKeys)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf(
+				"Test %s returns wrong response status code: got %d, wanted %d",
+				test.name,
+				responseRecorder.Code,
+				test.expectedStatusCode,
+			)
+		}
+
+		if test.expectedFlash != "" && !session.Exists(ctx, test.expectedFlash) {
+			t.Errorf("Test %s did not store %s in the session", test.name, test.expectedFlash)
+		}
+
+		// A new key is shown once and starts with the prefix
+		if test.expectedFlash == "api_key" && !strings.HasPrefix(session.GetString(ctx, "api_key"), apiKeyPrefix) {
+			t.Errorf("Test %s stored a key without the %s prefix", test.name, apiKeyPrefix)
+		}
+	}
+}
@@ -0,0 +1,466 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/pricing"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/webhooks"
+	"github.com/go-chi/chi/v5"
+)
+
+// Largest request body accepted by the API
+const maxApiBodySize = 1 << 20
+
+// Room as returned by the API
+type apiRoom struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Slug        string   `json:"slug"`
+	Description string   `json:"description"`
+	Capacity    int      `json:"capacity"`
+	BedConfiguration string `json:"bed_configuration"`
+	Amenities   []string `json:"amenities"`
+	Image       string   `json:"image"`
+}
+
+// Room which is available for the requested dates, with the price of the stay in cents
+type apiAvailableRoom struct {
+	apiRoom
+	TotalPrice int `json:"total_price"`
+}
+
+// Availability of all rooms for a date range
+type apiAvailability struct {
+	StartDate string             `json:"start_date"`
+	EndDate   string             `json:"end_date"`
+	Nights    int                `json:"nights"`
+	Rooms     []apiAvailableRoom `json:"rooms"`
+}
+
+// Reservation as returned by the API. Amounts are in cents
+type apiReservation struct {
+	ConfirmationCode string  `json:"confirmation_code"`
+	FirstName        string  `json:"first_name"`
+	LastName         string  `json:"last_name"`
+	Email            string  `json:"email"`
+	Phone            string  `json:"phone"`
+	StartDate        string  `json:"start_date"`
+	EndDate          string  `json:"end_date"`
+	Adults           int     `json:"adults"`
+	Children         int     `json:"children"`
+	TotalPrice       int     `json:"total_price"`
+	Cancelled        bool    `json:"cancelled"`
+	Room             apiRoom `json:"room"`
+}
+
+// Body of a request to create a reservation
+type apiReservationRequest struct {
+	RoomID    int    `json:"room_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	// Pointers, so that a party of a single adult can be left out
+	Adults    *int   `json:"adults"`
+	Children  *int   `json:"children"`
+}
+
+// Successful API responses wrap their payload in `data`
+type apiResponse struct {
+	Data interface{} `json:"data"`
+}
+
+// Failed API responses wrap the error in `error`
+type apiErrorResponse struct {
+	Error apiError `json:"error"`
+}
+
+// Error returned by the API. Fields holds the validation errors of each field, if any
+type apiError struct {
+	Code    string              `json:"code"`
+	Message string              `json:"message"`
+	Fields  map[string][]string `json:"fields,omitempty"`
+}
+
+// Lists the rooms shown to guests
+func (repo *Repository) ApiRooms(w http.ResponseWriter, r *http.Request) {
+	rooms, err := repo.DB.GetActiveRooms()
+	if err != nil {
+		repo.sendApiServerError(w, err)
+		return
+	}
+
+	apiRooms := make([]apiRoom, 0, len(rooms))
+	for _, room := range rooms {
+		apiRooms = append(apiRooms, newApiRoom(room))
+	}
+
+	sendApiResponse(w, http.StatusOK, apiRooms)
+}
+
+// Lists the rooms which are available between the `start_date` and `end_date` query parameters
+// and sleep the party given by the `adults` and `children` query parameters, together with the price of the stay
+func (repo *Repository) ApiAvailability(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	form := forms.New(query)
+	startDate, endDate, _ := validateApiDates(form, query.Get("start_date"), query.Get("end_date"))
+	adults, children := validateApiGuests(form)
+	if !form.IsValid() {
+		sendApiValidationError(w, form)
+		return
+	}
+
+	rooms, err := repo.DB.SearchAvailabilityForAllRooms(startDate, endDate, adults + children)
+	if err != nil {
+		repo.sendApiServerError(w, err)
+		return
+	}
+
+	// Rooms whose stay rules don't allow the stay aren't listed
+	rooms, _, err = repo.applyStayRules(rooms, startDate, endDate)
+	if err != nil {
+		repo.sendApiServerError(w, err)
+		return
+	}
+
+	availability := apiAvailability{
+		StartDate: startDate.Format("2006-01-02"),
+		EndDate: endDate.Format("2006-01-02"),
+		Nights: int(endDate.Sub(startDate).Hours() / 24),
+		Rooms: make([]apiAvailableRoom, 0, len(rooms)),
+	}
+
+	for _, room := range rooms {
+		plan, err := repo.DB.GetRatePlanForRoom(room.ID)
+		if err != nil {
+			repo.sendApiServerError(w, err)
+			return
+		}
+
+		availability.Rooms = append(availability.Rooms, apiAvailableRoom{
+			apiRoom: newApiRoom(room),
+			TotalPrice: pricing.Calculate(plan, startDate, endDate).Total,
+		})
+	}
+
+	sendApiResponse(w, http.StatusOK, availability)
+}
+
+// Creates a reservation from a JSON body. Responds with 409 Conflict if the room is not available
+// and with 422 Unprocessable Entity if the room is too small for the party or its stay rules don't allow the stay
+func (repo *Repository) ApiPostReservation(w http.ResponseWriter, r *http.Request) {
+	var request apiReservationRequest
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxApiBodySize))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(&request)
+	if err != nil {
+		sendApiError(w, http.StatusBadRequest, apiError{
+			Code: "invalid_json",
+			Message: fmt.Sprintf("Request body is not valid JSON: %s", err),
+		})
+		return
+	}
+
+	// Validate the body with the same rules as the make reservation form
+	form := forms.New(url.Values{
+		"first_name": {request.FirstName},
+		"last_name": {request.LastName},
+		"email": {request.Email},
+		"phone": {request.Phone},
+		"start_date": {request.StartDate},
+		"end_date": {request.EndDate},
+	})
+	if request.Adults != nil {
+		form.Set("adults", strconv.Itoa(*request.Adults))
+	}
+	if request.Children != nil {
+		form.Set("children", strconv.Itoa(*request.Children))
+	}
+	form.RequiredFields("first_name", "last_name", "email")
+	form.MinLength("first_name", 2)
+	form.IsEmail("email")
+
+	startDate, endDate, _ := validateApiDates(form, request.StartDate, request.EndDate)
+	adults, children := validateApiGuests(form)
+
+	if request.RoomID <= 0 {
+		form.Errors.Add("room_id", "This field cannot be empty")
+	}
+
+	if !form.IsValid() {
+		sendApiValidationError(w, form)
+		return
+	}
+
+	room, err := repo.DB.GetRoomByID(request.RoomID)
+	if err != nil || !room.Active {
+		sendApiError(w, http.StatusNotFound, apiError{
+			Code: "room_not_found",
+			Message: "Room not found",
+		})
+		return
+	}
+
+	if adults + children > room.Capacity {
+		sendApiError(w, http.StatusUnprocessableEntity, apiError{
+			Code: "too_many_guests",
+			Message: fmt.Sprintf("This room sleeps at most %d guests", room.Capacity),
+		})
+		return
+	}
+
+	reasons, err := repo.stayRuleReasons(room.ID, startDate, endDate)
+	if err != nil {
+		repo.sendApiServerError(w, err)
+		return
+	}
+
+	if len(reasons) > 0 {
+		sendApiError(w, http.StatusUnprocessableEntity, apiError{
+			Code: "stay_not_allowed",
+			Message: strings.Join(reasons, ". "),
+		})
+		return
+	}
+
+	plan, err := repo.DB.GetRatePlanForRoom(room.ID)
+	if err != nil {
+		repo.sendApiServerError(w, err)
+		return
+	}
+
+	quote := pricing.Calculate(plan, startDate, endDate)
+
+	reservation := models.Reservation{
+		FirstName: strings.TrimSpace(request.FirstName),
+		LastName: strings.TrimSpace(request.LastName),
+		Email: strings.TrimSpace(request.Email),
+		Phone: strings.TrimSpace(request.Phone),
+		StartDate: startDate,
+		EndDate: endDate,
+		RoomID: room.ID,
+		Adults: adults,
+		Children: children,
+		Room: room,
+		TotalPrice: quote.Total,
+		Quote: quote,
+	}
+
+	reservation.ConfirmationCode, err = helpers.GenerateConfirmationCode()
+	if err != nil {
+		repo.sendApiServerError(w, err)
+		return
+	}
+
+	confirmationEmails, err := repo.reservationConfirmationEmails(reservation, quote)
+	if err != nil {
+		repo.sendApiServerError(w, err)
+		return
+	}
+
+	reservation.ID, err = repo.DB.InsertReservationWithRestriction(reservation, confirmationEmails)
+	if errors.Is(err, repository.ErrRoomNotAvailable) {
+		sendApiError(w, http.StatusConflict, apiError{
+			Code: "room_not_available",
+			Message: "The room is not available for the selected dates",
+		})
+		return
+	}
+
+	if err != nil {
+		repo.sendApiServerError(w, err)
+		return
+	}
+
+	repo.sendWebhook(webhooks.EventReservationCreated, newApiAdminReservation(reservation))
+	repo.audit(r, audit.ActionCreated, audit.EntityReservation, reservation.ID, nil, newApiAdminReservation(reservation))
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/reservations/%s", reservation.ConfirmationCode))
+	sendApiResponse(w, http.StatusCreated, newApiReservation(reservation))
+}
+
+// Gets a reservation by its confirmation code. The email of the guest has to be given as `email` query parameter
+func (repo *Repository) ApiReservation(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	form.RequiredFields("email")
+	if !form.IsValid() {
+		sendApiValidationError(w, form)
+		return
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(chi.URLParam(r, "code")))
+	email := strings.TrimSpace(form.Get("email"))
+
+	reservation, err := repo.DB.GetReservationByConfirmationCode(code, email)
+	if err != nil {
+		sendApiError(w, http.StatusNotFound, apiError{
+			Code: "reservation_not_found",
+			Message: "No reservation with this confirmation code and email",
+		})
+		return
+	}
+
+	sendApiResponse(w, http.StatusOK, newApiReservation(reservation))
+}
+
+// Responds to unknown API routes
+func (repo *Repository) ApiNotFound(w http.ResponseWriter, r *http.Request) {
+	sendApiError(w, http.StatusNotFound, apiError{
+		Code: "not_found",
+		Message: "Not Found",
+	})
+}
+
+// Responds to API routes called with an unsupported method
+func (repo *Repository) ApiMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	sendApiError(w, http.StatusMethodNotAllowed, apiError{
+		Code: "method_not_allowed",
+		Message: "Method Not Allowed",
+	})
+}
+
+// Parses the dates of a stay, adding an error to the form for each invalid date.
+// Arrival can't be in the past and departure must be after arrival
+func validateApiDates(form *forms.Form, sd, ed string) (time.Time, time.Time, bool) {
+	form.RequiredFields("start_date", "end_date")
+	if !form.IsValid() {
+		return time.Time{}, time.Time{}, false
+	}
+
+	startDate, err := time.Parse("2006-01-02", sd)
+	if err != nil {
+		form.Errors.Add("start_date", "Date must be in the format YYYY-MM-DD")
+	}
+
+	endDate, err := time.Parse("2006-01-02", ed)
+	if err != nil {
+		form.Errors.Add("end_date", "Date must be in the format YYYY-MM-DD")
+	}
+
+	if !form.IsValid() {
+		return startDate, endDate, false
+	}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	if startDate.Before(today) {
+		form.Errors.Add("start_date", "Arrival can't be in the past")
+	}
+
+	if !endDate.After(startDate) {
+		form.Errors.Add("end_date", "Departure must be after arrival")
+	}
+
+	return startDate, endDate, form.IsValid()
+}
+
+// Parses the optional `adults` and `children` fields, adding an error to the form for each invalid one.
+// Parties without numbers are a single adult
+func validateApiGuests(form *forms.Form) (int, int) {
+	adults, children := 1, 0
+
+	if form.Has("adults") && form.MinValue("adults", 1) {
+		adults, _ = strconv.Atoi(form.Get("adults"))
+	}
+
+	if form.Has("children") && form.MinValue("children", 0) {
+		children, _ = strconv.Atoi(form.Get("children"))
+	}
+
+	return adults, children
+}
+
+// Converts a room into its API representation
+func newApiRoom(room models.Room) apiRoom {
+	amenities := room.AmenityList()
+	if amenities == nil {
+		amenities = []string{}
+	}
+
+	return apiRoom{
+		ID: room.ID,
+		Name: room.RoomName,
+		Slug: room.Slug,
+		Description: room.Description,
+		Capacity: room.Capacity,
+		BedConfiguration: room.BedConfiguration,
+		Amenities: amenities,
+		Image: room.Image,
+	}
+}
+
+// Converts a reservation into its API representation
+func newApiReservation(reservation models.Reservation) apiReservation {
+	return apiReservation{
+		ConfirmationCode: reservation.ConfirmationCode,
+		FirstName: reservation.FirstName,
+		LastName: reservation.LastName,
+		Email: reservation.Email,
+		Phone: reservation.Phone,
+		StartDate: reservation.StartDate.Format("2006-01-02"),
+		EndDate: reservation.EndDate.Format("2006-01-02"),
+		Adults: reservation.Adults,
+		Children: reservation.Children,
+		TotalPrice: reservation.TotalPrice,
+		Cancelled: reservation.Cancelled,
+		Room: newApiRoom(reservation.Room),
+	}
+}
+
+// Sends a successful JSON response
+func sendApiResponse(w http.ResponseWriter, status int, data interface{}) {
+	sendJson(w, status, apiResponse{Data: data})
+}
+
+// Sends a JSON error response
+func sendApiError(w http.ResponseWriter, status int, apiErr apiError) {
+	sendJson(w, status, apiErrorResponse{Error: apiErr})
+}
+
+// Sends the validation errors of a form as a 422 Unprocessable Entity response
+func sendApiValidationError(w http.ResponseWriter, form *forms.Form) {
+	sendApiError(w, http.StatusUnprocessableEntity, apiError{
+		Code: "validation_failed",
+		Message: "Some fields are invalid",
+		Fields: form.Errors,
+	})
+}
+
+// Logs an unexpected error and sends a 500 Internal Server Error response which doesn't leak its details
+func (repo *Repository) sendApiServerError(w http.ResponseWriter, err error) {
+	repo.App.ErrorLog.Println(err)
+
+	sendApiError(w, http.StatusInternalServerError, apiError{
+		Code: "internal_error",
+		Message: "Internal Server Error",
+	})
+}
+
+// Writes a value as JSON with the given status code
+func sendJson(w http.ResponseWriter, status int, value interface{}) {
+	out, err := json.MarshalIndent(value, "", "    ")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(out)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Reservation body for the given room and dates
+func apiReservationBody(roomID int, startDate, endDate string) string {
+	return fmt.Sprintf(
+		`{"room_id": %d, "first_name": "John", "last_name": "Smith", "email": "john@smith.com", "phone": "555-555-5555", "start_date": "%s", "end_date": "%s"}`,
+		roomID,
+		startDate,
+		endDate,
+	)
+}
+
+var apiTests = []struct {
+	name               string
+	method             string
+	url                string
+	body               string
+	expectedStatusCode int
+	expectedErrorCode  string
+}{
+	{"Lists rooms", "GET", "/api/v1/rooms", "", http.StatusOK, ""},
+	{"Rooms with wrong method", "DELETE", "/api/v1/rooms", "", http.StatusMethodNotAllowed, "method_not_allowed"},
+	{"Unknown route", "GET", "/api/v1/unknown", "", http.StatusNotFound, "not_found"},
+	{
+		"Gets availability",
+		"GET",
+		fmt.Sprintf("/api/v1/availability?start_date=%s&end_date=%s", futureStartDate, futureEndDate),
+		"",
+		http.StatusOK,
+		"",
+	},
+	{"Availability without dates", "GET", "/api/v1/availability", "", http.StatusUnprocessableEntity, "validation_failed"},
+	{"Availability with invalid date", "GET", "/api/v1/availability?start_date=01-01-2050&end_date=2050-01-02", "", http.StatusUnprocessableEntity, "validation_failed"},
+	{"Availability with departure before arrival", "GET", "/api/v1/availability?start_date=2050-01-05&end_date=2050-01-02", "", http.StatusUnprocessableEntity, "validation_failed"},
+	{"Availability in the past", "GET", "/api/v1/availability?start_date=2000-01-01&end_date=2000-01-02", "", http.StatusUnprocessableEntity, "validation_failed"},
+	{"Availability without adults", "GET", "/api/v1/availability?start_date=2050-01-01&end_date=2050-01-02&adults=0", "", http.StatusUnprocessableEntity, "validation_failed"},
+	{"Creates reservation", "POST", "/api/v1/reservations", apiReservationBody(1, futureStartDate, futureEndDate), http.StatusCreated, ""},
+	{"Reservation with invalid JSON", "POST", "/api/v1/reservations", `{"room_id": 1,`, http.StatusBadRequest, "invalid_json"},
+	{"Reservation with unknown field", "POST", "/api/v1/reservations", `{"room": 1}`, http.StatusBadRequest, "invalid_json"},
+	{"Reservation with missing fields", "POST", "/api/v1/reservations", `{"room_id": 1, "email": "not an email"}`, http.StatusUnprocessableEntity, "validation_failed"},
+	{"Reservation of non-existent room", "POST", "/api/v1/reservations", apiReservationBody(3, futureStartDate, futureEndDate), http.StatusNotFound, "room_not_found"},
+	{"Reservation of unavailable room", "POST", "/api/v1/reservations", apiReservationBody(1, "2100-01-01", "2100-01-03"), http.StatusConflict, "room_not_available"},
+	{
+		"Reservation for more guests than the room sleeps",
+		"POST",
+		"/api/v1/reservations",
+		strings.Replace(apiReservationBody(1, futureStartDate, futureEndDate), `"room_id": 1`, `"room_id": 1, "adults": 2, "children": 1`, 1),
+		http.StatusUnprocessableEntity,
+		"too_many_guests",
+	},
+	{"Reservation breaking stay rules", "POST", "/api/v1/reservations", apiReservationBody(1, "2049-12-24", "2049-12-28"), http.StatusUnprocessableEntity, "stay_not_allowed"},
+	{"Reservation insert failed", "POST", "/api/v1/reservations", apiReservationBody(2, futureStartDate, futureEndDate), http.StatusInternalServerError, "internal_error"},
+	{"Gets reservation", "GET", "/api/v1/reservations/abcdefghjk?email=john@smith.com", "", http.StatusOK, ""},
+	{"Reservation without email", "GET", "/api/v1/reservations/ABCDEFGHJK", "", http.StatusUnprocessableEntity, "validation_failed"},
+	{"Reservation with wrong code", "GET", "/api/v1/reservations/ZZZZZZZZZZ?email=john@smith.com", "", http.StatusNotFound, "reservation_not_found"},
+}
+
+func TestApi(t *testing.T) {
+	routes := getRoutes()
+
+	testServer := httptest.NewServer(routes)
+	defer testServer.Close()
+
+	for _, test := range apiTests {
+		req, err := http.NewRequest(test.method, testServer.URL + test.url, strings.NewReader(test.body))
+		if err != nil {
+			log.Println(err)
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := testServer.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var body struct {
+			Data  json.RawMessage `json:"data"`
+			Error apiError        `json:"error"`
+		}
+
+		err = json.NewDecoder(res.Body).Decode(&body)
+		res.Body.Close()
+		if err != nil {
+			t.Errorf("Test %s did not return JSON: %s", test.name, err)
+			continue
+		}
+
+		if res.StatusCode != test.expectedStatusCode {
+			t.Errorf(
+				"Test %s returns wrong response status code: got %d, wanted %d",
+				test.name,
+				res.StatusCode,
+				test.expectedStatusCode,
+			)
+		}
+
+		if body.Error.Code != test.expectedErrorCode {
+			t.Errorf("Test %s returns wrong error code: got %q, wanted %q", test.name, body.Error.Code, test.expectedErrorCode)
+		}
+
+		if test.expectedErrorCode == "" && len(body.Data) == 0 {
+			t.Errorf("Test %s did not return any data", test.name)
+		}
+	}
+}
+
+func TestApi_ValidationErrorFields(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/v1/reservations", strings.NewReader(`{"room_id": 1, "first_name": "J", "email": "john"}`))
+	responseRecorder := httptest.NewRecorder()
+
+	getRoutes().ServeHTTP(responseRecorder, req)
+
+	var body apiErrorResponse
+	err := json.NewDecoder(responseRecorder.Body).Decode(&body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{"first_name", "last_name", "email", "start_date", "end_date"} {
+		if len(body.Error.Fields[field]) == 0 {
+			t.Errorf("Expected a validation error for %s", field)
+		}
+	}
+}
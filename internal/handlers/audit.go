@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+)
+
+// Number of entries shown on the audit log page
+const auditLogPageSize = 200
+
+// Number of entries shown in the history of a reservation
+const reservationHistorySize = 50
+
+// AdminAuditLog is the audit log page handler in the admin dashboard.
+// The log can be filtered by entity, user and date with query parameters
+func (repo *Repository) AdminAuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	form := forms.New(query)
+
+	filter := models.AuditFilter{
+		EntityType: query.Get("entity"),
+		Limit: auditLogPageSize,
+	}
+
+	if filter.EntityType != "" && !isAuditEntity(filter.EntityType) {
+		form.Errors.Add("entity", "Choose one of the entities")
+	}
+
+	for field, value := range map[string]*int{"entity_id": &filter.EntityID, "user": &filter.UserID} {
+		if query.Get(field) == "" {
+			continue
+		}
+
+		id, err := strconv.Atoi(query.Get(field))
+		if err != nil || id <= 0 {
+			form.Errors.Add(field, "Enter a valid id")
+			continue
+		}
+
+		*value = id
+	}
+
+	for field, value := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if query.Get(field) == "" {
+			continue
+		}
+
+		date, err := time.Parse("2006-01-02", query.Get(field))
+		if err != nil {
+			form.Errors.Add(field, "Enter dates as YYYY-MM-DD")
+			continue
+		}
+
+		*value = date
+	}
+
+	var entries []models.AuditEntry
+
+	// An invalid filter shows no entries rather than more than asked for
+	if form.IsValid() {
+		var err error
+
+		entries, err = repo.DB.GetAuditEntries(filter)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	users, err := repo.DB.GetAllUsers()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	stringMap := make(map[string]string)
+	for _, field := range []string{"entity", "entity_id", "user", "from", "to"} {
+		stringMap[field] = query.Get(field)
+	}
+
+	data := make(map[string]interface{})
+	data["entries"] = entries
+	data["entities"] = audit.Entities
+	data["users"] = users
+
+	render.RenderTemplate(w, r, "admin-audit-log.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data: data,
+		Form: form,
+	})
+}
+
+// User as recorded in the audit log. Passwords and two-factor secrets are left out
+type auditUser struct {
+	FirstName string `json:"first_name"`
+	LastName string `json:"last_name"`
+	Email string `json:"email"`
+	Role string `json:"role"`
+	Disabled bool `json:"disabled"`
+	PasswordResetRequired bool `json:"password_reset_required"`
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
+}
+
+// Converts a user into its audit log representation
+func newAuditUser(user models.User) auditUser {
+	return auditUser{
+		FirstName: user.FirstName,
+		LastName: user.LastName,
+		Email: user.Email,
+		Role: user.RoleName(),
+		Disabled: user.Disabled,
+		PasswordResetRequired: user.PasswordResetRequired,
+		TwoFactorEnabled: user.TwoFactorEnabled,
+	}
+}
+
+// Stay rule as recorded in the audit log
+type auditStayRule struct {
+	RoomID int `json:"room_id"`
+	Name string `json:"name"`
+	StartDate string `json:"start_date"`
+	EndDate string `json:"end_date"`
+	MinNights int `json:"min_nights"`
+	MaxNights int `json:"max_nights"`
+	ClosedToArrival bool `json:"closed_to_arrival"`
+	ClosedToDeparture bool `json:"closed_to_departure"`
+	ArrivalDays string `json:"arrival_days"`
+}
+
+// Converts a stay rule into its audit log representation. The check-in days are listed by name
+func newAuditStayRule(rule models.StayRule) auditStayRule {
+	var days []string
+	for _, day := range rule.ArrivalWeekdays() {
+		days = append(days, day.String())
+	}
+
+	return auditStayRule{
+		RoomID: rule.RoomID,
+		Name: rule.Name,
+		StartDate: rule.StartDate.Format("2006-01-02"),
+		EndDate: rule.EndDate.Format("2006-01-02"),
+		MinNights: rule.MinNights,
+		MaxNights: rule.MaxNights,
+		ClosedToArrival: rule.ClosedToArrival,
+		ClosedToDeparture: rule.ClosedToDeparture,
+		ArrivalDays: strings.Join(days, ", "),
+	}
+}
+
+// API key as recorded in the audit log. The key itself is never stored
+type auditApiKey struct {
+	Name string `json:"name"`
+	Scopes string `json:"scopes"`
+	RateLimit int `json:"rate_limit"`
+	Revoked bool `json:"revoked"`
+}
+
+// Converts an API key into its audit log representation
+func newAuditApiKey(key models.ApiKey) auditApiKey {
+	return auditApiKey{
+		Name: key.Name,
+		Scopes: key.Scopes,
+		RateLimit: key.RateLimit,
+		Revoked: key.Revoked,
+	}
+}
+
+// Webhook endpoint as recorded in the audit log. The signing secret is left out
+type auditWebhook struct {
+	URL string `json:"url"`
+	Description string `json:"description"`
+	Events string `json:"events"`
+	Active bool `json:"active"`
+}
+
+// Converts a webhook endpoint into its audit log representation
+func newAuditWebhook(endpoint models.WebhookEndpoint) auditWebhook {
+	return auditWebhook{
+		URL: endpoint.URL,
+		Description: endpoint.Description,
+		Events: endpoint.Events,
+		Active: endpoint.Active,
+	}
+}
+
+// Calendar import source as recorded in the audit log
+type auditCalendarImport struct {
+	RoomID int `json:"room_id"`
+	Name string `json:"name"`
+	URL string `json:"url"`
+}
+
+// Converts a calendar import source into its audit log representation
+func newAuditCalendarImport(source models.CalendarImportSource) auditCalendarImport {
+	return auditCalendarImport{
+		RoomID: source.RoomID,
+		Name: source.Name,
+		URL: source.URL,
+	}
+}
+
+// Records a change made with the request in the audit log, under the logged in user or the API key of the request.
+// The change has already been made, so failing to record it is only logged
+func (repo *Repository) audit(r *http.Request, action, entityType string, entityID int, before, after interface{}) {
+	entry := models.AuditEntry{
+		Action: action,
+		EntityType: entityType,
+		EntityID: entityID,
+		IPAddress: helpers.ClientIP(r),
+	}
+
+	if user, ok := helpers.CurrentUser(r); ok {
+		entry.UserID = user.ID
+	}
+
+	if key, ok := helpers.CurrentApiKey(r); ok {
+		entry.ApiKeyID = key.ID
+	}
+
+	err := audit.Record(repo.DB, entry, before, after)
+	if err != nil {
+		repo.App.ErrorLog.Println("Can't record audit entry:", err)
+	}
+}
+
+// Reports whether changes of the given entity are recorded
+func isAuditEntity(entityType string) bool {
+	for _, entity := range audit.Entities {
+		if entity == entityType {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/ratelimit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// Test repository which keeps the audit entries instead of dropping them
+type auditRecorder struct {
+	repository.DatabaseRepository
+	entries []models.AuditEntry
+}
+
+func (recorder *auditRecorder) InsertAuditEntry(entry models.AuditEntry) error {
+	recorder.entries = append(recorder.entries, entry)
+
+	return nil
+}
+
+// Records the audit entries of the repository for the duration of a test
+func recordAuditEntries(t *testing.T) *auditRecorder {
+	previous := Repo.DB
+	t.Cleanup(func() { Repo.DB = previous })
+
+	recorder := &auditRecorder{DatabaseRepository: previous}
+	Repo.DB = recorder
+
+	return recorder
+}
+
+// Returns the change of a field in an audit entry
+func findAuditChange(entry models.AuditEntry, field string) (models.AuditChange, bool) {
+	for _, change := range entry.Changes {
+		if change.Field == field {
+			return change, true
+		}
+	}
+
+	return models.AuditChange{}, false
+}
+
+func TestRepository_AdminPostShowReservation_Audit(t *testing.T) {
+	recorder := recordAuditEntries(t)
+
+	body := url.Values{
+		"first_name": {"John"},
+		"last_name": {"Smith"},
+		"email": {"john@smith.com"},
+		"phone": {"555-555-5555"},
+	}
+
+	req, err := http.NewRequest("POST", "/admin/reservations/all/1", strings.NewReader(body.Encode()))
+	if err != nil {
+		log.Println(err)
+	}
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("src", "all")
+	rctx.URLParams.Add("id", "1")
+
+	ctx := getRequestContext(req)
+	ctx = helpers.ContextWithUser(ctx, models.User{ID: 2, AccessLevel: models.AccessFrontDesk})
+	req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = "192.0.2.1:50000"
+
+	responseRecorder := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.AdminPostShowReservation)
+	handler.ServeHTTP(responseRecorder, req)
+
+	if len(recorder.entries) != 1 {
+		t.Fatalf("Updating a reservation recorded %d audit entries, wanted 1", len(recorder.entries))
+	}
+
+	entry := recorder.entries[0]
+	if entry.UserID != 2 || entry.Action != audit.ActionUpdated || entry.EntityType != audit.EntityReservation || entry.EntityID != 1 || entry.IPAddress != "192.0.2.1" {
+		t.Errorf("Wrong audit entry: %+v", entry)
+	}
+
+	change, ok := findAuditChange(entry, "phone")
+	if !ok || change.Before != "" || change.After != "555-555-5555" {
+		t.Errorf("Wrong change of the phone: %+v", change)
+	}
+
+	if _, ok := findAuditChange(entry, "room_id"); ok {
+		t.Error("Fields which didn't change should not be recorded")
+	}
+}
+
+func TestRepository_AdminDisableUser_Audit(t *testing.T) {
+	recorder := recordAuditEntries(t)
+
+	req, err := http.NewRequest("POST", "/admin/users/2/disable", nil)
+	if err != nil {
+		log.Println(err)
+	}
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "2")
+
+	ctx := getRequestContext(req)
+	ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: models.AccessOwner})
+	req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+
+	responseRecorder := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.AdminDisableUser)
+	handler.ServeHTTP(responseRecorder, req)
+
+	if len(recorder.entries) != 1 {
+		t.Fatalf("Disabling a user recorded %d audit entries, wanted 1", len(recorder.entries))
+	}
+
+	entry := recorder.entries[0]
+	if entry.UserID != 1 || entry.Action != audit.ActionDisabled || entry.EntityType != audit.EntityUser || entry.EntityID != 2 {
+		t.Errorf("Wrong audit entry: %+v", entry)
+	}
+
+	if len(entry.Changes) != 1 || entry.Changes[0] != (models.AuditChange{Field: "disabled", Before: "false", After: "true"}) {
+		t.Errorf("Wrong changes: %+v", entry.Changes)
+	}
+}
+
+func TestRepository_Audit_ApiKey(t *testing.T) {
+	recorder := recordAuditEntries(t)
+
+	req, _ := http.NewRequest("DELETE", "/api/v1/admin/blocks/1", nil)
+	req = req.WithContext(helpers.ContextWithApiKey(req.Context(), models.ApiKey{ID: 3}))
+
+	Repo.audit(req, audit.ActionDeleted, audit.EntityBlock, 1, map[string]int{"room_id": 1}, nil)
+
+	if len(recorder.entries) != 1 || recorder.entries[0].ApiKeyID != 3 || recorder.entries[0].UserID != 0 {
+		t.Errorf("Changes through the admin API should be recorded under the API key: %+v", recorder.entries)
+	}
+}
+
+func TestAdminApiPostBlock_Audit(t *testing.T) {
+	recorder := recordAuditEntries(t)
+	apiKeyLimiter = ratelimit.New()
+
+	req, err := http.NewRequest("POST", "/api/v1/admin/blocks", strings.NewReader(`{"room_id": 1, "date": "2050-01-01"}`))
+	if err != nil {
+		log.Println(err)
+	}
+	req.Header.Set("Authorization", "Bearer write-key")
+
+	responseRecorder := httptest.NewRecorder()
+	getRoutes().ServeHTTP(responseRecorder, req)
+
+	// The creation has to be found under the same id as the deletion of the block
+	if len(recorder.entries) != 1 || recorder.entries[0].EntityType != audit.EntityBlock || recorder.entries[0].EntityID != 4 {
+		t.Errorf("Creating a block recorded wrong audit entries: %+v", recorder.entries)
+	}
+}
+
+func TestRepository_AdminProcessReservation_Audit(t *testing.T) {
+	for id, expectedEntries := range map[string]int{"1": 1, "7": 0} {
+		recorder := recordAuditEntries(t)
+
+		req, err := http.NewRequest("GET", "/admin/process-reservation/all/" + id, nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("src", "all")
+		rctx.URLParams.Add("id", id)
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: models.AccessOwner})
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminProcessReservation)
+		handler.ServeHTTP(responseRecorder, req)
+
+		// Reservation 7 is already processed, so nothing changes
+		if len(recorder.entries) != expectedEntries {
+			t.Errorf("Processing reservation %s recorded %d audit entries, wanted %d", id, len(recorder.entries), expectedEntries)
+		}
+	}
+}
+
+func TestRepository_AdminApiKeys_Audit(t *testing.T) {
+	recorder := recordAuditEntries(t)
+
+	body := url.Values{"name": {"Channel manager"}, "rate_limit": {"60"}, "scopes": {"reservations:write"}}
+
+	req, err := http.NewRequest("POST", "/admin/api-keys", strings.NewReader(body.Encode()))
+	if err != nil {
+		log.Println(err)
+	}
+
+	ctx := getRequestContext(req)
+	ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: models.AccessOwner})
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	handler := http.HandlerFunc(Repo.AdminPostApiKeys)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Revoke a key which is in use and one which has already been revoked
+	for _, id := range []string{"2", "3"} {
+		req, err = http.NewRequest("POST", "/admin/api-keys/" + id + "/revoke", nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+
+		ctx = getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: models.AccessOwner})
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+
+		handler = http.HandlerFunc(Repo.AdminRevokeApiKey)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(recorder.entries) != 2 {
+		t.Fatalf("API keys recorded %d audit entries, wanted 2: %+v", len(recorder.entries), recorder.entries)
+	}
+
+	created := recorder.entries[0]
+	if created.Action != audit.ActionCreated || created.EntityType != audit.EntityApiKey || created.EntityID != 4 {
+		t.Errorf("Wrong audit entry: %+v", created)
+	}
+
+	if change, ok := findAuditChange(created, "scopes"); !ok || change.After != "reservations:write" {
+		t.Errorf("Creating an API key should record its scopes: %+v", created.Changes)
+	}
+
+	// The key itself must never end up in the log
+	if _, ok := findAuditChange(created, "key_hash"); ok {
+		t.Errorf("Creating an API key recorded its hash: %+v", created.Changes)
+	}
+
+	revoked := recorder.entries[1]
+	if revoked.Action != audit.ActionRevoked || revoked.EntityID != 2 {
+		t.Errorf("Wrong audit entry: %+v", revoked)
+	}
+}
+
+func TestRepository_PostMyReservationCancel_Audit(t *testing.T) {
+	recorder := recordAuditEntries(t)
+
+	req, err := http.NewRequest("POST", "/my-reservation/cancel", nil)
+	if err != nil {
+		log.Println(err)
+	}
+
+	ctx := getRequestContext(req)
+	req = req.WithContext(ctx)
+	session.Put(ctx, "guest_reservation_id", 2)
+
+	responseRecorder := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.PostMyReservationCancel)
+	handler.ServeHTTP(responseRecorder, req)
+
+	if len(recorder.entries) != 1 {
+		t.Fatalf("Cancelling a reservation recorded %d audit entries, wanted 1", len(recorder.entries))
+	}
+
+	// Guests have no user, so the entry must not name one
+	entry := recorder.entries[0]
+	if entry.UserID != 0 || entry.ApiKeyID != 0 || entry.Action != audit.ActionCancelled || entry.EntityType != audit.EntityReservation || entry.EntityID != 2 {
+		t.Errorf("Wrong audit entry: %+v", entry)
+	}
+
+	change, ok := findAuditChange(entry, "cancelled")
+	if !ok || change.Before != "false" || change.After != "true" {
+		t.Errorf("Wrong changes: %+v", entry.Changes)
+	}
+}
+
+var adminAuditLogTests = []struct {
+	name         string
+	query        string
+	expectedHTML []string
+	missingHTML  []string
+}{
+	{"All entries", "", []string{"555-0199", "Admin User"}, nil},
+	{"Filtered by entity", "?entity=user", []string{"disabled"}, []string{"555-0199"}},
+	{"Filtered by user and date", "?user=1&from=2021-12-01&to=2021-12-31", []string{"Admin User"}, nil},
+	{"Unknown entity", "?entity=invoice", []string{"Choose one of the entities", "No changes recorded"}, nil},
+	{"Invalid date", "?from=yesterday", []string{"Enter dates as YYYY-MM-DD", "No changes recorded"}, nil},
+}
+
+func TestRepository_AdminAuditLog(t *testing.T) {
+	for _, test := range adminAuditLogTests {
+		req, err := http.NewRequest("GET", "/admin/audit-log"+test.query, nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: models.AccessOwner})
+		req = req.WithContext(ctx)
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminAuditLog)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != http.StatusOK {
+			t.Errorf("Test %s returns wrong response status code: got %d", test.name, responseRecorder.Code)
+		}
+
+		html := responseRecorder.Body.String()
+		for _, expected := range test.expectedHTML {
+			if !strings.Contains(html, expected) {
+				t.Errorf("Test %s does not show %s", test.name, expected)
+			}
+		}
+
+		for _, missing := range test.missingHTML {
+			if strings.Contains(html, missing) {
+				t.Errorf("Test %s should not show %s", test.name, missing)
+			}
+		}
+	}
+}
+
+func TestRepository_AdminShowReservation_History(t *testing.T) {
+	for accessLevel, expectHistory := range map[int]bool{models.AccessOwner: true, models.AccessFrontDesk: false} {
+		req, err := http.NewRequest("GET", "/admin/reservations/all/1", nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("src", "all")
+		rctx.URLParams.Add("id", "1")
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: accessLevel})
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminShowReservation)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if strings.Contains(responseRecorder.Body.String(), "555-0199") != expectHistory {
+			t.Errorf("Access level %d has wrong reservation history, wanted shown: %t", accessLevel, expectHistory)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/webhooks"
+	"github.com/go-chi/chi/v5"
+)
+
+// Owner block of several nights as shown in the reservations calendar. The block is shown in the cell
+// of its first day in the month, spanning `Days` days. The other days of the block are `Covered`, so
+// that no cell is shown for them
+type blockSpan struct {
+	Block models.RoomRestriction
+	Days int
+	Covered bool
+}
+
+// Reason offered in the block form
+type blockReasonOption struct {
+	Value string
+	Name string
+}
+
+// AdminBlocks is the owner blocks page handler in the admin dashboard. Blocks which have ended aren't listed
+func (repo *Repository) AdminBlocks(w http.ResponseWriter, r *http.Request) {
+	blocks, err := repo.DB.GetOwnerBlocks(time.Now())
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["blocks"] = blocks
+
+	render.RenderTemplate(w, r, "admin-blocks.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminNewBlock is the new owner block page handler in the admin dashboard.
+// The room and dates can be filled in with the `room`, `start` and `end` query parameters
+func (repo *Repository) AdminNewBlock(w http.ResponseWriter, r *http.Request) {
+	rooms, err := repo.DB.GetAllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	block := models.RoomRestriction{Reason: models.BlockReasonMaintenance}
+	block.RoomID, _ = strconv.Atoi(r.URL.Query().Get("room"))
+
+	stringMap := newBlockStringMap()
+	stringMap["start_date"] = r.URL.Query().Get("start")
+	stringMap["end_date"] = r.URL.Query().Get("end")
+
+	renderBlockForm(w, r, stringMap, forms.New(nil), block, rooms)
+}
+
+// Handler to create an owner block with received form data
+func (repo *Repository) AdminPostNewBlock(w http.ResponseWriter, r *http.Request) {
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := repo.DB.GetAllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	block := models.RoomRestriction{RestrictionID: models.RestrictionOwnerBlock}
+	form := validateBlockForm(r, &block, rooms)
+
+	stringMap := newBlockStringMap()
+	stringMap["start_date"] = r.Form.Get("start_date")
+	stringMap["end_date"] = r.Form.Get("end_date")
+
+	// Rerender block form with updated error information
+	if !form.IsValid() {
+		renderBlockForm(w, r, stringMap, form, block, rooms)
+		return
+	}
+
+	block.ID, err = repo.DB.InsertOwnerBlock(block)
+	if errors.Is(err, repository.ErrRoomNotAvailable) {
+		form.Errors.Add("start_date", "The room is already booked or blocked on some of these dates")
+		renderBlockForm(w, r, stringMap, form, block, rooms)
+		return
+	}
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.sendWebhook(webhooks.EventBlockCreated, newApiBlockFromRestriction(block))
+	repo.audit(r, audit.ActionCreated, audit.EntityBlock, block.ID, nil, newApiBlockFromRestriction(block))
+
+	repo.App.Session.Put(r.Context(), "success", "Block created")
+	http.Redirect(w, r, "/admin/blocks", http.StatusSeeOther)
+}
+
+// AdminShowBlock is the edit owner block page handler in the admin dashboard
+func (repo *Repository) AdminShowBlock(w http.ResponseWriter, r *http.Request) {
+	block, ok := repo.ownerBlockFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	rooms, err := repo.DB.GetAllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	stringMap := editBlockStringMap(block.ID)
+	stringMap["start_date"] = block.StartDate.Format("2006-01-02")
+	stringMap["end_date"] = block.EndDate.Format("2006-01-02")
+
+	renderBlockForm(w, r, stringMap, forms.New(nil), block, rooms)
+}
+
+// Handler to update the room, dates, reason and note of an owner block with received form data
+func (repo *Repository) AdminPostShowBlock(w http.ResponseWriter, r *http.Request) {
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	block, ok := repo.ownerBlockFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	rooms, err := repo.DB.GetAllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	before := newApiBlockFromRestriction(block)
+	form := validateBlockForm(r, &block, rooms)
+
+	stringMap := editBlockStringMap(block.ID)
+	stringMap["start_date"] = r.Form.Get("start_date")
+	stringMap["end_date"] = r.Form.Get("end_date")
+
+	// Rerender block form with updated error information
+	if !form.IsValid() {
+		renderBlockForm(w, r, stringMap, form, block, rooms)
+		return
+	}
+
+	err = repo.DB.UpdateOwnerBlock(block)
+	if errors.Is(err, repository.ErrRoomNotAvailable) {
+		form.Errors.Add("start_date", "The room is already booked or blocked on some of these dates")
+		renderBlockForm(w, r, stringMap, form, block, rooms)
+		return
+	}
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.sendWebhook(webhooks.EventBlockUpdated, newApiBlockFromRestriction(block))
+	repo.audit(r, audit.ActionUpdated, audit.EntityBlock, block.ID, before, newApiBlockFromRestriction(block))
+
+	repo.App.Session.Put(r.Context(), "success", "Block updated")
+	http.Redirect(w, r, "/admin/blocks", http.StatusSeeOther)
+}
+
+// Handler to delete an owner block
+func (repo *Repository) AdminDeleteBlock(w http.ResponseWriter, r *http.Request) {
+	block, ok := repo.ownerBlockFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	err := repo.DB.DeleteBlockByID(block.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.sendWebhook(webhooks.EventBlockDeleted, newApiBlockFromRestriction(block))
+	repo.audit(r, audit.ActionDeleted, audit.EntityBlock, block.ID, newApiBlockFromRestriction(block), nil)
+
+	repo.App.Session.Put(r.Context(), "success", "Block deleted")
+	http.Redirect(w, r, "/admin/blocks", http.StatusSeeOther)
+}
+
+// Returns the owner block with the id given in the URL. Responds with 404 Not Found if there is no such
+// block, since restrictions of reservations and external bookings can't be changed as blocks
+func (repo *Repository) ownerBlockFromURL(w http.ResponseWriter, r *http.Request) (models.RoomRestriction, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return models.RoomRestriction{}, false
+	}
+
+	block, err := repo.DB.GetRoomRestrictionByID(id)
+	if err != nil || block.RestrictionID != models.RestrictionOwnerBlock {
+		helpers.ClientError(w, http.StatusNotFound)
+		return models.RoomRestriction{}, false
+	}
+
+	return block, true
+}
+
+// Stores the block form data in `block` and validates it. The room has to be one of `rooms`
+func validateBlockForm(r *http.Request, block *models.RoomRestriction, rooms []models.Room) *forms.Form {
+	block.RoomID, _ = strconv.Atoi(r.Form.Get("room_id"))
+	block.Reason = r.Form.Get("reason")
+	block.Note = strings.TrimSpace(r.Form.Get("note"))
+
+	form := forms.New(r.PostForm)
+	form.RequiredFields("room_id", "start_date", "end_date", "reason")
+
+	if !isRoom(block.RoomID, rooms) {
+		form.Errors.Add("room_id", "Choose one of the rooms")
+	}
+
+	if !isBlockReason(block.Reason) {
+		form.Errors.Add("reason", "Choose one of the reasons")
+	}
+
+	startDate, startErr := time.Parse("2006-01-02", r.Form.Get("start_date"))
+	if startErr != nil {
+		form.Errors.Add("start_date", "Enter dates as YYYY-MM-DD")
+	}
+
+	endDate, endErr := time.Parse("2006-01-02", r.Form.Get("end_date"))
+	if endErr != nil {
+		form.Errors.Add("end_date", "Enter dates as YYYY-MM-DD")
+	}
+
+	if startErr == nil && endErr == nil && !endDate.After(startDate) {
+		form.Errors.Add("end_date", "The end date must be after the start date")
+	}
+
+	block.StartDate = startDate
+	block.EndDate = endDate
+
+	return form
+}
+
+// Renders the owner block form of the admin dashboard
+func renderBlockForm(w http.ResponseWriter, r *http.Request, stringMap map[string]string, form *forms.Form, block models.RoomRestriction, rooms []models.Room) {
+	var reasons []blockReasonOption
+	for _, reason := range models.BlockReasons {
+		reasons = append(reasons, blockReasonOption{Value: reason, Name: models.BlockReasonName(reason)})
+	}
+
+	data := make(map[string]interface{})
+	data["block"] = block
+	data["rooms"] = rooms
+	data["reasons"] = reasons
+
+	render.RenderTemplate(w, r, "admin-block.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Form: form,
+		Data: data,
+	})
+}
+
+// Title and form action of the new block page
+func newBlockStringMap() map[string]string {
+	stringMap := make(map[string]string)
+	stringMap["title"] = "New Block"
+	stringMap["action"] = "/admin/blocks/new"
+
+	return stringMap
+}
+
+// Title and form action of the edit block page
+func editBlockStringMap(id int) map[string]string {
+	stringMap := make(map[string]string)
+	stringMap["title"] = "Edit Block"
+	stringMap["action"] = fmt.Sprintf("/admin/blocks/%d", id)
+	stringMap["delete"] = fmt.Sprintf("/admin/blocks/%d/delete", id)
+
+	return stringMap
+}
+
+// Reports whether the id is the id of one of the rooms
+func isRoom(id int, rooms []models.Room) bool {
+	for _, room := range rooms {
+		if room.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reports whether the reason is one of the block reasons
+func isBlockReason(reason string) bool {
+	for _, blockReason := range models.BlockReasons {
+		if blockReason == reason {
+			return true
+		}
+	}
+
+	return false
+}
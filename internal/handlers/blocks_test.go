@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+var adminPostBlockTests = []struct {
+	name               string
+	url                string
+	id                 string
+	body               url.Values
+	expectedStatusCode int
+	expectedHTML       string
+}{
+	{
+		"New block",
+		"/admin/blocks/new",
+		"",
+		url.Values{"room_id": {"1"}, "start_date": {"2050-01-01"}, "end_date": {"2050-01-15"}, "reason": {"maintenance"}, "note": {"New bathroom"}},
+		http.StatusSeeOther,
+		"",
+	},
+	{
+		"End date before start date",
+		"/admin/blocks/new",
+		"",
+		url.Values{"room_id": {"1"}, "start_date": {"2050-01-15"}, "end_date": {"2050-01-15"}, "reason": {"maintenance"}},
+		http.StatusOK,
+		"The end date must be after the start date",
+	},
+	{
+		"Invalid date",
+		"/admin/blocks/new",
+		"",
+		url.Values{"room_id": {"1"}, "start_date": {"tomorrow"}, "end_date": {"2050-01-15"}, "reason": {"maintenance"}},
+		http.StatusOK,
+		"Enter dates as YYYY-MM-DD",
+	},
+	{
+		"Unknown reason",
+		"/admin/blocks/new",
+		"",
+		url.Values{"room_id": {"1"}, "start_date": {"2050-01-01"}, "end_date": {"2050-01-15"}, "reason": {"holiday"}},
+		http.StatusOK,
+		"Choose one of the reasons",
+	},
+	{
+		"Unknown room",
+		"/admin/blocks/new",
+		"",
+		url.Values{"room_id": {"9"}, "start_date": {"2050-01-01"}, "end_date": {"2050-01-15"}, "reason": {"maintenance"}},
+		http.StatusOK,
+		"Choose one of the rooms",
+	},
+	{
+		"Dates not available",
+		"/admin/blocks/new",
+		"",
+		url.Values{"room_id": {"1"}, "start_date": {"2100-01-01"}, "end_date": {"2100-01-15"}, "reason": {"deep_clean"}},
+		http.StatusOK,
+		"The room is already booked or blocked on some of these dates",
+	},
+	{
+		"Updated block",
+		"/admin/blocks/3",
+		"3",
+		url.Values{"room_id": {"1"}, "start_date": {"2050-01-01"}, "end_date": {"2050-01-03"}, "reason": {"personal_use"}},
+		http.StatusSeeOther,
+		"",
+	},
+	{
+		"Updated block on dates not available",
+		"/admin/blocks/3",
+		"3",
+		url.Values{"room_id": {"1"}, "start_date": {"2100-01-01"}, "end_date": {"2100-01-03"}, "reason": {"personal_use"}},
+		http.StatusOK,
+		"The room is already booked or blocked on some of these dates",
+	},
+	{
+		"Updated reservation",
+		"/admin/blocks/2",
+		"2",
+		url.Values{"room_id": {"1"}, "start_date": {"2050-01-01"}, "end_date": {"2050-01-03"}, "reason": {"personal_use"}},
+		http.StatusNotFound,
+		"",
+	},
+}
+
+func TestRepository_AdminPostBlock(t *testing.T) {
+	for _, test := range adminPostBlockTests {
+		req, err := http.NewRequest("POST", test.url, strings.NewReader(test.body.Encode()))
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: models.AccessOwner})
+
+		handler := http.HandlerFunc(Repo.AdminPostNewBlock)
+		if test.id != "" {
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", test.id)
+			ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+
+			handler = http.HandlerFunc(Repo.AdminPostShowBlock)
+		}
+
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		responseRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf("Test %s returns wrong response status code: got %d, wanted %d", test.name, responseRecorder.Code, test.expectedStatusCode)
+		}
+
+		if test.expectedStatusCode == http.StatusSeeOther {
+			redirectURL, _ := responseRecorder.Result().Location()
+			if redirectURL == nil || redirectURL.String() != "/admin/blocks" {
+				t.Errorf("Test %s redirects to wrong URL: got %v", test.name, redirectURL)
+			}
+		}
+
+		if test.expectedHTML != "" && !strings.Contains(responseRecorder.Body.String(), test.expectedHTML) {
+			t.Errorf("Test %s does not show %s", test.name, test.expectedHTML)
+		}
+	}
+}
+
+func TestRepository_AdminPostShowBlock_Audit(t *testing.T) {
+	recorder := recordAuditEntries(t)
+
+	body := url.Values{"room_id": {"1"}, "start_date": {"2050-01-01"}, "end_date": {"2050-01-03"}, "reason": {"personal_use"}, "note": {"New bathroom"}}
+
+	req, err := http.NewRequest("POST", "/admin/blocks/3", strings.NewReader(body.Encode()))
+	if err != nil {
+		log.Println(err)
+	}
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "3")
+
+	ctx := getRequestContext(req)
+	ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: models.AccessOwner})
+	req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	responseRecorder := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.AdminPostShowBlock)
+	handler.ServeHTTP(responseRecorder, req)
+
+	if len(recorder.entries) != 1 {
+		t.Fatalf("Updating a block recorded %d audit entries, wanted 1", len(recorder.entries))
+	}
+
+	entry := recorder.entries[0]
+	if entry.Action != audit.ActionUpdated || entry.EntityType != audit.EntityBlock || entry.EntityID != 3 {
+		t.Errorf("Wrong audit entry: %+v", entry)
+	}
+
+	change, ok := findAuditChange(entry, "reason")
+	if !ok || change.Before != models.BlockReasonMaintenance || change.After != models.BlockReasonPersonalUse {
+		t.Errorf("Wrong change of the reason: %+v", change)
+	}
+
+	if _, ok := findAuditChange(entry, "note"); ok {
+		t.Error("The note didn't change and should not be recorded")
+	}
+}
+
+func TestRepository_AdminReservationsCalendar_BlockSpan(t *testing.T) {
+	// The test repository has a block of 4 nights starting in 5 days
+	start := time.Now().AddDate(0, 0, 5)
+
+	req, err := http.NewRequest("GET", "/admin/reservations-calendar?y="+start.Format("2006")+"&m="+start.Format("01"), nil)
+	if err != nil {
+		log.Println(err)
+	}
+
+	ctx := getRequestContext(req)
+	ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: models.AccessOwner})
+	req = req.WithContext(ctx)
+
+	responseRecorder := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.AdminReservationsCalendar)
+	handler.ServeHTTP(responseRecorder, req)
+
+	html := responseRecorder.Body.String()
+	if !strings.Contains(html, `<a href="/admin/blocks/3">Maintenance</a>`) {
+		t.Error("Calendar should show the block of several nights as one span linking to the block form")
+	}
+
+	if strings.Contains(html, "remove_block_1_"+start.Format("2006-01-2")) {
+		t.Error("Days of a block of several nights should not be toggled one by one")
+	}
+
+	// Blocks of one night are still toggled in the calendar
+	blockMap := session.Get(ctx, "block_map_1").(map[string]int)
+	for date, id := range blockMap {
+		if id == 3 {
+			t.Errorf("Block of several nights should not be removable from the calendar on %s", date)
+		}
+	}
+}
@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/emails"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/pricing"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/webhooks"
+	"github.com/go-chi/chi/v5"
+)
+
+// Returns the booking the guest is putting together in the cart, which is empty if the guest
+// hasn't added any room yet
+func (repo *Repository) cartFromSession(r *http.Request) models.Booking {
+	cart, _ := repo.App.Session.Get(r.Context(), "cart").(models.Booking)
+	return cart
+}
+
+// Adds the room of the make reservation form to the cart, so that the guest can book several rooms at once
+func (repo *Repository) PostAddToCart(w http.ResponseWriter, r *http.Request) {
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't parse form")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Parse start and end dates received as form data
+	startDate, endDate, err := helpers.ParseDates(w, r.Form.Get("start_date"), r.Form.Get("end_date"))
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't parse dates")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	roomID, err := strconv.Atoi(r.Form.Get("room_id"))
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Invalid room id")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	room, err := repo.DB.GetRoomByID(roomID)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "can't find room!")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	if !room.Active {
+		repo.App.Session.Put(r.Context(), "error", "This room can't be booked at the moment")
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	adults, children, err := helpers.ParseGuests(r.Form.Get("adults"), r.Form.Get("children"))
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Enter at least one adult and no negative number of children")
+		http.Redirect(w, r, "/make-reservation", http.StatusSeeOther)
+		return
+	}
+
+	// Keep the room, dates and party size in the `Session` object, so that the guest can go back to the form
+	// or search other rooms for the same dates
+	reservation, _ := repo.App.Session.Get(r.Context(), "reservation").(models.Reservation)
+	reservation.RoomID = roomID
+	reservation.StartDate = startDate
+	reservation.EndDate = endDate
+	reservation.Adults = adults
+	reservation.Children = children
+	repo.App.Session.Put(r.Context(), "reservation", reservation)
+
+	if adults + children > room.Capacity {
+		repo.App.Session.Put(r.Context(), "error", fmt.Sprintf("This room sleeps at most %d guests", room.Capacity))
+		http.Redirect(w, r, "/make-reservation", http.StatusSeeOther)
+		return
+	}
+
+	available, err := repo.DB.SearchAvailabilityByDatesAndRoom(startDate, endDate, roomID)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't check availability of room")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	if !available {
+		repo.App.Session.Put(r.Context(), "warning", "Sorry, this room has just been booked for your dates. Please search again for available rooms")
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	reasons, err := repo.stayRuleReasons(roomID, startDate, endDate)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't get stay rules")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	if len(reasons) > 0 {
+		repo.App.Session.Put(r.Context(), "error", strings.Join(reasons, ". "))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	cart := repo.cartFromSession(r)
+
+	// The same room can be in the cart more than once, but not for overlapping dates
+	for _, line := range cart.Reservations {
+		if line.RoomID == roomID && line.StartDate.Before(endDate) && startDate.Before(line.EndDate) {
+			repo.App.Session.Put(r.Context(), "error", "This room is already in your cart for some of these dates")
+			http.Redirect(w, r, "/cart", http.StatusSeeOther)
+			return
+		}
+	}
+
+	plan, err := repo.DB.GetRatePlanForRoom(roomID)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't get room rates")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	quote := pricing.Calculate(plan, startDate, endDate)
+
+	cart.Reservations = append(cart.Reservations, models.Reservation{
+		StartDate: startDate,
+		EndDate: endDate,
+		RoomID: roomID,
+		Room: room,
+		Adults: adults,
+		Children: children,
+		TotalPrice: quote.Total,
+		Quote: quote,
+	})
+	cart.TotalPrice += cart.Reservations[len(cart.Reservations) - 1].TotalPrice
+
+	// Prefill the checkout form with the guest details already entered in the make reservation form
+	if firstName := r.Form.Get("first_name"); firstName != "" {
+		cart.FirstName = firstName
+	}
+	if lastName := r.Form.Get("last_name"); lastName != "" {
+		cart.LastName = lastName
+	}
+	if email := r.Form.Get("email"); email != "" {
+		cart.Email = email
+	}
+	if phone := r.Form.Get("phone"); phone != "" {
+		cart.Phone = phone
+	}
+
+	repo.App.Session.Put(r.Context(), "cart", cart)
+	repo.App.Session.Put(r.Context(), "success", "Room added to your cart")
+	http.Redirect(w, r, "/cart", http.StatusSeeOther)
+}
+
+// Shows the rooms in the cart and the form to book them all at once
+func (repo *Repository) Cart(w http.ResponseWriter, r *http.Request) {
+	data := make(map[string]interface{})
+	data["cart"] = repo.cartFromSession(r)
+
+	render.RenderTemplate(w, r, "cart.page.tmpl", &models.TemplateData{
+		Form: forms.New(nil),
+		Data: data,
+	})
+}
+
+// Removes the room with the index given in the URL from the cart
+func (repo *Repository) RemoveFromCart(w http.ResponseWriter, r *http.Request) {
+	cart := repo.cartFromSession(r)
+
+	index, err := strconv.Atoi(chi.URLParam(r, "index"))
+	if err != nil || index < 0 || index >= len(cart.Reservations) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	cart.TotalPrice -= cart.Reservations[index].TotalPrice
+	cart.Reservations = append(cart.Reservations[:index], cart.Reservations[index + 1:]...)
+
+	repo.App.Session.Put(r.Context(), "cart", cart)
+	repo.App.Session.Put(r.Context(), "success", "Room removed from your cart")
+	http.Redirect(w, r, "/cart", http.StatusSeeOther)
+}
+
+// Books all the rooms in the cart at once. Either every room is booked or none of them is
+func (repo *Repository) PostCheckout(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't parse form")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	cart := repo.cartFromSession(r)
+	if len(cart.Reservations) == 0 {
+		repo.App.Session.Put(r.Context(), "error", "Your cart is empty")
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	cart.FirstName = r.Form.Get("first_name")
+	cart.LastName = r.Form.Get("last_name")
+	cart.Email = r.Form.Get("email")
+	cart.Phone = r.Form.Get("phone")
+
+	form := forms.New(r.PostForm)
+	form.RequiredFields("first_name", "last_name", "email")
+	form.MinLength("first_name", 2)
+	form.IsEmail("email")
+
+	if !form.IsValid() {
+		data := make(map[string]interface{})
+		data["cart"] = cart
+
+		render.RenderTemplate(w, r, "cart.page.tmpl", &models.TemplateData{
+			Form: form,
+			Data: data,
+		})
+
+		return
+	}
+
+	// Check the stay rules and price of every room again, since they can change while the guest fills the cart
+	cart.TotalPrice = 0
+	for i, line := range cart.Reservations {
+		reasons, err := repo.stayRuleReasons(line.RoomID, line.StartDate, line.EndDate)
+		if err != nil {
+			repo.App.Session.Put(r.Context(), "error", "Can't get stay rules")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+
+		if len(reasons) > 0 {
+			repo.App.Session.Put(r.Context(), "error", fmt.Sprintf("%s: %s", line.Room.RoomName, strings.Join(reasons, ". ")))
+			http.Redirect(w, r, "/cart", http.StatusSeeOther)
+			return
+		}
+
+		plan, err := repo.DB.GetRatePlanForRoom(line.RoomID)
+		if err != nil {
+			repo.App.Session.Put(r.Context(), "error", "Can't get room rates")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+
+		cart.Reservations[i].Quote = pricing.Calculate(plan, line.StartDate, line.EndDate)
+		cart.Reservations[i].TotalPrice = cart.Reservations[i].Quote.Total
+		cart.TotalPrice += cart.Reservations[i].TotalPrice
+	}
+
+	// The booking and each of its reservations get their own confirmation code, so that the guest can
+	// still look up, change or cancel the stay in a single room
+	booking := cart
+	booking.Reservations = make([]models.Reservation, len(cart.Reservations))
+	booking.ConfirmationCode, err = helpers.GenerateConfirmationCode()
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't generate confirmation code")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	for i, line := range cart.Reservations {
+		line.FirstName = booking.FirstName
+		line.LastName = booking.LastName
+		line.Email = booking.Email
+		line.Phone = booking.Phone
+
+		line.ConfirmationCode, err = helpers.GenerateConfirmationCode()
+		if err != nil {
+			repo.App.Session.Put(r.Context(), "error", "Can't generate confirmation code")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+
+		booking.Reservations[i] = line
+	}
+
+	confirmationEmails, err := repo.bookingConfirmationEmails(booking)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't create confirmation emails")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Insert the booking, its reservations, their room restrictions and the confirmation emails into the database
+	bookingID, err := repo.DB.InsertBookingWithReservations(booking, confirmationEmails)
+	if errors.Is(err, repository.ErrRoomNotAvailable) {
+		repo.dropUnavailableFromCart(r, cart)
+		http.Redirect(w, r, "/cart", http.StatusSeeOther)
+		return
+	}
+
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't insert booking into the database")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	booking.ID = bookingID
+
+	// The reservations only get their ids when they are inserted
+	inserted, err := repo.DB.GetBookingByID(bookingID)
+	if err != nil {
+		repo.App.ErrorLog.Println("Can't get reservations of new booking:", err)
+	} else {
+		for _, reservation := range inserted.Reservations {
+			repo.sendWebhook(webhooks.EventReservationCreated, newApiAdminReservation(reservation))
+			repo.audit(r, audit.ActionCreated, audit.EntityReservation, reservation.ID, nil, newApiAdminReservation(reservation))
+		}
+	}
+
+	repo.App.Session.Remove(r.Context(), "cart")
+	repo.App.Session.Remove(r.Context(), "reservation")
+	repo.App.Session.Put(r.Context(), "booking", booking)
+
+	http.Redirect(w, r, "/booking-summary", http.StatusSeeOther)
+}
+
+// Removes the rooms which have been booked by someone else from the cart, after the booking of the cart failed.
+// The guest can then check out the remaining rooms or search for others
+func (repo *Repository) dropUnavailableFromCart(r *http.Request, cart models.Booking) {
+	var available []models.Reservation
+	var booked []string
+
+	for _, line := range cart.Reservations {
+		ok, err := repo.DB.SearchAvailabilityByDatesAndRoom(line.StartDate, line.EndDate, line.RoomID)
+		if err == nil && !ok {
+			cart.TotalPrice -= line.TotalPrice
+			booked = append(booked, fmt.Sprintf("%s from %s", line.Room.RoomName, line.StartDate.Format("2006-01-02")))
+			continue
+		}
+
+		available = append(available, line)
+	}
+
+	cart.Reservations = available
+	repo.App.Session.Put(r.Context(), "cart", cart)
+
+	if len(booked) == 0 {
+		repo.App.Session.Put(r.Context(), "warning", "Sorry, one of the rooms has just been booked. Please try again")
+		return
+	}
+
+	repo.App.Session.Put(r.Context(), "warning", fmt.Sprintf("Sorry, these rooms have just been booked and were removed from your cart: %s", strings.Join(booked, ", ")))
+}
+
+// Builds the emails confirming a new booking to the guest and to the owner.
+// The guest gets a single calendar invite with the stays in all the rooms
+func (repo *Repository) bookingConfirmationEmails(booking models.Booking) ([]models.MailData, error) {
+	data := emails.BookingConfirmationData{
+		Booking: booking,
+	}
+
+	guestMsg, err := emails.NewMail(emails.BookingConfirmationGuest, booking.Email, data)
+	if err != nil {
+		return nil, err
+	}
+
+	invite, err := emails.BookingCalendarInvite(booking, emails.MethodRequest)
+	if err != nil {
+		return nil, err
+	}
+	guestMsg.Attachments = append(guestMsg.Attachments, invite)
+
+	ownerMsg, err := emails.NewMail(emails.BookingConfirmationOwner, repo.App.OwnerEmail, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return []models.MailData{guestMsg, ownerMsg}, nil
+}
+
+// Shows the confirmation of the booking the guest has just made
+func (repo *Repository) BookingSummary(w http.ResponseWriter, r *http.Request) {
+	booking, ok := repo.App.Session.Get(r.Context(), "booking").(models.Booking)
+	if !ok {
+		repo.App.Session.Put(r.Context(), "error", "Can't get booking from session")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	repo.App.Session.Remove(r.Context(), "booking")
+
+	data := make(map[string]interface{})
+	data["booking"] = booking
+
+	render.RenderTemplate(w, r, "booking-summary.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// Shows all bookings of several rooms in the admin tool
+func (repo *Repository) AdminBookings(w http.ResponseWriter, r *http.Request) {
+	bookings, err := repo.DB.GetBookings()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["bookings"] = bookings
+
+	render.RenderTemplate(w, r, "admin-bookings.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// Shows a booking with the reservation of each of its rooms
+func (repo *Repository) AdminShowBooking(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	booking, err := repo.DB.GetBookingByID(id)
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["booking"] = booking
+
+	render.RenderTemplate(w, r, "admin-booking.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
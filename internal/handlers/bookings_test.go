@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/pricing"
+	"github.com/go-chi/chi/v5"
+)
+
+// Cart holding room 1 from 2049-01-10 to 2049-01-12
+func testCart() models.Booking {
+	return models.Booking{
+		TotalPrice: 20000,
+		Reservations: []models.Reservation{
+			{
+				RoomID: 1,
+				Room: models.Room{ ID: 1, RoomName: "General's Quarters", Capacity: 2 },
+				StartDate: time.Date(2049, time.January, 10, 0, 0, 0, 0, time.UTC),
+				EndDate: time.Date(2049, time.January, 12, 0, 0, 0, 0, time.UTC),
+				Adults: 2,
+				TotalPrice: 20000,
+			},
+		},
+	}
+}
+
+var postAddToCartTests = []struct {
+	name                string
+	cart                models.Booking
+	body                url.Values
+	expectedRedirectURL string
+	expectedCartLines   int
+}{
+	{
+		"First room",
+		models.Booking{},
+		url.Values{"room_id": {"1"}, "start_date": {"2049-01-01"}, "end_date": {"2049-01-03"}, "adults": {"2"}},
+		"/cart",
+		1,
+	},
+	{
+		"Same room for other dates",
+		testCart(),
+		url.Values{"room_id": {"1"}, "start_date": {"2049-01-12"}, "end_date": {"2049-01-14"}, "adults": {"1"}},
+		"/cart",
+		2,
+	},
+	{
+		"Other room for the same dates",
+		testCart(),
+		url.Values{"room_id": {"2"}, "start_date": {"2049-01-10"}, "end_date": {"2049-01-12"}, "adults": {"2"}},
+		"/cart",
+		2,
+	},
+	{
+		"Same room for overlapping dates",
+		testCart(),
+		url.Values{"room_id": {"1"}, "start_date": {"2049-01-11"}, "end_date": {"2049-01-13"}, "adults": {"1"}},
+		"/cart",
+		1,
+	},
+	{
+		"Party too big for the room",
+		testCart(),
+		url.Values{"room_id": {"1"}, "start_date": {"2049-01-01"}, "end_date": {"2049-01-03"}, "adults": {"2"}, "children": {"1"}},
+		"/make-reservation",
+		1,
+	},
+	{
+		"Room not available",
+		testCart(),
+		url.Values{"room_id": {"1"}, "start_date": {"2050-01-01"}, "end_date": {"2050-01-03"}, "adults": {"2"}},
+		"/search-availability",
+		1,
+	},
+	{
+		// Summer stays have to be at least 3 nights long
+		"Stay rules not followed",
+		testCart(),
+		url.Values{"room_id": {"1"}, "start_date": {"2049-07-02"}, "end_date": {"2049-07-03"}, "adults": {"2"}},
+		"/search-availability",
+		1,
+	},
+	{
+		"Invalid room id",
+		testCart(),
+		url.Values{"room_id": {"invalid"}, "start_date": {"2049-01-01"}, "end_date": {"2049-01-03"}},
+		"/",
+		1,
+	},
+	{
+		"Non-existent room",
+		testCart(),
+		url.Values{"room_id": {"3"}, "start_date": {"2049-01-01"}, "end_date": {"2049-01-03"}},
+		"/",
+		1,
+	},
+	{
+		"Deactivated room",
+		testCart(),
+		url.Values{"room_id": {"4"}, "start_date": {"2049-01-01"}, "end_date": {"2049-01-03"}},
+		"/search-availability",
+		1,
+	},
+}
+
+func TestRepository_PostAddToCart(t *testing.T) {
+	for _, test := range postAddToCartTests {
+		req, err := http.NewRequest("POST", "/cart", strings.NewReader(test.body.Encode()))
+		if err != nil {
+			log.Println(err)
+		}
+		ctx := getRequestContext(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		if len(test.cart.Reservations) > 0 {
+			session.Put(ctx, "cart", test.cart)
+		}
+
+		responseRecorder := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostAddToCart)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != http.StatusSeeOther {
+			t.Errorf("Test %s returns wrong response status code: got %d, wanted %d", test.name, responseRecorder.Code, http.StatusSeeOther)
+		}
+
+		redirectURL, _ := responseRecorder.Result().Location()
+		if redirectURL == nil || redirectURL.String() != test.expectedRedirectURL {
+			t.Errorf("Test %s redirects to wrong URL: got %v, wanted %s", test.name, redirectURL, test.expectedRedirectURL)
+		}
+
+		cart, _ := session.Get(ctx, "cart").(models.Booking)
+		if len(cart.Reservations) != test.expectedCartLines {
+			t.Errorf("Test %s leaves %d rooms in the cart, wanted %d", test.name, len(cart.Reservations), test.expectedCartLines)
+		}
+	}
+}
+
+var postCheckoutTests = []struct {
+	name                string
+	cart                models.Booking
+	body                url.Values
+	expectedStatusCode  int
+	expectedRedirectURL string
+	expectedCartLines   int
+}{
+	{
+		"Two rooms booked",
+		func() models.Booking {
+			cart := testCart()
+			line := cart.Reservations[0]
+			line.StartDate = time.Date(2049, time.February, 1, 0, 0, 0, 0, time.UTC)
+			line.EndDate = time.Date(2049, time.February, 3, 0, 0, 0, 0, time.UTC)
+			cart.Reservations = append(cart.Reservations, line)
+			cart.TotalPrice += line.TotalPrice
+			return cart
+		}(),
+		url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@smith.com"}, "phone": {"123456789"}},
+		http.StatusSeeOther,
+		"/booking-summary",
+		0,
+	},
+	{
+		"Empty cart",
+		models.Booking{},
+		url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@smith.com"}, "phone": {"123456789"}},
+		http.StatusSeeOther,
+		"/search-availability",
+		0,
+	},
+	{
+		"Invalid form",
+		testCart(),
+		url.Values{"first_name": {"J"}, "last_name": {"Smith"}, "email": {"john"}},
+		http.StatusOK,
+		"",
+		1,
+	},
+	{
+		"Room booked by someone else in the meantime",
+		func() models.Booking {
+			cart := testCart()
+			line := cart.Reservations[0]
+			line.StartDate = time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)
+			line.EndDate = time.Date(2100, time.January, 3, 0, 0, 0, 0, time.UTC)
+			cart.Reservations = append(cart.Reservations, line)
+			cart.TotalPrice += line.TotalPrice
+			return cart
+		}(),
+		url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@smith.com"}, "phone": {"123456789"}},
+		http.StatusSeeOther,
+		"/cart",
+		1,
+	},
+	{
+		"Reservation insertion fails",
+		func() models.Booking {
+			cart := testCart()
+			cart.Reservations[0].RoomID = 2
+			return cart
+		}(),
+		url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@smith.com"}, "phone": {"123456789"}},
+		http.StatusSeeOther,
+		"/",
+		1,
+	},
+	{
+		// Guests can't arrive at Christmas
+		"Stay rules changed since the room was added",
+		func() models.Booking {
+			cart := testCart()
+			cart.Reservations[0].StartDate = time.Date(2049, time.December, 25, 0, 0, 0, 0, time.UTC)
+			cart.Reservations[0].EndDate = time.Date(2049, time.December, 28, 0, 0, 0, 0, time.UTC)
+			return cart
+		}(),
+		url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@smith.com"}, "phone": {"123456789"}},
+		http.StatusSeeOther,
+		"/cart",
+		1,
+	},
+}
+
+func TestRepository_PostCheckout(t *testing.T) {
+	for _, test := range postCheckoutTests {
+		req, err := http.NewRequest("POST", "/cart/checkout", strings.NewReader(test.body.Encode()))
+		if err != nil {
+			log.Println(err)
+		}
+		ctx := getRequestContext(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		if len(test.cart.Reservations) > 0 {
+			session.Put(ctx, "cart", test.cart)
+		}
+
+		responseRecorder := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostCheckout)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf("Test %s returns wrong response status code: got %d, wanted %d", test.name, responseRecorder.Code, test.expectedStatusCode)
+		}
+
+		if test.expectedRedirectURL != "" {
+			redirectURL, _ := responseRecorder.Result().Location()
+			if redirectURL == nil || redirectURL.String() != test.expectedRedirectURL {
+				t.Errorf("Test %s redirects to wrong URL: got %v, wanted %s", test.name, redirectURL, test.expectedRedirectURL)
+			}
+		}
+
+		// Only the rooms which couldn't be booked stay in the cart
+		cart, _ := session.Get(ctx, "cart").(models.Booking)
+		if test.expectedStatusCode == http.StatusSeeOther && len(cart.Reservations) != test.expectedCartLines {
+			t.Errorf("Test %s leaves %d rooms in the cart, wanted %d", test.name, len(cart.Reservations), test.expectedCartLines)
+		}
+
+		if test.expectedRedirectURL != "/booking-summary" {
+			continue
+		}
+
+		booking, ok := session.Get(ctx, "booking").(models.Booking)
+		if !ok {
+			t.Errorf("Test %s doesn't store the booking in the session", test.name)
+			continue
+		}
+
+		// The booking and each room get their own confirmation code
+		codes := map[string]bool{booking.ConfirmationCode: true}
+		for _, reservation := range booking.Reservations {
+			if reservation.Email != "john@smith.com" {
+				t.Errorf("Test %s doesn't copy the guest details to the reservation of room %d", test.name, reservation.RoomID)
+			}
+			codes[reservation.ConfirmationCode] = true
+		}
+		if len(codes) != len(booking.Reservations) + 1 || codes[""] {
+			t.Errorf("Test %s doesn't give the booking and its rooms distinct confirmation codes: %v", test.name, codes)
+		}
+	}
+}
+
+func TestRepository_PostCheckout_RepricesCart(t *testing.T) {
+	req, err := http.NewRequest("POST", "/cart/checkout", strings.NewReader(url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@smith.com"}}.Encode()))
+	if err != nil {
+		log.Println(err)
+	}
+	ctx := getRequestContext(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// Prices in the cart were quoted before the rates changed
+	cart := testCart()
+	cart.Reservations[0].TotalPrice = 1
+	cart.TotalPrice = 1
+	session.Put(ctx, "cart", cart)
+
+	responseRecorder := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.PostCheckout)
+	handler.ServeHTTP(responseRecorder, req)
+
+	booking, ok := session.Get(ctx, "booking").(models.Booking)
+	if !ok {
+		t.Fatal("PostCheckout doesn't store the booking in the session")
+	}
+
+	plan, _ := Repo.DB.GetRatePlanForRoom(1)
+	expected := pricing.Calculate(plan, cart.Reservations[0].StartDate, cart.Reservations[0].EndDate).Total
+
+	if booking.Reservations[0].TotalPrice != expected {
+		t.Errorf("PostCheckout books the room for %d, wanted %d", booking.Reservations[0].TotalPrice, expected)
+	}
+
+	if booking.TotalPrice != expected {
+		t.Errorf("PostCheckout books the cart for %d, wanted %d", booking.TotalPrice, expected)
+	}
+}
+
+func TestRepository_RemoveFromCart(t *testing.T) {
+	req, err := http.NewRequest("POST", "/cart/0/remove", nil)
+	if err != nil {
+		log.Println(err)
+	}
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("index", "0")
+
+	ctx := getRequestContext(req)
+	req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+	session.Put(ctx, "cart", testCart())
+
+	responseRecorder := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.RemoveFromCart)
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusSeeOther {
+		t.Errorf("RemoveFromCart returns wrong response status code: got %d, wanted %d", responseRecorder.Code, http.StatusSeeOther)
+	}
+
+	cart, _ := session.Get(ctx, "cart").(models.Booking)
+	if len(cart.Reservations) != 0 || cart.TotalPrice != 0 {
+		t.Errorf("RemoveFromCart leaves %d rooms for %d in the cart", len(cart.Reservations), cart.TotalPrice)
+	}
+}
+
+func TestRepository_BookingSummary(t *testing.T) {
+	req, err := http.NewRequest("GET", "/booking-summary", nil)
+	if err != nil {
+		log.Println(err)
+	}
+	ctx := getRequestContext(req)
+	req = req.WithContext(ctx)
+
+	booking := testCart()
+	booking.ConfirmationCode = "ABCD2345"
+	booking.Reservations[0].ConfirmationCode = "EFGH6789"
+	session.Put(ctx, "booking", booking)
+
+	responseRecorder := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.BookingSummary)
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Errorf("BookingSummary returns wrong response status code: got %d, wanted %d", responseRecorder.Code, http.StatusOK)
+	}
+
+	for _, code := range []string{"ABCD2345", "EFGH6789"} {
+		if !strings.Contains(responseRecorder.Body.String(), code) {
+			t.Errorf("BookingSummary doesn't show confirmation code %s", code)
+		}
+	}
+
+	// The summary is only shown once
+	responseRecorder = httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusSeeOther {
+		t.Errorf("BookingSummary without booking returns wrong response status code: got %d, wanted %d", responseRecorder.Code, http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/ical"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/go-chi/chi/v5"
+)
+
+// Date range covered by the calendar feeds, which include every room restriction
+var (
+	feedStartDate = time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+	feedEndDate = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+)
+
+// Serves the reservations, owner blocks and external bookings of a room as an iCalendar feed
+func (repo *Repository) RoomCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if !repo.hasValidFeedToken(r) {
+		helpers.ClientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	room, err := repo.DB.GetRoomByID(id)
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	events, err := repo.getRoomCalendarEvents(room, "")
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	writeCalendar(w, ical.Calendar{
+		Name: room.RoomName,
+		Events: events,
+	})
+}
+
+// Serves the reservations, owner blocks and external bookings of all rooms as a single iCalendar feed
+func (repo *Repository) AllRoomsCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if !repo.hasValidFeedToken(r) {
+		helpers.ClientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	rooms, err := repo.DB.GetAllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	var events []ical.Event
+
+	for _, room := range rooms {
+		// Prefix the events with the room name, since they are all in the same calendar
+		roomEvents, err := repo.getRoomCalendarEvents(room, fmt.Sprintf("%s: ", room.RoomName))
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		events = append(events, roomEvents...)
+	}
+
+	writeCalendar(w, ical.Calendar{
+		Name: "All rooms",
+		Events: events,
+	})
+}
+
+// AdminCalendarFeeds is the calendar feed tokens page handler in the admin dashboard
+func (repo *Repository) AdminCalendarFeeds(w http.ResponseWriter, r *http.Request) {
+	tokens, err := repo.DB.GetAllCalendarFeedTokens()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := repo.DB.GetAllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["tokens"] = tokens
+	data["rooms"] = rooms
+
+	// A newly created token is only shown once, right after it has been created
+	stringMap := make(map[string]string)
+	stringMap["new_token"] = repo.App.Session.PopString(r.Context(), "calendar_feed_token")
+	stringMap["base_url"] = baseURL(r)
+
+	render.RenderTemplate(w, r, "admin-calendar-feeds.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data: data,
+		Form: forms.New(nil),
+	})
+}
+
+// Handler to create a calendar feed token
+func (repo *Repository) AdminPostCalendarFeeds(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.RequiredFields("name")
+	if !form.IsValid() {
+		repo.App.Session.Put(r.Context(), "error", "Please give the token a name")
+		http.Redirect(w, r, "/admin/calendar-feeds", http.StatusSeeOther)
+		return
+	}
+
+	token, err := helpers.GenerateToken()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	name := strings.TrimSpace(r.Form.Get("name"))
+
+	id, err := repo.DB.InsertCalendarFeedToken(models.CalendarFeedToken{
+		Name: name,
+		TokenHash: helpers.HashToken(token),
+	})
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionCreated, audit.EntityCalendarFeed, id, nil, map[string]interface{}{"name": name, "revoked": false})
+
+	repo.App.Session.Put(r.Context(), "calendar_feed_token", token)
+	repo.App.Session.Put(r.Context(), "success", "Token created")
+	http.Redirect(w, r, "/admin/calendar-feeds", http.StatusSeeOther)
+}
+
+// Handler to revoke a calendar feed token
+func (repo *Repository) AdminRevokeCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	token, err := repo.DB.GetCalendarFeedTokenByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.RevokeCalendarFeedToken(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if !token.Revoked {
+		repo.audit(r, audit.ActionRevoked, audit.EntityCalendarFeed, id, map[string]interface{}{"name": token.Name, "revoked": false}, map[string]interface{}{"name": token.Name, "revoked": true})
+	}
+
+	repo.App.Session.Put(r.Context(), "success", "Token revoked")
+	http.Redirect(w, r, "/admin/calendar-feeds", http.StatusSeeOther)
+}
+
+// Checks the `token` query parameter against the calendar feed tokens which have not been revoked
+func (repo *Repository) hasValidFeedToken(r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return false
+	}
+
+	feedToken, err := repo.DB.GetCalendarFeedTokenByHash(helpers.HashToken(token))
+	if err != nil {
+		return false
+	}
+
+	return !feedToken.Revoked
+}
+
+// Converts every restriction of a room into a calendar event. The UID of an event is derived from the
+// id of the restriction, so it stays the same when the dates of a reservation change
+func (repo *Repository) getRoomCalendarEvents(room models.Room, summaryPrefix string) ([]ical.Event, error) {
+	var events []ical.Event
+
+	restrictions, err := repo.DB.GetRestrictionsForRoomByDate(room.ID, feedStartDate, feedEndDate)
+	if err != nil {
+		return events, err
+	}
+
+	now := time.Now()
+
+	for _, restriction := range restrictions {
+		event := ical.Event{
+			UID: fmt.Sprintf("room-restriction-%d@bed-and-breakfast", restriction.ID),
+			Start: restriction.StartDate,
+			End: restriction.EndDate,
+			Summary: summaryPrefix + "Owner block",
+			Stamp: now,
+		}
+
+		if restriction.ReservationID > 0 {
+			event.Summary = summaryPrefix + "Reservation"
+			event.Description = fmt.Sprintf("Reservation %d", restriction.ReservationID)
+		} else if restriction.RestrictionID == models.RestrictionExternalBooking {
+			event.Summary = summaryPrefix + "External booking"
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// Writes an iCalendar response
+func writeCalendar(w http.ResponseWriter, calendar ical.Calendar) {
+	var out bytes.Buffer
+
+	err := ical.Encode(&out, calendar)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write(out.Bytes())
+}
+
+// Returns the scheme and host the request was made to, e.g. https://example.com
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRepository_RoomCalendarFeed(t *testing.T) {
+	// Setup routes
+	routes := getRoutes()
+
+	// Create test server
+	testServer := httptest.NewTLSServer(routes)
+	defer testServer.Close()
+
+	res, err := testServer.Client().Get(testServer.URL + "/calendar/rooms/1.ics?token=valid-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/calendar") {
+		t.Errorf("Calendar feed returns wrong content type: got %s", res.Header.Get("Content-Type"))
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The test repository returns an owner block with id 1 and a reservation with id 2
+	expectedLines := []string{
+		"UID:room-restriction-1@bed-and-breakfast",
+		"SUMMARY:Owner block",
+		"UID:room-restriction-2@bed-and-breakfast",
+		"SUMMARY:Reservation",
+	}
+
+	for _, line := range expectedLines {
+		if !strings.Contains(string(body), line) {
+			t.Errorf("Calendar feed is missing line %q", line)
+		}
+	}
+}
+
+var adminPostCalendarFeedsTests = []struct {
+	name               string
+	body               url.Values
+	expectedStatusCode int
+	expectedFlash      string
+}{
+	{"Creates token", url.Values{"name": {"Google Calendar"}}, http.StatusSeeOther, "calendar_feed_token"},
+	{"Token without name", url.Values{"name": {""}}, http.StatusSeeOther, "error"},
+	{"Token insert failed", url.Values{"name": {"Invalid"}}, http.StatusInternalServerError, ""},
+}
+
+func TestRepository_AdminPostCalendarFeeds(t *testing.T) {
+	for _, test := range adminPostCalendarFeedsTests {
+		req, err := http.NewRequest("POST", "/admin/calendar-feeds", strings.NewReader(test.body.Encode()))
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminPostCalendarFeeds)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf(
+				"Test %s returns wrong response status code: got %d, wanted %d",
+				test.name,
+				responseRecorder.Code,
+				test.expectedStatusCode,
+			)
+		}
+
+		if test.expectedFlash != "" && !session.Exists(ctx, test.expectedFlash) {
+			t.Errorf("Test %s did not store %s in the session", test.name, test.expectedFlash)
+		}
+	}
+}
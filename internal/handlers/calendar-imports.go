@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/channelsync"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminCalendarImports is the calendar import sources page handler in the admin dashboard
+func (repo *Repository) AdminCalendarImports(w http.ResponseWriter, r *http.Request) {
+	repo.renderCalendarImports(w, r, forms.New(nil))
+}
+
+// Handler to add a calendar import source with received form data
+func (repo *Repository) AdminPostCalendarImports(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.RequiredFields("room_id", "name", "url")
+
+	roomID, _ := strconv.Atoi(r.Form.Get("room_id"))
+	url := strings.TrimSpace(r.Form.Get("url"))
+
+	// Local files can only be imported outside of production, for testing
+	if url != "" && !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		if repo.App.InProduction || !strings.HasPrefix(url, "file://") {
+			form.Errors.Add("url", "The URL must start with http:// or https://")
+		}
+	}
+
+	// Rerender page with updated error information
+	if !form.IsValid() {
+		repo.renderCalendarImports(w, r, form)
+		return
+	}
+
+	source := models.CalendarImportSource{
+		RoomID: roomID,
+		Name: strings.TrimSpace(r.Form.Get("name")),
+		URL: url,
+	}
+
+	source.ID, err = repo.DB.InsertCalendarImportSource(source)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionCreated, audit.EntityCalendarImport, source.ID, nil, newAuditCalendarImport(source))
+
+	repo.App.Session.Put(r.Context(), "success", "Calendar import added. It will be synced within the next sync interval")
+	http.Redirect(w, r, "/admin/calendar-imports", http.StatusSeeOther)
+}
+
+// Handler to sync a calendar import source right away
+func (repo *Repository) AdminSyncCalendarImport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	source, err := repo.DB.GetCalendarImportSourceByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	result, err := channelsync.NewSyncer(repo.DB, repo.App).SyncSource(source)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", fmt.Sprintf("Can't sync %s: %s", source.Name, err))
+		http.Redirect(w, r, "/admin/calendar-imports", http.StatusSeeOther)
+		return
+	}
+
+	if result.Conflicts > 0 {
+		repo.App.Session.Put(r.Context(), "warning", fmt.Sprintf("%s synced: %s. Conflicting bookings were logged", source.Name, result))
+	} else {
+		repo.App.Session.Put(r.Context(), "success", fmt.Sprintf("%s synced: %s", source.Name, result))
+	}
+
+	http.Redirect(w, r, "/admin/calendar-imports", http.StatusSeeOther)
+}
+
+// Handler to delete a calendar import source together with its external bookings
+func (repo *Repository) AdminDeleteCalendarImport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	source, err := repo.DB.GetCalendarImportSourceByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.DeleteCalendarImportSource(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionDeleted, audit.EntityCalendarImport, id, newAuditCalendarImport(source), nil)
+
+	repo.App.Session.Put(r.Context(), "success", "Calendar import deleted")
+	http.Redirect(w, r, "/admin/calendar-imports", http.StatusSeeOther)
+}
+
+// Renders the calendar import sources page of the admin dashboard
+func (repo *Repository) renderCalendarImports(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	sources, err := repo.DB.GetAllCalendarImportSources()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := repo.DB.GetAllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["sources"] = sources
+	data["rooms"] = rooms
+
+	render.RenderTemplate(w, r, "admin-calendar-imports.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
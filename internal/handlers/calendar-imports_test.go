@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var adminPostCalendarImportsTests = []struct {
+	name               string
+	body               url.Values
+	inProduction       bool
+	expectedStatusCode int
+}{
+	{
+		"Adds calendar import",
+		url.Values{"room_id": {"1"}, "name": {"Booking site"}, "url": {"https://example.com/listing.ics"}},
+		false,
+		http.StatusSeeOther,
+	},
+	{
+		"Missing name",
+		url.Values{"room_id": {"1"}, "name": {""}, "url": {"https://example.com/listing.ics"}},
+		false,
+		http.StatusOK,
+	},
+	{
+		"Unsupported URL",
+		url.Values{"room_id": {"1"}, "name": {"Booking site"}, "url": {"ftp://example.com/listing.ics"}},
+		false,
+		http.StatusOK,
+	},
+	{
+		"Local file outside of production",
+		url.Values{"room_id": {"1"}, "name": {"Test file"}, "url": {"file:///tmp/listing.ics"}},
+		false,
+		http.StatusSeeOther,
+	},
+	{
+		"Local file in production",
+		url.Values{"room_id": {"1"}, "name": {"Test file"}, "url": {"file:///tmp/listing.ics"}},
+		true,
+		http.StatusOK,
+	},
+	{
+		"Insert failed",
+		url.Values{"room_id": {"1"}, "name": {"Invalid"}, "url": {"https://example.com/listing.ics"}},
+		false,
+		http.StatusInternalServerError,
+	},
+}
+
+func TestRepository_AdminPostCalendarImports(t *testing.T) {
+	defer func() { Repo.App.InProduction = false }()
+
+	for _, test := range adminPostCalendarImportsTests {
+		Repo.App.InProduction = test.inProduction
+
+		req, err := http.NewRequest("POST", "/admin/calendar-imports", strings.NewReader(test.body.Encode()))
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminPostCalendarImports)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf(
+				"Test %s returns wrong response status code: got %d, wanted %d",
+				test.name,
+				responseRecorder.Code,
+				test.expectedStatusCode,
+			)
+		}
+	}
+}
+
+var adminSyncCalendarImportTests = []struct {
+	name          string
+	id            string
+	expectedFlash string
+}{
+	// The test calendar has a booking which conflicts with a reservation
+	{"Synced with conflicts", "1", "warning"},
+	{"Calendar file is missing", "2", "error"},
+}
+
+func TestRepository_AdminSyncCalendarImport(t *testing.T) {
+	for _, test := range adminSyncCalendarImportTests {
+		req, err := http.NewRequest("GET", "/admin/calendar-imports/" + test.id + "/sync", nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", test.id)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		req = req.WithContext(ctx)
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminSyncCalendarImport)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != http.StatusSeeOther {
+			t.Errorf("Test %s returns wrong response status code: got %d, wanted %d", test.name, responseRecorder.Code, http.StatusSeeOther)
+		}
+
+		if !session.Exists(ctx, test.expectedFlash) {
+			t.Errorf("Test %s did not store %s in the session", test.name, test.expectedFlash)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/emails"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminEmailTemplates is the page handler listing the email templates in the admin dashboard
+func (repo *Repository) AdminEmailTemplates(w http.ResponseWriter, r *http.Request) {
+	names, err := emails.Names()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["names"] = names
+
+	render.RenderTemplate(w, r, "admin-email-templates.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminEmailTemplate is the page handler previewing an email template rendered with sample data
+func (repo *Repository) AdminEmailTemplate(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	sampleData, ok := emails.SampleData(name)
+	if !ok {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	message, err := emails.Render(name, sampleData)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["message"] = message
+
+	stringMap := make(map[string]string)
+	stringMap["name"] = name
+
+	render.RenderTemplate(w, r, "admin-email-template.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data: data,
+	})
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/go-chi/chi/v5"
+)
+
+// Number of emails shown on the outbox page
+const outboxPageSize = 200
+
+// Statuses the outbox page can be filtered by, in the order they are shown
+var outboxStatuses = []string{
+	models.EmailPending,
+	models.EmailDead,
+	models.EmailSent,
+	models.EmailCancelled,
+}
+
+// AdminEmails is the email outbox page handler in the admin dashboard.
+// The emails can be filtered by status with the `status` query parameter
+func (repo *Repository) AdminEmails(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && !isOutboxStatus(status) {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	emails, err := repo.DB.GetOutboxEmails(status, outboxPageSize)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["emails"] = emails
+	data["statuses"] = outboxStatuses
+
+	stringMap := make(map[string]string)
+	stringMap["status"] = status
+
+	render.RenderTemplate(w, r, "admin-emails.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data: data,
+	})
+}
+
+// AdminEmail is the page handler showing an email of the outbox
+func (repo *Repository) AdminEmail(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	email, err := repo.DB.GetOutboxEmailByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["email"] = email
+
+	render.RenderTemplate(w, r, "admin-email.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// Handler to queue an email to be sent again right away
+func (repo *Repository) AdminResendEmail(w http.ResponseWriter, r *http.Request) {
+	email, ok := repo.getOutboxEmail(w, r)
+	if !ok {
+		return
+	}
+
+	if email.Status == models.EmailPending {
+		repo.App.Session.Put(r.Context(), "error", "This email is already queued")
+		http.Redirect(w, r, fmt.Sprintf("/admin/emails/%d", email.ID), http.StatusSeeOther)
+		return
+	}
+
+	err := repo.DB.ResendOutboxEmail(email.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionResent, audit.EntityEmail, email.ID, map[string]string{"status": email.Status}, map[string]string{"status": models.EmailPending})
+
+	repo.App.Session.Put(r.Context(), "success", "Email queued to be sent again")
+	http.Redirect(w, r, fmt.Sprintf("/admin/emails/%d", email.ID), http.StatusSeeOther)
+}
+
+// Handler to cancel a queued or failed email so that it is not sent
+func (repo *Repository) AdminCancelEmail(w http.ResponseWriter, r *http.Request) {
+	email, ok := repo.getOutboxEmail(w, r)
+	if !ok {
+		return
+	}
+
+	if email.Status != models.EmailPending && email.Status != models.EmailDead {
+		repo.App.Session.Put(r.Context(), "error", "Only queued or failed emails can be cancelled")
+		http.Redirect(w, r, fmt.Sprintf("/admin/emails/%d", email.ID), http.StatusSeeOther)
+		return
+	}
+
+	err := repo.DB.CancelOutboxEmail(email.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionCancelled, audit.EntityEmail, email.ID, map[string]string{"status": email.Status}, map[string]string{"status": models.EmailCancelled})
+
+	repo.App.Session.Put(r.Context(), "success", "Email cancelled")
+	http.Redirect(w, r, fmt.Sprintf("/admin/emails/%d", email.ID), http.StatusSeeOther)
+}
+
+// Gets the email with the id given in the URL
+func (repo *Repository) getOutboxEmail(w http.ResponseWriter, r *http.Request) (models.OutboxEmail, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return models.OutboxEmail{}, false
+	}
+
+	email, err := repo.DB.GetOutboxEmailByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return models.OutboxEmail{}, false
+	}
+
+	return email, true
+}
+
+// Reports whether the outbox page can be filtered by the given status
+func isOutboxStatus(status string) bool {
+	for _, outboxStatus := range outboxStatuses {
+		if outboxStatus == status {
+			return true
+		}
+	}
+
+	return false
+}
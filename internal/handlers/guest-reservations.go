@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/emails"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/pricing"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/webhooks"
+)
+
+// MyReservation is the page handler where guests look up their reservation
+func (repo *Repository) MyReservation(w http.ResponseWriter, r *http.Request) {
+	render.RenderTemplate(w, r, "my-reservation-lookup.page.tmpl", &models.TemplateData{
+		Form: forms.New(nil),
+	})
+}
+
+// Handler to look up a reservation given its confirmation code and the email address of the guest.
+// The reservation id is stored in the `Session` object so that the guest can manage the reservation
+func (repo *Repository) PostMyReservation(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't parse form")
+		http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.RequiredFields("confirmation_code", "email")
+	form.IsEmail("email")
+	if !form.IsValid() {
+		render.RenderTemplate(w, r, "my-reservation-lookup.page.tmpl", &models.TemplateData{
+			Form: form,
+		})
+		return
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(r.Form.Get("confirmation_code")))
+	email := strings.TrimSpace(r.Form.Get("email"))
+
+	reservation, err := repo.DB.GetReservationByConfirmationCode(code, email)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "We couldn't find a reservation with this confirmation code and email")
+		http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
+		return
+	}
+
+	repo.App.Session.Put(r.Context(), "guest_reservation_id", reservation.ID)
+	http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+}
+
+// MyReservationDetails is the page handler where guests view, change or cancel their reservation
+func (repo *Repository) MyReservationDetails(w http.ResponseWriter, r *http.Request) {
+	reservation, ok := repo.getGuestReservation(w, r)
+	if !ok {
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["reservation"] = reservation
+	data["can_modify"] = repo.canGuestModifyReservation(reservation)
+
+	stringMap := make(map[string]string)
+	stringMap["start_date"] = reservation.StartDate.Format("2006-01-02")
+	stringMap["end_date"] = reservation.EndDate.Format("2006-01-02")
+	stringMap["deadline"] = reservation.StartDate.Add(-repo.App.CancellationWindow).Format("2006-01-02 15:04")
+
+	render.RenderTemplate(w, r, "my-reservation.page.tmpl", &models.TemplateData{
+		Data: data,
+		StringMap: stringMap,
+	})
+}
+
+// Handler to move a reservation to new dates if the room is available
+func (repo *Repository) PostMyReservationChangeDates(w http.ResponseWriter, r *http.Request) {
+	reservation, ok := repo.getGuestReservation(w, r)
+	if !ok {
+		return
+	}
+
+	if !repo.canGuestModifyReservation(reservation) {
+		repo.App.Session.Put(r.Context(), "error", "This reservation can no longer be changed")
+		http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't parse form")
+		http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+		return
+	}
+
+	startDate, endDate, err := helpers.ParseDates(w, r.Form.Get("start_date"), r.Form.Get("end_date"))
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't parse dates")
+		http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+		return
+	}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	if startDate.Before(today) || !endDate.After(startDate) {
+		repo.App.Session.Put(r.Context(), "error", "Departure must be after arrival and arrival can't be in the past")
+		http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+		return
+	}
+
+	// The new dates have to follow the stay rules of the room like a new booking
+	reasons, err := repo.stayRuleReasons(reservation.RoomID, startDate, endDate)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't get stay rules")
+		http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+		return
+	}
+
+	if len(reasons) > 0 {
+		repo.App.Session.Put(r.Context(), "error", strings.Join(reasons, ". "))
+		http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+		return
+	}
+
+	// The price of the stay is calculated again for the new dates
+	plan, err := repo.DB.GetRatePlanForRoom(reservation.RoomID)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't get room rates")
+		http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+		return
+	}
+
+	previousStartDate := reservation.StartDate
+	previousEndDate := reservation.EndDate
+	before := newApiAdminReservation(reservation)
+
+	reservation.StartDate = startDate
+	reservation.EndDate = endDate
+	reservation.Quote = pricing.Calculate(plan, startDate, endDate)
+	reservation.TotalPrice = reservation.Quote.Total
+
+	changedEmails, err := repo.reservationChangedEmails(reservation, previousStartDate, previousEndDate)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't create emails about the change")
+		http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+		return
+	}
+
+	err = repo.DB.UpdateReservationDates(reservation, changedEmails)
+	if errors.Is(err, repository.ErrRoomNotAvailable) {
+		repo.App.Session.Put(r.Context(), "warning", "Sorry, the room is not available for the new dates")
+		http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+		return
+	}
+
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't change the dates of the reservation")
+		http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+		return
+	}
+
+	repo.sendWebhook(webhooks.EventReservationUpdated, newApiAdminReservation(reservation))
+	repo.audit(r, audit.ActionUpdated, audit.EntityReservation, reservation.ID, before, newApiAdminReservation(reservation))
+
+	repo.App.Session.Put(r.Context(), "success", "Your reservation has been changed")
+	http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+}
+
+// Handler to cancel a reservation, freeing its dates for other guests
+func (repo *Repository) PostMyReservationCancel(w http.ResponseWriter, r *http.Request) {
+	reservation, ok := repo.getGuestReservation(w, r)
+	if !ok {
+		return
+	}
+
+	if !repo.canGuestModifyReservation(reservation) {
+		repo.App.Session.Put(r.Context(), "error", "This reservation can no longer be cancelled")
+		http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+		return
+	}
+
+	cancelledEmails, err := repo.reservationCancelledEmails(reservation)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't create emails about the cancellation")
+		http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+		return
+	}
+
+	err = repo.DB.CancelReservation(reservation.ID, cancelledEmails)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't cancel the reservation")
+		http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+		return
+	}
+
+	before := newApiAdminReservation(reservation)
+
+	reservation.Cancelled = true
+	repo.sendWebhook(webhooks.EventReservationUpdated, newApiAdminReservation(reservation))
+	repo.audit(r, audit.ActionCancelled, audit.EntityReservation, reservation.ID, before, newApiAdminReservation(reservation))
+
+	repo.App.Session.Put(r.Context(), "success", "Your reservation has been cancelled")
+	http.Redirect(w, r, "/my-reservation/details", http.StatusSeeOther)
+}
+
+// EmailOptOut is the page handler where guests confirm that they don't want to receive the scheduled emails
+// about a reservation. The scheduled emails link to it with the confirmation code and email of the reservation
+func (repo *Repository) EmailOptOut(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("code")
+	email := r.URL.Query().Get("email")
+
+	reservation, err := repo.DB.GetReservationByConfirmationCode(code, email)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "We couldn't find a reservation with this confirmation code and email")
+		http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["reservation"] = reservation
+
+	stringMap := make(map[string]string)
+	stringMap["code"] = code
+	stringMap["email"] = email
+
+	render.RenderTemplate(w, r, "email-opt-out.page.tmpl", &models.TemplateData{
+		Data: data,
+		StringMap: stringMap,
+	})
+}
+
+// Handler to stop sending the scheduled reminder and follow-up emails about a reservation
+func (repo *Repository) PostEmailOptOut(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't parse form")
+		http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
+		return
+	}
+
+	reservation, err := repo.DB.GetReservationByConfirmationCode(r.Form.Get("code"), r.Form.Get("email"))
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "We couldn't find a reservation with this confirmation code and email")
+		http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
+		return
+	}
+
+	err = repo.DB.UpdateEmailOptOutForReservation(reservation.ID, true)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't update your email preferences")
+		http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
+		return
+	}
+
+	repo.audit(r, audit.ActionUpdated, audit.EntityReservation, reservation.ID, map[string]bool{"email_opt_out": reservation.EmailOptOut}, map[string]bool{"email_opt_out": true})
+
+	repo.App.Session.Put(r.Context(), "success", "You won't receive any more reminders about this reservation")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// Builds the emails telling the guest and the owner that a reservation has been moved to new dates
+func (repo *Repository) reservationChangedEmails(reservation models.Reservation, previousStartDate, previousEndDate time.Time) ([]models.MailData, error) {
+	data := emails.ChangeData{
+		Reservation: reservation,
+		PreviousStartDate: previousStartDate,
+		PreviousEndDate: previousEndDate,
+	}
+
+	guestMsg, err := emails.NewMail(emails.ChangeGuest, reservation.Email, data)
+	if err != nil {
+		return nil, err
+	}
+
+	invite, err := emails.CalendarInvite(reservation, emails.MethodRequest)
+	if err != nil {
+		return nil, err
+	}
+	guestMsg.Attachments = append(guestMsg.Attachments, invite)
+
+	ownerMsg, err := emails.NewMail(emails.ChangeOwner, repo.App.OwnerEmail, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return []models.MailData{guestMsg, ownerMsg}, nil
+}
+
+// Builds the emails telling the guest and the owner that a reservation has been cancelled
+func (repo *Repository) reservationCancelledEmails(reservation models.Reservation) ([]models.MailData, error) {
+	data := emails.CancellationData{
+		Reservation: reservation,
+	}
+
+	guestMsg, err := emails.NewMail(emails.Cancellation, reservation.Email, data)
+	if err != nil {
+		return nil, err
+	}
+
+	invite, err := emails.CalendarInvite(reservation, emails.MethodCancel)
+	if err != nil {
+		return nil, err
+	}
+	guestMsg.Attachments = append(guestMsg.Attachments, invite)
+
+	ownerMsg, err := emails.NewMail(emails.CancellationOwner, repo.App.OwnerEmail, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return []models.MailData{guestMsg, ownerMsg}, nil
+}
+
+// Gets the reservation the guest looked up. If there is none, the guest is redirected to the lookup page
+func (repo *Repository) getGuestReservation(w http.ResponseWriter, r *http.Request) (models.Reservation, bool) {
+	id := repo.App.Session.GetInt(r.Context(), "guest_reservation_id")
+	if id == 0 {
+		repo.App.Session.Put(r.Context(), "error", "Please enter your confirmation code and email")
+		http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
+		return models.Reservation{}, false
+	}
+
+	reservation, err := repo.DB.GetReservationByID(id)
+	if err != nil {
+		repo.App.Session.Remove(r.Context(), "guest_reservation_id")
+		repo.App.Session.Put(r.Context(), "error", "Can't find your reservation")
+		http.Redirect(w, r, "/my-reservation", http.StatusSeeOther)
+		return models.Reservation{}, false
+	}
+
+	return reservation, true
+}
+
+// Guests can change or cancel a reservation until the cancellation window before arrival starts
+func (repo *Repository) canGuestModifyReservation(reservation models.Reservation) bool {
+	return !reservation.Cancelled && time.Until(reservation.StartDate) >= repo.App.CancellationWindow
+}
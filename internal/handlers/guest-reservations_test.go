@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+// Dates a few weeks from now, outside of the cancellation window
+var futureStartDate = time.Now().AddDate(0, 1, 10).Format("2006-01-02")
+var futureEndDate = time.Now().AddDate(0, 1, 12).Format("2006-01-02")
+
+var guestReservationTests = []struct {
+	name                string
+	handler             func(*Repository, http.ResponseWriter, *http.Request)
+	method              string
+	reservationID       int
+	body                url.Values
+	expectedStatusCode  int
+	expectedRedirectURL string
+	expectedHTML        string
+	expectedFlash       string
+}{
+	{
+		"Looks up reservation",
+		(*Repository).PostMyReservation,
+		"POST",
+		0,
+		url.Values{
+			"confirmation_code": {"abcdefghjk"},
+			"email":             {"john@smith.com"},
+		},
+		http.StatusSeeOther,
+		"/my-reservation/details",
+		"",
+		"",
+	},
+	{
+		"Looks up reservation with wrong email",
+		(*Repository).PostMyReservation,
+		"POST",
+		0,
+		url.Values{
+			"confirmation_code": {"ABCDEFGHJK"},
+			"email":             {"jane@smith.com"},
+		},
+		http.StatusSeeOther,
+		"/my-reservation",
+		"",
+		"error",
+	},
+	{
+		"Opts out of scheduled emails",
+		(*Repository).PostEmailOptOut,
+		"POST",
+		0,
+		url.Values{
+			"code":  {"ABCDEFGHJK"},
+			"email": {"john@smith.com"},
+		},
+		http.StatusSeeOther,
+		"/",
+		"",
+		"success",
+	},
+	{
+		"Opts out of scheduled emails with wrong email",
+		(*Repository).PostEmailOptOut,
+		"POST",
+		0,
+		url.Values{
+			"code":  {"ABCDEFGHJK"},
+			"email": {"jane@smith.com"},
+		},
+		http.StatusSeeOther,
+		"/my-reservation",
+		"",
+		"error",
+	},
+	{
+		"Looks up reservation with invalid form",
+		(*Repository).PostMyReservation,
+		"POST",
+		0,
+		url.Values{
+			"email": {"john@"},
+		},
+		http.StatusOK,
+		"",
+		"Invalid email address",
+		"",
+	},
+	{
+		"Shows reservation",
+		(*Repository).MyReservationDetails,
+		"GET",
+		2,
+		nil,
+		http.StatusOK,
+		"",
+		"Change Dates",
+		"",
+	},
+	{
+		"Shows reservation inside cancellation window",
+		(*Repository).MyReservationDetails,
+		"GET",
+		3,
+		nil,
+		http.StatusOK,
+		"",
+		"can no longer be changed or cancelled online",
+		"",
+	},
+	{
+		"Shows cancelled reservation",
+		(*Repository).MyReservationDetails,
+		"GET",
+		4,
+		nil,
+		http.StatusOK,
+		"",
+		"This reservation has been cancelled",
+		"",
+	},
+	{
+		"Shows reservation without looking it up",
+		(*Repository).MyReservationDetails,
+		"GET",
+		0,
+		nil,
+		http.StatusSeeOther,
+		"/my-reservation",
+		"",
+		"error",
+	},
+	{
+		"Shows non-existent reservation",
+		(*Repository).MyReservationDetails,
+		"GET",
+		11,
+		nil,
+		http.StatusSeeOther,
+		"/my-reservation",
+		"",
+		"error",
+	},
+	{
+		"Changes dates",
+		(*Repository).PostMyReservationChangeDates,
+		"POST",
+		2,
+		url.Values{
+			"start_date": {futureStartDate},
+			"end_date":   {futureEndDate},
+		},
+		http.StatusSeeOther,
+		"/my-reservation/details",
+		"",
+		"success",
+	},
+	{
+		"Changes dates when room is not available",
+		(*Repository).PostMyReservationChangeDates,
+		"POST",
+		2,
+		url.Values{
+			"start_date": {"2100-01-01"},
+			"end_date":   {"2100-01-03"},
+		},
+		http.StatusSeeOther,
+		"/my-reservation/details",
+		"",
+		"warning",
+	},
+	{
+		"Changes dates to the past",
+		(*Repository).PostMyReservationChangeDates,
+		"POST",
+		2,
+		url.Values{
+			"start_date": {"2000-01-01"},
+			"end_date":   {"2000-01-03"},
+		},
+		http.StatusSeeOther,
+		"/my-reservation/details",
+		"",
+		"error",
+	},
+	{
+		"Changes dates with departure before arrival",
+		(*Repository).PostMyReservationChangeDates,
+		"POST",
+		2,
+		url.Values{
+			"start_date": {futureEndDate},
+			"end_date":   {futureStartDate},
+		},
+		http.StatusSeeOther,
+		"/my-reservation/details",
+		"",
+		"error",
+	},
+	{
+		"Changes dates inside cancellation window",
+		(*Repository).PostMyReservationChangeDates,
+		"POST",
+		3,
+		url.Values{
+			"start_date": {futureStartDate},
+			"end_date":   {futureEndDate},
+		},
+		http.StatusSeeOther,
+		"/my-reservation/details",
+		"",
+		"error",
+	},
+	{
+		"Cancels reservation",
+		(*Repository).PostMyReservationCancel,
+		"POST",
+		2,
+		url.Values{},
+		http.StatusSeeOther,
+		"/my-reservation/details",
+		"",
+		"success",
+	},
+	{
+		"Cancels reservation inside cancellation window",
+		(*Repository).PostMyReservationCancel,
+		"POST",
+		3,
+		url.Values{},
+		http.StatusSeeOther,
+		"/my-reservation/details",
+		"",
+		"error",
+	},
+	{
+		"Cancels reservation which is already cancelled",
+		(*Repository).PostMyReservationCancel,
+		"POST",
+		4,
+		url.Values{},
+		http.StatusSeeOther,
+		"/my-reservation/details",
+		"",
+		"error",
+	},
+}
+
+func TestRepository_GuestReservation(t *testing.T) {
+	for _, test := range guestReservationTests {
+		var reqBody io.Reader
+
+		if test.body != nil {
+			reqBody = strings.NewReader(test.body.Encode())
+		}
+
+		// Create request and store context on it which includes the `X-Session` header
+		// in order to read to/from the `Session object`
+		req, err := http.NewRequest(test.method, "/my-reservation", reqBody)
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		// Store the id of the reservation the guest looked up
+		if test.reservationID > 0 {
+			session.Put(ctx, "guest_reservation_id", test.reservationID)
+		}
+
+		// This fakes all of the request/response lifecycle
+		// Stores the response we get from the request
+		responseRecorder := httptest.NewRecorder()
+
+		test.handler(Repo, responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf(
+				"Test %s returns wrong response status code: got %d, wanted %d",
+				test.name,
+				responseRecorder.Code,
+				test.expectedStatusCode,
+			)
+		}
+
+		if test.expectedRedirectURL != "" {
+			// Get redirect URL
+			redirectURL, err := responseRecorder.Result().Location()
+			if err != nil {
+				log.Println(err)
+			}
+
+			if redirectURL.String() != test.expectedRedirectURL {
+				t.Errorf(
+					"Test %s redirects user to wrong URL: got %s, wanted %s",
+					test.name,
+					redirectURL.String(),
+					test.expectedRedirectURL,
+				)
+			}
+		}
+
+		if test.expectedHTML != "" {
+			html := responseRecorder.Body.String()
+
+			if !strings.Contains(html, test.expectedHTML) {
+				t.Errorf(
+					"Test %s return wrong HTML: expected %s",
+					test.name,
+					test.expectedHTML,
+				)
+			}
+		}
+
+		if test.expectedFlash != "" && !session.Exists(ctx, test.expectedFlash) {
+			t.Errorf("Test %s did not store a %s message in the session", test.name, test.expectedFlash)
+		}
+	}
+}
+
+func TestReservationEmails_CalendarInvites(t *testing.T) {
+	reservation := models.Reservation{
+		ID: 2,
+		FirstName: "John",
+		LastName: "Smith",
+		Email: "john@smith.com",
+		StartDate: time.Now().AddDate(0, 1, 10),
+		EndDate: time.Now().AddDate(0, 1, 12),
+		ConfirmationCode: "ABCDEFGHJK",
+		Room: models.Room{ID: 1, RoomName: "General's Quarters"},
+	}
+
+	confirmationEmails, err := Repo.reservationConfirmationEmails(reservation, models.Quote{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changedEmails, err := Repo.reservationChangedEmails(reservation, reservation.StartDate, reservation.EndDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancelledEmails, err := Repo.reservationCancelledEmails(reservation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		emails []models.MailData
+		method string
+	}{
+		{"confirmation", confirmationEmails, "METHOD:REQUEST"},
+		{"change", changedEmails, "METHOD:REQUEST"},
+		{"cancellation", cancelledEmails, "METHOD:CANCEL"},
+	}
+
+	for _, test := range tests {
+		guestMsg, ownerMsg := test.emails[0], test.emails[1]
+
+		if len(guestMsg.Attachments) != 1 || !strings.Contains(string(guestMsg.Attachments[0].Data), test.method) {
+			t.Errorf("Guest %s email has wrong attachments: %+v", test.name, guestMsg.Attachments)
+		}
+
+		if len(ownerMsg.Attachments) != 0 {
+			t.Errorf("Owner %s email should not have attachments", test.name)
+		}
+
+		if ownerMsg.To != app.OwnerEmail {
+			t.Errorf("Owner %s email sent to %s instead of %s", test.name, ownerMsg.To, app.OwnerEmail)
+		}
+	}
+}
@@ -1,27 +1,37 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/driver"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/emails"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/loginguard"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/pricing"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
 	dbrepository "github.com/LuisBarroso37/bed-and-breakfast/internal/repository/db-repository"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/sessionstore"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/webhooks"
 	"github.com/go-chi/chi/v5"
 )
 
 type Repository struct {
 	App *config.AppConfig
 	DB repository.DatabaseRepository
+	// Counts failed logins and locks accounts and IP addresses which fail too often
+	LoginGuard *loginguard.Guard
 }
 
 // Repository used by the handlers
@@ -29,9 +39,12 @@ var Repo *Repository
 
 // Create a new repository
 func NewRepository(app *config.AppConfig, pool *driver.DB) *Repository {
+	db := dbrepository.NewPostgresRepository(pool.SQL, app)
+
 	return &Repository{ 
 		App: app,
-		DB: dbrepository.NewPostgresRepository(pool.SQL, app),
+		DB: db,
+		LoginGuard: loginguard.New(loginguard.NewDatabaseStore(db), loginguard.DefaultConfig),
 	}
 }
 
@@ -40,6 +53,7 @@ func NewTestRepository(app *config.AppConfig) *Repository {
 	return &Repository{ 
 		App: app,
 		DB: dbrepository.NewTestRepository(app),
+		LoginGuard: loginguard.New(loginguard.NewMemoryStore(), loginguard.DefaultConfig),
 	}
 }
 
@@ -89,10 +103,24 @@ func (repo *Repository) MakeReservation(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Store room name in `reservation` and update `reservation` in the `Session` object
+	// Store room name and capacity in `reservation` and update `reservation` in the `Session` object
 	reservation.Room.RoomName = room.RoomName
+	reservation.Room.Capacity = room.Capacity
+	if reservation.Adults == 0 {
+		reservation.Adults = 1
+	}
 	repo.App.Session.Put(r.Context(), "reservation", reservation)
 
+	// Calculate the price of the stay
+	plan, err := repo.DB.GetRatePlanForRoom(reservation.RoomID)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't get room rates")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	quote := pricing.Calculate(plan, reservation.StartDate, reservation.EndDate)
+
 	// Parse dates into YYYY-MM-DD string format
 	startDate := reservation.StartDate.Format("2006-01-02")
 	endDate := reservation.EndDate.Format("2006-01-02")
@@ -102,9 +130,10 @@ func (repo *Repository) MakeReservation(w http.ResponseWriter, r *http.Request)
 	stringMap["start_date"] = startDate
 	stringMap["end_date"] = endDate
 
-	// Store reservation in data map
+	// Store reservation and price breakdown in data map
 	data := make(map[string]interface{})
 	data["reservation"] = reservation
+	data["quote"] = quote
 
 	render.RenderTemplate(w, r, "make-reservation.page.tmpl", &models.TemplateData{
 		Form: forms.New(nil),
@@ -149,6 +178,46 @@ func (repo *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if !room.Active {
+		repo.App.Session.Put(r.Context(), "error", "This room can't be booked at the moment")
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	// Check the stay rules of the room again, since they can change while the guest fills in the form
+	reasons, err := repo.stayRuleReasons(roomID, startDate, endDate)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't get stay rules")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// The guest can change the party size in the form, which is checked against the room's capacity below
+	adults, children, guestsErr := helpers.ParseGuests(r.Form.Get("adults"), r.Form.Get("children"))
+
+	if len(reasons) > 0 {
+		// Keep the dates in the `Session` object so that the guest can change them
+		repo.App.Session.Put(r.Context(), "reservation", models.Reservation{
+			StartDate: startDate,
+			EndDate: endDate,
+			Adults: adults,
+			Children: children,
+		})
+		repo.App.Session.Put(r.Context(), "error", strings.Join(reasons, ". "))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	// Calculate the price of the stay
+	plan, err := repo.DB.GetRatePlanForRoom(roomID)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't get room rates")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	quote := pricing.Calculate(plan, startDate, endDate)
+
 	// Create reservation with the form data
 	reservation := models.Reservation{
 		FirstName: r.Form.Get("first_name"),
@@ -159,6 +228,10 @@ func (repo *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Reque
 		Room: room,
 		EndDate:   endDate,
 		RoomID:    roomID,
+		Adults:    adults,
+		Children:  children,
+		TotalPrice: quote.Total,
+		Quote: quote,
 	}
 
 	// Validate form data and add any errors that might exist to `form` variable
@@ -167,10 +240,17 @@ func (repo *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Reque
 	form.MinLength("first_name", 2)
 	form.IsEmail("email")
 
+	if guestsErr != nil {
+		form.Errors.Add("adults", "Enter at least one adult and no negative number of children")
+	} else if reservation.Guests() > room.Capacity {
+		form.Errors.Add("adults", fmt.Sprintf("This room sleeps at most %d guests", room.Capacity))
+	}
+
 	// Rerender make reservation form with updated error information
 	if !form.IsValid() {
 		data := make(map[string]interface{})
 		data["reservation"] = reservation
+		data["quote"] = quote
 
 		stringMap := make(map[string]string)
 		stringMap["start_date"] = sd
@@ -185,69 +265,46 @@ func (repo *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Insert reservation into database
-	reservationID, err := repo.DB.InsertReservation(reservation)
+	// Give the reservation a confirmation code so that the guest can look it up later
+	reservation.ConfirmationCode, err = helpers.GenerateConfirmationCode()
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "Can't insert reservation into the database")
+		repo.App.Session.Put(r.Context(), "error", "Can't generate confirmation code")
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
-	// Save data in a RoomRestriction struct and save it in the database
-	roomRestriction := models.RoomRestriction{
-		StartDate: startDate,
-		EndDate: endDate,
-		RoomID: roomID,
-		ReservationID: reservationID,
-		RestrictionID: 1,
+	confirmationEmails, err := repo.reservationConfirmationEmails(reservation, quote)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't create confirmation emails")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Insert reservation, its room restriction and the confirmation emails into the database
+	reservationID, err := repo.DB.InsertReservationWithRestriction(reservation, confirmationEmails)
+	if errors.Is(err, repository.ErrRoomNotAvailable) {
+		// Keep the dates in the `Session` object so that the guest can search again for the same dates
+		repo.App.Session.Put(r.Context(), "reservation", models.Reservation{
+			StartDate: startDate,
+			EndDate: endDate,
+			Adults: adults,
+			Children: children,
+		})
+		repo.App.Session.Put(r.Context(), "warning", "Sorry, this room has just been booked for your dates. Please search again for available rooms")
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
 	}
 
-	err = repo.DB.InsertRoomRestriction(roomRestriction)
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "Can't insert room restriction into the database")
+		repo.App.Session.Put(r.Context(), "error", "Can't insert reservation into the database")
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
-	// Send email to guest
-	htmlMessage := fmt.Sprintf(`
-			<strong>Reservation confirmation</strong><br>
-			Dear %s, <br>
-			This is to confirm your reservation of the %s from %s to %s.
-		`, reservation.FirstName,
-		reservation.Room.RoomName, 
-		reservation.StartDate.Format("2006-01-02"), 
-		reservation.EndDate.Format("2006-01-02"),
-	)
-
-	msg := models.MailData{
-		To: reservation.Email,
-		From: "me@here.com",
-		Subject: "Reservation confirmation",
-		Content: htmlMessage,
-		Template: "basic.html",
-	}
-	repo.App.MailChan <- msg
-
-	// Send email to owner
-	htmlMessage = fmt.Sprintf(`
-			<strong>Reservation confirmation</strong><br>
-			Dear %s:, <br>
-			This is to confirm your reservation of the %s from %s to %s.
-		`, reservation.FirstName,
-		reservation.Room.RoomName, 
-		reservation.StartDate.Format("2006-01-02"), 
-		reservation.EndDate.Format("2006-01-02"),
-	)
-
-	msg = models.MailData{
-		To: "me@here.com",
-		From: "me@here.com",
-		Subject: "Reservation confirmation",
-		Content: htmlMessage,
-		Template: "basic.html",
-	}
-	repo.App.MailChan <- msg
+	reservation.ID = reservationID
+
+	repo.sendWebhook(webhooks.EventReservationCreated, newApiAdminReservation(reservation))
+	repo.audit(r, audit.ActionCreated, audit.EntityReservation, reservation.ID, nil, newApiAdminReservation(reservation))
 
 	// Update `reservation` in `Session` object
 	repo.App.Session.Put(r.Context(), "reservation", reservation)
@@ -256,9 +313,52 @@ func (repo *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Reque
 	http.Redirect(w, r, "/reservation-summary", http.StatusSeeOther)
 }
 
+// Builds the emails confirming a new reservation to the guest and to the owner.
+// The guest gets a calendar invite for the stay
+func (repo *Repository) reservationConfirmationEmails(reservation models.Reservation, quote models.Quote) ([]models.MailData, error) {
+	data := emails.ConfirmationData{
+		Reservation: reservation,
+		Quote: quote,
+	}
+
+	guestMsg, err := emails.NewMail(emails.ConfirmationGuest, reservation.Email, data)
+	if err != nil {
+		return nil, err
+	}
+
+	invite, err := emails.CalendarInvite(reservation, emails.MethodRequest)
+	if err != nil {
+		return nil, err
+	}
+	guestMsg.Attachments = append(guestMsg.Attachments, invite)
+
+	ownerMsg, err := emails.NewMail(emails.ConfirmationOwner, repo.App.OwnerEmail, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return []models.MailData{guestMsg, ownerMsg}, nil
+}
+
 // SearchAvailability is the search availability page handler
 func (repo *Repository) SearchAvailability(w http.ResponseWriter, r *http.Request) {
-	render.RenderTemplate(w, r, "search-availability.page.tmpl", &models.TemplateData{})
+	// Prefill the dates if the guest already picked them (e.g. the room was booked by someone else meanwhile)
+	stringMap := make(map[string]string)
+
+	reservation, ok := repo.App.Session.Get(r.Context(), "reservation").(models.Reservation)
+	if ok && !reservation.StartDate.IsZero() {
+		stringMap["start_date"] = reservation.StartDate.Format("2006-01-02")
+		stringMap["end_date"] = reservation.EndDate.Format("2006-01-02")
+	}
+
+	if ok && reservation.Adults > 0 {
+		stringMap["adults"] = strconv.Itoa(reservation.Adults)
+		stringMap["children"] = strconv.Itoa(reservation.Children)
+	}
+
+	render.RenderTemplate(w, r, "search-availability.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+	})
 }
 
 // Accepts form data for search availability and returns a response
@@ -281,32 +381,61 @@ func (repo *Repository) PostSearchAvailability(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// Parse the party size, so that only rooms sleeping everyone are offered
+	adults, children, err := helpers.ParseGuests(r.Form.Get("adults"), r.Form.Get("children"))
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Enter at least one adult and no negative number of children")
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
 	// Search for availability in all rooms
-	rooms, err := repo.DB.SearchAvailabilityForAllRooms(startDate, endDate)
+	rooms, err := repo.DB.SearchAvailabilityForAllRooms(startDate, endDate, adults + children)
 	if err != nil {
 		repo.App.Session.Put(r.Context(), "error", "Can't get available rooms")
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
-	// If there is no availability
-	if len(rooms) == 0 {
-		repo.App.Session.Put(r.Context(), "error", "No availability")
-		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+	// Rooms whose stay rules don't allow the stay can't be booked for these dates
+	rooms, rejected, err := repo.applyStayRules(rooms, startDate, endDate)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't get available rooms")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
-	// Store start and end dates in the `Session` object
+	// Store start and end dates and the party size in the `Session` object
 	// This data will be used later in the `make-reservation` page
+	// or to search again for other dates
 	reservation := models.Reservation{
 		StartDate: startDate,
 		EndDate: endDate,
+		Adults: adults,
+		Children: children,
 	}
 	repo.App.Session.Put(r.Context(), "reservation", reservation)
 
+	// Tell the guest why the free rooms can't be booked, so that he/she can change the dates
+	if len(rooms) == 0 && len(rejected) > 0 {
+		repo.App.Session.Put(r.Context(), "error", rejectedRoomsMessage(rejected))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	// If there is no availability
+	if len(rooms) == 0 {
+		repo.App.Session.Put(r.Context(), "error", "No availability")
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
 	// Render `choose-room` page with available rooms information
 	data := make(map[string]interface{})
 	data["rooms"] = rooms
+	data["rejected"] = rejected
+
+	data["reservation"] = reservation
 
 	render.RenderTemplate(w, r, "choose-room.page.tmpl", &models.TemplateData{
 		Data: data,
@@ -319,6 +448,8 @@ type jsonResponse struct {
 	RoomID 		string	`json:"room_id"`
 	StartDate string 	`json:"start_date"`
 	EndDate 	string 	`json:"end_date"`
+	Adults 		string 	`json:"adults"`
+	Children 	string 	`json:"children"`
 }
 
 // Accepts form data for search availability and returns a JSON response
@@ -346,18 +477,58 @@ func (repo *Repository) SearchAvailabilityJson(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	adults, children, err := helpers.ParseGuests(r.Form.Get("adults"), r.Form.Get("children"))
+	if err != nil {
+		SendJsonErrorResponse(w, false, "Enter at least one adult and no negative number of children")
+		return
+	}
+
 	available, err := repo.DB.SearchAvailabilityByDatesAndRoom(startDate, endDate, roomId)
 	if err != nil {
 		SendJsonErrorResponse(w, false, "Error connecting to database")
 		return
 	}
 
+	// A free room can still not be bookable because it's too small for the party
+	// or because of its stay rules, in which case the guest is told why
+	message := ""
+	if available {
+		room, err := repo.DB.GetRoomByID(roomId)
+		if err != nil {
+			SendJsonErrorResponse(w, false, "Error connecting to database")
+			return
+		}
+
+		if !room.Active {
+			available = false
+			message = "This room can't be booked at the moment"
+		} else if adults + children > room.Capacity {
+			available = false
+			message = fmt.Sprintf("This room sleeps at most %d guests", room.Capacity)
+		}
+	}
+
+	if available {
+		reasons, err := repo.stayRuleReasons(roomId, startDate, endDate)
+		if err != nil {
+			SendJsonErrorResponse(w, false, "Error connecting to database")
+			return
+		}
+
+		if len(reasons) > 0 {
+			available = false
+			message = strings.Join(reasons, ". ")
+		}
+	}
+
 	// Response to send back as JSON
 	res := jsonResponse{
 		OK: available,
-		Message: "",
+		Message: message,
 		StartDate: sd,
 		EndDate: ed,
+		Adults: strconv.Itoa(adults),
+		Children: strconv.Itoa(children),
 		RoomID: strconv.Itoa(roomId),
 	}
 
@@ -369,16 +540,6 @@ func (repo *Repository) SearchAvailabilityJson(w http.ResponseWriter, r *http.Re
 	w.Write(jsonRes)
 }
 
-// Generals is the Generals quarters room page handler
-func (repo *Repository) Generals(w http.ResponseWriter, r *http.Request) {
-	render.RenderTemplate(w, r, "generals-quarters.page.tmpl", &models.TemplateData{})
-}
-
-// Majors is the Majors quarters room page handler
-func (repo *Repository) Majors(w http.ResponseWriter, r *http.Request) {
-	render.RenderTemplate(w, r, "majors-suite.page.tmpl", &models.TemplateData{})
-}
-
 // Contact is the contact page handler
 func (repo *Repository) Contact(w http.ResponseWriter, r *http.Request) {
 	render.RenderTemplate(w, r, "contact.page.tmpl", &models.TemplateData{})
@@ -400,6 +561,11 @@ func (repo *Repository) ReservationSummary(w http.ResponseWriter, r *http.Reques
 	data := make(map[string]interface{})
 	data["reservation"] = reservation
 
+	// Show the price breakdown the guest was quoted when booking
+	if len(reservation.Quote.Nights) > 0 {
+		data["quote"] = reservation.Quote
+	}
+
 	// Parse start and end dates into YYYY-MM-DD string format
 	// Store them in a string map
 	startDate := reservation.StartDate.Format("2006-01-02")
@@ -473,6 +639,14 @@ func (repo *Repository) BookRoom(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Links without a party size book the room for a single adult
+	adults, children, err := helpers.ParseGuests(r.URL.Query().Get("adults"), r.URL.Query().Get("children"))
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Invalid number of guests")
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
 	// Get room information from database
 	room, err := repo.DB.GetRoomByID(roomID)
 	if err != nil {
@@ -481,11 +655,40 @@ func (repo *Repository) BookRoom(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Deactivated rooms are hidden, but old links to them still work
+	if !room.Active {
+		repo.App.Session.Put(r.Context(), "error", "This room can't be booked at the moment")
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	if adults + children > room.Capacity {
+		repo.App.Session.Put(r.Context(), "error", fmt.Sprintf("This room sleeps at most %d guests", room.Capacity))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	// The link can be followed after the stay rules of the room changed
+	reasons, err := repo.stayRuleReasons(roomID, startDate, endDate)
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Can't get stay rules from database")
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	if len(reasons) > 0 {
+		repo.App.Session.Put(r.Context(), "error", strings.Join(reasons, ". "))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
 	// Create reservation
 	var reservation models.Reservation
 	reservation.RoomID = roomID
 	reservation.StartDate = startDate
 	reservation.EndDate = endDate
+	reservation.Adults = adults
+	reservation.Children = children
 	reservation.Room.RoomName = room.RoomName
 
 	// Set `reservation` in the `Session` object
@@ -533,20 +736,74 @@ func (repo *Repository) PostShowLogin(w http.ResponseWriter, r *http.Request) {
 	email := r.Form.Get("email")
 	password := r.Form.Get("password")
 
+	// Attempts are refused while the account or IP address has to wait after failed logins,
+	// without checking the password, so that guessing it doesn't get any faster
+	decision, err := repo.LoginGuard.Check(email, helpers.ClientIP(r))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if !decision.Allowed {
+		repo.refuseLogin(w, r, email, decision)
+		return
+	}
+
 	// Authenticate user
 	id, _, err := repo.DB.Authenticate(email, password)
+	if errors.Is(err, repository.ErrUserDisabled) {
+		repo.recordLogin(r, email, id, models.LoginFailureDisabled)
+		repo.App.Session.Put(r.Context(), "error", "This account has been disabled")
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+
 	if err != nil {
+		err = repo.loginFailed(r, email, 0, models.LoginFailureInvalidCredentials)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
 		repo.App.Session.Put(r.Context(), "error", "Invalid login credentials")
 		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
 		return
 	}
 
-	// Store user id in `Session`
-	repo.App.Session.Put(r.Context(), "user_id", id)
-	repo.App.Session.Put(r.Context(), "success", "Logged in successfully")
+	user, err := repo.DB.GetUserByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// Users with two-factor authentication aren't logged in until they enter a code of their authenticator app
+	if user.TwoFactorEnabled {
+		repo.startTwoFactorLogin(r, user)
+		http.Redirect(w, r, "/auth/two-factor", http.StatusSeeOther)
+		return
+	}
+
+	err = repo.loginSucceeded(r, user)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.logIn(r, user)
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// Logs the user in by storing the user id in `Session`.
+// The session version is stored too, so that the session is logged out once the password changes.
+// Where the user logged in from is shown in the list of their sessions
+func (repo *Repository) logIn(r *http.Request, user models.User) {
+	repo.App.Session.Put(r.Context(), "user_id", user.ID)
+	repo.App.Session.Put(r.Context(), "session_version", user.SessionVersion)
+	repo.App.Session.Put(r.Context(), sessionstore.KeyIPAddress, helpers.ClientIP(r))
+	repo.App.Session.Put(r.Context(), sessionstore.KeyUserAgent, r.UserAgent())
+	repo.App.Session.Put(r.Context(), "success", "Logged in successfully")
+}
+
 // Logout user
 func (repo *Repository) Logout(w http.ResponseWriter, r *http.Request) {
 	repo.App.Session.Destroy(r.Context())
@@ -622,6 +879,26 @@ func (repo *Repository) AdminShowReservation(w http.ResponseWriter, r *http.Requ
 	data := make(map[string]interface{})
 	data["reservation"] = reservation
 
+	// Show the price breakdown the guest was quoted, which older reservations don't have
+	if len(reservation.Quote.Nights) > 0 {
+		data["quote"] = reservation.Quote
+	}
+
+	// Show who changed the reservation to users who can see the audit log
+	if user, _ := helpers.CurrentUser(r); user.Can(models.PermissionViewAuditLog) {
+		history, err := repo.DB.GetAuditEntries(models.AuditFilter{
+			EntityType: audit.EntityReservation,
+			EntityID: id,
+			Limit: reservationHistorySize,
+		})
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		data["history"] = history
+	}
+
 	render.RenderTemplate(w, r, "admin-show-reservation.page.tmpl", &models.TemplateData{
 		StringMap: stringMap,
 		Data: data,
@@ -656,6 +933,9 @@ func (repo *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.
 		return
 	}
 
+	// Keep the stored values for the audit log
+	before := newApiAdminReservation(reservation)
+
 	// Store form data in the reservation
 	reservation.FirstName = r.Form.Get("first_name")
 	reservation.LastName = r.Form.Get("last_name")
@@ -690,6 +970,9 @@ func (repo *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.
 		return
 	}
 
+	repo.sendWebhook(webhooks.EventReservationUpdated, newApiAdminReservation(reservation))
+	repo.audit(r, audit.ActionUpdated, audit.EntityReservation, id, before, newApiAdminReservation(reservation))
+
 	// Store success message in `Session`
 	repo.App.Session.Put(r.Context(), "success", "Reservation successfully updated")
 
@@ -771,14 +1054,19 @@ func (repo *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http
 	data["rooms"] = rooms
 
 	for _, room := range rooms {
-		// Create reservation and owner block maps
+		// Create reservation, owner block and external booking maps
 		reservationMap := make(map[string]int)
 		ownerBlockMap := make(map[string]int)
+		externalBookingMap := make(map[string]int)
+		blockSpanMap := make(map[string]blockSpan)
+		// Guest and party size of the reservations, shown when hovering over them
+		reservationTitleMap := make(map[string]string)
 
 		// Add an entry in the maps for every day of the month
 		for day := firstDayOfMonth; !day.After(lastDayOfMonth); day = day.AddDate(0, 0, 1) {
 			reservationMap[day.Format("2006-01-2")] = 0
 			ownerBlockMap[day.Format("2006-01-2")] = 0
+			externalBookingMap[day.Format("2006-01-2")] = 0
 		}
 
 		// Get all restrictions for the room in the current month
@@ -792,19 +1080,48 @@ func (repo *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http
 			// If reservation id is bigger than 0, it is a reservation
 			if restriction.ReservationID > 0 {
 				// Loop through the dates between the start date and the end date of the restriction
+				title := fmt.Sprintf("%s %s, %s", restriction.Reservation.FirstName, restriction.Reservation.LastName, restriction.Reservation.PartySize())
+
 				for day := restriction.StartDate; !day.After(restriction.EndDate); day = day.AddDate(0, 0, 1) {
 					reservationMap[day.Format("2006-01-2")] = restriction.ReservationID
+					reservationTitleMap[day.Format("2006-01-2")] = title
+				}
+			} else if restriction.RestrictionID == models.RestrictionExternalBooking {
+				// External bookings are kept in sync by the calendar imports, so they can't be removed here.
+				// The guest leaves on the end date, which stays available
+				for day := restriction.StartDate; day.Before(restriction.EndDate); day = day.AddDate(0, 0, 1) {
+					externalBookingMap[day.Format("2006-01-2")] = restriction.ID
+				}
+			} else if restriction.Nights() > 1 {
+				// Owner blocks of several nights are shown as one span, which links to the block form.
+				// Only the days in the current month are spanned
+				var days []string
+				for night := 0; night < restriction.Nights(); night++ {
+					day := restriction.StartDate.AddDate(0, 0, night).Format("2006-01-2")
+					if _, ok := ownerBlockMap[day]; ok {
+						days = append(days, day)
+					}
+				}
+
+				for i, day := range days {
+					if i == 0 {
+						blockSpanMap[day] = blockSpan{Block: restriction, Days: len(days)}
+					} else {
+						blockSpanMap[day] = blockSpan{Covered: true}
+					}
 				}
 			} else {
-				// Otherwise it is an owner block
-				// Each block is 1 day long
+				// Otherwise it is an owner block of 1 day, which can be toggled in the calendar
 				ownerBlockMap[restriction.StartDate.Format("2006-01-2")] = restriction.ID
 			}
 		}
 
 		// Store maps in the data map with a reference to the room
 		data[fmt.Sprintf("reservation_map_%d", room.ID)] = reservationMap
+		data[fmt.Sprintf("reservation_title_map_%d", room.ID)] = reservationTitleMap
 		data[fmt.Sprintf("block_map_%d", room.ID)] = ownerBlockMap
+		data[fmt.Sprintf("external_booking_map_%d", room.ID)] = externalBookingMap
+		data[fmt.Sprintf("block_span_map_%d", room.ID)] = blockSpanMap
 
 		// Store owner block map in `Session`.
 		// This will be used in the POST handler to compare the calendar that was first rendered 
@@ -833,6 +1150,13 @@ func (repo *Repository) AdminProcessReservation(w http.ResponseWriter, r *http.R
 	year := r.URL.Query().Get("y")
 	month := r.URL.Query().Get("m")
 
+	// Keep the stored state, so that only an actual change is recorded
+	reservation, err := repo.DB.GetReservationByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
 	// Mark reservation as processed
 	err = repo.DB.UpdateProcessedForReservation(id, true)
 	if err != nil {
@@ -840,6 +1164,12 @@ func (repo *Repository) AdminProcessReservation(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	repo.sendReservationWebhook(webhooks.EventReservationProcessed, id)
+
+	if !reservation.Processed {
+		repo.audit(r, audit.ActionProcessed, audit.EntityReservation, id, map[string]bool{"processed": false}, map[string]bool{"processed": true})
+	}
+
 	// Store success message in `Session`
 	repo.App.Session.Put(r.Context(), "success", "Reservation marked as processed")
 
@@ -862,6 +1192,15 @@ func (repo *Repository) AdminDeleteReservation(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// Keep the reservation for the webhook payload, since it won't exist after being deleted
+	reservation, err := repo.DB.GetReservationByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		reservation = models.Reservation{ID: id}
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
 	// Delete the reservation
 	err = repo.DB.DeleteReservation(id)
 	if err != nil {
@@ -869,6 +1208,9 @@ func (repo *Repository) AdminDeleteReservation(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	repo.sendWebhook(webhooks.EventReservationDeleted, newApiAdminReservation(reservation))
+	repo.audit(r, audit.ActionDeleted, audit.EntityReservation, id, newApiAdminReservation(reservation), nil)
+
 	// Extract query parameters
 	year := r.URL.Query().Get("y")
 	month := r.URL.Query().Get("m")
@@ -935,6 +1277,10 @@ func (repo *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, r *
 					helpers.ServerError(w, err)
 					return
 				}
+
+				blockDate, _ := time.Parse("2006-01-2", date)
+				repo.sendWebhook(webhooks.EventBlockDeleted, newApiBlock(value, room.ID, blockDate))
+				repo.audit(r, audit.ActionDeleted, audit.EntityBlock, value, newApiBlock(value, room.ID, blockDate), nil)
 			}
 		}
 	}
@@ -960,11 +1306,14 @@ func (repo *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, r *
 			}
 
 			// Insert owner block as room restriction for given room
-			err = repo.DB.InsertBlockForRoom(roomID, date)
+			blockID, err := repo.DB.InsertBlockForRoom(roomID, date)
 			if err != nil {
 				helpers.ServerError(w, err)
 				return
 			}
+
+			repo.sendWebhook(webhooks.EventBlockCreated, newApiBlock(blockID, roomID, date))
+			repo.audit(r, audit.ActionCreated, audit.EntityBlock, blockID, nil, newApiBlock(blockID, roomID, date))
 		}
 	}
 
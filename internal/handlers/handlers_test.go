@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/driver"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
 	"github.com/go-chi/chi/v5"
 )
@@ -50,17 +51,111 @@ var testsWithoutSession = []struct {
 	{"about", "/about", "GET", http.StatusOK},
 	{"generals-quarters", "/generals-quarters", "GET", http.StatusOK},
 	{"majors-suite", "/majors-suite", "GET", http.StatusOK},
+	{"rooms", "/rooms", "GET", http.StatusOK},
+	{"room", "/rooms/generals-quarters", "GET", http.StatusOK},
+	{"inactive room", "/rooms/closed-room", "GET", http.StatusNotFound},
+	{"non-existent room", "/rooms/invalid-room", "GET", http.StatusNotFound},
 	{"search-availability", "/search-availability", "GET", http.StatusOK},
 	{"contact", "/contact", "GET", http.StatusOK},
+	{"my reservation", "/my-reservation", "GET", http.StatusOK},
+	{"empty cart", "/cart", "GET", http.StatusOK},
+	{"remove from empty cart", "/cart/0/remove", "POST", http.StatusNotFound},
+	{"email opt-out", "/my-reservation/email-opt-out?code=ABCDEFGHJK&email=john%40smith.com", "GET", http.StatusOK},
+	{"email opt-out with wrong email", "/my-reservation/email-opt-out?code=ABCDEFGHJK&email=jane%40smith.com", "GET", http.StatusOK},
+	{"room calendar feed", "/calendar/rooms/1.ics?token=valid-token", "GET", http.StatusOK},
+	{"room calendar feed of non-existent room", "/calendar/rooms/3.ics?token=valid-token", "GET", http.StatusNotFound},
+	{"all rooms calendar feed", "/calendar/all.ics?token=valid-token", "GET", http.StatusOK},
+	{"calendar feed without token", "/calendar/all.ics", "GET", http.StatusUnauthorized},
+	{"calendar feed with invalid token", "/calendar/all.ics?token=invalid-token", "GET", http.StatusUnauthorized},
+	{"calendar feed with revoked token", "/calendar/rooms/1.ics?token=revoked-token", "GET", http.StatusUnauthorized},
 	{"non-existent route", "/invalid-route", "GET", http.StatusNotFound},
 	{"login", "/auth/login", "GET", http.StatusOK},
+	{"forgot password", "/auth/forgot-password", "GET", http.StatusOK},
+	{"reset password with valid token", "/auth/reset-password?token=valid-reset-token", "GET", http.StatusOK},
+	{"reset password with expired token", "/auth/reset-password?token=expired-reset-token", "GET", http.StatusOK},
 	{"logout", "/auth/logout", "GET", http.StatusOK},
 	{"admin dashboard", "/admin/dashboard", "GET", http.StatusOK},
 	{"admin all reservations", "/admin/all-reservations", "GET", http.StatusOK},
 	{"admin new reservations", "/admin/new-reservations", "GET", http.StatusOK},
+	{"admin bookings", "/admin/bookings", "GET", http.StatusOK},
+	{"admin booking", "/admin/bookings/1", "GET", http.StatusOK},
+	{"admin non-existent booking", "/admin/bookings/9", "GET", http.StatusNotFound},
+	{"admin reservation of a booking", "/admin/reservations/all/5", "GET", http.StatusOK},
 	{"admin show reservation", "/admin/reservations/new/1", "GET", http.StatusOK},
 	{"admin resservation calendar", "/admin/reservations-calendar", "GET", http.StatusOK},
 	{"admin resservation calendar with query params", "/admin/reservations-calendar?y=2020&m=1", "GET", http.StatusOK},
+	{"admin owner blocks", "/admin/blocks", "GET", http.StatusOK},
+	{"admin new owner block", "/admin/blocks/new?room=1&start=2050-01-01&end=2050-01-15", "GET", http.StatusOK},
+	{"admin owner block", "/admin/blocks/3", "GET", http.StatusOK},
+	{"admin owner block of a reservation", "/admin/blocks/2", "GET", http.StatusNotFound},
+	{"admin non-existent owner block", "/admin/blocks/9", "GET", http.StatusNotFound},
+	{"admin delete owner block", "/admin/blocks/3/delete", "GET", http.StatusOK},
+	{"admin delete non-existent owner block", "/admin/blocks/9/delete", "GET", http.StatusNotFound},
+	{"admin stay rules", "/admin/stay-rules", "GET", http.StatusOK},
+	{"admin new stay rule", "/admin/stay-rules/new", "GET", http.StatusOK},
+	{"admin stay rule", "/admin/stay-rules/1", "GET", http.StatusOK},
+	{"admin non-existent stay rule", "/admin/stay-rules/9", "GET", http.StatusNotFound},
+	{"admin delete stay rule", "/admin/stay-rules/2/delete", "GET", http.StatusOK},
+	{"admin delete non-existent stay rule", "/admin/stay-rules/9/delete", "GET", http.StatusNotFound},
+	{"admin rooms", "/admin/rooms", "GET", http.StatusOK},
+	{"admin new room", "/admin/rooms/new", "GET", http.StatusOK},
+	{"admin show room", "/admin/rooms/1", "GET", http.StatusOK},
+	{"admin show non-existent room", "/admin/rooms/3", "GET", http.StatusInternalServerError},
+	{"admin calendar feeds", "/admin/calendar-feeds", "GET", http.StatusOK},
+	{"admin revoke calendar feed", "/admin/calendar-feeds/1/revoke", "GET", http.StatusOK},
+	{"admin revoke non-existent calendar feed", "/admin/calendar-feeds/3/revoke", "GET", http.StatusInternalServerError},
+	{"admin calendar imports", "/admin/calendar-imports", "GET", http.StatusOK},
+	{"admin sync calendar import", "/admin/calendar-imports/1/sync", "GET", http.StatusOK},
+	{"admin sync non-existent calendar import", "/admin/calendar-imports/3/sync", "GET", http.StatusInternalServerError},
+	{"admin delete calendar import", "/admin/calendar-imports/1/delete", "GET", http.StatusOK},
+	{"admin delete non-existent calendar import", "/admin/calendar-imports/3/delete", "GET", http.StatusInternalServerError},
+	{"admin api keys", "/admin/api-keys", "GET", http.StatusOK},
+	{"admin revoke api key", "/admin/api-keys/1/revoke", "POST", http.StatusOK},
+	{"admin revoke non-existent api key", "/admin/api-keys/5/revoke", "POST", http.StatusInternalServerError},
+	{"admin webhooks", "/admin/webhooks", "GET", http.StatusOK},
+	{"admin webhook", "/admin/webhooks/1", "GET", http.StatusOK},
+	{"admin inactive webhook", "/admin/webhooks/2", "GET", http.StatusOK},
+	{"admin non-existent webhook", "/admin/webhooks/3", "GET", http.StatusInternalServerError},
+	{"admin activate webhook", "/admin/webhooks/2/activate", "GET", http.StatusOK},
+	{"admin deactivate webhook", "/admin/webhooks/1/deactivate", "GET", http.StatusOK},
+	{"admin deactivate non-existent webhook", "/admin/webhooks/3/deactivate", "GET", http.StatusInternalServerError},
+	{"admin delete webhook", "/admin/webhooks/1/delete", "GET", http.StatusOK},
+	{"admin delete non-existent webhook", "/admin/webhooks/3/delete", "GET", http.StatusInternalServerError},
+	{"admin emails", "/admin/emails", "GET", http.StatusOK},
+	{"admin dead emails", "/admin/emails?status=dead", "GET", http.StatusOK},
+	{"admin emails with unknown status", "/admin/emails?status=lost", "GET", http.StatusBadRequest},
+	{"admin email", "/admin/emails/3", "GET", http.StatusOK},
+	{"admin non-existent email", "/admin/emails/5", "GET", http.StatusInternalServerError},
+	{"admin resend dead email", "/admin/emails/3/resend", "GET", http.StatusOK},
+	{"admin resend sent email", "/admin/emails/2/resend", "GET", http.StatusOK},
+	{"admin resend pending email", "/admin/emails/1/resend", "GET", http.StatusOK},
+	{"admin resend non-existent email", "/admin/emails/5/resend", "GET", http.StatusInternalServerError},
+	{"admin cancel pending email", "/admin/emails/1/cancel", "GET", http.StatusOK},
+	{"admin cancel sent email", "/admin/emails/2/cancel", "GET", http.StatusOK},
+	{"admin cancel non-existent email", "/admin/emails/5/cancel", "GET", http.StatusInternalServerError},
+	{"admin email templates", "/admin/email-templates", "GET", http.StatusOK},
+	{"admin email template", "/admin/email-templates/confirmation-guest", "GET", http.StatusOK},
+	{"admin change email template", "/admin/email-templates/change-owner", "GET", http.StatusOK},
+	{"admin non-existent email template", "/admin/email-templates/welcome", "GET", http.StatusNotFound},
+	{"admin users", "/admin/users", "GET", http.StatusOK},
+	{"admin login security", "/admin/login-security", "GET", http.StatusOK},
+	{"admin audit log", "/admin/audit-log", "GET", http.StatusOK},
+	{"admin audit log filtered", "/admin/audit-log?entity=user&user=1&from=2021-12-01&to=2021-12-31", "GET", http.StatusOK},
+	{"admin new user", "/admin/users/new", "GET", http.StatusOK},
+	{"admin show user", "/admin/users/2", "GET", http.StatusOK},
+	{"admin show non-existent user", "/admin/users/9", "GET", http.StatusInternalServerError},
+	{"admin enable user", "/admin/users/4/enable", "POST", http.StatusOK},
+	{"admin disable user", "/admin/users/2/disable", "POST", http.StatusOK},
+	{"admin disable non-existent user", "/admin/users/9/disable", "POST", http.StatusInternalServerError},
+	{"admin reset user password", "/admin/users/2/reset-password", "POST", http.StatusOK},
+	{"admin delete user", "/admin/users/3/delete", "POST", http.StatusOK},
+	{"admin delete non-existent user", "/admin/users/9/delete", "POST", http.StatusInternalServerError},
+	{"admin user sessions", "/admin/users/1/sessions", "GET", http.StatusOK},
+	{"admin non-existent user sessions", "/admin/users/9/sessions", "GET", http.StatusInternalServerError},
+	{"admin choose password without reset", "/admin/choose-password", "GET", http.StatusOK},
+	{"admin change password", "/admin/change-password", "GET", http.StatusOK},
+	{"admin two-factor setup", "/admin/two-factor", "GET", http.StatusOK},
+	{"two-factor login without password", "/auth/two-factor", "GET", http.StatusOK},
 }
 
 func TestHandlersThatDoNotRequireSession(t *testing.T) {
@@ -72,7 +167,12 @@ func TestHandlersThatDoNotRequireSession(t *testing.T) {
 	defer testServer.Close()
 
 	for _, test := range testsWithoutSession {
-		res, err := testServer.Client().Get(testServer.URL + test.url)
+		req, err := http.NewRequest(test.method, testServer.URL + test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := testServer.Client().Do(req)
 		if err != nil {
 			t.Log(err)
 			t.Fatal(err)
@@ -106,6 +206,22 @@ var makeReservationTests = []struct {
 		"",
 		`action="/make-reservation"`,
 	},
+	{
+		"Shows price breakdown",
+		models.Reservation{
+			RoomID: 1,
+			StartDate: time.Date(2050, time.January, 3, 0, 0, 0, 0, time.UTC),
+			EndDate: time.Date(2050, time.January, 10, 0, 0, 0, 0, time.UTC),
+			Room: models.Room{
+				ID: 1,
+				RoomName: "General's Quarters",
+			},
+		}, 
+		true,
+		http.StatusOK,
+		"",
+		"Length-of-stay discount (10%)",
+	},
 	{
 		"Reservation not in session", 
 		models.Reservation{}, 
@@ -317,6 +433,87 @@ var postMakeReservationTests = []struct {
 		"/",
 		"",
 	},
+	{
+		"Room booked by someone else in the meantime", 
+		url.Values{
+			"start_date": []string{"2100-01-01"},
+			"end_date": []string{"2100-01-02"},
+			"first_name": []string{"John"},
+			"last_name": []string{"Smith"},
+			"email": []string{"john@smith.com"},
+			"phone": []string{"123456789"},
+			"room_id": []string{"1"},
+		}, 
+		http.StatusSeeOther,
+		"/search-availability",
+		"",
+	},
+	{
+		// Room 1 only takes summer stays of 3 nights or more
+		"Stay rules not followed", 
+		url.Values{
+			"start_date": []string{"2049-07-02"},
+			"end_date": []string{"2049-07-03"},
+			"first_name": []string{"John"},
+			"last_name": []string{"Smith"},
+			"email": []string{"john@smith.com"},
+			"phone": []string{"123456789"},
+			"room_id": []string{"1"},
+		}, 
+		http.StatusSeeOther,
+		"/search-availability",
+		"",
+	},
+	{
+		// Room 1 sleeps 2
+		"Party too big for the room", 
+		url.Values{
+			"start_date": []string{"2050-01-01"},
+			"end_date": []string{"2050-01-02"},
+			"adults": []string{"2"},
+			"children": []string{"1"},
+			"first_name": []string{"John"},
+			"last_name": []string{"Smith"},
+			"email": []string{"john@smith.com"},
+			"phone": []string{"123456789"},
+			"room_id": []string{"1"},
+		}, 
+		http.StatusOK,
+		"",
+		"This room sleeps at most 2 guests",
+	},
+	{
+		"Party without adults", 
+		url.Values{
+			"start_date": []string{"2050-01-01"},
+			"end_date": []string{"2050-01-02"},
+			"adults": []string{"0"},
+			"children": []string{"1"},
+			"first_name": []string{"John"},
+			"last_name": []string{"Smith"},
+			"email": []string{"john@smith.com"},
+			"phone": []string{"123456789"},
+			"room_id": []string{"1"},
+		}, 
+		http.StatusOK,
+		"",
+		"Enter at least one adult and no negative number of children",
+	},
+	{
+		"Deactivated room",
+		url.Values{
+			"start_date": []string{"2050-01-01"},
+			"end_date": []string{"2050-01-02"},
+			"first_name": []string{"John"},
+			"last_name": []string{"Smith"},
+			"email": []string{"john@smith.com"},
+			"phone": []string{"123456789"},
+			"room_id": []string{"4"},
+		},
+		http.StatusSeeOther,
+		"/search-availability",
+		"",
+	},
 }
 
 func TestRepository_PostMakeReservation(t *testing.T) {
@@ -440,6 +637,44 @@ var postSearchAvailabilityTests = []struct {
 		},
 		http.StatusSeeOther,
 	},
+	{
+		// The only free room doesn't take stays arriving on a Monday in summer
+		"Stay rules not followed",
+		url.Values{
+			"start_date": {"2049-07-05"},
+			"end_date": {"2049-07-08"},
+		},
+		http.StatusSeeOther,
+	},
+	{
+		"Party too big for the free rooms",
+		url.Values{
+			"start_date": {"2049-01-01"},
+			"end_date": {"2049-01-02"},
+			"adults": {"3"},
+			"children": {"2"},
+		},
+		http.StatusSeeOther,
+	},
+	{
+		"Party which fits the free rooms",
+		url.Values{
+			"start_date": {"2049-01-01"},
+			"end_date": {"2049-01-02"},
+			"adults": {"1"},
+			"children": {"1"},
+		},
+		http.StatusOK,
+	},
+	{
+		"Invalid number of adults",
+		url.Values{
+			"start_date": {"2049-01-01"},
+			"end_date": {"2049-01-02"},
+			"adults": {"none"},
+		},
+		http.StatusSeeOther,
+	},
 }
 
 
@@ -547,6 +782,59 @@ var searchAvailabilityJsonTests = []struct {
 		false,
 		"Error connecting to database",
 	},
+	{
+		"Stay rules not followed",
+		url.Values{
+			"start_date": {"2049-07-05"},
+			"end_date": {"2049-07-08"},
+			"room_id": {"1"},
+		},
+		false,
+		"Stays between Jul 1, 2049 and Aug 31, 2049 have to start on a Friday or Saturday",
+	},
+	{
+		"Stay rules of another room",
+		url.Values{
+			"start_date": {"2049-07-05"},
+			"end_date": {"2049-07-08"},
+			"room_id": {"2"},
+		},
+		true,
+		"",
+	},
+	{
+		"Party too big for the room",
+		url.Values{
+			"start_date": {"2049-01-01"},
+			"end_date": {"2049-01-02"},
+			"room_id": {"1"},
+			"adults": {"2"},
+			"children": {"1"},
+		},
+		false,
+		"This room sleeps at most 2 guests",
+	},
+	{
+		"Negative number of children",
+		url.Values{
+			"start_date": {"2049-01-01"},
+			"end_date": {"2049-01-02"},
+			"room_id": {"1"},
+			"children": {"-1"},
+		},
+		false,
+		"Enter at least one adult and no negative number of children",
+	},
+	{
+		"Deactivated room",
+		url.Values{
+			"start_date": {"2049-01-01"},
+			"end_date": {"2049-01-02"},
+			"room_id": {"4"},
+		},
+		false,
+		"This room can't be booked at the moment",
+	},
 }
 
 func TestAvailabilityJSON(t *testing.T) {
@@ -615,6 +903,23 @@ var reservationSummaryTests = []struct {
 		http.StatusOK,
 		"",
 	},
+	{
+		"Reservation with price breakdown in session",
+		models.Reservation{
+			RoomID: 1,
+			Room: models.Room{
+				ID:       1,
+				RoomName: "General's Quarters",
+			},
+			Quote: models.Quote{
+				Nights: []models.NightlyPrice{{ Date: time.Date(2050, time.January, 3, 0, 0, 0, 0, time.UTC), Label: "Spring promotion", Rate: 8000, Amount: 8000 }},
+				Subtotal: 8000,
+				Total: 8000,
+			},
+		},
+		http.StatusOK,
+		"",
+	},
 	{
 		"No reservation in session",
 		models.Reservation{},
@@ -658,6 +963,40 @@ func TestRepository_ReservationSummary_Success(t *testing.T) {
 				test.expectedStatusCode,
 			)
 		}
+
+		// The price breakdown is the one quoted when booking, not one at the current rates
+		for _, night := range test.reservation.Quote.Nights {
+			if !strings.Contains(responseRecorder.Body.String(), night.Label) {
+				t.Errorf("Test %s doesn't show the quoted price breakdown", test.name)
+			}
+		}
+	}
+}
+
+func TestRepository_AdminShowReservation_QuotedPrice(t *testing.T) {
+	// Reservation 1 was made before price breakdowns were stored, reservation 2 has one
+	for id, expectBreakdown := range map[string]bool{"1": false, "2": true} {
+		req, err := http.NewRequest("GET", "/admin/reservations/all/" + id, nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("src", "all")
+		rctx.URLParams.Add("id", id)
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: models.AccessOwner})
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminShowReservation)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if strings.Contains(responseRecorder.Body.String(), "Spring promotion") != expectBreakdown {
+			t.Errorf("Reservation %s has wrong price breakdown, wanted shown: %t", id, expectBreakdown)
+		}
 	}
 }
 
@@ -816,6 +1155,36 @@ var bookRoomTests = []struct {
 		http.StatusSeeOther,
 		"/search-availability",
 	},
+	{
+		"Stay rules not followed",
+		"/book-room?start_date=2049-12-24&end_date=2049-12-28&id=1",
+		http.StatusSeeOther,
+		"/search-availability",
+	},
+	{
+		"Party which fits the room",
+		"/book-room?start_date=2049-01-01&end_date=2049-01-02&id=1&adults=1&children=1",
+		http.StatusSeeOther,
+		"/make-reservation",
+	},
+	{
+		"Party too big for the room",
+		"/book-room?start_date=2049-01-01&end_date=2049-01-02&id=1&adults=2&children=1",
+		http.StatusSeeOther,
+		"/search-availability",
+	},
+	{
+		"Invalid adults query parameter",
+		"/book-room?start_date=2049-01-01&end_date=2049-01-02&id=1&adults=none",
+		http.StatusSeeOther,
+		"/search-availability",
+	},
+	{
+		"Deactivated room",
+		"/book-room?start_date=2049-01-01&end_date=2049-01-02&id=4",
+		http.StatusSeeOther,
+		"/search-availability",
+	},
 }
 
 func TestRepository_BookRoom(t *testing.T) {
@@ -884,6 +1253,26 @@ var postShowLoginTests = []struct {
 		"", 
 		"/",
 	},
+	{
+		"Two-factor authentication",
+		url.Values{
+			"email": {"twofactor@here.com"},
+			"password": {"password"},
+		}, 
+		http.StatusSeeOther, 
+		"", 
+		"/auth/two-factor",
+	},
+	{
+		"Disabled user",
+		url.Values{
+			"email": {"former@here.com"},
+			"password": {"password"},
+		}, 
+		http.StatusSeeOther, 
+		"", 
+		"/auth/login",
+	},
 	{
 		"Invalid credentials",
 		url.Values{
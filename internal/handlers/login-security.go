@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/emails"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/loginguard"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+)
+
+// Number of recent logins shown on the login security page
+const recentLoginEvents = 50
+
+// AdminLoginSecurity is the login security page handler in the admin dashboard,
+// which lists the locked accounts and IP addresses and the recent logins
+func (repo *Repository) AdminLoginSecurity(w http.ResponseWriter, r *http.Request) {
+	locked, err := repo.LoginGuard.Locked()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	events, err := repo.DB.GetLoginEvents(recentLoginEvents)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["locked"] = locked
+	data["events"] = events
+
+	render.RenderTemplate(w, r, "admin-login-security.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// Handler to unlock an account or IP address before its lockout ends
+func (repo *Repository) AdminPostUnlockLogin(w http.ResponseWriter, r *http.Request) {
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	key := r.Form.Get("key")
+	if key == "" {
+		repo.App.Session.Put(r.Context(), "error", "Choose what to unlock")
+		http.Redirect(w, r, "/admin/login-security", http.StatusSeeOther)
+		return
+	}
+
+	err = repo.LoginGuard.Unlock(key)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	currentUser, _ := helpers.CurrentUser(r)
+	repo.App.InfoLog.Printf("User %d unlocked logins of %s", currentUser.ID, key)
+
+	repo.auditUnlock(r, key)
+
+	repo.App.Session.Put(r.Context(), "success", "Logins unlocked")
+	http.Redirect(w, r, "/admin/login-security", http.StatusSeeOther)
+}
+
+// Records the unlock of the given key in the audit log. Unlocks of accounts are recorded under their user,
+// so that they show up in the history of the user, and unlocks of IP addresses under the address
+func (repo *Repository) auditUnlock(r *http.Request, key string) {
+	if strings.HasPrefix(key, loginguard.KeyPrefixAccount) {
+		user, err := repo.DB.GetUserByEmail(strings.TrimPrefix(key, loginguard.KeyPrefixAccount))
+		if err == nil {
+			repo.audit(r, audit.ActionUnlocked, audit.EntityUser, user.ID, map[string]bool{"login_locked": true}, map[string]bool{"login_locked": false})
+			return
+		}
+	}
+
+	repo.audit(r, audit.ActionUnlocked, audit.EntityLogin, 0, map[string]string{"locked": key}, nil)
+}
+
+// Refuses a login which has to wait after failed logins, or whose account or IP address is locked
+func (repo *Repository) refuseLogin(w http.ResponseWriter, r *http.Request, email string, decision loginguard.Decision) {
+	repo.recordLogin(r, email, 0, models.LoginFailureThrottled)
+
+	if decision.Locked {
+		repo.App.Session.Put(r.Context(), "error", fmt.Sprintf("Too many failed logins, please try again in %s or reset your password", describeDuration(roundUp(decision.RetryAfter, time.Minute))))
+	} else {
+		repo.App.Session.Put(r.Context(), "error", fmt.Sprintf("Too many failed logins, please wait %s before trying again", describeDuration(roundUp(decision.RetryAfter, time.Second))))
+	}
+
+	http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+}
+
+// Records a login attempt from the request. An empty reason means the login succeeded.
+// Failing to record it doesn't stop the login, the error is only logged
+func (repo *Repository) recordLogin(r *http.Request, email string, userID int, reason string) {
+	err := repo.DB.InsertLoginEvent(models.LoginEvent{
+		Email: strings.TrimSpace(email),
+		UserID: userID,
+		IPAddress: helpers.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Success: reason == "",
+		Reason: reason,
+	})
+	if err != nil {
+		repo.App.ErrorLog.Printf("Cannot record login of %s: %s", email, err)
+	}
+}
+
+// Records a failed login and counts it against the account and IP address.
+// The user is emailed if the account has just been locked
+func (repo *Repository) loginFailed(r *http.Request, email string, userID int, reason string) error {
+	repo.recordLogin(r, email, userID, reason)
+
+	ip := helpers.ClientIP(r)
+
+	accountLocked, err := repo.LoginGuard.Failure(email, ip)
+	if err != nil {
+		return err
+	}
+
+	if !accountLocked {
+		return nil
+	}
+
+	repo.App.InfoLog.Printf("Logins to %s are locked after too many failures, the last one from %s", email, ip)
+
+	// Emails without an account are locked too, so that the lockout doesn't tell which emails have one,
+	// but there is nobody to tell
+	user, err := repo.DB.GetUserByEmail(strings.TrimSpace(email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	mailData, err := emails.NewMail(emails.AccountLocked, user.Email, emails.AccountLockedData{
+		User: user,
+		LockedFor: describeDuration(repo.LoginGuard.LockoutDuration()),
+		IPAddress: ip,
+		ResetURL: fmt.Sprintf("%s/auth/forgot-password", strings.TrimSuffix(repo.App.BaseURL, "/")),
+	})
+	if err != nil {
+		return err
+	}
+
+	return repo.DB.InsertOutboxEmails([]models.MailData{mailData})
+}
+
+// Records a successful login and forgets the failed logins to the account
+func (repo *Repository) loginSucceeded(r *http.Request, user models.User) error {
+	repo.recordLogin(r, user.Email, user.ID, "")
+
+	return repo.LoginGuard.Success(user.Email)
+}
+
+// Rounds a duration up to a multiple of the unit
+func roundUp(duration time.Duration, unit time.Duration) time.Duration {
+	return (duration + unit - 1) / unit * unit
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/loginguard"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+// Gives the repository a guard without failed logins for the duration of a test
+func useNewLoginGuard(t *testing.T) *loginguard.Guard {
+	previous := Repo.LoginGuard
+	t.Cleanup(func() { Repo.LoginGuard = previous })
+
+	Repo.LoginGuard = loginguard.New(loginguard.NewMemoryStore(), loginguard.DefaultConfig)
+
+	return Repo.LoginGuard
+}
+
+// Posts the login form of the test owner from the given IP address
+func postLogin(ip string) (*httptest.ResponseRecorder, context.Context) {
+	body := url.Values{"email": {"me@here.com"}, "password": {"Old password 1"}}
+
+	req, err := http.NewRequest("POST", "/auth/login", strings.NewReader(body.Encode()))
+	if err != nil {
+		log.Println(err)
+	}
+
+	ctx := getRequestContext(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = ip + ":50000"
+
+	responseRecorder := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.PostShowLogin)
+	handler.ServeHTTP(responseRecorder, req)
+
+	return responseRecorder, ctx
+}
+
+func TestRepository_PostShowLogin_Throttled(t *testing.T) {
+	guard := useNewLoginGuard(t)
+
+	// Two failed logins make the next attempt wait, even with the right password
+	for i := 0; i < 2; i++ {
+		guard.Failure("me@here.com", "192.0.2.1")
+	}
+
+	responseRecorder, ctx := postLogin("192.0.2.1")
+
+	redirectURL, _ := responseRecorder.Result().Location()
+	if redirectURL == nil || redirectURL.String() != "/auth/login" {
+		t.Errorf("Throttled login redirects to wrong URL: got %v", redirectURL)
+	}
+
+	if session.Exists(ctx, "user_id") {
+		t.Error("Throttled login should not log the user in")
+	}
+
+	if message := session.GetString(ctx, "error"); !strings.Contains(message, "please wait 1 second") {
+		t.Errorf("Throttled login shows wrong message: %q", message)
+	}
+}
+
+func TestRepository_PostShowLogin_Locked(t *testing.T) {
+	guard := useNewLoginGuard(t)
+
+	for i := 0; i < loginguard.DefaultConfig.MaxAccountFailures; i++ {
+		guard.Failure("me@here.com", "198.51.100.7")
+	}
+
+	// The account is locked from every IP address
+	_, ctx := postLogin("192.0.2.1")
+
+	if session.Exists(ctx, "user_id") {
+		t.Error("Login to a locked account should not log the user in")
+	}
+
+	if message := session.GetString(ctx, "error"); !strings.Contains(message, "try again in 15 minutes") {
+		t.Errorf("Login to a locked account shows wrong message: %q", message)
+	}
+
+	// Once unlocked, the user can log in again
+	guard.Unlock(loginguard.AccountKey("me@here.com"))
+	guard.Unlock(loginguard.IPKey("198.51.100.7"))
+
+	responseRecorder, ctx := postLogin("192.0.2.1")
+
+	redirectURL, _ := responseRecorder.Result().Location()
+	if redirectURL == nil || redirectURL.String() != "/" || session.GetInt(ctx, "user_id") != 1 {
+		t.Errorf("Login to an unlocked account failed: redirected to %v", redirectURL)
+	}
+}
+
+func TestRepository_LoginFailed_LocksAccount(t *testing.T) {
+	guard := useNewLoginGuard(t)
+
+	req, _ := http.NewRequest("POST", "/auth/login", nil)
+	req.RemoteAddr = "192.0.2.1:50000"
+
+	// The last failure locks the account and queues the email telling its user
+	for i := 0; i < loginguard.DefaultConfig.MaxAccountFailures; i++ {
+		err := Repo.loginFailed(req, "me@here.com", 0, models.LoginFailureInvalidCredentials)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	decision, _ := guard.Check("me@here.com", "198.51.100.7")
+	if !decision.Locked {
+		t.Errorf("Account should be locked after %d failed logins", loginguard.DefaultConfig.MaxAccountFailures)
+	}
+}
+
+func TestRepository_AdminLoginSecurity(t *testing.T) {
+	guard := useNewLoginGuard(t)
+
+	for i := 0; i < loginguard.DefaultConfig.MaxAccountFailures; i++ {
+		guard.Failure("me@here.com", "192.0.2.1")
+	}
+
+	req, err := http.NewRequest("GET", "/admin/login-security", nil)
+	if err != nil {
+		log.Println(err)
+	}
+
+	ctx := getRequestContext(req)
+	ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, Email: "me@here.com", AccessLevel: models.AccessOwner})
+	req = req.WithContext(ctx)
+
+	responseRecorder := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.AdminLoginSecurity)
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("Login security page returns wrong response status code: got %d", responseRecorder.Code)
+	}
+
+	html := responseRecorder.Body.String()
+	for _, expected := range []string{`value="account:me@here.com"`, "Wrong email or password", "Firefox"} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Login security page does not show %s", expected)
+		}
+	}
+}
+
+func TestRepository_AdminPostUnlockLogin(t *testing.T) {
+	guard := useNewLoginGuard(t)
+
+	for i := 0; i < loginguard.DefaultConfig.MaxAccountFailures; i++ {
+		guard.Failure("me@here.com", "192.0.2.1")
+	}
+
+	for key, expectedFlash := range map[string]string{loginguard.AccountKey("me@here.com"): "success", "": "error"} {
+		body := url.Values{"key": {key}}
+
+		req, err := http.NewRequest("POST", "/admin/login-security/unlock", strings.NewReader(body.Encode()))
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, Email: "me@here.com", AccessLevel: models.AccessOwner})
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminPostUnlockLogin)
+		handler.ServeHTTP(responseRecorder, req)
+
+		redirectURL, _ := responseRecorder.Result().Location()
+		if redirectURL == nil || redirectURL.String() != "/admin/login-security" {
+			t.Errorf("Unlocking %q redirects to wrong URL: got %v", key, redirectURL)
+		}
+
+		if !session.Exists(ctx, expectedFlash) {
+			t.Errorf("Unlocking %q should show a %s message", key, expectedFlash)
+		}
+	}
+
+	decision, _ := guard.Check("me@here.com", "198.51.100.7")
+	if decision.Locked {
+		t.Error("Account should be unlocked")
+	}
+}
+
+func TestRepository_PostTwoFactor_Locked(t *testing.T) {
+	guard := useNewLoginGuard(t)
+
+	for i := 0; i < loginguard.DefaultConfig.MaxAccountFailures; i++ {
+		guard.Failure("twofactor@here.com", "198.51.100.7")
+	}
+
+	body := url.Values{"code": {currentTwoFactorCode()}}
+
+	req, err := http.NewRequest("POST", "/auth/two-factor", strings.NewReader(body.Encode()))
+	if err != nil {
+		log.Println(err)
+	}
+
+	ctx := getRequestContext(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// The user entered the right password before the account got locked
+	session.Put(ctx, "two_factor_user_id", 6)
+	session.Put(ctx, "two_factor_started_at", int(time.Now().Unix()))
+
+	responseRecorder := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.PostTwoFactor)
+	handler.ServeHTTP(responseRecorder, req)
+
+	redirectURL, _ := responseRecorder.Result().Location()
+	if redirectURL == nil || redirectURL.String() != "/auth/login" {
+		t.Errorf("Two-factor login to a locked account redirects to wrong URL: got %v", redirectURL)
+	}
+
+	if session.Exists(ctx, "user_id") || session.Exists(ctx, "two_factor_user_id") {
+		t.Error("Two-factor login to a locked account should be stopped")
+	}
+}
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/emails"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/loginguard"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/ratelimit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+)
+
+// Shown whether or not an account exists for the email, so that the form can't be used to find out who has one
+const passwordResetSentMessage = "If there is an account for this email, we have sent it a link to choose a new password"
+
+// Reset links which can be asked for per minute, so that the form can't be used to flood inboxes or the outbox
+const (
+	passwordResetsPerIP = 5
+	passwordResetsPerAccount = 1
+)
+
+// Limits how often reset links are asked for by IP address and by account
+var passwordResetLimiter = ratelimit.New()
+
+// ForgotPassword is the page handler where users ask for a password reset link
+func (repo *Repository) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	render.RenderTemplate(w, r, "forgot-password.page.tmpl", &models.TemplateData{
+		Form: forms.New(nil),
+	})
+}
+
+// Handler to email a single-use password reset link to the user with the received email
+func (repo *Repository) PostForgotPassword(w http.ResponseWriter, r *http.Request) {
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.RequiredFields("email")
+	form.IsEmail("email")
+
+	// Rerender page with updated error information
+	if !form.IsValid() {
+		render.RenderTemplate(w, r, "forgot-password.page.tmpl", &models.TemplateData{
+			Form: form,
+		})
+		return
+	}
+
+	allowed, wait := passwordResetLimiter.Allow(loginguard.IPKey(helpers.ClientIP(r)), passwordResetsPerIP)
+	if !allowed {
+		repo.App.Session.Put(r.Context(), "error", fmt.Sprintf("Too many reset links asked for, please wait %s before trying again", describeDuration(roundUp(wait, time.Second))))
+		http.Redirect(w, r, "/auth/forgot-password", http.StatusSeeOther)
+		return
+	}
+
+	email := strings.TrimSpace(form.Get("email"))
+
+	// The answer doesn't change when the account has already been sent a link, so that it doesn't tell who has one
+	if allowed, _ := passwordResetLimiter.Allow(loginguard.AccountKey(email), passwordResetsPerAccount); !allowed {
+		repo.App.InfoLog.Printf("Password reset for %s requested again too soon, no link sent", email)
+		repo.App.Session.Put(r.Context(), "success", passwordResetSentMessage)
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+
+	user, err := repo.DB.GetUserByEmail(email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// Disabled users can't log in, so they don't get a link either
+	if err == nil && !user.Disabled {
+		err = repo.sendPasswordResetLink(user)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	} else {
+		repo.App.InfoLog.Printf("Password reset requested for %s, which has no active account", email)
+	}
+
+	repo.App.Session.Put(r.Context(), "success", passwordResetSentMessage)
+	http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+}
+
+// ResetPassword is the page handler where users choose a new password with the link of a password reset email
+func (repo *Repository) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	resetToken, err := repo.DB.GetPasswordResetTokenByHash(helpers.HashToken(token))
+	if err != nil || !resetToken.IsUsable(time.Now()) {
+		repo.App.Session.Put(r.Context(), "error", "This link is invalid or has expired, please ask for a new one")
+		http.Redirect(w, r, "/auth/forgot-password", http.StatusSeeOther)
+		return
+	}
+
+	renderResetPassword(w, r, token, forms.New(nil))
+}
+
+// Handler to store the new password chosen with the link of a password reset email
+func (repo *Repository) PostResetPassword(w http.ResponseWriter, r *http.Request) {
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	token := r.Form.Get("token")
+
+	form := forms.New(r.PostForm)
+	validateNewPassword(form)
+
+	// Rerender page with updated error information
+	if !form.IsValid() {
+		renderResetPassword(w, r, token, form)
+		return
+	}
+
+	userID, err := repo.DB.ResetPasswordWithToken(helpers.HashToken(token), form.Get("password"))
+	if errors.Is(err, repository.ErrResetTokenInvalid) {
+		repo.App.Session.Put(r.Context(), "error", "This link is invalid or has expired, please ask for a new one")
+		http.Redirect(w, r, "/auth/forgot-password", http.StatusSeeOther)
+		return
+	}
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.App.InfoLog.Printf("User %d reset their password with an emailed link", userID)
+
+	// Nobody is logged in, the user is only known by the link
+	repo.audit(r, audit.ActionPasswordChanged, audit.EntityUser, userID, nil, nil)
+
+	// Whoever can read the user's email can log in now, so the account doesn't have to wait out a lockout
+	user, err := repo.DB.GetUserByID(userID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.LoginGuard.Success(user.Email)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.App.Session.Put(r.Context(), "success", "Your password has been changed, you can now log in")
+	http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+}
+
+// AdminChangePassword is the page handler where logged in users change their password
+func (repo *Repository) AdminChangePassword(w http.ResponseWriter, r *http.Request) {
+	render.RenderTemplate(w, r, "admin-change-password.page.tmpl", &models.TemplateData{
+		Form: forms.New(nil),
+	})
+}
+
+// Handler to change the password of the logged in user, who has to enter the current password first.
+// Every other session of the user is logged out
+func (repo *Repository) AdminPostChangePassword(w http.ResponseWriter, r *http.Request) {
+	user, _ := helpers.CurrentUser(r)
+
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.RequiredFields("current_password")
+	validateNewPassword(form)
+
+	if form.Has("current_password") {
+		// Guesses of the current password count as failed logins, so that a left open session
+		// can't be used to guess it any faster than on the login page
+		decision, err := repo.LoginGuard.Check(user.Email, helpers.ClientIP(r))
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		if !decision.Allowed {
+			repo.recordLogin(r, user.Email, user.ID, models.LoginFailureThrottled)
+			form.Errors.Add("current_password", fmt.Sprintf("Too many wrong passwords, please try again in %s", describeDuration(roundUp(decision.RetryAfter, time.Second))))
+		} else if _, _, err = repo.DB.Authenticate(user.Email, form.Get("current_password")); err != nil {
+			err = repo.loginFailed(r, user.Email, user.ID, models.LoginFailureCurrentPassword)
+			if err != nil {
+				helpers.ServerError(w, err)
+				return
+			}
+
+			form.Errors.Add("current_password", "Your current password is wrong")
+		} else if form.Get("password") == form.Get("current_password") {
+			form.Errors.Add("password", "Choose a password different from your current one")
+		}
+	}
+
+	// Rerender page with updated error information
+	if !form.IsValid() {
+		render.RenderTemplate(w, r, "admin-change-password.page.tmpl", &models.TemplateData{
+			Form: form,
+		})
+		return
+	}
+
+	err = repo.storeNewPassword(r, user.ID, form.Get("password"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.App.Session.Put(r.Context(), "success", "Your password has been changed and your other sessions have been logged out")
+	http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
+}
+
+// Stores a new password for the logged in user. The password change logs out every session of the user,
+// so the current session is renewed with the new session version to stay logged in
+func (repo *Repository) storeNewPassword(r *http.Request, userID int, password string) error {
+	sessionVersion, err := repo.DB.UpdateUserPassword(userID, password)
+	if err != nil {
+		return err
+	}
+
+	user, _ := helpers.CurrentUser(r)
+	before := newAuditUser(user)
+	user.PasswordResetRequired = false
+	repo.audit(r, audit.ActionPasswordChanged, audit.EntityUser, userID, before, newAuditUser(user))
+
+	// Prevent session fixation attack
+	err = repo.App.Session.RenewToken(r.Context())
+	if err != nil {
+		return err
+	}
+
+	repo.App.Session.Put(r.Context(), "session_version", sessionVersion)
+
+	return nil
+}
+
+// Creates a password reset token for the user and queues the email with the link
+func (repo *Repository) sendPasswordResetLink(user models.User) error {
+	token, err := helpers.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	mailData, err := emails.NewMail(emails.PasswordReset, user.Email, emails.PasswordResetData{
+		User: user,
+		ResetURL: fmt.Sprintf("%s/auth/reset-password?token=%s", strings.TrimSuffix(repo.App.BaseURL, "/"), token),
+		ValidFor: describeDuration(repo.App.PasswordResetLifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	return repo.DB.InsertPasswordResetToken(models.PasswordResetToken{
+		UserID: user.ID,
+		TokenHash: helpers.HashToken(token),
+		ExpiresAt: time.Now().Add(repo.App.PasswordResetLifetime),
+	}, []models.MailData{mailData})
+}
+
+// Checks the new password and its confirmation of a form
+func validateNewPassword(form *forms.Form) {
+	form.RequiredFields("password", "password_confirmation")
+	form.IsStrongPassword("password")
+
+	if form.Get("password") != form.Get("password_confirmation") {
+		form.Errors.Add("password_confirmation", "Passwords don't match")
+	}
+}
+
+// Renders the page to choose a new password with the token of a password reset link
+func renderResetPassword(w http.ResponseWriter, r *http.Request, token string, form *forms.Form) {
+	// The token is part of the URL, so it mustn't leak to other sites through the Referer header
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	stringMap := make(map[string]string)
+	stringMap["token"] = token
+
+	render.RenderTemplate(w, r, "reset-password.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Form: form,
+	})
+}
+
+// Describes a duration in whole hours, minutes or, under a minute, seconds, e.g. "1 hour" or "30 minutes"
+func describeDuration(duration time.Duration) string {
+	if duration < time.Minute {
+		seconds := int(duration / time.Second)
+		if seconds == 1 {
+			return "1 second"
+		}
+
+		return fmt.Sprintf("%d seconds", seconds)
+	}
+
+	if duration >= time.Hour && duration % time.Hour == 0 {
+		hours := int(duration / time.Hour)
+		if hours == 1 {
+			return "1 hour"
+		}
+
+		return fmt.Sprintf("%d hours", hours)
+	}
+
+	minutes := int(duration / time.Minute)
+	if minutes == 1 {
+		return "1 minute"
+	}
+
+	return fmt.Sprintf("%d minutes", minutes)
+}
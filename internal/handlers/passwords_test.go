@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/loginguard"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/ratelimit"
+)
+
+var postPasswordTests = []struct {
+	name                string
+	handler             func(*Repository, http.ResponseWriter, *http.Request)
+	body                url.Values
+	expectedStatusCode  int
+	expectedRedirectURL string
+	expectedHTML        string
+}{
+	{
+		"Asks for reset link",
+		(*Repository).PostForgotPassword,
+		url.Values{"email": {"me@here.com"}},
+		http.StatusSeeOther,
+		"/auth/login",
+		"",
+	},
+	{
+		"Asks for reset link of unknown email",
+		(*Repository).PostForgotPassword,
+		url.Values{"email": {"nobody@here.com"}},
+		http.StatusSeeOther,
+		"/auth/login",
+		"",
+	},
+	{
+		"Asks for reset link with invalid email",
+		(*Repository).PostForgotPassword,
+		url.Values{"email": {"me"}},
+		http.StatusOK,
+		"",
+		"Invalid email address",
+	},
+	{
+		"Resets password",
+		(*Repository).PostResetPassword,
+		url.Values{"token": {"valid-reset-token"}, "password": {"Sunny room 4"}, "password_confirmation": {"Sunny room 4"}},
+		http.StatusSeeOther,
+		"/auth/login",
+		"",
+	},
+	{
+		"Resets password with weak password",
+		(*Repository).PostResetPassword,
+		url.Values{"token": {"valid-reset-token"}, "password": {"sunnyroom"}, "password_confirmation": {"sunnyroom"}},
+		http.StatusOK,
+		"",
+		"Mix at least three of lowercase letters",
+	},
+	{
+		"Resets password with used token",
+		(*Repository).PostResetPassword,
+		url.Values{"token": {"used-reset-token"}, "password": {"Sunny room 4"}, "password_confirmation": {"Sunny room 4"}},
+		http.StatusSeeOther,
+		"/auth/forgot-password",
+		"",
+	},
+	{
+		"Resets password with expired token",
+		(*Repository).PostResetPassword,
+		url.Values{"token": {"expired-reset-token"}, "password": {"Sunny room 4"}, "password_confirmation": {"Sunny room 4"}},
+		http.StatusSeeOther,
+		"/auth/forgot-password",
+		"",
+	},
+	{
+		"Changes password",
+		(*Repository).AdminPostChangePassword,
+		url.Values{"current_password": {"Old password 1"}, "password": {"Sunny room 4"}, "password_confirmation": {"Sunny room 4"}},
+		http.StatusSeeOther,
+		"/admin/dashboard",
+		"",
+	},
+	{
+		"Changes password to the current one",
+		(*Repository).AdminPostChangePassword,
+		url.Values{"current_password": {"Sunny room 4"}, "password": {"Sunny room 4"}, "password_confirmation": {"Sunny room 4"}},
+		http.StatusOK,
+		"",
+		"Choose a password different from your current one",
+	},
+	{
+		"Changes password without current password",
+		(*Repository).AdminPostChangePassword,
+		url.Values{"password": {"Sunny room 4"}, "password_confirmation": {"Sunny room 4"}},
+		http.StatusOK,
+		"",
+		"This field cannot be empty",
+	},
+}
+
+// Gives the handlers a limiter without any reset links asked for during a test
+func useNewPasswordResetLimiter(t *testing.T) {
+	previous := passwordResetLimiter
+	t.Cleanup(func() { passwordResetLimiter = previous })
+
+	passwordResetLimiter = ratelimit.New()
+}
+
+// Posts the forgot password form with the given email from the given IP address
+func postForgotPassword(email, ip string) (*httptest.ResponseRecorder, context.Context) {
+	body := url.Values{"email": {email}}
+
+	req, err := http.NewRequest("POST", "/auth/forgot-password", strings.NewReader(body.Encode()))
+	if err != nil {
+		log.Println(err)
+	}
+
+	ctx := getRequestContext(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = ip + ":50000"
+
+	responseRecorder := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.PostForgotPassword)
+	handler.ServeHTTP(responseRecorder, req)
+
+	return responseRecorder, ctx
+}
+
+func TestRepository_PostPassword(t *testing.T) {
+	useNewPasswordResetLimiter(t)
+
+	for _, test := range postPasswordTests {
+		req, err := http.NewRequest("POST", "/auth/reset-password", strings.NewReader(test.body.Encode()))
+		if err != nil {
+			log.Println(err)
+		}
+
+		// The owner is logged in for the change password page
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, Email: "me@here.com", AccessLevel: models.AccessOwner})
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		responseRecorder := httptest.NewRecorder()
+
+		test.handler(Repo, responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf("Test %s returns wrong response status code: got %d, wanted %d", test.name, responseRecorder.Code, test.expectedStatusCode)
+		}
+
+		if test.expectedRedirectURL != "" {
+			redirectURL, _ := responseRecorder.Result().Location()
+			if redirectURL == nil || redirectURL.String() != test.expectedRedirectURL {
+				t.Errorf("Test %s redirects to wrong URL: got %v, wanted %s", test.name, redirectURL, test.expectedRedirectURL)
+			}
+		}
+
+		if test.expectedHTML != "" && !strings.Contains(responseRecorder.Body.String(), test.expectedHTML) {
+			t.Errorf("Test %s did not render %s", test.name, test.expectedHTML)
+		}
+	}
+}
+
+func TestRepository_PostForgotPassword_SameMessage(t *testing.T) {
+	useNewPasswordResetLimiter(t)
+
+	// Known and unknown emails get the same answer, so that the form doesn't tell who has an account.
+	// Asking again too soon for the same email doesn't change it either
+	var messages []string
+
+	for _, email := range []string{"me@here.com", "nobody@here.com", "former@here.com", "me@here.com"} {
+		_, ctx := postForgotPassword(email, "192.0.2.1")
+
+		messages = append(messages, session.GetString(ctx, "success"))
+	}
+
+	for _, message := range messages {
+		if message != passwordResetSentMessage {
+			t.Errorf("Wrong message after asking for a reset link: %q", message)
+		}
+	}
+}
+
+func TestRepository_ResetPassword(t *testing.T) {
+	tokens := map[string]int{
+		"valid-reset-token": http.StatusOK,
+		"used-reset-token": http.StatusSeeOther,
+		"expired-reset-token": http.StatusSeeOther,
+		"unknown-token": http.StatusSeeOther,
+	}
+
+	for token, expectedStatusCode := range tokens {
+		req, err := http.NewRequest("GET", "/auth/reset-password?token="+token, nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		req = req.WithContext(getRequestContext(req))
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.ResetPassword)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != expectedStatusCode {
+			t.Errorf("Token %s returns wrong response status code: got %d, wanted %d", token, responseRecorder.Code, expectedStatusCode)
+		}
+
+		if expectedStatusCode == http.StatusOK && responseRecorder.Header().Get("Referrer-Policy") != "no-referrer" {
+			t.Errorf("Token %s can leak through the Referer header", token)
+		}
+	}
+}
+
+func TestRepository_LoadUser_SessionVersion(t *testing.T) {
+	// Sessions started before the password changed are logged out
+	sessionVersions := map[int]int{
+		0: http.StatusOK,
+		3: http.StatusSeeOther,
+	}
+
+	for sessionVersion, expectedStatusCode := range sessionVersions {
+		req, err := http.NewRequest("GET", "/admin/dashboard", nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		req = req.WithContext(ctx)
+		session.Put(ctx, "user_id", 1)
+		session.Put(ctx, "session_version", sessionVersion)
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := Repo.LoadUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != expectedStatusCode {
+			t.Errorf("Session version %d returns wrong response status code: got %d, wanted %d", sessionVersion, responseRecorder.Code, expectedStatusCode)
+		}
+	}
+}
+
+func TestDescribeDuration(t *testing.T) {
+	durations := map[time.Duration]string{
+		time.Hour: "1 hour",
+		24 * time.Hour: "24 hours",
+		90 * time.Minute: "90 minutes",
+		time.Minute: "1 minute",
+		time.Second: "1 second",
+		30 * time.Second: "30 seconds",
+	}
+
+	for duration, expected := range durations {
+		if describeDuration(duration) != expected {
+			t.Errorf("Wrong description of %s: got %q, wanted %q", duration, describeDuration(duration), expected)
+		}
+	}
+}
+
+func TestRepository_AdminPostChangePassword_Throttled(t *testing.T) {
+	guard := useNewLoginGuard(t)
+
+	// The test repository only accepts the password of the owner, so every guess of this user is wrong
+	expectedErrors := []string{"Your current password is wrong", "Your current password is wrong", "Too many wrong passwords"}
+
+	for i, expectedError := range expectedErrors {
+		body := url.Values{"current_password": {"Guess 1234"}, "password": {"Sunny room 4"}, "password_confirmation": {"Sunny room 4"}}
+
+		req, err := http.NewRequest("POST", "/admin/change-password", strings.NewReader(body.Encode()))
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 2, Email: "guessed@here.com", AccessLevel: models.AccessFrontDesk})
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = "192.0.2.1:50000"
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminPostChangePassword)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if !strings.Contains(responseRecorder.Body.String(), expectedError) {
+			t.Errorf("Attempt %d did not render %s", i + 1, expectedError)
+		}
+	}
+
+	// The wrong guesses count against logging in too
+	decision, _ := guard.Check("guessed@here.com", "198.51.100.7")
+	if decision.Allowed {
+		t.Error("Wrong current passwords don't count as failed logins")
+	}
+}
+
+func TestRepository_PostForgotPassword_Throttled(t *testing.T) {
+	useNewPasswordResetLimiter(t)
+
+	// An account is sent a single link per minute, whichever IP address asks for it
+	postForgotPassword("me@here.com", "192.0.2.1")
+	postForgotPassword("me@here.com", "198.51.100.7")
+
+	if allowed, _ := passwordResetLimiter.Allow(loginguard.AccountKey("me@here.com"), passwordResetsPerAccount); allowed {
+		t.Error("Asking for a reset link doesn't count against the account")
+	}
+
+	// An IP address asking for links for many emails has to wait
+	for i := 0; i < passwordResetsPerIP; i++ {
+		postForgotPassword(fmt.Sprintf("guest%d@here.com", i), "203.0.113.9")
+	}
+
+	responseRecorder, _ := postForgotPassword("one-more@here.com", "203.0.113.9")
+
+	redirectURL, _ := responseRecorder.Result().Location()
+	if redirectURL == nil || redirectURL.String() != "/auth/forgot-password" {
+		t.Errorf("Throttled request for a reset link redirects to wrong URL: got %v, wanted /auth/forgot-password", redirectURL)
+	}
+}
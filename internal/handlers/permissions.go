@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/sessionstore"
+)
+
+// Page where users who have to reset their password choose a new one
+const choosePasswordPath = "/admin/choose-password"
+
+// Loads the logged in user into the request context, so that middlewares, handlers and templates
+// can check what the user is allowed to do. Users who have been deleted or disabled in the meantime are logged out,
+// as are sessions started before the last password change. Users who have to reset their password are sent to choose a new one
+// and users whose role requires two-factor authentication are sent to set it up
+func (repo *Repository) LoadUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := repo.App.Session.GetInt(r.Context(), "user_id")
+		if userID == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := repo.DB.GetUserByID(userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			repo.logOut(w, r, "Your account no longer exists")
+			return
+		}
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		if user.Disabled {
+			repo.logOut(w, r, "Your account has been disabled")
+			return
+		}
+
+		if repo.App.Session.GetInt(r.Context(), "session_version") != user.SessionVersion {
+			repo.logOut(w, r, "Your password has been changed, please log in again")
+			return
+		}
+
+		if user.PasswordResetRequired && r.URL.Path != choosePasswordPath {
+			repo.App.Session.Put(r.Context(), "warning", "Please choose a new password")
+			http.Redirect(w, r, choosePasswordPath, http.StatusSeeOther)
+			return
+		}
+
+		if !user.PasswordResetRequired && !user.TwoFactorEnabled && repo.twoFactorRequired(user) && !isTwoFactorPath(r.URL.Path) {
+			repo.App.Session.Put(r.Context(), "warning", "Please set up two-factor authentication")
+			http.Redirect(w, r, twoFactorPath, http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(helpers.ContextWithUser(r.Context(), user)))
+	})
+}
+
+// Logs the user out and sends them to the login page with the given error
+func (repo *Repository) logOut(w http.ResponseWriter, r *http.Request, message string) {
+	repo.App.Session.Remove(r.Context(), "user_id")
+	repo.App.Session.Remove(r.Context(), "session_version")
+	repo.App.Session.Remove(r.Context(), sessionstore.KeyIPAddress)
+	repo.App.Session.Remove(r.Context(), sessionstore.KeyUserAgent)
+	repo.App.Session.RenewToken(r.Context())
+	repo.App.Session.Put(r.Context(), "error", message)
+	http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+}
+
+// Only lets users whose role grants the given permission through. Other users are sent back to the dashboard.
+// Needs to run after `LoadUser`
+func (repo *Repository) RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, _ := helpers.CurrentUser(r)
+			if !user.Can(permission) {
+				repo.App.InfoLog.Printf("User %d is missing the %s permission for %s %s", user.ID, permission, r.Method, r.URL.Path)
+				repo.App.Session.Put(r.Context(), "error", "You don't have permission to do that")
+				http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
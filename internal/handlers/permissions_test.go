@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+var requirePermissionTests = []struct {
+	name               string
+	accessLevel        int
+	permission         string
+	expectedStatusCode int
+}{
+	{"Owner deletes reservation", models.AccessOwner, models.PermissionDeleteReservations, http.StatusOK},
+	{"Owner manages integrations", models.AccessOwner, models.PermissionManageIntegrations, http.StatusOK},
+	{"Front desk edits reservation", models.AccessFrontDesk, models.PermissionEditReservations, http.StatusOK},
+	{"Front desk resends email", models.AccessFrontDesk, models.PermissionManageEmails, http.StatusOK},
+	{"Front desk deletes reservation", models.AccessFrontDesk, models.PermissionDeleteReservations, http.StatusSeeOther},
+	{"Front desk manages rooms", models.AccessFrontDesk, models.PermissionManageRooms, http.StatusSeeOther},
+	{"Housekeeping views reservations", models.AccessHousekeeping, models.PermissionViewReservations, http.StatusOK},
+	{"Housekeeping edits reservation", models.AccessHousekeeping, models.PermissionEditReservations, http.StatusSeeOther},
+	{"Housekeeping reads emails", models.AccessHousekeeping, models.PermissionManageEmails, http.StatusSeeOther},
+	{"Unknown access level", 0, models.PermissionViewReservations, http.StatusSeeOther},
+}
+
+func TestRepository_RequirePermission(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, test := range requirePermissionTests {
+		req, err := http.NewRequest("GET", "/admin/all-reservations", nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: test.accessLevel})
+		req = req.WithContext(ctx)
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := Repo.RequirePermission(test.permission)(next)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf("Test %s returns wrong response status code: got %d, wanted %d", test.name, responseRecorder.Code, test.expectedStatusCode)
+		}
+
+		if test.expectedStatusCode == http.StatusSeeOther {
+			redirectURL, _ := responseRecorder.Result().Location()
+			if redirectURL == nil || redirectURL.String() != "/admin/dashboard" {
+				t.Errorf("Test %s redirects to wrong URL: %v", test.name, redirectURL)
+			}
+
+			if !session.Exists(ctx, "error") {
+				t.Errorf("Test %s did not store error in the session", test.name)
+			}
+		}
+	}
+}
+
+var loadUserTests = []struct {
+	name                string
+	userID              int
+	expectedAccessLevel int
+	expectedStatusCode  int
+}{
+	{"Not logged in", 0, 0, http.StatusOK},
+	{"Owner", 1, models.AccessOwner, http.StatusOK},
+	{"Housekeeping", 3, models.AccessHousekeeping, http.StatusOK},
+	{"Deleted user", 9, 0, http.StatusSeeOther},
+	{"Disabled user", 4, 0, http.StatusSeeOther},
+}
+
+func TestRepository_LoadUser(t *testing.T) {
+	for _, test := range loadUserTests {
+		req, err := http.NewRequest("GET", "/admin/dashboard", nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		req = req.WithContext(ctx)
+
+		if test.userID != 0 {
+			session.Put(ctx, "user_id", test.userID)
+		}
+
+		var user models.User
+
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, _ = helpers.CurrentUser(r)
+		})
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := Repo.LoadUser(next)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf("Test %s returns wrong response status code: got %d, wanted %d", test.name, responseRecorder.Code, test.expectedStatusCode)
+		}
+
+		if user.AccessLevel != test.expectedAccessLevel {
+			t.Errorf("Test %s loaded wrong user: %+v", test.name, user)
+		}
+
+		// Deleted users are logged out
+		if test.expectedStatusCode == http.StatusSeeOther && session.Exists(ctx, "user_id") {
+			t.Errorf("Test %s did not log the user out", test.name)
+		}
+	}
+}
+
+var adminShowReservationActionTests = []struct {
+	name            string
+	accessLevel     int
+	expectedActions []string
+	hiddenActions   []string
+}{
+	{"Owner", models.AccessOwner, []string{`value="Save"`, "Mark as Processed", "btn-danger"}, nil},
+	{"Front desk", models.AccessFrontDesk, []string{`value="Save"`, "Mark as Processed"}, []string{"btn-danger", "/admin/rooms"}},
+	{"Housekeeping", models.AccessHousekeeping, nil, []string{`value="Save"`, "Mark as Processed", "btn-danger", "/admin/emails"}},
+}
+
+func TestRepository_AdminShowReservation_HidesActions(t *testing.T) {
+	for _, test := range adminShowReservationActionTests {
+		req, err := http.NewRequest("GET", "/admin/reservations/new/1", nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("src", "new")
+		rctx.URLParams.Add("id", "1")
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: test.accessLevel})
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminShowReservation)
+		handler.ServeHTTP(responseRecorder, req)
+
+		html := responseRecorder.Body.String()
+
+		for _, action := range test.expectedActions {
+			if !strings.Contains(html, action) {
+				t.Errorf("Test %s is missing %s", test.name, action)
+			}
+		}
+
+		for _, action := range test.hiddenActions {
+			if strings.Contains(html, action) {
+				t.Errorf("Test %s shows %s", test.name, action)
+			}
+		}
+	}
+}
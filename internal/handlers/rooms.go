@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// Rooms is the page handler listing all rooms shown to guests
+func (repo *Repository) Rooms(w http.ResponseWriter, r *http.Request) {
+	rooms, err := repo.DB.GetActiveRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["rooms"] = rooms
+
+	render.RenderTemplate(w, r, "rooms.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// Room is the room page handler. It renders any active room given its slug
+func (repo *Repository) Room(w http.ResponseWriter, r *http.Request) {
+	room, err := repo.DB.GetRoomBySlug(chi.URLParam(r, "slug"))
+	if err != nil || !room.Active {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["room"] = room
+
+	render.RenderTemplate(w, r, "room.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// Redirects the URL of a room page which existed before rooms were managed from the admin dashboard
+func (repo *Repository) RedirectToRoom(slug string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, fmt.Sprintf("/rooms/%s", slug), http.StatusMovedPermanently)
+	}
+}
+
+// AdminRooms is the rooms page handler in the admin dashboard
+func (repo *Repository) AdminRooms(w http.ResponseWriter, r *http.Request) {
+	rooms, err := repo.DB.GetAllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["rooms"] = rooms
+
+	render.RenderTemplate(w, r, "admin-rooms.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminNewRoom is the new room page handler in the admin dashboard
+func (repo *Repository) AdminNewRoom(w http.ResponseWriter, r *http.Request) {
+	renderRoomForm(w, r, newRoomStringMap(), forms.New(nil), models.Room{Capacity: 2, Active: true})
+}
+
+// Handler to create a room with received form data
+func (repo *Repository) AdminPostNewRoom(w http.ResponseWriter, r *http.Request) {
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	room := models.Room{Active: true}
+	form := validateRoomForm(r, &room)
+
+	// Rerender room form with updated error information
+	if !form.IsValid() {
+		renderRoomForm(w, r, newRoomStringMap(), form, room)
+		return
+	}
+
+	room.ID, err = repo.DB.InsertRoom(room)
+	if errors.Is(err, repository.ErrSlugTaken) {
+		form.Errors.Add("slug", "This slug is already used by another room")
+		renderRoomForm(w, r, newRoomStringMap(), form, room)
+		return
+	}
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionCreated, audit.EntityRoom, room.ID, nil, newApiAdminRoom(room))
+
+	repo.App.Session.Put(r.Context(), "success", "Room created")
+	http.Redirect(w, r, "/admin/rooms", http.StatusSeeOther)
+}
+
+// AdminShowRoom is the edit room page handler in the admin dashboard
+func (repo *Repository) AdminShowRoom(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	room, err := repo.DB.GetRoomByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	renderRoomForm(w, r, editRoomStringMap(id), forms.New(nil), room)
+}
+
+// Handler to update a room with received form data
+func (repo *Repository) AdminPostShowRoom(w http.ResponseWriter, r *http.Request) {
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	room, err := repo.DB.GetRoomByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	before := newApiAdminRoom(room)
+	form := validateRoomForm(r, &room)
+
+	// Rerender room form with updated error information
+	if !form.IsValid() {
+		renderRoomForm(w, r, editRoomStringMap(id), form, room)
+		return
+	}
+
+	err = repo.DB.UpdateRoom(room)
+	if errors.Is(err, repository.ErrSlugTaken) {
+		form.Errors.Add("slug", "This slug is already used by another room")
+		renderRoomForm(w, r, editRoomStringMap(id), form, room)
+		return
+	}
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionUpdated, audit.EntityRoom, room.ID, before, newApiAdminRoom(room))
+
+	repo.App.Session.Put(r.Context(), "success", "Room successfully updated")
+	http.Redirect(w, r, "/admin/rooms", http.StatusSeeOther)
+}
+
+// Handler to show a room to guests again
+func (repo *Repository) AdminActivateRoom(w http.ResponseWriter, r *http.Request) {
+	repo.updateActiveForRoom(w, r, true)
+}
+
+// Handler to hide a room from guests. Existing reservations of the room are kept
+func (repo *Repository) AdminDeactivateRoom(w http.ResponseWriter, r *http.Request) {
+	repo.updateActiveForRoom(w, r, false)
+}
+
+// Handler to save the display order of the rooms
+func (repo *Repository) AdminPostRoomsOrder(w http.ResponseWriter, r *http.Request) {
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// Form fields are named 'sort_order_<room id>'
+	sortOrders := make(map[int]int)
+
+	for name := range r.PostForm {
+		if !strings.HasPrefix(name, "sort_order_") {
+			continue
+		}
+
+		roomID, err := strconv.Atoi(strings.TrimPrefix(name, "sort_order_"))
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		sortOrder, err := strconv.Atoi(r.PostForm.Get(name))
+		if err != nil {
+			repo.App.Session.Put(r.Context(), "error", "Positions must be whole numbers")
+			http.Redirect(w, r, "/admin/rooms", http.StatusSeeOther)
+			return
+		}
+
+		sortOrders[roomID] = sortOrder
+	}
+
+	// Positions before the change, to record the rooms which moved
+	rooms, err := repo.DB.GetAllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.UpdateSortOrderForRooms(sortOrders)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	for _, room := range rooms {
+		if sortOrder, ok := sortOrders[room.ID]; ok {
+			repo.audit(r, audit.ActionUpdated, audit.EntityRoom, room.ID, map[string]int{"sort_order": room.SortOrder}, map[string]int{"sort_order": sortOrder})
+		}
+	}
+
+	repo.App.Session.Put(r.Context(), "success", "Room order saved")
+	http.Redirect(w, r, "/admin/rooms", http.StatusSeeOther)
+}
+
+// Activates or deactivates the room with the id given in the URL
+func (repo *Repository) updateActiveForRoom(w http.ResponseWriter, r *http.Request, active bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	room, err := repo.DB.GetRoomByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.UpdateActiveForRoom(id, active)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	action := audit.ActionDisabled
+	if active {
+		action = audit.ActionEnabled
+	}
+	repo.audit(r, action, audit.EntityRoom, id, map[string]bool{"active": room.Active}, map[string]bool{"active": active})
+
+	if active {
+		repo.App.Session.Put(r.Context(), "success", "Room activated")
+	} else {
+		repo.App.Session.Put(r.Context(), "success", "Room deactivated")
+	}
+
+	http.Redirect(w, r, "/admin/rooms", http.StatusSeeOther)
+}
+
+// Stores the room form data in `room` and validates it
+func validateRoomForm(r *http.Request, room *models.Room) *forms.Form {
+	room.RoomName = strings.TrimSpace(r.Form.Get("room_name"))
+	room.Slug = strings.TrimSpace(r.Form.Get("slug"))
+	room.Description = strings.TrimSpace(r.Form.Get("description"))
+	room.Amenities = strings.TrimSpace(r.Form.Get("amenities"))
+	room.Image = strings.TrimSpace(r.Form.Get("image"))
+	room.Capacity, _ = strconv.Atoi(r.Form.Get("capacity"))
+	room.BedConfiguration = strings.TrimSpace(r.Form.Get("bed_configuration"))
+
+	form := forms.New(r.PostForm)
+	form.RequiredFields("room_name", "slug", "capacity")
+	form.MinLength("room_name", 2)
+	form.IsSlug("slug")
+	form.MinValue("capacity", 1)
+
+	return form
+}
+
+// Renders the room form of the admin dashboard
+func renderRoomForm(w http.ResponseWriter, r *http.Request, stringMap map[string]string, form *forms.Form, room models.Room) {
+	data := make(map[string]interface{})
+	data["room"] = room
+
+	render.RenderTemplate(w, r, "admin-room.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Form: form,
+		Data: data,
+	})
+}
+
+// Title and form action of the new room page
+func newRoomStringMap() map[string]string {
+	stringMap := make(map[string]string)
+	stringMap["title"] = "New Room"
+	stringMap["action"] = "/admin/rooms/new"
+
+	return stringMap
+}
+
+// Title and form action of the edit room page
+func editRoomStringMap(id int) map[string]string {
+	stringMap := make(map[string]string)
+	stringMap["title"] = "Edit Room"
+	stringMap["action"] = fmt.Sprintf("/admin/rooms/%d", id)
+
+	return stringMap
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var validRoomForm = url.Values{
+	"room_name":   {"Colonel's Cabin"},
+	"slug":        {"colonels-cabin"},
+	"description": {"A cabin by the sea"},
+	"capacity":    {"3"},
+	"amenities":   {"Fireplace\nSea view"},
+}
+
+// Returns a copy of the valid room form with the given field changed
+func roomFormWith(field, value string) url.Values {
+	form := url.Values{}
+	for key, values := range validRoomForm {
+		form[key] = values
+	}
+	form.Set(field, value)
+
+	return form
+}
+
+var adminPostRoomTests = []struct {
+	name                string
+	handler             func(*Repository, http.ResponseWriter, *http.Request)
+	id                  string
+	body                url.Values
+	expectedStatusCode  int
+	expectedRedirectURL string
+	expectedHTML        string
+}{
+	{
+		"Creates room",
+		(*Repository).AdminPostNewRoom,
+		"",
+		validRoomForm,
+		http.StatusSeeOther,
+		"/admin/rooms",
+		"",
+	},
+	{
+		"Create room with invalid slug",
+		(*Repository).AdminPostNewRoom,
+		"",
+		roomFormWith("slug", "Colonel's Cabin"),
+		http.StatusOK,
+		"",
+		"Only lowercase letters, numbers and hyphens are allowed",
+	},
+	{
+		"Create room with invalid capacity",
+		(*Repository).AdminPostNewRoom,
+		"",
+		roomFormWith("capacity", "0"),
+		http.StatusOK,
+		"",
+		"This field must be at least 1",
+	},
+	{
+		"Create room with slug of another room",
+		(*Repository).AdminPostNewRoom,
+		"",
+		roomFormWith("slug", "taken-slug"),
+		http.StatusOK,
+		"",
+		"This slug is already used by another room",
+	},
+	{
+		"Create room with empty request body",
+		(*Repository).AdminPostNewRoom,
+		"",
+		nil,
+		http.StatusInternalServerError,
+		"",
+		"",
+	},
+	{
+		"Updates room",
+		(*Repository).AdminPostShowRoom,
+		"1",
+		validRoomForm,
+		http.StatusSeeOther,
+		"/admin/rooms",
+		"",
+	},
+	{
+		"Update room with missing name",
+		(*Repository).AdminPostShowRoom,
+		"1",
+		roomFormWith("room_name", ""),
+		http.StatusOK,
+		"",
+		"This field cannot be empty",
+	},
+	{
+		"Update room with slug of another room",
+		(*Repository).AdminPostShowRoom,
+		"1",
+		roomFormWith("slug", "taken-slug"),
+		http.StatusOK,
+		"",
+		"This slug is already used by another room",
+	},
+	{
+		"Update non-existent room",
+		(*Repository).AdminPostShowRoom,
+		"3",
+		validRoomForm,
+		http.StatusInternalServerError,
+		"",
+		"",
+	},
+	{
+		"Update room with invalid id URL parameter",
+		(*Repository).AdminPostShowRoom,
+		"invalid",
+		validRoomForm,
+		http.StatusInternalServerError,
+		"",
+		"",
+	},
+	{
+		"Saves room order",
+		(*Repository).AdminPostRoomsOrder,
+		"",
+		url.Values{
+			"sort_order_1": {"2"},
+			"sort_order_2": {"1"},
+		},
+		http.StatusSeeOther,
+		"/admin/rooms",
+		"",
+	},
+	{
+		"Room order with invalid position",
+		(*Repository).AdminPostRoomsOrder,
+		"",
+		url.Values{
+			"sort_order_1": {"first"},
+		},
+		http.StatusSeeOther,
+		"/admin/rooms",
+		"",
+	},
+	{
+		"Room order update failed",
+		(*Repository).AdminPostRoomsOrder,
+		"",
+		url.Values{
+			"sort_order_1000": {"1"},
+		},
+		http.StatusInternalServerError,
+		"",
+		"",
+	},
+}
+
+func TestRepository_AdminPostRoom(t *testing.T) {
+	for _, test := range adminPostRoomTests {
+		var reqBody io.Reader
+
+		if test.body != nil {
+			reqBody = strings.NewReader(test.body.Encode())
+		}
+
+		// Create POST request and store context on it which includes the `X-Session` header
+		// in order to read to/from the `Session object`
+		req, err := http.NewRequest("POST", "/admin/rooms", reqBody)
+		if err != nil {
+			log.Println(err)
+		}
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", test.id)
+
+		ctx := getRequestContext(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		// This fakes all of the request/response lifecycle
+		// Stores the response we get from the request
+		responseRecorder := httptest.NewRecorder()
+
+		test.handler(Repo, responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf(
+				"Test %s returns wrong response status code: got %d, wanted %d",
+				test.name,
+				responseRecorder.Code,
+				test.expectedStatusCode,
+			)
+		}
+
+		if test.expectedRedirectURL != "" {
+			// Get redirect URL
+			redirectURL, err := responseRecorder.Result().Location()
+			if err != nil {
+				log.Println(err)
+			}
+
+			if redirectURL.String() != test.expectedRedirectURL {
+				t.Errorf(
+					"Test %s redirects user to wrong URL: got %s, wanted %s",
+					test.name,
+					redirectURL.String(),
+					test.expectedRedirectURL,
+				)
+			}
+		}
+
+		if test.expectedHTML != "" {
+			html := responseRecorder.Body.String()
+
+			if !strings.Contains(html, test.expectedHTML) {
+				t.Errorf(
+					"Test %s return wrong HTML: expected %s",
+					test.name,
+					test.expectedHTML,
+				)
+			}
+		}
+	}
+}
+
+var adminUpdateRoomStatusTests = []struct {
+	name               string
+	handler            func(*Repository, http.ResponseWriter, *http.Request)
+	id                 string
+	expectedStatusCode int
+}{
+	{"Activates room", (*Repository).AdminActivateRoom, "1", http.StatusSeeOther},
+	{"Deactivates room", (*Repository).AdminDeactivateRoom, "2", http.StatusSeeOther},
+	{"Deactivates non-existent room", (*Repository).AdminDeactivateRoom, "3", http.StatusInternalServerError},
+	{"Invalid id URL parameter", (*Repository).AdminActivateRoom, "invalid", http.StatusInternalServerError},
+}
+
+func TestRepository_AdminUpdateRoomStatus(t *testing.T) {
+	for _, test := range adminUpdateRoomStatusTests {
+		req, err := http.NewRequest("GET", "/admin/rooms/"+test.id+"/activate", nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", test.id)
+
+		ctx := getRequestContext(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+
+		responseRecorder := httptest.NewRecorder()
+
+		test.handler(Repo, responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf(
+				"Test %s returns wrong response status code: got %d, wanted %d",
+				test.name,
+				responseRecorder.Code,
+				test.expectedStatusCode,
+			)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/go-chi/chi/v5"
+)
+
+// Session of a user as shown in the admin dashboard. The page identifies sessions by the hash of their token,
+// so that the tokens, which would let anyone reading them use the session, are never shown
+type activeSession struct {
+	ID string
+	models.Session
+}
+
+// AdminUserSessions is the page handler in the admin dashboard listing the sessions a user is logged in with
+func (repo *Repository) AdminUserSessions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	user, err := repo.DB.GetUserByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	sessions, err := repo.activeSessions(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["user"] = user
+	data["sessions"] = sessions
+
+	// Sessions kept in memory aren't in the database, so none of them can be listed
+	stringMap := make(map[string]string)
+	stringMap["store"] = repo.App.SessionStore
+
+	render.RenderTemplate(w, r, "admin-user-sessions.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data: data,
+	})
+}
+
+// Handler to revoke a session of a user, which logs the user out of it on their next request
+func (repo *Repository) AdminPostRevokeUserSession(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	sessionsURL := fmt.Sprintf("/admin/users/%d/sessions", id)
+
+	sessions, err := repo.activeSessions(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	for _, session := range sessions {
+		if session.ID != r.Form.Get("session") {
+			continue
+		}
+
+		// The token is remembered, so that a request of the session which is still running can't save it again
+		err = repo.DB.RevokeSession(session.Token, session.Expiry)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		repo.audit(r, audit.ActionSessionRevoked, audit.EntityUser, id, map[string]string{
+			"ip_address": session.IPAddress,
+			"user_agent": session.UserAgent,
+		}, nil)
+
+		repo.App.Session.Put(r.Context(), "success", "Session revoked")
+		http.Redirect(w, r, sessionsURL, http.StatusSeeOther)
+		return
+	}
+
+	repo.App.Session.Put(r.Context(), "error", "The session has already ended")
+	http.Redirect(w, r, sessionsURL, http.StatusSeeOther)
+}
+
+// Returns the sessions a user is logged in with
+func (repo *Repository) activeSessions(userID int) ([]activeSession, error) {
+	// Session expiry is stored in UTC
+	sessions, err := repo.DB.GetSessionsForUser(userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	var active []activeSession
+	for _, session := range sessions {
+		active = append(active, activeSession{ID: helpers.HashToken(session.Token), Session: session})
+	}
+
+	return active, nil
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/sessionstore"
+	"github.com/go-chi/chi/v5"
+)
+
+// Test repository which records the revoked sessions and the audit entries
+type sessionRecorder struct {
+	auditRecorder
+	deleted []string
+}
+
+func (recorder *sessionRecorder) RevokeSession(token string, expiry time.Time) error {
+	recorder.deleted = append(recorder.deleted, token)
+
+	return nil
+}
+
+func TestRepository_AdminUserSessions(t *testing.T) {
+	previous := Repo.App.SessionStore
+	t.Cleanup(func() { Repo.App.SessionStore = previous })
+
+	for store, expectedHTML := range map[string][]string{
+		sessionstore.StorePostgres: {"Firefox", "Safari", helpers.HashToken("laptop-session-token")},
+		sessionstore.StoreMemory: {"Sessions are kept in memory"},
+	} {
+		Repo.App.SessionStore = store
+
+		req, err := http.NewRequest("GET", "/admin/users/1/sessions", nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: models.AccessOwner})
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminUserSessions)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != http.StatusOK {
+			t.Errorf("Sessions page with %s store returns wrong response status code: got %d", store, responseRecorder.Code)
+		}
+
+		html := responseRecorder.Body.String()
+		for _, expected := range expectedHTML {
+			if !strings.Contains(html, expected) {
+				t.Errorf("Sessions page with %s store does not show %s", store, expected)
+			}
+		}
+
+		// Tokens would let anyone reading the page use the session
+		if strings.Contains(html, "laptop-session-token") {
+			t.Errorf("Sessions page with %s store shows a session token", store)
+		}
+	}
+}
+
+var adminPostRevokeUserSessionTests = []struct {
+	name            string
+	session         string
+	expectedFlash   string
+	expectedDeleted []string
+}{
+	{"Active session", helpers.HashToken("phone-session-token"), "success", []string{"phone-session-token"}},
+	{"Unknown session", helpers.HashToken("other-session-token"), "error", nil},
+	{"Raw token", "phone-session-token", "error", nil},
+}
+
+func TestRepository_AdminPostRevokeUserSession(t *testing.T) {
+	previous := Repo.DB
+	t.Cleanup(func() { Repo.DB = previous })
+
+	for _, test := range adminPostRevokeUserSessionTests {
+		recorder := &sessionRecorder{auditRecorder: auditRecorder{DatabaseRepository: previous}}
+		Repo.DB = recorder
+
+		body := url.Values{"session": {test.session}}
+
+		req, err := http.NewRequest("POST", "/admin/users/1/sessions/revoke", strings.NewReader(body.Encode()))
+		if err != nil {
+			log.Println(err)
+		}
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: models.AccessOwner})
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminPostRevokeUserSession)
+		handler.ServeHTTP(responseRecorder, req)
+
+		redirectURL, _ := responseRecorder.Result().Location()
+		if redirectURL == nil || redirectURL.String() != "/admin/users/1/sessions" {
+			t.Errorf("Test %s redirects to wrong URL: got %v", test.name, redirectURL)
+		}
+
+		if !session.Exists(ctx, test.expectedFlash) {
+			t.Errorf("Test %s should show a %s message", test.name, test.expectedFlash)
+		}
+
+		if strings.Join(recorder.deleted, ",") != strings.Join(test.expectedDeleted, ",") {
+			t.Errorf("Test %s deleted wrong sessions: got %v, wanted %v", test.name, recorder.deleted, test.expectedDeleted)
+		}
+
+		if len(test.expectedDeleted) > 0 && (len(recorder.entries) != 1 || recorder.entries[0].Action != audit.ActionSessionRevoked) {
+			t.Errorf("Test %s recorded wrong audit entries: %+v", test.name, recorder.entries)
+		}
+	}
+}
+
+func TestRepository_LogIn_RecordsClient(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/auth/login", nil)
+	req.RemoteAddr = "192.0.2.1:50000"
+	req.Header.Set("User-Agent", "Firefox")
+
+	ctx := getRequestContext(req)
+	req = req.WithContext(ctx)
+
+	Repo.logIn(req, models.User{ID: 1})
+
+	if session.GetString(ctx, sessionstore.KeyIPAddress) != "192.0.2.1" || session.GetString(ctx, sessionstore.KeyUserAgent) != "Firefox" {
+		t.Error("Logging in should store where the user logged in from in the session")
+	}
+}
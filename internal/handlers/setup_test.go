@@ -12,8 +12,10 @@ import (
 	"time"
 
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/emails"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/pricing"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-chi/chi/v5"
@@ -30,11 +32,13 @@ var functions = template.FuncMap{
 	"formatDate": render.FormatDate,
 	"convertDateToFormat": render.ConvertDateToFormat,
 	"iterate": render.Iterate,
+	"formatAmount": pricing.FormatAmount,
 }
 
 func TestMain(m *testing.M) {
 	// What we want to store in the session in global config
 	gob.Register(models.Reservation{})
+	gob.Register(models.Booking{})
 	gob.Register(models.Room{})
 	gob.Register(models.RoomRestriction{})
 	gob.Register(models.User{})
@@ -58,12 +62,14 @@ func TestMain(m *testing.M) {
 	// Set session in global config
 	app.Session = session
 
-	// Set email server
-	mailChan := make(chan models.MailData)
-	app.MailChan = mailChan
-	defer close(mailChan)
+	// Guests can change or cancel reservations until 2 days before arrival
+	app.CancellationWindow = 48 * time.Hour
 
-	listenForMail()
+	// Password reset links can be used for an hour
+	app.PasswordResetLifetime = time.Hour
+
+	// Owner notifications go to a fixed test address
+	app.OwnerEmail = "owner@example.com"
 
 	// Get all template pages
 	templates, err := GetTemplatePages()
@@ -78,6 +84,15 @@ func TestMain(m *testing.M) {
 	// Store app configuration in 'render' package
 	render.StoreAppConfig(&app)
 
+	// Get all email templates
+	err = emails.LoadTemplates("../../email-templates")
+	if err != nil {
+		log.Fatal("Cannot get email templates")
+	}
+
+	// Store app configuration in 'emails' package
+	emails.StoreAppConfig(&app)
+
 	// Create a repository and set it in the 'handlers' package
 	repo := NewTestRepository(&app)
 	SetRepository(repo)
@@ -88,14 +103,6 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
-func listenForMail() {
-	go func() {
-		for {
-			<- app.MailChan
-		}
-	}()
-}
-
 func getRoutes() http.Handler {
 	// Create router
 	mux := chi.NewRouter()
@@ -108,8 +115,10 @@ func getRoutes() http.Handler {
 	// Routes
 	mux.Get("/", Repo.Home)
 	mux.Get("/about", Repo.About)
-	mux.Get("/generals-quarters", Repo.Generals)
-	mux.Get("/majors-suite", Repo.Majors)
+	mux.Get("/rooms", Repo.Rooms)
+	mux.Get("/rooms/{slug}", Repo.Room)
+	mux.Get("/generals-quarters", Repo.RedirectToRoom("generals-quarters"))
+	mux.Get("/majors-suite", Repo.RedirectToRoom("majors-suite"))
 	mux.Get("/contact", Repo.Contact)
 	
 	mux.Get("/search-availability", Repo.SearchAvailability)
@@ -119,21 +128,141 @@ func getRoutes() http.Handler {
 	mux.Get("/make-reservation", Repo.MakeReservation)
 	mux.Post("/make-reservation", Repo.PostMakeReservation)
 	mux.Get("/reservation-summary", Repo.ReservationSummary)
+	mux.Get("/booking-summary", Repo.BookingSummary)
+
+	mux.Get("/cart", Repo.Cart)
+	mux.Post("/cart", Repo.PostAddToCart)
+	mux.Post("/cart/{index}/remove", Repo.RemoveFromCart)
+	mux.Post("/cart/checkout", Repo.PostCheckout)
+
+	mux.Get("/my-reservation", Repo.MyReservation)
+	mux.Post("/my-reservation", Repo.PostMyReservation)
+	mux.Get("/my-reservation/details", Repo.MyReservationDetails)
+	mux.Post("/my-reservation/change-dates", Repo.PostMyReservationChangeDates)
+	mux.Post("/my-reservation/cancel", Repo.PostMyReservationCancel)
+	mux.Get("/my-reservation/email-opt-out", Repo.EmailOptOut)
+	mux.Post("/my-reservation/email-opt-out", Repo.PostEmailOptOut)
+
+	mux.Get("/calendar/all.ics", Repo.AllRoomsCalendarFeed)
+	mux.Get("/calendar/rooms/{id}.ics", Repo.RoomCalendarFeed)
+
+	mux.Route("/api/v1", func(mux chi.Router) {
+		mux.NotFound(Repo.ApiNotFound)
+		mux.MethodNotAllowed(Repo.ApiMethodNotAllowed)
+
+		mux.Get("/rooms", Repo.ApiRooms)
+		mux.Get("/availability", Repo.ApiAvailability)
+		mux.Post("/reservations", Repo.ApiPostReservation)
+		mux.Get("/reservations/{code}", Repo.ApiReservation)
+
+		mux.Route("/admin", func(mux chi.Router) {
+			mux.With(Repo.RequireApiKey(ScopeReservationsRead)).Get("/reservations", Repo.AdminApiReservations)
+			mux.With(Repo.RequireApiKey(ScopeReservationsRead)).Get("/reservations/{id}", Repo.AdminApiReservation)
+			mux.With(Repo.RequireApiKey(ScopeReservationsWrite)).Post("/reservations/{id}/process", Repo.AdminApiProcessReservation)
+			mux.With(Repo.RequireApiKey(ScopeReservationsWrite)).Delete("/reservations/{id}", Repo.AdminApiDeleteReservation)
+			mux.With(Repo.RequireApiKey(ScopeCalendarWrite)).Post("/blocks", Repo.AdminApiPostBlock)
+			mux.With(Repo.RequireApiKey(ScopeCalendarWrite)).Delete("/blocks/{id}", Repo.AdminApiDeleteBlock)
+			mux.With(Repo.RequireApiKey(ScopeRoomsRead)).Get("/rooms", Repo.AdminApiRooms)
+		})
+	})
 	
 	mux.Get("/auth/login", Repo.ShowLogin)
 	mux.Post("/auth/login", Repo.PostShowLogin)
 	mux.Get("/auth/logout", Repo.Logout)
+	mux.Get("/auth/forgot-password", Repo.ForgotPassword)
+	mux.Post("/auth/forgot-password", Repo.PostForgotPassword)
+	mux.Get("/auth/reset-password", Repo.ResetPassword)
+	mux.Post("/auth/reset-password", Repo.PostResetPassword)
+	mux.Get("/auth/two-factor", Repo.TwoFactor)
+	mux.Post("/auth/two-factor", Repo.PostTwoFactor)
 
 	mux.Route("/admin", func(mux chi.Router) {
+		mux.Use(loginTestUser)
+		mux.Use(Repo.LoadUser)
+
 		mux.Get("/dashboard", Repo.AdminDashboard)
-		mux.Get("/new-reservations", Repo.AdminNewReservations)
-		mux.Get("/all-reservations", Repo.AdminAllReservations)
-		mux.Get("/reservations-calendar", Repo.AdminReservationsCalendar)
-		mux.Post("/reservations-calendar", Repo.AdminPostReservationsCalendar)
-		mux.Get("/reservations/{src}/{id}", Repo.AdminShowReservation)
-		mux.Post("/reservations/{src}/{id}", Repo.AdminPostShowReservation)
-		mux.Get("/process-reservation/{src}/{id}", Repo.AdminProcessReservation)
-		mux.Get("/delete-reservation/{src}/{id}", Repo.AdminDeleteReservation)
+		mux.Get("/choose-password", Repo.AdminChoosePassword)
+		mux.Post("/choose-password", Repo.AdminPostChoosePassword)
+		mux.Get("/change-password", Repo.AdminChangePassword)
+		mux.Post("/change-password", Repo.AdminPostChangePassword)
+		mux.Get("/two-factor", Repo.AdminTwoFactor)
+		mux.Post("/two-factor", Repo.AdminPostTwoFactor)
+		mux.Post("/two-factor/recovery-codes", Repo.AdminPostRecoveryCodes)
+		mux.Post("/two-factor/disable", Repo.AdminPostDisableTwoFactor)
+		mux.With(Repo.RequirePermission(models.PermissionViewReservations)).Get("/new-reservations", Repo.AdminNewReservations)
+		mux.With(Repo.RequirePermission(models.PermissionViewReservations)).Get("/all-reservations", Repo.AdminAllReservations)
+		mux.With(Repo.RequirePermission(models.PermissionViewReservations)).Get("/bookings", Repo.AdminBookings)
+		mux.With(Repo.RequirePermission(models.PermissionViewReservations)).Get("/bookings/{id}", Repo.AdminShowBooking)
+		mux.With(Repo.RequirePermission(models.PermissionViewReservations)).Get("/reservations-calendar", Repo.AdminReservationsCalendar)
+		mux.With(Repo.RequirePermission(models.PermissionEditReservations)).Post("/reservations-calendar", Repo.AdminPostReservationsCalendar)
+		mux.With(Repo.RequirePermission(models.PermissionEditReservations)).Get("/blocks", Repo.AdminBlocks)
+		mux.With(Repo.RequirePermission(models.PermissionEditReservations)).Get("/blocks/new", Repo.AdminNewBlock)
+		mux.With(Repo.RequirePermission(models.PermissionEditReservations)).Post("/blocks/new", Repo.AdminPostNewBlock)
+		mux.With(Repo.RequirePermission(models.PermissionEditReservations)).Get("/blocks/{id}", Repo.AdminShowBlock)
+		mux.With(Repo.RequirePermission(models.PermissionEditReservations)).Post("/blocks/{id}", Repo.AdminPostShowBlock)
+		mux.With(Repo.RequirePermission(models.PermissionEditReservations)).Get("/blocks/{id}/delete", Repo.AdminDeleteBlock)
+		mux.With(Repo.RequirePermission(models.PermissionViewReservations)).Get("/reservations/{src}/{id}", Repo.AdminShowReservation)
+		mux.With(Repo.RequirePermission(models.PermissionEditReservations)).Post("/reservations/{src}/{id}", Repo.AdminPostShowReservation)
+		mux.With(Repo.RequirePermission(models.PermissionEditReservations)).Get("/process-reservation/{src}/{id}", Repo.AdminProcessReservation)
+		mux.With(Repo.RequirePermission(models.PermissionDeleteReservations)).Get("/delete-reservation/{src}/{id}", Repo.AdminDeleteReservation)
+
+		mux.With(Repo.RequirePermission(models.PermissionManageRooms)).Get("/rooms", Repo.AdminRooms)
+		mux.With(Repo.RequirePermission(models.PermissionManageRooms)).Get("/rooms/new", Repo.AdminNewRoom)
+		mux.With(Repo.RequirePermission(models.PermissionManageRooms)).Post("/rooms/new", Repo.AdminPostNewRoom)
+		mux.With(Repo.RequirePermission(models.PermissionManageRooms)).Post("/rooms/order", Repo.AdminPostRoomsOrder)
+		mux.With(Repo.RequirePermission(models.PermissionManageRooms)).Get("/rooms/{id}", Repo.AdminShowRoom)
+		mux.With(Repo.RequirePermission(models.PermissionManageRooms)).Post("/rooms/{id}", Repo.AdminPostShowRoom)
+		mux.With(Repo.RequirePermission(models.PermissionManageRooms)).Get("/rooms/{id}/activate", Repo.AdminActivateRoom)
+		mux.With(Repo.RequirePermission(models.PermissionManageRooms)).Get("/rooms/{id}/deactivate", Repo.AdminDeactivateRoom)
+		mux.With(Repo.RequirePermission(models.PermissionManageRooms)).Get("/stay-rules", Repo.AdminStayRules)
+		mux.With(Repo.RequirePermission(models.PermissionManageRooms)).Get("/stay-rules/new", Repo.AdminNewStayRule)
+		mux.With(Repo.RequirePermission(models.PermissionManageRooms)).Post("/stay-rules/new", Repo.AdminPostNewStayRule)
+		mux.With(Repo.RequirePermission(models.PermissionManageRooms)).Get("/stay-rules/{id}", Repo.AdminShowStayRule)
+		mux.With(Repo.RequirePermission(models.PermissionManageRooms)).Post("/stay-rules/{id}", Repo.AdminPostShowStayRule)
+		mux.With(Repo.RequirePermission(models.PermissionManageRooms)).Get("/stay-rules/{id}/delete", Repo.AdminDeleteStayRule)
+
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/calendar-feeds", Repo.AdminCalendarFeeds)
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Post("/calendar-feeds", Repo.AdminPostCalendarFeeds)
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/calendar-feeds/{id}/revoke", Repo.AdminRevokeCalendarFeed)
+
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/calendar-imports", Repo.AdminCalendarImports)
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Post("/calendar-imports", Repo.AdminPostCalendarImports)
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/calendar-imports/{id}/sync", Repo.AdminSyncCalendarImport)
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/calendar-imports/{id}/delete", Repo.AdminDeleteCalendarImport)
+
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/api-keys", Repo.AdminApiKeys)
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Post("/api-keys", Repo.AdminPostApiKeys)
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Post("/api-keys/{id}/revoke", Repo.AdminRevokeApiKey)
+
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/webhooks", Repo.AdminWebhooks)
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Post("/webhooks", Repo.AdminPostWebhooks)
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/webhooks/{id}", Repo.AdminWebhook)
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/webhooks/{id}/activate", Repo.AdminActivateWebhook)
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/webhooks/{id}/deactivate", Repo.AdminDeactivateWebhook)
+		mux.With(Repo.RequirePermission(models.PermissionManageIntegrations)).Get("/webhooks/{id}/delete", Repo.AdminDeleteWebhook)
+
+		mux.With(Repo.RequirePermission(models.PermissionManageEmails)).Get("/emails", Repo.AdminEmails)
+		mux.With(Repo.RequirePermission(models.PermissionManageEmails)).Get("/emails/{id}", Repo.AdminEmail)
+		mux.With(Repo.RequirePermission(models.PermissionManageEmails)).Get("/emails/{id}/resend", Repo.AdminResendEmail)
+		mux.With(Repo.RequirePermission(models.PermissionManageEmails)).Get("/emails/{id}/cancel", Repo.AdminCancelEmail)
+		mux.With(Repo.RequirePermission(models.PermissionManageEmails)).Get("/email-templates", Repo.AdminEmailTemplates)
+		mux.With(Repo.RequirePermission(models.PermissionManageEmails)).Get("/email-templates/{name}", Repo.AdminEmailTemplate)
+
+		mux.With(Repo.RequirePermission(models.PermissionManageUsers)).Get("/users", Repo.AdminUsers)
+		mux.With(Repo.RequirePermission(models.PermissionManageUsers)).Get("/users/new", Repo.AdminNewUser)
+		mux.With(Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/new", Repo.AdminPostNewUser)
+		mux.With(Repo.RequirePermission(models.PermissionManageUsers)).Get("/users/{id}", Repo.AdminShowUser)
+		mux.With(Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/{id}", Repo.AdminPostShowUser)
+		mux.With(Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/{id}/enable", Repo.AdminEnableUser)
+		mux.With(Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/{id}/disable", Repo.AdminDisableUser)
+		mux.With(Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/{id}/reset-password", Repo.AdminResetUserPassword)
+		mux.With(Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/{id}/reset-two-factor", Repo.AdminResetUserTwoFactor)
+		mux.With(Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/{id}/delete", Repo.AdminDeleteUser)
+		mux.With(Repo.RequirePermission(models.PermissionManageUsers)).Get("/users/{id}/sessions", Repo.AdminUserSessions)
+		mux.With(Repo.RequirePermission(models.PermissionManageUsers)).Post("/users/{id}/sessions/revoke", Repo.AdminPostRevokeUserSession)
+		mux.With(Repo.RequirePermission(models.PermissionManageUsers)).Get("/login-security", Repo.AdminLoginSecurity)
+		mux.With(Repo.RequirePermission(models.PermissionManageUsers)).Post("/login-security/unlock", Repo.AdminPostUnlockLogin)
+		mux.With(Repo.RequirePermission(models.PermissionViewAuditLog)).Get("/audit-log", Repo.AdminAuditLog)
 	})
 
 	// Serve static files
@@ -163,6 +292,17 @@ func SessionLoad(next http.Handler) http.Handler {
 	return session.LoadAndSave(next)
 }
 
+// Logs requests to the admin dashboard in as the owner, since the test router doesn't require logging in
+func loginTestUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !session.Exists(r.Context(), "user_id") {
+			session.Put(r.Context(), "user_id", 1)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // Get all template pages
 func GetTemplatePages() (map[string]*template.Template, error) {
 	// Store all template pages found
@@ -198,6 +338,20 @@ func GetTemplatePages() (map[string]*template.Template, error) {
 			}
 		}
 
+		// Find partial files
+		matches, err = filepath.Glob(fmt.Sprintf("%s/*.partial.tmpl", pathToTemplates))
+		if err != nil {
+			return templates, err
+		}
+
+		// If any partial files have been found, associate them with the created template page
+		if len(matches) > 0 {
+			template, err = template.ParseGlob(fmt.Sprintf("%s/*.partial.tmpl", pathToTemplates))
+			if err != nil {
+				return templates, err
+			}
+		}
+
 		templates[name] = template
 	}
 
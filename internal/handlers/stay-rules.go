@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/stayrules"
+	"github.com/go-chi/chi/v5"
+)
+
+// Weekday offered in the stay rule form
+type stayRuleWeekday struct {
+	Value int
+	Name string
+	Checked bool
+}
+
+// Room found available by a search which can't be booked for the searched dates because of its stay rules
+type rejectedRoom struct {
+	Room models.Room
+	Reasons []string
+}
+
+// Returns the reasons why a room can't be booked from the arrival date (`startDate`) to the departure date (`endDate`)
+// because of its stay rules, or nil if it can
+func (repo *Repository) stayRuleReasons(roomID int, startDate, endDate time.Time) ([]string, error) {
+	rules, err := repo.DB.GetStayRulesForRoom(roomID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return stayrules.Check(rules, startDate, endDate), nil
+}
+
+// Splits the rooms found available by a search into the ones which can be booked for the searched dates
+// and the ones whose stay rules don't allow the stay
+func (repo *Repository) applyStayRules(rooms []models.Room, startDate, endDate time.Time) ([]models.Room, []rejectedRoom, error) {
+	var bookable []models.Room
+	var rejected []rejectedRoom
+
+	for _, room := range rooms {
+		reasons, err := repo.stayRuleReasons(room.ID, startDate, endDate)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(reasons) > 0 {
+			rejected = append(rejected, rejectedRoom{Room: room, Reasons: reasons})
+			continue
+		}
+
+		bookable = append(bookable, room)
+	}
+
+	return bookable, rejected, nil
+}
+
+// Joins the reasons of the rejected rooms into a message for the guest, giving each reason once
+func rejectedRoomsMessage(rejected []rejectedRoom) string {
+	var reasons []string
+	seen := make(map[string]bool)
+
+	for _, room := range rejected {
+		for _, reason := range room.Reasons {
+			if !seen[reason] {
+				seen[reason] = true
+				reasons = append(reasons, reason)
+			}
+		}
+	}
+
+	return strings.Join(reasons, ". ")
+}
+
+// AdminStayRules is the stay rules page handler in the admin dashboard. Rules which have ended aren't listed
+func (repo *Repository) AdminStayRules(w http.ResponseWriter, r *http.Request) {
+	// Rules last until the end of their end date, so the ones ending today are still listed
+	now := time.Now()
+	rules, err := repo.DB.GetStayRules(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["rules"] = rules
+
+	render.RenderTemplate(w, r, "admin-stay-rules.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminNewStayRule is the new stay rule page handler in the admin dashboard
+func (repo *Repository) AdminNewStayRule(w http.ResponseWriter, r *http.Request) {
+	rooms, err := repo.DB.GetAllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	renderStayRuleForm(w, r, newStayRuleStringMap(), forms.New(nil), models.StayRule{}, rooms)
+}
+
+// Handler to create a stay rule with received form data
+func (repo *Repository) AdminPostNewStayRule(w http.ResponseWriter, r *http.Request) {
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := repo.DB.GetAllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	var rule models.StayRule
+	form := validateStayRuleForm(r, &rule, rooms)
+
+	// Rerender stay rule form with updated error information
+	if !form.IsValid() {
+		renderStayRuleForm(w, r, newStayRuleStringMap(), form, rule, rooms)
+		return
+	}
+
+	rule.ID, err = repo.DB.InsertStayRule(rule)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionCreated, audit.EntityStayRule, rule.ID, nil, newAuditStayRule(rule))
+
+	repo.App.Session.Put(r.Context(), "success", "Stay rule created")
+	http.Redirect(w, r, "/admin/stay-rules", http.StatusSeeOther)
+}
+
+// AdminShowStayRule is the edit stay rule page handler in the admin dashboard
+func (repo *Repository) AdminShowStayRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := repo.stayRuleFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	rooms, err := repo.DB.GetAllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	stringMap := editStayRuleStringMap(rule.ID)
+	stringMap["start_date"] = rule.StartDate.Format("2006-01-02")
+	stringMap["end_date"] = rule.EndDate.Format("2006-01-02")
+	if rule.MinNights > 0 {
+		stringMap["min_nights"] = strconv.Itoa(rule.MinNights)
+	}
+	if rule.MaxNights > 0 {
+		stringMap["max_nights"] = strconv.Itoa(rule.MaxNights)
+	}
+
+	renderStayRuleForm(w, r, stringMap, forms.New(nil), rule, rooms)
+}
+
+// Handler to update a stay rule with received form data
+func (repo *Repository) AdminPostShowStayRule(w http.ResponseWriter, r *http.Request) {
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rule, ok := repo.stayRuleFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	rooms, err := repo.DB.GetAllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	before := newAuditStayRule(rule)
+	form := validateStayRuleForm(r, &rule, rooms)
+
+	// Rerender stay rule form with updated error information
+	if !form.IsValid() {
+		renderStayRuleForm(w, r, editStayRuleStringMap(rule.ID), form, rule, rooms)
+		return
+	}
+
+	err = repo.DB.UpdateStayRule(rule)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionUpdated, audit.EntityStayRule, rule.ID, before, newAuditStayRule(rule))
+
+	repo.App.Session.Put(r.Context(), "success", "Stay rule updated")
+	http.Redirect(w, r, "/admin/stay-rules", http.StatusSeeOther)
+}
+
+// Handler to delete a stay rule
+func (repo *Repository) AdminDeleteStayRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := repo.stayRuleFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	err := repo.DB.DeleteStayRule(rule.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionDeleted, audit.EntityStayRule, rule.ID, newAuditStayRule(rule), nil)
+
+	repo.App.Session.Put(r.Context(), "success", "Stay rule deleted")
+	http.Redirect(w, r, "/admin/stay-rules", http.StatusSeeOther)
+}
+
+// Returns the stay rule with the id given in the URL. Responds with 404 Not Found if there is no such rule
+func (repo *Repository) stayRuleFromURL(w http.ResponseWriter, r *http.Request) (models.StayRule, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return models.StayRule{}, false
+	}
+
+	rule, err := repo.DB.GetStayRuleByID(id)
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return models.StayRule{}, false
+	}
+
+	return rule, true
+}
+
+// Stores the stay rule form data in `rule` and validates it. The room has to be one of `rooms`
+func validateStayRuleForm(r *http.Request, rule *models.StayRule, rooms []models.Room) *forms.Form {
+	rule.RoomID, _ = strconv.Atoi(r.Form.Get("room_id"))
+	rule.Name = strings.TrimSpace(r.Form.Get("name"))
+	rule.ClosedToArrival = r.Form.Get("closed_to_arrival") != ""
+	rule.ClosedToDeparture = r.Form.Get("closed_to_departure") != ""
+
+	form := forms.New(r.PostForm)
+	form.RequiredFields("room_id", "start_date", "end_date")
+
+	if !isRoom(rule.RoomID, rooms) {
+		form.Errors.Add("room_id", "Choose one of the rooms")
+	}
+
+	startDate, startErr := time.Parse("2006-01-02", r.Form.Get("start_date"))
+	if startErr != nil {
+		form.Errors.Add("start_date", "Enter dates as YYYY-MM-DD")
+	}
+
+	endDate, endErr := time.Parse("2006-01-02", r.Form.Get("end_date"))
+	if endErr != nil {
+		form.Errors.Add("end_date", "Enter dates as YYYY-MM-DD")
+	}
+
+	if startErr == nil && endErr == nil && endDate.Before(startDate) {
+		form.Errors.Add("end_date", "The end date can't be before the start date")
+	}
+
+	rule.StartDate = startDate
+	rule.EndDate = endDate
+
+	// Empty lengths of stay don't limit it
+	rule.MinNights, rule.MaxNights = 0, 0
+	if form.Get("min_nights") != "" && form.MinValue("min_nights", 1) {
+		rule.MinNights, _ = strconv.Atoi(form.Get("min_nights"))
+	}
+
+	if form.Get("max_nights") != "" && form.MinValue("max_nights", 1) {
+		rule.MaxNights, _ = strconv.Atoi(form.Get("max_nights"))
+	}
+
+	if rule.MinNights > 0 && rule.MaxNights > 0 && rule.MaxNights < rule.MinNights {
+		form.Errors.Add("max_nights", "The maximum stay can't be shorter than the minimum stay")
+	}
+
+	rule.ArrivalDays = 0
+	for _, value := range r.PostForm["arrival_days"] {
+		day, err := strconv.Atoi(value)
+		if err != nil || day < int(time.Sunday) || day > int(time.Saturday) {
+			form.Errors.Add("arrival_days", "Choose days of the week")
+			continue
+		}
+
+		rule.ArrivalDays |= 1 << uint(day)
+	}
+
+	// Arriving on every day of the week is the same as not limiting the check-in days
+	if rule.ArrivalDays == 1 << 7 - 1 {
+		rule.ArrivalDays = 0
+	}
+
+	if rule.MinNights == 0 && rule.MaxNights == 0 && !rule.ClosedToArrival && !rule.ClosedToDeparture && rule.ArrivalDays == 0 {
+		form.Errors.Add("limits", "Set at least one limit for the stays")
+	}
+
+	return form
+}
+
+// Renders the stay rule form of the admin dashboard
+func renderStayRuleForm(w http.ResponseWriter, r *http.Request, stringMap map[string]string, form *forms.Form, rule models.StayRule, rooms []models.Room) {
+	// Rerendered forms keep the dates and lengths of stay as they were entered
+	if form.Values != nil {
+		for _, field := range []string{"start_date", "end_date", "min_nights", "max_nights"} {
+			stringMap[field] = form.Get(field)
+		}
+	}
+
+	var weekdays []stayRuleWeekday
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		weekdays = append(weekdays, stayRuleWeekday{
+			Value: int(day),
+			Name: day.String(),
+			Checked: rule.ArrivalDays & (1 << uint(day)) != 0,
+		})
+	}
+
+	data := make(map[string]interface{})
+	data["rule"] = rule
+	data["rooms"] = rooms
+	data["weekdays"] = weekdays
+
+	render.RenderTemplate(w, r, "admin-stay-rule.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Form: form,
+		Data: data,
+	})
+}
+
+// Title and form action of the new stay rule page
+func newStayRuleStringMap() map[string]string {
+	stringMap := make(map[string]string)
+	stringMap["title"] = "New Stay Rule"
+	stringMap["action"] = "/admin/stay-rules/new"
+
+	return stringMap
+}
+
+// Title and form action of the edit stay rule page
+func editStayRuleStringMap(id int) map[string]string {
+	stringMap := make(map[string]string)
+	stringMap["title"] = "Edit Stay Rule"
+	stringMap["action"] = fmt.Sprintf("/admin/stay-rules/%d", id)
+	stringMap["delete"] = fmt.Sprintf("/admin/stay-rules/%d/delete", id)
+
+	return stringMap
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+var adminPostStayRuleTests = []struct {
+	name               string
+	url                string
+	id                 string
+	body               url.Values
+	expectedStatusCode int
+	expectedHTML       string
+}{
+	{
+		"New stay rule",
+		"/admin/stay-rules/new",
+		"",
+		url.Values{"room_id": {"1"}, "name": {"Easter"}, "start_date": {"2050-04-01"}, "end_date": {"2050-04-15"}, "min_nights": {"2"}, "arrival_days": {"5", "6"}},
+		http.StatusSeeOther,
+		"",
+	},
+	{
+		"Rule of a single day",
+		"/admin/stay-rules/new",
+		"",
+		url.Values{"room_id": {"1"}, "start_date": {"2050-12-31"}, "end_date": {"2050-12-31"}, "closed_to_arrival": {"1"}},
+		http.StatusSeeOther,
+		"",
+	},
+	{
+		"End date before start date",
+		"/admin/stay-rules/new",
+		"",
+		url.Values{"room_id": {"1"}, "start_date": {"2050-04-15"}, "end_date": {"2050-04-01"}, "min_nights": {"2"}},
+		http.StatusOK,
+		"The end date can&#39;t be before the start date",
+	},
+	{
+		"Invalid date",
+		"/admin/stay-rules/new",
+		"",
+		url.Values{"room_id": {"1"}, "start_date": {"easter"}, "end_date": {"2050-04-15"}, "min_nights": {"2"}},
+		http.StatusOK,
+		"Enter dates as YYYY-MM-DD",
+	},
+	{
+		"Unknown room",
+		"/admin/stay-rules/new",
+		"",
+		url.Values{"room_id": {"9"}, "start_date": {"2050-04-01"}, "end_date": {"2050-04-15"}, "min_nights": {"2"}},
+		http.StatusOK,
+		"Choose one of the rooms",
+	},
+	{
+		"Invalid minimum stay",
+		"/admin/stay-rules/new",
+		"",
+		url.Values{"room_id": {"1"}, "start_date": {"2050-04-01"}, "end_date": {"2050-04-15"}, "min_nights": {"0"}},
+		http.StatusOK,
+		"This field must be at least 1",
+	},
+	{
+		"Maximum stay shorter than minimum stay",
+		"/admin/stay-rules/new",
+		"",
+		url.Values{"room_id": {"1"}, "start_date": {"2050-04-01"}, "end_date": {"2050-04-15"}, "min_nights": {"5"}, "max_nights": {"3"}},
+		http.StatusOK,
+		"The maximum stay can&#39;t be shorter than the minimum stay",
+	},
+	{
+		"Unknown check-in day",
+		"/admin/stay-rules/new",
+		"",
+		url.Values{"room_id": {"1"}, "start_date": {"2050-04-01"}, "end_date": {"2050-04-15"}, "arrival_days": {"7"}},
+		http.StatusOK,
+		"Choose days of the week",
+	},
+	{
+		"Rule without limits",
+		"/admin/stay-rules/new",
+		"",
+		url.Values{"room_id": {"1"}, "start_date": {"2050-04-01"}, "end_date": {"2050-04-15"}, "arrival_days": {"0", "1", "2", "3", "4", "5", "6"}},
+		http.StatusOK,
+		"Set at least one limit for the stays",
+	},
+	{
+		"Updated stay rule",
+		"/admin/stay-rules/1",
+		"1",
+		url.Values{"room_id": {"1"}, "name": {"Summer"}, "start_date": {"2049-07-01"}, "end_date": {"2049-08-31"}, "min_nights": {"7"}},
+		http.StatusSeeOther,
+		"",
+	},
+	{
+		"Updated non-existent stay rule",
+		"/admin/stay-rules/9",
+		"9",
+		url.Values{"room_id": {"1"}, "start_date": {"2049-07-01"}, "end_date": {"2049-08-31"}, "min_nights": {"7"}},
+		http.StatusNotFound,
+		"",
+	},
+}
+
+func TestRepository_AdminPostStayRule(t *testing.T) {
+	for _, test := range adminPostStayRuleTests {
+		req, err := http.NewRequest("POST", test.url, strings.NewReader(test.body.Encode()))
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: models.AccessOwner})
+
+		handler := http.HandlerFunc(Repo.AdminPostNewStayRule)
+		if test.id != "" {
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", test.id)
+			ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+
+			handler = http.HandlerFunc(Repo.AdminPostShowStayRule)
+		}
+
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		responseRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf("Test %s returns wrong response status code: got %d, wanted %d", test.name, responseRecorder.Code, test.expectedStatusCode)
+		}
+
+		if test.expectedStatusCode == http.StatusSeeOther {
+			redirectURL, _ := responseRecorder.Result().Location()
+			if redirectURL == nil || redirectURL.String() != "/admin/stay-rules" {
+				t.Errorf("Test %s redirects to wrong URL: got %v", test.name, redirectURL)
+			}
+		}
+
+		if test.expectedHTML != "" && !strings.Contains(responseRecorder.Body.String(), test.expectedHTML) {
+			t.Errorf("Test %s does not show %s", test.name, test.expectedHTML)
+		}
+	}
+}
+
+func TestRepository_AdminPostShowStayRule_Audit(t *testing.T) {
+	recorder := recordAuditEntries(t)
+
+	body := url.Values{
+		"room_id": {"1"},
+		"name": {"Summer"},
+		"start_date": {"2049-07-01"},
+		"end_date": {"2049-08-31"},
+		"min_nights": {"3"},
+		"max_nights": {"14"},
+		"arrival_days": {"6"},
+	}
+
+	req, err := http.NewRequest("POST", "/admin/stay-rules/1", strings.NewReader(body.Encode()))
+	if err != nil {
+		log.Println(err)
+	}
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "1")
+
+	ctx := getRequestContext(req)
+	ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: models.AccessOwner})
+	req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	responseRecorder := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.AdminPostShowStayRule)
+	handler.ServeHTTP(responseRecorder, req)
+
+	if len(recorder.entries) != 1 {
+		t.Fatalf("Updating a stay rule recorded %d audit entries, wanted 1", len(recorder.entries))
+	}
+
+	entry := recorder.entries[0]
+	if entry.Action != audit.ActionUpdated || entry.EntityType != audit.EntityStayRule || entry.EntityID != 1 {
+		t.Errorf("Wrong audit entry: %+v", entry)
+	}
+
+	// Only Friday was unticked
+	if len(entry.Changes) != 1 || entry.Changes[0] != (models.AuditChange{Field: "arrival_days", Before: "Friday, Saturday", After: "Saturday"}) {
+		t.Errorf("Wrong changes: %+v", entry.Changes)
+	}
+}
+
+func TestRepository_PostSearchAvailability_StayRules(t *testing.T) {
+	body := url.Values{"start_date": {"2049-07-02"}, "end_date": {"2049-07-03"}}
+
+	req, err := http.NewRequest("POST", "/search-availability", strings.NewReader(body.Encode()))
+	if err != nil {
+		log.Println(err)
+	}
+
+	ctx := getRequestContext(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	responseRecorder := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.PostSearchAvailability)
+	handler.ServeHTTP(responseRecorder, req)
+
+	expected := "Stays arriving between Jul 1, 2049 and Aug 31, 2049 must be at least 3 nights"
+	if message := session.GetString(ctx, "error"); message != expected {
+		t.Errorf("Search breaking the stay rules gives wrong reason: got %q, wanted %q", message, expected)
+	}
+
+	// The dates are kept so that the guest can change them
+	reservation, ok := session.Get(ctx, "reservation").(models.Reservation)
+	if !ok || reservation.StartDate.Format("2006-01-02") != "2049-07-02" {
+		t.Errorf("Searched dates should be kept in the session, got %+v", reservation)
+	}
+}
@@ -0,0 +1,468 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/totp"
+	"github.com/go-chi/chi/v5"
+)
+
+// Page where users set up and manage two-factor authentication
+const twoFactorPath = "/admin/two-factor"
+
+// Users have this long after entering their password to enter the code of their authenticator app
+const twoFactorLoginTimeout = 5 * time.Minute
+
+// Users who enter this many wrong codes have to log in with their password again
+const maxTwoFactorAttempts = 5
+
+// TwoFactor is the page handler where users with two-factor authentication enter a code after their password
+func (repo *Repository) TwoFactor(w http.ResponseWriter, r *http.Request) {
+	_, ok := repo.pendingTwoFactorUser(r)
+	if !ok {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+
+	render.RenderTemplate(w, r, "two-factor.page.tmpl", &models.TemplateData{
+		Form: forms.New(nil),
+	})
+}
+
+// Handler to finish the login of a user with two-factor authentication with an authenticator or recovery code
+func (repo *Repository) PostTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := repo.pendingTwoFactorUser(r)
+	if !ok {
+		repo.App.Session.Put(r.Context(), "error", "Your login has expired, please log in again")
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// Wrong codes count as failed logins, so the account can get locked while its user is entering codes
+	decision, err := repo.LoginGuard.Check(user.Email, helpers.ClientIP(r))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if decision.Locked {
+		repo.clearPendingTwoFactorUser(r)
+		repo.refuseLogin(w, r, user.Email, decision)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.RequiredFields("code")
+
+	usedRecoveryCode := false
+
+	if form.IsValid() {
+		usedRecoveryCode, err = repo.checkTwoFactorCode(user, form.Get("code"))
+		if errors.Is(err, repository.ErrTwoFactorCodeUsed) {
+			form.Errors.Add("code", "Invalid code")
+		} else if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	if !form.IsValid() {
+		err = repo.loginFailed(r, user.Email, user.ID, models.LoginFailureTwoFactor)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		attempts := repo.App.Session.GetInt(r.Context(), "two_factor_attempts") + 1
+		if attempts >= maxTwoFactorAttempts {
+			repo.clearPendingTwoFactorUser(r)
+			repo.App.InfoLog.Printf("User %d entered too many wrong two-factor codes", user.ID)
+			repo.App.Session.Put(r.Context(), "error", "Too many wrong codes, please log in again")
+			http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+			return
+		}
+
+		repo.App.Session.Put(r.Context(), "two_factor_attempts", attempts)
+
+		// Rerender page with updated error information
+		render.RenderTemplate(w, r, "two-factor.page.tmpl", &models.TemplateData{
+			Form: form,
+		})
+		return
+	}
+
+	repo.clearPendingTwoFactorUser(r)
+
+	// Prevent session fixation attack
+	err = repo.App.Session.RenewToken(r.Context())
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.loginSucceeded(r, user)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.logIn(r, user)
+
+	if usedRecoveryCode {
+		remaining, err := repo.DB.CountUnusedRecoveryCodes(user.ID)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		repo.App.Session.Put(r.Context(), "warning", "You used a recovery code, you have " + strconv.Itoa(remaining) + " left")
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// AdminTwoFactor is the page handler where users set up two-factor authentication,
+// or get new recovery codes and turn it off once it is set up
+func (repo *Repository) AdminTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, _ := helpers.CurrentUser(r)
+
+	if !user.TwoFactorEnabled {
+		// The secret is only stored for the user once a code of the authenticator app confirms the setup
+		secret := repo.App.Session.GetString(r.Context(), "two_factor_setup_secret")
+		if secret == "" {
+			var err error
+
+			secret, err = totp.GenerateSecret()
+			if err != nil {
+				helpers.ServerError(w, err)
+				return
+			}
+
+			repo.App.Session.Put(r.Context(), "two_factor_setup_secret", secret)
+		}
+
+		repo.renderTwoFactorSetup(w, r, user, secret, forms.New(nil))
+		return
+	}
+
+	repo.renderTwoFactor(w, r, user, forms.New(nil))
+}
+
+// Handler to turn on two-factor authentication once the user entered a code of the authenticator app
+func (repo *Repository) AdminPostTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, _ := helpers.CurrentUser(r)
+
+	secret := repo.App.Session.GetString(r.Context(), "two_factor_setup_secret")
+	if user.TwoFactorEnabled || secret == "" {
+		http.Redirect(w, r, twoFactorPath, http.StatusSeeOther)
+		return
+	}
+
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.RequiredFields("code")
+
+	if form.IsValid() {
+		if _, ok := totp.Validate(secret, form.Get("code"), time.Now()); !ok {
+			form.Errors.Add("code", "Invalid code, check that the clock of your phone is right")
+		}
+	}
+
+	// Rerender page with updated error information
+	if !form.IsValid() {
+		repo.renderTwoFactorSetup(w, r, user, secret, form)
+		return
+	}
+
+	codes, codeHashes, err := generateRecoveryCodes()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.EnableTwoFactorForUser(user.ID, secret, codeHashes)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	before := newAuditUser(user)
+	user.TwoFactorEnabled = true
+	repo.audit(r, audit.ActionTwoFactorEnabled, audit.EntityUser, user.ID, before, newAuditUser(user))
+
+	repo.App.Session.Remove(r.Context(), "two_factor_setup_secret")
+	repo.App.Session.Put(r.Context(), "recovery_codes", codes)
+	repo.App.Session.Put(r.Context(), "success", "Two-factor authentication is turned on")
+	http.Redirect(w, r, twoFactorPath, http.StatusSeeOther)
+}
+
+// Handler to replace the recovery codes of the user, who has to enter a current code first
+func (repo *Repository) AdminPostRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, _ := helpers.CurrentUser(r)
+
+	form, ok := repo.confirmTwoFactorAction(w, r, user)
+	if !ok {
+		if form != nil {
+			repo.renderTwoFactor(w, r, user, form)
+		}
+		return
+	}
+
+	codes, codeHashes, err := generateRecoveryCodes()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.ReplaceRecoveryCodes(user.ID, codeHashes)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionRecoveryCodesReplaced, audit.EntityUser, user.ID, nil, nil)
+
+	repo.App.Session.Put(r.Context(), "recovery_codes", codes)
+	repo.App.Session.Put(r.Context(), "success", "You have new recovery codes, the old ones no longer work")
+	http.Redirect(w, r, twoFactorPath, http.StatusSeeOther)
+}
+
+// Handler to turn off two-factor authentication of the user, who has to enter a current code first
+func (repo *Repository) AdminPostDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, _ := helpers.CurrentUser(r)
+
+	if repo.twoFactorRequired(user) {
+		repo.App.Session.Put(r.Context(), "error", "Your role has to use two-factor authentication")
+		http.Redirect(w, r, twoFactorPath, http.StatusSeeOther)
+		return
+	}
+
+	form, ok := repo.confirmTwoFactorAction(w, r, user)
+	if !ok {
+		if form != nil {
+			repo.renderTwoFactor(w, r, user, form)
+		}
+		return
+	}
+
+	err := repo.DB.DisableTwoFactorForUser(user.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	before := newAuditUser(user)
+	user.TwoFactorEnabled = false
+	repo.audit(r, audit.ActionTwoFactorDisabled, audit.EntityUser, user.ID, before, newAuditUser(user))
+
+	repo.App.Session.Put(r.Context(), "success", "Two-factor authentication is turned off")
+	http.Redirect(w, r, twoFactorPath, http.StatusSeeOther)
+}
+
+// Handler to turn off two-factor authentication of a user who lost their phone and recovery codes.
+// The user can set it up again after logging in, and has to if their role requires it
+func (repo *Repository) AdminResetUserTwoFactor(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	currentUser, _ := helpers.CurrentUser(r)
+	if id == currentUser.ID {
+		repo.App.Session.Put(r.Context(), "error", "Manage your own two-factor authentication on its page")
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	user, err := repo.DB.GetUserByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.DisableTwoFactorForUser(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.App.InfoLog.Printf("User %d reset the two-factor authentication of user %d", currentUser.ID, id)
+
+	before := newAuditUser(user)
+	user.TwoFactorEnabled = false
+	repo.audit(r, audit.ActionTwoFactorReset, audit.EntityUser, id, before, newAuditUser(user))
+
+	repo.App.Session.Put(r.Context(), "success", "Two-factor authentication of the user has been reset")
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// Remembers a user who entered the right password but still has to enter a two-factor code.
+// The user isn't logged in until then
+func (repo *Repository) startTwoFactorLogin(r *http.Request, user models.User) {
+	repo.App.Session.Put(r.Context(), "two_factor_user_id", user.ID)
+	repo.App.Session.Put(r.Context(), "two_factor_started_at", int(time.Now().Unix()))
+	repo.App.Session.Remove(r.Context(), "two_factor_attempts")
+}
+
+// Returns the user who still has to enter a two-factor code, if the password was entered recently enough
+func (repo *Repository) pendingTwoFactorUser(r *http.Request) (models.User, bool) {
+	userID := repo.App.Session.GetInt(r.Context(), "two_factor_user_id")
+	startedAt := time.Unix(int64(repo.App.Session.GetInt(r.Context(), "two_factor_started_at")), 0)
+
+	if userID == 0 || time.Since(startedAt) > twoFactorLoginTimeout {
+		return models.User{}, false
+	}
+
+	user, err := repo.DB.GetUserByID(userID)
+	if err != nil || user.Disabled || !user.TwoFactorEnabled {
+		return models.User{}, false
+	}
+
+	return user, true
+}
+
+// Forgets the user who had to enter a two-factor code
+func (repo *Repository) clearPendingTwoFactorUser(r *http.Request) {
+	repo.App.Session.Remove(r.Context(), "two_factor_user_id")
+	repo.App.Session.Remove(r.Context(), "two_factor_started_at")
+	repo.App.Session.Remove(r.Context(), "two_factor_attempts")
+}
+
+// Checks an authenticator code or, failing that, a recovery code of the user and uses it up.
+// Returns whether it was a recovery code, or `repository.ErrTwoFactorCodeUsed` if the code isn't valid
+func (repo *Repository) checkTwoFactorCode(user models.User, code string) (bool, error) {
+	step, ok := totp.Validate(user.TwoFactorSecret, code, time.Now())
+	if ok {
+		// A code can only be used once, even within its 30 seconds
+		return false, repo.DB.UpdateTwoFactorLastStepForUser(user.ID, step)
+	}
+
+	err := repo.DB.UseRecoveryCode(user.ID, helpers.HashToken(helpers.NormalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Checks the code users enter to confirm changes to their two-factor authentication.
+// On failure it returns the form to rerender, or nil if a response has already been written
+func (repo *Repository) confirmTwoFactorAction(w http.ResponseWriter, r *http.Request, user models.User) (*forms.Form, bool) {
+	if !user.TwoFactorEnabled {
+		http.Redirect(w, r, twoFactorPath, http.StatusSeeOther)
+		return nil, false
+	}
+
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return nil, false
+	}
+
+	form := forms.New(r.PostForm)
+	form.RequiredFields("code")
+
+	if form.IsValid() {
+		_, err = repo.checkTwoFactorCode(user, form.Get("code"))
+		if errors.Is(err, repository.ErrTwoFactorCodeUsed) {
+			form.Errors.Add("code", "Invalid code")
+		} else if err != nil {
+			helpers.ServerError(w, err)
+			return nil, false
+		}
+	}
+
+	return form, form.IsValid()
+}
+
+// Reports whether the role of the user has to use two-factor authentication
+func (repo *Repository) twoFactorRequired(user models.User) bool {
+	for _, accessLevel := range repo.App.TwoFactorRoles {
+		if accessLevel == user.AccessLevel {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Renders the page to set up two-factor authentication with the QR code of the secret
+func (repo *Repository) renderTwoFactorSetup(w http.ResponseWriter, r *http.Request, user models.User, secret string, form *forms.Form) {
+	stringMap := make(map[string]string)
+	stringMap["secret"] = secret
+	stringMap["uri"] = totp.URI(repo.App.PropertyName, user.Email, secret)
+
+	render.RenderTemplate(w, r, "admin-two-factor-setup.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Form: form,
+	})
+}
+
+// Renders the page of users with two-factor authentication. New recovery codes are shown once
+func (repo *Repository) renderTwoFactor(w http.ResponseWriter, r *http.Request, user models.User, form *forms.Form) {
+	remaining, err := repo.DB.CountUnusedRecoveryCodes(user.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["recovery_codes"], _ = repo.App.Session.Pop(r.Context(), "recovery_codes").([]string)
+	data["required"] = repo.twoFactorRequired(user)
+
+	intMap := make(map[string]int)
+	intMap["remaining_recovery_codes"] = remaining
+
+	render.RenderTemplate(w, r, "admin-two-factor.page.tmpl", &models.TemplateData{
+		IntMap: intMap,
+		Data: data,
+		Form: form,
+	})
+}
+
+// Generates a new set of recovery codes and their hashes
+func generateRecoveryCodes() ([]string, []string, error) {
+	var codes, codeHashes []string
+
+	for i := 0; i < helpers.RecoveryCodeCount; i++ {
+		code, err := helpers.GenerateRecoveryCode()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		codes = append(codes, code)
+		codeHashes = append(codeHashes, helpers.HashToken(helpers.NormalizeRecoveryCode(code)))
+	}
+
+	return codes, codeHashes, nil
+}
+
+// Reports whether the path belongs to the pages where users manage their two-factor authentication
+func isTwoFactorPath(path string) bool {
+	return path == twoFactorPath || strings.HasPrefix(path, twoFactorPath + "/")
+}
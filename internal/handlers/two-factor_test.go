@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	dbrepository "github.com/LuisBarroso37/bed-and-breakfast/internal/repository/db-repository"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/totp"
+	"github.com/go-chi/chi/v5"
+)
+
+// Returns the current code of the authenticator app of the test user with two-factor authentication
+func currentTwoFactorCode() string {
+	code, _ := totp.Code(dbrepository.TestTwoFactorSecret, totp.Step(time.Now()))
+
+	return code
+}
+
+var postTwoFactorTests = []struct {
+	name                string
+	code                string
+	startedAt           time.Time
+	expectedStatusCode  int
+	expectedRedirectURL string
+	loggedIn            bool
+}{
+	{"Authenticator code", currentTwoFactorCode(), time.Now(), http.StatusSeeOther, "/", true},
+	{"Recovery code", "abcde-23456", time.Now(), http.StatusSeeOther, "/", true},
+	{"Used recovery code", "USED2-34567", time.Now(), http.StatusOK, "", false},
+	{"Wrong code", "000000", time.Now(), http.StatusOK, "", false},
+	{"Expired login", currentTwoFactorCode(), time.Now().Add(-10 * time.Minute), http.StatusSeeOther, "/auth/login", false},
+}
+
+func TestRepository_PostTwoFactor(t *testing.T) {
+	for _, test := range postTwoFactorTests {
+		body := url.Values{"code": {test.code}}
+
+		req, err := http.NewRequest("POST", "/auth/two-factor", strings.NewReader(body.Encode()))
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		// The user entered the right password
+		session.Put(ctx, "two_factor_user_id", 6)
+		session.Put(ctx, "two_factor_started_at", int(test.startedAt.Unix()))
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.PostTwoFactor)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf("Test %s returns wrong response status code: got %d, wanted %d", test.name, responseRecorder.Code, test.expectedStatusCode)
+		}
+
+		if test.expectedRedirectURL != "" {
+			redirectURL, _ := responseRecorder.Result().Location()
+			if redirectURL == nil || redirectURL.String() != test.expectedRedirectURL {
+				t.Errorf("Test %s redirects to wrong URL: got %v, wanted %s", test.name, redirectURL, test.expectedRedirectURL)
+			}
+		}
+
+		if session.GetInt(ctx, "user_id") == 6 != test.loggedIn {
+			t.Errorf("Test %s has wrong login state", test.name)
+		}
+	}
+}
+
+func TestRepository_PostTwoFactor_TooManyAttempts(t *testing.T) {
+	req, err := http.NewRequest("POST", "/auth/two-factor", strings.NewReader("code=000000"))
+	if err != nil {
+		log.Println(err)
+	}
+
+	ctx := getRequestContext(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	session.Put(ctx, "two_factor_user_id", 6)
+	session.Put(ctx, "two_factor_started_at", int(time.Now().Unix()))
+	session.Put(ctx, "two_factor_attempts", maxTwoFactorAttempts - 1)
+
+	responseRecorder := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.PostTwoFactor)
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusSeeOther {
+		t.Errorf("Wrong response status code: got %d, wanted %d", responseRecorder.Code, http.StatusSeeOther)
+	}
+
+	// The password has to be entered again
+	if session.Exists(ctx, "two_factor_user_id") {
+		t.Error("User still doesn't have to enter the password again")
+	}
+}
+
+func TestRepository_AdminPostTwoFactor(t *testing.T) {
+	secret, _ := totp.GenerateSecret()
+	code, _ := totp.Code(secret, totp.Step(time.Now()))
+
+	codes := map[string]int{
+		"000000": http.StatusOK,
+		code: http.StatusSeeOther,
+	}
+
+	for code, expectedStatusCode := range codes {
+		req, err := http.NewRequest("POST", "/admin/two-factor", strings.NewReader("code="+code))
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, Email: "me@here.com", AccessLevel: models.AccessOwner})
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		session.Put(ctx, "two_factor_setup_secret", secret)
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminPostTwoFactor)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != expectedStatusCode {
+			t.Errorf("Code %s returns wrong response status code: got %d, wanted %d", code, responseRecorder.Code, expectedStatusCode)
+		}
+
+		// Recovery codes are handed out once the setup is confirmed
+		recoveryCodes, _ := session.Get(ctx, "recovery_codes").([]string)
+		if expectedStatusCode == http.StatusSeeOther && len(recoveryCodes) != helpers.RecoveryCodeCount {
+			t.Errorf("Code %s handed out %d recovery codes", code, len(recoveryCodes))
+		}
+	}
+}
+
+func TestRepository_AdminPostDisableTwoFactor(t *testing.T) {
+	twoFactorUser := models.User{ID: 6, AccessLevel: models.AccessOwner, TwoFactorSecret: dbrepository.TestTwoFactorSecret, TwoFactorEnabled: true}
+
+	tests := []struct {
+		name               string
+		code               string
+		roles              []int
+		expectedStatusCode int
+	}{
+		{"Turns off", currentTwoFactorCode(), nil, http.StatusSeeOther},
+		{"Wrong code", "000000", nil, http.StatusOK},
+		{"Required for role", currentTwoFactorCode(), []int{models.AccessOwner}, http.StatusSeeOther},
+	}
+
+	for _, test := range tests {
+		Repo.App.TwoFactorRoles = test.roles
+
+		req, err := http.NewRequest("POST", "/admin/two-factor/disable", strings.NewReader("code="+test.code))
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, twoFactorUser)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminPostDisableTwoFactor)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf("Test %s returns wrong response status code: got %d, wanted %d", test.name, responseRecorder.Code, test.expectedStatusCode)
+		}
+
+		if test.roles != nil && !session.Exists(ctx, "error") {
+			t.Errorf("Test %s turned off required two-factor authentication", test.name)
+		}
+	}
+
+	Repo.App.TwoFactorRoles = nil
+}
+
+func TestRepository_AdminResetUserTwoFactor(t *testing.T) {
+	ids := map[string]string{
+		"6": "success",
+		"1": "error",
+	}
+
+	for id, expectedFlash := range ids {
+		req, err := http.NewRequest("POST", "/admin/users/"+id+"/reset-two-factor", nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: models.AccessOwner})
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminResetUserTwoFactor)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != http.StatusSeeOther {
+			t.Errorf("User %s returns wrong response status code: got %d, wanted %d", id, responseRecorder.Code, http.StatusSeeOther)
+		}
+
+		if !session.Exists(ctx, expectedFlash) {
+			t.Errorf("User %s did not store %s in the session", id, expectedFlash)
+		}
+	}
+}
+
+func TestRepository_LoadUser_TwoFactorRequired(t *testing.T) {
+	Repo.App.TwoFactorRoles = []int{models.AccessOwner}
+	defer func() { Repo.App.TwoFactorRoles = nil }()
+
+	tests := []struct {
+		name               string
+		userID             int
+		path               string
+		expectedStatusCode int
+	}{
+		{"Owner without two-factor", 1, "/admin/dashboard", http.StatusSeeOther},
+		{"Owner setting up two-factor", 1, "/admin/two-factor", http.StatusOK},
+		{"Owner with two-factor", 6, "/admin/dashboard", http.StatusOK},
+		{"Front desk without two-factor", 2, "/admin/dashboard", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", test.path, nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		req = req.WithContext(ctx)
+		session.Put(ctx, "user_id", test.userID)
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := Repo.LoadUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf("Test %s returns wrong response status code: got %d, wanted %d", test.name, responseRecorder.Code, test.expectedStatusCode)
+		}
+	}
+}
@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/emails"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminUsers is the users page handler in the admin dashboard
+func (repo *Repository) AdminUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := repo.DB.GetAllUsers()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["users"] = users
+
+	// The temporary password of an invited user is only shown once, right after the invitation
+	stringMap := make(map[string]string)
+	stringMap["temporary_password"] = repo.App.Session.PopString(r.Context(), "temporary_password")
+	stringMap["temporary_password_email"] = repo.App.Session.PopString(r.Context(), "temporary_password_email")
+
+	render.RenderTemplate(w, r, "admin-users.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data: data,
+	})
+}
+
+// AdminNewUser is the invite user page handler in the admin dashboard
+func (repo *Repository) AdminNewUser(w http.ResponseWriter, r *http.Request) {
+	renderUserForm(w, r, newUserStringMap(), forms.New(nil), models.User{AccessLevel: models.AccessFrontDesk})
+}
+
+// Handler to invite a user with received form data. The user gets a temporary password,
+// which is shown once to the inviting user and has to be changed on the first login
+func (repo *Repository) AdminPostNewUser(w http.ResponseWriter, r *http.Request) {
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	user := models.User{PasswordResetRequired: true}
+	form := validateUserForm(r, &user)
+
+	// Rerender user form with updated error information
+	if !form.IsValid() {
+		renderUserForm(w, r, newUserStringMap(), form, user)
+		return
+	}
+
+	password, err := helpers.GenerateTemporaryPassword()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	currentUser, _ := helpers.CurrentUser(r)
+
+	invitation, err := emails.NewMail(emails.Invitation, user.Email, emails.InvitationData{
+		User: user,
+		InvitedBy: fmt.Sprintf("%s %s", currentUser.FirstName, currentUser.LastName),
+		LoginURL: fmt.Sprintf("%s/auth/login", strings.TrimSuffix(repo.App.BaseURL, "/")),
+	})
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	id, err := repo.DB.InsertUser(user, password, []models.MailData{invitation})
+	if errors.Is(err, repository.ErrEmailTaken) {
+		form.Errors.Add("email", "This email is already used by another user")
+		renderUserForm(w, r, newUserStringMap(), form, user)
+		return
+	}
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionCreated, audit.EntityUser, id, nil, newAuditUser(user))
+
+	repo.App.Session.Put(r.Context(), "temporary_password", password)
+	repo.App.Session.Put(r.Context(), "temporary_password_email", user.Email)
+	repo.App.Session.Put(r.Context(), "success", "User invited")
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// AdminShowUser is the edit user page handler in the admin dashboard
+func (repo *Repository) AdminShowUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	user, err := repo.DB.GetUserByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	renderUserForm(w, r, editUserStringMap(id), forms.New(nil), user)
+}
+
+// Handler to update the name, email and role of a user with received form data
+func (repo *Repository) AdminPostShowUser(w http.ResponseWriter, r *http.Request) {
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	user, err := repo.DB.GetUserByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// Keep the stored values for the audit log
+	before := newAuditUser(user)
+
+	form := validateUserForm(r, &user)
+
+	// Users can't take away their own right to manage users, so there is always someone left who can
+	currentUser, _ := helpers.CurrentUser(r)
+	if user.ID == currentUser.ID && !user.Can(models.PermissionManageUsers) {
+		form.Errors.Add("access_level", "You can't remove your own right to manage users")
+	}
+
+	// Rerender user form with updated error information
+	if !form.IsValid() {
+		renderUserForm(w, r, editUserStringMap(id), form, user)
+		return
+	}
+
+	err = repo.DB.UpdateUser(user)
+	if errors.Is(err, repository.ErrEmailTaken) {
+		form.Errors.Add("email", "This email is already used by another user")
+		renderUserForm(w, r, editUserStringMap(id), form, user)
+		return
+	}
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionUpdated, audit.EntityUser, id, before, newAuditUser(user))
+
+	repo.App.Session.Put(r.Context(), "success", "User successfully updated")
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// Handler to let a disabled user log in again
+func (repo *Repository) AdminEnableUser(w http.ResponseWriter, r *http.Request) {
+	repo.updateDisabledForUser(w, r, false)
+}
+
+// Handler to stop a user from logging in. The user is logged out on the next request
+func (repo *Repository) AdminDisableUser(w http.ResponseWriter, r *http.Request) {
+	repo.updateDisabledForUser(w, r, true)
+}
+
+// Handler to make a user choose a new password before using the admin dashboard again
+func (repo *Repository) AdminResetUserPassword(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	user, err := repo.DB.GetUserByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.UpdatePasswordResetRequiredForUser(id, true)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	before := newAuditUser(user)
+	user.PasswordResetRequired = true
+	repo.audit(r, audit.ActionPasswordReset, audit.EntityUser, id, before, newAuditUser(user))
+
+	repo.App.Session.Put(r.Context(), "success", "The user has to choose a new password")
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// Handler to delete a user
+func (repo *Repository) AdminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	currentUser, _ := helpers.CurrentUser(r)
+	if id == currentUser.ID {
+		repo.App.Session.Put(r.Context(), "error", "You can't delete yourself")
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	user, err := repo.DB.GetUserByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.DeleteUser(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionDeleted, audit.EntityUser, id, newAuditUser(user), nil)
+
+	repo.App.Session.Put(r.Context(), "success", "User deleted")
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// AdminChoosePassword is the page handler where users who have to reset their password choose a new one
+func (repo *Repository) AdminChoosePassword(w http.ResponseWriter, r *http.Request) {
+	user, _ := helpers.CurrentUser(r)
+	if !user.PasswordResetRequired {
+		http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
+		return
+	}
+
+	render.RenderTemplate(w, r, "admin-choose-password.page.tmpl", &models.TemplateData{
+		Form: forms.New(nil),
+	})
+}
+
+// Handler to store the new password chosen by a user who has to reset their password
+func (repo *Repository) AdminPostChoosePassword(w http.ResponseWriter, r *http.Request) {
+	user, _ := helpers.CurrentUser(r)
+	if !user.PasswordResetRequired {
+		http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
+		return
+	}
+
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	validateNewPassword(form)
+
+	// Rerender page with updated error information
+	if !form.IsValid() {
+		render.RenderTemplate(w, r, "admin-choose-password.page.tmpl", &models.TemplateData{
+			Form: form,
+		})
+		return
+	}
+
+	err = repo.storeNewPassword(r, user.ID, form.Get("password"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.App.Session.Put(r.Context(), "success", "Your password has been changed")
+	http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
+}
+
+// Disables or enables the user with the id given in the URL
+func (repo *Repository) updateDisabledForUser(w http.ResponseWriter, r *http.Request, disabled bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	currentUser, _ := helpers.CurrentUser(r)
+	if disabled && id == currentUser.ID {
+		repo.App.Session.Put(r.Context(), "error", "You can't disable yourself")
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	user, err := repo.DB.GetUserByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.UpdateDisabledForUser(id, disabled)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	before := newAuditUser(user)
+	user.Disabled = disabled
+
+	if disabled {
+		repo.audit(r, audit.ActionDisabled, audit.EntityUser, id, before, newAuditUser(user))
+	} else {
+		repo.audit(r, audit.ActionEnabled, audit.EntityUser, id, before, newAuditUser(user))
+	}
+
+	if disabled {
+		repo.App.Session.Put(r.Context(), "success", "User disabled")
+	} else {
+		repo.App.Session.Put(r.Context(), "success", "User enabled")
+	}
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// Stores the user form data in `user` and validates it
+func validateUserForm(r *http.Request, user *models.User) *forms.Form {
+	user.FirstName = strings.TrimSpace(r.Form.Get("first_name"))
+	user.LastName = strings.TrimSpace(r.Form.Get("last_name"))
+	user.Email = strings.TrimSpace(r.Form.Get("email"))
+	user.AccessLevel, _ = strconv.Atoi(r.Form.Get("access_level"))
+
+	form := forms.New(r.PostForm)
+	form.RequiredFields("first_name", "last_name", "email", "access_level")
+	form.IsEmail("email")
+
+	if !isAccessLevel(user.AccessLevel) {
+		form.Errors.Add("access_level", "Choose one of the roles")
+	}
+
+	return form
+}
+
+// Renders the user form of the admin dashboard
+func renderUserForm(w http.ResponseWriter, r *http.Request, stringMap map[string]string, form *forms.Form, user models.User) {
+	// Roles offered in the form
+	var roles []models.User
+	for _, accessLevel := range models.AccessLevels {
+		roles = append(roles, models.User{AccessLevel: accessLevel})
+	}
+
+	data := make(map[string]interface{})
+	data["user"] = user
+	data["roles"] = roles
+
+	render.RenderTemplate(w, r, "admin-user.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Form: form,
+		Data: data,
+	})
+}
+
+// Title and form action of the invite user page
+func newUserStringMap() map[string]string {
+	stringMap := make(map[string]string)
+	stringMap["title"] = "Invite User"
+	stringMap["action"] = "/admin/users/new"
+
+	return stringMap
+}
+
+// Title and form action of the edit user page
+func editUserStringMap(id int) map[string]string {
+	stringMap := make(map[string]string)
+	stringMap["title"] = "Edit User"
+	stringMap["action"] = fmt.Sprintf("/admin/users/%d", id)
+
+	return stringMap
+}
+
+// Reports whether an access level belongs to one of the roles
+func isAccessLevel(accessLevel int) bool {
+	for _, level := range models.AccessLevels {
+		if level == accessLevel {
+			return true
+		}
+	}
+
+	return false
+}
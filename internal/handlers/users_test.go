@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+var validUserForm = url.Values{
+	"first_name":   {"Jane"},
+	"last_name":    {"Doe"},
+	"email":        {"jane@doe.com"},
+	"access_level": {"2"},
+}
+
+// Returns a copy of the valid user form with the given field changed
+func userFormWith(field, value string) url.Values {
+	form := url.Values{}
+	for key, values := range validUserForm {
+		form[key] = values
+	}
+	form.Set(field, value)
+
+	return form
+}
+
+var adminPostUserTests = []struct {
+	name                string
+	handler             func(*Repository, http.ResponseWriter, *http.Request)
+	id                  string
+	body                url.Values
+	expectedStatusCode  int
+	expectedRedirectURL string
+	expectedHTML        string
+}{
+	{
+		"Invites user",
+		(*Repository).AdminPostNewUser,
+		"",
+		validUserForm,
+		http.StatusSeeOther,
+		"/admin/users",
+		"",
+	},
+	{
+		"Invite user with email of another user",
+		(*Repository).AdminPostNewUser,
+		"",
+		userFormWith("email", "desk@here.com"),
+		http.StatusOK,
+		"",
+		"This email is already used by another user",
+	},
+	{
+		"Invite user with unknown role",
+		(*Repository).AdminPostNewUser,
+		"",
+		userFormWith("access_level", "7"),
+		http.StatusOK,
+		"",
+		"Choose one of the roles",
+	},
+	{
+		"Invite user with invalid email",
+		(*Repository).AdminPostNewUser,
+		"",
+		userFormWith("email", "jane"),
+		http.StatusOK,
+		"",
+		"Invalid email address",
+	},
+	{
+		"Updates user",
+		(*Repository).AdminPostShowUser,
+		"2",
+		validUserForm,
+		http.StatusSeeOther,
+		"/admin/users",
+		"",
+	},
+	{
+		"Update user with email of another user",
+		(*Repository).AdminPostShowUser,
+		"2",
+		userFormWith("email", "me@here.com"),
+		http.StatusOK,
+		"",
+		"This email is already used by another user",
+	},
+	{
+		"Update own role",
+		(*Repository).AdminPostShowUser,
+		"1",
+		userFormWith("access_level", "2"),
+		http.StatusOK,
+		"",
+		"You can&#39;t remove your own right to manage users",
+	},
+	{
+		"Update non-existent user",
+		(*Repository).AdminPostShowUser,
+		"9",
+		validUserForm,
+		http.StatusInternalServerError,
+		"",
+		"",
+	},
+	{
+		"Disable own user",
+		(*Repository).AdminDisableUser,
+		"1",
+		nil,
+		http.StatusSeeOther,
+		"/admin/users",
+		"",
+	},
+	{
+		"Delete own user",
+		(*Repository).AdminDeleteUser,
+		"1",
+		nil,
+		http.StatusSeeOther,
+		"/admin/users",
+		"",
+	},
+}
+
+func TestRepository_AdminPostUser(t *testing.T) {
+	for _, test := range adminPostUserTests {
+		var reqBody io.Reader
+
+		if test.body != nil {
+			reqBody = strings.NewReader(test.body.Encode())
+		}
+
+		// Create POST request and store context on it which includes the `X-Session` header
+		// in order to read to/from the `Session object`
+		req, err := http.NewRequest("POST", "/admin/users", reqBody)
+		if err != nil {
+			log.Println(err)
+		}
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", test.id)
+
+		// The owner is logged in
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, FirstName: "Admin", LastName: "User", AccessLevel: models.AccessOwner})
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		// This fakes all of the request/response lifecycle
+		// Stores the response we get from the request
+		responseRecorder := httptest.NewRecorder()
+
+		test.handler(Repo, responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf(
+				"Test %s returns wrong response status code: got %d, wanted %d",
+				test.name,
+				responseRecorder.Code,
+				test.expectedStatusCode,
+			)
+		}
+
+		if test.expectedRedirectURL != "" {
+			redirectURL, err := responseRecorder.Result().Location()
+			if err != nil {
+				log.Println(err)
+			}
+
+			if redirectURL.String() != test.expectedRedirectURL {
+				t.Errorf(
+					"Test %s redirects to wrong URL: got %s, wanted %s",
+					test.name,
+					redirectURL.String(),
+					test.expectedRedirectURL,
+				)
+			}
+		}
+
+		if test.expectedHTML != "" && !strings.Contains(responseRecorder.Body.String(), test.expectedHTML) {
+			t.Errorf("Test %s did not render %s", test.name, test.expectedHTML)
+		}
+	}
+}
+
+func TestRepository_AdminPostNewUser_TemporaryPassword(t *testing.T) {
+	req, err := http.NewRequest("POST", "/admin/users/new", strings.NewReader(validUserForm.Encode()))
+	if err != nil {
+		log.Println(err)
+	}
+
+	ctx := getRequestContext(req)
+	ctx = helpers.ContextWithUser(ctx, models.User{ID: 1, AccessLevel: models.AccessOwner})
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	responseRecorder := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.AdminPostNewUser)
+	handler.ServeHTTP(responseRecorder, req)
+
+	// The temporary password is shown once on the users page
+	password := session.GetString(ctx, "temporary_password")
+	if len(password) != 16 {
+		t.Fatalf("Wrong temporary password: %q", password)
+	}
+
+	req, err = http.NewRequest("GET", "/admin/users", nil)
+	if err != nil {
+		log.Println(err)
+	}
+	req = req.WithContext(ctx)
+
+	responseRecorder = httptest.NewRecorder()
+
+	handler = http.HandlerFunc(Repo.AdminUsers)
+	handler.ServeHTTP(responseRecorder, req)
+
+	if !strings.Contains(responseRecorder.Body.String(), password) {
+		t.Error("Users page doesn't show the temporary password")
+	}
+
+	if session.Exists(ctx, "temporary_password") {
+		t.Error("Temporary password is still stored in the session")
+	}
+}
+
+var adminPostChoosePasswordTests = []struct {
+	name                string
+	user                models.User
+	body                url.Values
+	expectedStatusCode  int
+	expectedHTML        string
+}{
+	{
+		"Chooses password",
+		models.User{ID: 5, PasswordResetRequired: true},
+		url.Values{"password": {"New password 1"}, "password_confirmation": {"New password 1"}},
+		http.StatusSeeOther,
+		"",
+	},
+	{
+		"Password too short",
+		models.User{ID: 5, PasswordResetRequired: true},
+		url.Values{"password": {"short"}, "password_confirmation": {"short"}},
+		http.StatusOK,
+		"The password must be at least 8 characters long",
+	},
+	{
+		"Password too weak",
+		models.User{ID: 5, PasswordResetRequired: true},
+		url.Values{"password": {"newpassword"}, "password_confirmation": {"newpassword"}},
+		http.StatusOK,
+		"Mix at least three of lowercase letters",
+	},
+	{
+		"Passwords don't match",
+		models.User{ID: 5, PasswordResetRequired: true},
+		url.Values{"password": {"New password 1"}, "password_confirmation": {"Other password 1"}},
+		http.StatusOK,
+		"Passwords don&#39;t match",
+	},
+	{
+		"No reset required",
+		models.User{ID: 1},
+		url.Values{"password": {"New password 1"}, "password_confirmation": {"New password 1"}},
+		http.StatusSeeOther,
+		"",
+	},
+}
+
+func TestRepository_AdminPostChoosePassword(t *testing.T) {
+	for _, test := range adminPostChoosePasswordTests {
+		req, err := http.NewRequest("POST", "/admin/choose-password", strings.NewReader(test.body.Encode()))
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		ctx = helpers.ContextWithUser(ctx, test.user)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminPostChoosePassword)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf("Test %s returns wrong response status code: got %d, wanted %d", test.name, responseRecorder.Code, test.expectedStatusCode)
+		}
+
+		if test.expectedHTML != "" && !strings.Contains(responseRecorder.Body.String(), test.expectedHTML) {
+			t.Errorf("Test %s did not render %s", test.name, test.expectedHTML)
+		}
+	}
+}
+
+func TestRepository_LoadUser_PasswordResetRequired(t *testing.T) {
+	for _, path := range []string{"/admin/dashboard", "/admin/choose-password"} {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		req = req.WithContext(ctx)
+		session.Put(ctx, "user_id", 5)
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := Repo.LoadUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		handler.ServeHTTP(responseRecorder, req)
+
+		// Only the page to choose a new password can be used
+		expectedStatusCode := http.StatusSeeOther
+		if path == choosePasswordPath {
+			expectedStatusCode = http.StatusOK
+		}
+
+		if responseRecorder.Code != expectedStatusCode {
+			t.Errorf("%s returns wrong response status code: got %d, wanted %d", path, responseRecorder.Code, expectedStatusCode)
+		}
+	}
+}
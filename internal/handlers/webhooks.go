@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/render"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/webhooks"
+	"github.com/go-chi/chi/v5"
+)
+
+// Number of deliveries shown in the delivery log of an endpoint
+const webhookDeliveryLogSize = 100
+
+// AdminWebhooks is the webhook endpoints page handler in the admin dashboard
+func (repo *Repository) AdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(nil)
+
+	repo.renderWebhooks(w, r, form)
+}
+
+// Handler to register a webhook endpoint with received form data
+func (repo *Repository) AdminPostWebhooks(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.RequiredFields("url")
+
+	url := strings.TrimSpace(r.Form.Get("url"))
+	if url != "" && !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		form.Errors.Add("url", "The URL must start with http:// or https://")
+	}
+
+	// Only known events can be subscribed to
+	var events []string
+	for _, event := range r.PostForm["events"] {
+		if !webhooks.IsEvent(event) {
+			form.Errors.Add("events", "Unknown event")
+			break
+		}
+
+		events = append(events, event)
+	}
+
+	if len(events) == 0 {
+		form.Errors.Add("events", "Pick at least one event")
+	}
+
+	// Rerender page with updated error information
+	if !form.IsValid() {
+		repo.renderWebhooks(w, r, form)
+		return
+	}
+
+	secret, err := helpers.GenerateToken()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	endpoint := models.WebhookEndpoint{
+		URL: url,
+		Description: strings.TrimSpace(r.Form.Get("description")),
+		Events: strings.Join(events, " "),
+		Secret: secret,
+		Active: true,
+	}
+
+	id, err := repo.DB.InsertWebhookEndpoint(endpoint)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionCreated, audit.EntityWebhook, id, nil, newAuditWebhook(endpoint))
+
+	repo.App.Session.Put(r.Context(), "success", "Webhook endpoint added. Use its secret to verify the signature of the payloads")
+	http.Redirect(w, r, fmt.Sprintf("/admin/webhooks/%d", id), http.StatusSeeOther)
+}
+
+// AdminWebhook is the page handler showing a webhook endpoint and its delivery log
+func (repo *Repository) AdminWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	endpoint, err := repo.DB.GetWebhookEndpointByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	deliveries, err := repo.DB.GetWebhookDeliveriesForEndpoint(id, webhookDeliveryLogSize)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["endpoint"] = endpoint
+	data["deliveries"] = deliveries
+
+	render.RenderTemplate(w, r, "admin-webhook.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// Handler to resume sending events to a webhook endpoint
+func (repo *Repository) AdminActivateWebhook(w http.ResponseWriter, r *http.Request) {
+	repo.updateActiveForWebhook(w, r, true)
+}
+
+// Handler to pause sending events to a webhook endpoint. Events are still queued and sent once it is activated again
+func (repo *Repository) AdminDeactivateWebhook(w http.ResponseWriter, r *http.Request) {
+	repo.updateActiveForWebhook(w, r, false)
+}
+
+// Handler to delete a webhook endpoint along with its delivery log
+func (repo *Repository) AdminDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	endpoint, err := repo.DB.GetWebhookEndpointByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.DeleteWebhookEndpoint(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, audit.ActionDeleted, audit.EntityWebhook, id, newAuditWebhook(endpoint), nil)
+
+	repo.App.Session.Put(r.Context(), "success", "Webhook endpoint deleted")
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+// Activates or deactivates the webhook endpoint with the id given in the URL
+func (repo *Repository) updateActiveForWebhook(w http.ResponseWriter, r *http.Request, active bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	endpoint, err := repo.DB.GetWebhookEndpointByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.UpdateActiveForWebhookEndpoint(id, active)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if endpoint.Active != active {
+		action := audit.ActionDisabled
+		if active {
+			action = audit.ActionEnabled
+		}
+
+		repo.audit(r, action, audit.EntityWebhook, id, map[string]bool{"active": endpoint.Active}, map[string]bool{"active": active})
+	}
+
+	if active {
+		repo.App.Session.Put(r.Context(), "success", "Webhook endpoint activated")
+	} else {
+		repo.App.Session.Put(r.Context(), "success", "Webhook endpoint deactivated")
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/webhooks/%d", id), http.StatusSeeOther)
+}
+
+// Renders the webhook endpoints page of the admin dashboard
+func (repo *Repository) renderWebhooks(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	endpoints, err := repo.DB.GetAllWebhookEndpoints()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// Check the events picked before the form was rerendered
+	checkedEvents := make(map[string]bool)
+	for _, event := range form.Values["events"] {
+		checkedEvents[event] = true
+	}
+
+	data := make(map[string]interface{})
+	data["endpoints"] = endpoints
+	data["events"] = webhooks.Events
+	data["checked_events"] = checkedEvents
+
+	render.RenderTemplate(w, r, "admin-webhooks.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// Queues a webhook event. The change which caused the event is already saved,
+// so a failure is only logged instead of failing the request
+func (repo *Repository) sendWebhook(event string, data interface{}) {
+	err := webhooks.Enqueue(repo.DB, event, data)
+	if err != nil {
+		repo.App.ErrorLog.Println("Can't queue webhook:", err)
+	}
+}
+
+// Queues a webhook event with the current state of a reservation as payload
+func (repo *Repository) sendReservationWebhook(event string, id int) {
+	reservation, err := repo.DB.GetReservationByID(id)
+	if err != nil {
+		repo.App.ErrorLog.Println("Can't get reservation for webhook:", err)
+		return
+	}
+
+	repo.sendWebhook(event, newApiAdminReservation(reservation))
+}
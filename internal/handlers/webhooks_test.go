@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var adminPostWebhooksTests = []struct {
+	name               string
+	body               url.Values
+	expectedStatusCode int
+	expectedLocation   string
+}{
+	{
+		"Adds endpoint",
+		url.Values{"url": {"https://example.com/hooks"}, "description": {"Channel manager"}, "events": {"reservation.created", "block.deleted"}},
+		http.StatusSeeOther,
+		"/admin/webhooks/3",
+	},
+	{"Without URL", url.Values{"url": {""}, "events": {"reservation.created"}}, http.StatusOK, ""},
+	{"Invalid URL", url.Values{"url": {"ftp://example.com"}, "events": {"reservation.created"}}, http.StatusOK, ""},
+	{"Without events", url.Values{"url": {"https://example.com/hooks"}}, http.StatusOK, ""},
+	{"Unknown event", url.Values{"url": {"https://example.com/hooks"}, "events": {"room.created"}}, http.StatusOK, ""},
+	{
+		"Insert failed",
+		url.Values{"url": {"https://example.com/hooks"}, "description": {"Invalid"}, "events": {"reservation.created"}},
+		http.StatusInternalServerError,
+		"",
+	},
+}
+
+func TestRepository_AdminPostWebhooks(t *testing.T) {
+	for _, test := range adminPostWebhooksTests {
+		req, err := http.NewRequest("POST", "/admin/webhooks", strings.NewReader(test.body.Encode()))
+		if err != nil {
+			log.Println(err)
+		}
+
+		ctx := getRequestContext(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		responseRecorder := httptest.NewRecorder()
+
+		handler := http.HandlerFunc(Repo.AdminPostWebhooks)
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expectedStatusCode {
+			t.Errorf(
+				"Test %s returns wrong response status code: got %d, wanted %d",
+				test.name,
+				responseRecorder.Code,
+				test.expectedStatusCode,
+			)
+		}
+
+		// A new endpoint is shown right away, so the admin can copy its secret
+		if test.expectedLocation != "" {
+			location, _ := responseRecorder.Result().Location()
+			if location.String() != test.expectedLocation {
+				t.Errorf("Test %s redirects to wrong location: got %s, wanted %s", test.name, location, test.expectedLocation)
+			}
+		}
+	}
+}
@@ -1,16 +1,40 @@
 package helpers
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
 )
 
 var app *config.AppConfig
 
+// Characters used in confirmation codes. Letters and digits which are easily confused (I, O, 0, 1) are left out
+const confirmationCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// Length of confirmation codes, which gives 32^10 (about 10^15) possible codes
+const confirmationCodeLength = 10
+
+// Length of temporary passwords, which gives 32^16 (about 10^24) possible passwords
+const temporaryPasswordLength = 16
+
+// Length of recovery codes, which gives 32^10 (about 10^15) possible codes
+const recoveryCodeLength = 10
+
+// Number of recovery codes users get when they set up two-factor authentication
+const RecoveryCodeCount = 10
+
 // Stores app config for this package
 func StoreAppConfig(appConfig *config.AppConfig) {
 	app = appConfig
@@ -48,7 +72,145 @@ func ParseDates(w http.ResponseWriter, sd string, ed string) (time.Time, time.Ti
 	return startDate, endDate, nil
 }
 
+// Parse the number of adults and children of a party. Parties without numbers are a single adult
+func ParseGuests(a string, c string) (int, int, error) {
+	adults, children := 1, 0
+
+	var err error
+
+	if a != "" {
+		adults, err = strconv.Atoi(a)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if c != "" {
+		children, err = strconv.Atoi(c)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if adults < 1 || children < 0 {
+		return 0, 0, errors.New("a party needs at least one adult")
+	}
+
+	return adults, children, nil
+}
+
 // Check if user is authenticated
 func IsAuthenticated(r *http.Request) bool {
 	return app.Session.Exists(r.Context(), "user_id")
-}
\ No newline at end of file
+}
+
+// Type of the keys under which values are stored in the request context,
+// so that they can't collide with the keys of other packages
+type contextKey string
+
+// Key under which the logged in user is stored in the request context
+const userContextKey contextKey = "user"
+
+// Key under which the API key of an admin API request is stored in the request context
+const apiKeyContextKey contextKey = "api_key"
+
+// Returns a copy of the context holding the logged in user
+func ContextWithUser(ctx context.Context, user models.User) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+// Returns the logged in user stored in the request context, if any
+func CurrentUser(r *http.Request) (models.User, bool) {
+	user, ok := r.Context().Value(userContextKey).(models.User)
+
+	return user, ok
+}
+
+// Returns a copy of the context holding the API key of an admin API request
+func ContextWithApiKey(ctx context.Context, key models.ApiKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey, key)
+}
+
+// Returns the API key of an admin API request stored in the request context, if any
+func CurrentApiKey(r *http.Request) (models.ApiKey, bool) {
+	key, ok := r.Context().Value(apiKeyContextKey).(models.ApiKey)
+
+	return key, ok
+}
+
+// Returns the IP address the request comes from. Forwarding headers are ignored,
+// since clients can set them to anything and get around the login throttling
+func ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return ip
+}
+
+// Generates a random, unguessable confirmation code for a reservation
+func GenerateConfirmationCode() (string, error) {
+	return generateCode(confirmationCodeLength)
+}
+
+// Generates a random password given to invited users, which they have to replace when they first log in
+func GenerateTemporaryPassword() (string, error) {
+	return generateCode(temporaryPasswordLength)
+}
+
+// Generates a random recovery code, which users enter instead of an authenticator code when they lose their phone.
+// The code is split in two halves to make it easier to copy, e.g. "ABCDE-23456"
+func GenerateRecoveryCode() (string, error) {
+	code, err := generateCode(recoveryCodeLength)
+	if err != nil {
+		return "", err
+	}
+
+	return code[:recoveryCodeLength / 2] + "-" + code[recoveryCodeLength / 2:], nil
+}
+
+// Removes the separator, spaces and lowercase letters a user may have typed in a recovery code,
+// so that it matches the stored hash
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// Generates a random code of the given length from the confirmation code alphabet
+func generateCode(length int) (string, error) {
+	randomBytes := make([]byte, length)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	// The alphabet has 32 characters, so every byte maps to a character without bias
+	code := make([]byte, length)
+	for i, b := range randomBytes {
+		code[i] = confirmationCodeAlphabet[int(b) % len(confirmationCodeAlphabet)]
+	}
+
+	return string(code), nil
+}
+
+// Generates a random secret token (256 bits, hex encoded) to be handed out once
+func GenerateToken() (string, error) {
+	randomBytes := make([]byte, 32)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(randomBytes), nil
+}
+
+// Hashes a secret token so that only the hash needs to be stored in the database
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(hash[:])
+}
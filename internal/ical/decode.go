@@ -0,0 +1,190 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Reads an iCalendar object and returns its events.
+// Events are reduced to whole days of the property, which is in the local time zone, and an event without an end lasts one day
+func Decode(r io.Reader) (Calendar, error) {
+	return DecodeInLocation(r, time.Local)
+}
+
+// Like `Decode`, but the property is in the given time zone. Times of events are converted to it
+// before they are reduced to whole days, so that an event starting at 01:00 UTC may start the day before
+func DecodeInLocation(r io.Reader, location *time.Location) (Calendar, error) {
+	var calendar Calendar
+
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return calendar, err
+	}
+
+	var event *Event
+
+	for number, line := range lines {
+		name, params, value, ok := splitContentLine(line)
+		if !ok {
+			return calendar, fmt.Errorf("line %d is not a valid content line: %q", number + 1, line)
+		}
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			event = &Event{}
+		case name == "END" && value == "VEVENT":
+			if event == nil {
+				return calendar, fmt.Errorf("line %d ends an event which was not started", number + 1)
+			}
+
+			if event.End.IsZero() {
+				event.End = event.Start.AddDate(0, 0, 1)
+			}
+
+			calendar.Events = append(calendar.Events, *event)
+			event = nil
+		case name == "X-WR-CALNAME" && event == nil:
+			calendar.Name = UnescapeText(value)
+		case name == "METHOD" && event == nil:
+			calendar.Method = value
+		case event == nil:
+			// Other properties of the calendar and its components (e.g. VTIMEZONE) are not needed
+		case name == "UID":
+			event.UID = UnescapeText(value)
+		case name == "SUMMARY":
+			event.Summary = UnescapeText(value)
+		case name == "DESCRIPTION":
+			event.Description = UnescapeText(value)
+		case name == "STATUS":
+			event.Status = strings.ToUpper(value)
+		case name == "DTSTAMP":
+			event.Stamp, _ = time.Parse(dateTimeLayout, value)
+		case name == "DTSTART" || name == "DTEND":
+			date, err := parseDate(value, params, location)
+			if err != nil {
+				return calendar, fmt.Errorf("line %d has an invalid %s (%s): %w", number + 1, name, params, err)
+			}
+
+			if name == "DTSTART" {
+				event.Start = date
+			} else {
+				event.End = date
+			}
+		}
+	}
+
+	if event != nil {
+		return calendar, fmt.Errorf("event %q is not terminated", event.UID)
+	}
+
+	return calendar, nil
+}
+
+// Reverts the escaping done by `EscapeText`
+func UnescapeText(value string) string {
+	replacer := strings.NewReplacer(
+		`\\`, `\`,
+		`\;`, ";",
+		`\,`, ",",
+		`\n`, "\n",
+		`\N`, "\n",
+	)
+
+	return replacer.Replace(value)
+}
+
+// Reads all content lines, joining folded lines back together
+func unfoldLines(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		// A line starting with a space or a tab continues the previous line
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines) - 1] += line[1:]
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// Splits a content line such as `DTSTART;VALUE=DATE:20500103` into its name, parameters and value.
+// Parameter values may be quoted and contain colons
+func splitContentLine(line string) (string, string, string, bool) {
+	inQuotes := false
+
+	for i, char := range line {
+		switch {
+		case char == '"':
+			inQuotes = !inQuotes
+		case char == ':' && !inQuotes:
+			nameAndParams := line[:i]
+			value := line[i + 1:]
+
+			name := nameAndParams
+			params := ""
+			if separator := strings.Index(nameAndParams, ";"); separator >= 0 {
+				name = nameAndParams[:separator]
+				params = nameAndParams[separator + 1:]
+			}
+
+			return strings.ToUpper(name), params, value, name != ""
+		}
+	}
+
+	return "", "", "", false
+}
+
+// Returns the value of a parameter of a content line, e.g. `Europe/Lisbon` for TZID in `TZID="Europe/Lisbon";VALUE=DATE-TIME`
+func paramValue(params, name string) string {
+	for _, param := range strings.Split(params, ";") {
+		separator := strings.Index(param, "=")
+		if separator >= 0 && strings.EqualFold(param[:separator], name) {
+			return strings.Trim(param[separator + 1:], `"`)
+		}
+	}
+
+	return ""
+}
+
+// Parses a DATE or DATE-TIME value, keeping only the date in the time zone of the property.
+// DATE-TIME values are in UTC if they end with Z, in the zone of their TZID parameter if it is known
+// and otherwise in the time zone of the property
+func parseDate(value, params string, location *time.Location) (time.Time, error) {
+	if len(value) < len(dateLayout) {
+		return time.Time{}, fmt.Errorf("%q is too short", value)
+	}
+
+	if len(value) == len(dateLayout) {
+		return time.Parse(dateLayout, value)
+	}
+
+	zone := location
+	if strings.HasSuffix(value, "Z") {
+		zone = time.UTC
+	} else if tzid := paramValue(params, "TZID"); tzid != "" {
+		// Calendars of some sites use time zone names of their own, such as "Eastern Standard Time"
+		if tzidZone, err := time.LoadLocation(tzid); err == nil {
+			zone = tzidZone
+		}
+	}
+
+	dateTime, err := time.ParseInLocation(strings.TrimSuffix(dateTimeLayout, "Z"), strings.TrimSuffix(value, "Z"), zone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	dateTime = dateTime.In(location)
+
+	return time.Date(dateTime.Year(), dateTime.Month(), dateTime.Day(), 0, 0, 0, 0, time.UTC), nil
+}
@@ -0,0 +1,182 @@
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Product identifier written in every calendar
+const productID = "-//bed-and-breakfast//Reservations//EN"
+
+// Content lines longer than this many octets are folded (RFC 5545, section 3.1)
+const maxLineLength = 75
+
+// Layouts of DATE and DATE-TIME values
+const (
+	dateLayout = "20060102"
+	dateTimeLayout = "20060102T150405Z"
+)
+
+// All-day event of a calendar. `End` is exclusive, e.g. the departure date of a reservation
+type Event struct {
+	UID string
+	Start time.Time
+	End time.Time
+	// Start and end are written with their time instead of as dates
+	Timed bool
+	Summary string
+	Description string
+	Location string
+	// Empty, TENTATIVE, CONFIRMED or CANCELLED
+	Status string
+	// Grows with every update of an event sent as invite
+	Sequence int
+	// Email addresses of the organizer and the attendee of an invite
+	Organizer string
+	OrganizerName string
+	Attendee string
+	AttendeeName string
+	// Time at which the event was generated
+	Stamp time.Time
+}
+
+// iCalendar object holding a list of events
+type Calendar struct {
+	Name string
+	// Defaults to PUBLISH
+	Method string
+	Events []Event
+}
+
+// Writes the calendar to `w` in iCalendar format
+func Encode(w io.Writer, calendar Calendar) error {
+	method := calendar.Method
+	if method == "" {
+		method = "PUBLISH"
+	}
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:" + productID,
+		"CALSCALE:GREGORIAN",
+		"METHOD:" + method,
+	}
+
+	if calendar.Name != "" {
+		lines = append(lines, "X-WR-CALNAME:" + EscapeText(calendar.Name))
+	}
+
+	for _, event := range calendar.Events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:" + EscapeText(event.UID),
+			"DTSTAMP:" + event.Stamp.UTC().Format(dateTimeLayout),
+		)
+
+		if event.Timed {
+			lines = append(lines,
+				"DTSTART:" + event.Start.UTC().Format(dateTimeLayout),
+				"DTEND:" + event.End.UTC().Format(dateTimeLayout),
+			)
+		} else {
+			lines = append(lines,
+				"DTSTART;VALUE=DATE:" + event.Start.Format(dateLayout),
+				"DTEND;VALUE=DATE:" + event.End.Format(dateLayout),
+			)
+		}
+
+		lines = append(lines, "SUMMARY:" + EscapeText(event.Summary))
+
+		if event.Description != "" {
+			lines = append(lines, "DESCRIPTION:" + EscapeText(event.Description))
+		}
+
+		if event.Location != "" {
+			lines = append(lines, "LOCATION:" + EscapeText(event.Location))
+		}
+
+		if event.Status != "" {
+			lines = append(lines, "STATUS:" + event.Status)
+		}
+
+		if event.Sequence > 0 {
+			lines = append(lines, fmt.Sprintf("SEQUENCE:%d", event.Sequence))
+		}
+
+		if event.Organizer != "" {
+			lines = append(lines, "ORGANIZER" + commonName(event.OrganizerName) + ":mailto:" + event.Organizer)
+		}
+
+		if event.Attendee != "" {
+			lines = append(lines, "ATTENDEE" + commonName(event.AttendeeName) + ";ROLE=REQ-PARTICIPANT:mailto:" + event.Attendee)
+		}
+
+		lines = append(lines,
+			"TRANSP:OPAQUE",
+			"END:VEVENT",
+		)
+	}
+
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		_, err := io.WriteString(w, FoldLine(line))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Escapes backslashes, semicolons, commas and newlines of a TEXT value
+func EscapeText(value string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	)
+
+	return replacer.Replace(value)
+}
+
+// Returns the CN parameter of a calendar user. Parameter values are quoted and can't contain quotes
+func commonName(name string) string {
+	if name == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(`;CN="%s"`, strings.ReplaceAll(name, `"`, ""))
+}
+
+// Splits a content line into lines of at most 75 octets, each continuation line starting with a space.
+// The returned string ends with CRLF. Multi-byte characters are never split
+func FoldLine(line string) string {
+	var folded strings.Builder
+
+	limit := maxLineLength
+	for len(line) > limit {
+		// Move the cut back to the start of a character
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		folded.WriteString(line[:cut])
+		folded.WriteString("\r\n ")
+		line = line[cut:]
+
+		// The leading space of a continuation line counts towards its length
+		limit = maxLineLength - 1
+	}
+
+	folded.WriteString(fmt.Sprintf("%s\r\n", line))
+
+	return folded.String()
+}
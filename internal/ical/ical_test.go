@@ -0,0 +1,305 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEscapeText(t *testing.T) {
+	values := map[string]string{
+		"Reservation": "Reservation",
+		"General's Quarters, room 1": `General's Quarters\, room 1`,
+		"a;b": `a\;b`,
+		`C:\path`: `C:\\path`,
+		"line 1\nline 2": `line 1\nline 2`,
+	}
+
+	for value, expected := range values {
+		if EscapeText(value) != expected {
+			t.Errorf("Wrong escaping of %q: got %q, wanted %q", value, EscapeText(value), expected)
+		}
+	}
+}
+
+func TestFoldLine(t *testing.T) {
+	short := "SUMMARY:Reservation"
+	if FoldLine(short) != short + "\r\n" {
+		t.Errorf("Short line should not be folded: got %q", FoldLine(short))
+	}
+
+	long := "DESCRIPTION:" + strings.Repeat("é", 100)
+	folded := FoldLine(long)
+
+	for _, line := range strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n") {
+		if len(line) > maxLineLength {
+			t.Errorf("Folded line is %d octets long: %q", len(line), line)
+		}
+
+		if !strings.HasPrefix(line, "DESCRIPTION:") && !strings.HasPrefix(line, " ") {
+			t.Errorf("Continuation line does not start with a space: %q", line)
+		}
+	}
+
+	// Unfolding must give back the original line
+	unfolded := strings.ReplaceAll(strings.TrimSuffix(folded, "\r\n"), "\r\n ", "")
+	if unfolded != long {
+		t.Errorf("Unfolded line does not match the original line: got %q", unfolded)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	calendar := Calendar{
+		Name: "General's Quarters",
+		Events: []Event{
+			{
+				UID: "room-restriction-1@bed-and-breakfast",
+				Start: time.Date(2050, time.January, 3, 0, 0, 0, 0, time.UTC),
+				End: time.Date(2050, time.January, 5, 0, 0, 0, 0, time.UTC),
+				Summary: "Reservation",
+				Stamp: time.Date(2049, time.December, 1, 10, 30, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	var output strings.Builder
+	err := Encode(&output, calendar)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLines := []string{
+		"BEGIN:VCALENDAR\r\n",
+		"METHOD:PUBLISH\r\n",
+		"X-WR-CALNAME:General's Quarters\r\n",
+		"BEGIN:VEVENT\r\n",
+		"UID:room-restriction-1@bed-and-breakfast\r\n",
+		"DTSTAMP:20491201T103000Z\r\n",
+		"DTSTART;VALUE=DATE:20500103\r\n",
+		"DTEND;VALUE=DATE:20500105\r\n",
+		"SUMMARY:Reservation\r\n",
+		"END:VEVENT\r\n",
+		"END:VCALENDAR\r\n",
+	}
+
+	for _, line := range expectedLines {
+		if !strings.Contains(output.String(), line) {
+			t.Errorf("Calendar is missing line %q:\n%s", line, output.String())
+		}
+	}
+
+	if strings.Contains(output.String(), "DESCRIPTION") {
+		t.Error("Calendar should not have a description for events without one")
+	}
+}
+
+func TestEncode_Invite(t *testing.T) {
+	calendar := Calendar{
+		Method: "REQUEST",
+		Events: []Event{
+			{
+				UID: "reservation-1@bed-and-breakfast",
+				Start: time.Date(2050, time.January, 3, 15, 0, 0, 0, time.UTC),
+				End: time.Date(2050, time.January, 5, 11, 0, 0, 0, time.UTC),
+				Timed: true,
+				Summary: "Stay in the General's Quarters",
+				Location: "1 Main Street, Springfield",
+				Sequence: 2,
+				Organizer: "me@here.com",
+				OrganizerName: `Fort "Smythe" B&B`,
+				Attendee: "john@smith.com",
+				AttendeeName: "John Smith",
+				Stamp: time.Date(2049, time.December, 1, 10, 30, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	var output strings.Builder
+	err := Encode(&output, calendar)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLines := []string{
+		"METHOD:REQUEST\r\n",
+		"DTSTART:20500103T150000Z\r\n",
+		"DTEND:20500105T110000Z\r\n",
+		"LOCATION:1 Main Street\\, Springfield\r\n",
+		"SEQUENCE:2\r\n",
+		"ORGANIZER;CN=\"Fort Smythe B&B\":mailto:me@here.com\r\n",
+		"ATTENDEE;CN=\"John Smith\";ROLE=REQ-PARTICIPANT:mailto:john@smith.com\r\n",
+	}
+
+	for _, line := range expectedLines {
+		if !strings.Contains(output.String(), line) {
+			t.Errorf("Calendar is missing line %q:\n%s", line, output.String())
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	feed := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//External//Bookings//EN",
+		"X-WR-CALNAME:Listing 123",
+		"BEGIN:VEVENT",
+		"UID:booking-1@external",
+		"DTSTART;VALUE=DATE:20500103",
+		"DTEND;VALUE=DATE:20500105",
+		"SUMMARY:Reserved\\, not available",
+		"DESCRIPTION:A long description which has been folded over",
+		"  two lines",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:booking-2@external",
+		"DTSTART;TZID=\"Europe/Lisbon: Portugal\":20500110T150000",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	calendar, err := Decode(strings.NewReader(feed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calendar.Name != "Listing 123" {
+		t.Errorf("Wrong calendar name: got %q", calendar.Name)
+	}
+
+	if len(calendar.Events) != 2 {
+		t.Fatalf("Wrong number of events: got %d, wanted 2", len(calendar.Events))
+	}
+
+	first := calendar.Events[0]
+	if first.UID != "booking-1@external" || first.Summary != "Reserved, not available" {
+		t.Errorf("Wrong first event: %+v", first)
+	}
+
+	if first.Description != "A long description which has been folded over two lines" {
+		t.Errorf("Folded description was not unfolded: got %q", first.Description)
+	}
+
+	if !first.Start.Equal(time.Date(2050, time.January, 3, 0, 0, 0, 0, time.UTC)) || !first.End.Equal(time.Date(2050, time.January, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Wrong dates of first event: %s - %s", first.Start, first.End)
+	}
+
+	// An event without an end lasts one day
+	second := calendar.Events[1]
+	if !second.Start.Equal(time.Date(2050, time.January, 10, 0, 0, 0, 0, time.UTC)) || !second.End.Equal(time.Date(2050, time.January, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Wrong dates of second event: %s - %s", second.Start, second.End)
+	}
+}
+
+func TestDecodeInLocation(t *testing.T) {
+	property, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Times near midnight fall on another day in the time zone of the property
+	tests := []struct {
+		name          string
+		start         string
+		end           string
+		expectedStart time.Time
+		expectedEnd   time.Time
+	}{
+		{
+			"UTC",
+			"DTSTART:20500110T030000Z",
+			"DTEND:20500112T030000Z",
+			time.Date(2050, time.January, 9, 0, 0, 0, 0, time.UTC),
+			time.Date(2050, time.January, 11, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"TZID",
+			"DTSTART;TZID=Europe/Lisbon:20500110T003000",
+			"DTEND;TZID=\"Europe/Lisbon\":20500112T003000",
+			time.Date(2050, time.January, 9, 0, 0, 0, 0, time.UTC),
+			time.Date(2050, time.January, 11, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"TZID of the property",
+			"DTSTART;TZID=America/New_York:20500110T233000",
+			"DTEND;TZID=America/New_York:20500112T233000",
+			time.Date(2050, time.January, 10, 0, 0, 0, 0, time.UTC),
+			time.Date(2050, time.January, 12, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"Floating time",
+			"DTSTART:20500110T233000",
+			"DTEND:20500112T233000",
+			time.Date(2050, time.January, 10, 0, 0, 0, 0, time.UTC),
+			time.Date(2050, time.January, 12, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, test := range tests {
+		feed := strings.Join([]string{"BEGIN:VCALENDAR", "BEGIN:VEVENT", "UID:1", test.start, test.end, "END:VEVENT", "END:VCALENDAR"}, "\r\n")
+
+		calendar, err := DecodeInLocation(strings.NewReader(feed), property)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		event := calendar.Events[0]
+		if !event.Start.Equal(test.expectedStart) || !event.End.Equal(test.expectedEnd) {
+			t.Errorf("%s: wrong dates %s - %s, wanted %s - %s", test.name, event.Start, event.End, test.expectedStart, test.expectedEnd)
+		}
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	feeds := map[string]string{
+		"Unterminated event": "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:1\r\n",
+		"Invalid date": "BEGIN:VEVENT\r\nDTSTART:2050\r\nEND:VEVENT\r\n",
+		"Line without value": "BEGIN:VEVENT\r\nUID\r\nEND:VEVENT\r\n",
+	}
+
+	for name, feed := range feeds {
+		_, err := Decode(strings.NewReader(feed))
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	calendar := Calendar{
+		Name: "Rooms; all of them",
+		Events: []Event{
+			{
+				UID: "room-restriction-7@bed-and-breakfast",
+				Start: time.Date(2050, time.March, 1, 0, 0, 0, 0, time.UTC),
+				End: time.Date(2050, time.March, 4, 0, 0, 0, 0, time.UTC),
+				Summary: "Reservation",
+				Description: strings.Repeat("Line with, commas\n", 10),
+				Status: "CONFIRMED",
+			},
+		},
+	}
+
+	var output strings.Builder
+	err := Encode(&output, calendar)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := Decode(strings.NewReader(output.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Name != calendar.Name || len(decoded.Events) != 1 {
+		t.Fatalf("Decoded calendar does not match: %+v", decoded)
+	}
+
+	event := decoded.Events[0]
+	original := calendar.Events[0]
+	if event.UID != original.UID || event.Description != original.Description || event.Status != original.Status ||
+		!event.Start.Equal(original.Start) || !event.End.Equal(original.End) {
+		t.Errorf("Decoded event does not match: got %+v, wanted %+v", event, original)
+	}
+}
@@ -0,0 +1,55 @@
+package loginguard
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+)
+
+// Store keeping the failed logins in the database, so that they survive restarts and are shared between servers
+type DatabaseStore struct {
+	DB repository.DatabaseRepository
+}
+
+// Creates a store on top of the given repository
+func NewDatabaseStore(db repository.DatabaseRepository) *DatabaseStore {
+	return &DatabaseStore{DB: db}
+}
+
+// Returns the failed logins of a key
+func (store *DatabaseStore) Get(key string) (models.LoginThrottle, error) {
+	throttle, err := store.DB.GetLoginThrottle(key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.LoginThrottle{Key: key}, nil
+	}
+
+	return throttle, err
+}
+
+// Counts a failed login of a key
+func (store *DatabaseStore) RecordFailure(key string, at time.Time, window time.Duration) (models.LoginThrottle, error) {
+	return store.DB.RecordLoginFailure(key, at, window)
+}
+
+// Locks a key until the given time
+func (store *DatabaseStore) Lock(key string, until time.Time) error {
+	return store.DB.LockLoginThrottle(key, until)
+}
+
+// Forgets the failed logins of a key
+func (store *DatabaseStore) Delete(key string) error {
+	return store.DB.DeleteLoginThrottle(key)
+}
+
+// Returns the keys which are locked at the given time
+func (store *DatabaseStore) GetLocked(now time.Time) ([]models.LoginThrottle, error) {
+	return store.DB.GetLockedLoginThrottles(now)
+}
+
+// Forgets the keys whose last failure is older than `window` and which aren't locked at the given time
+func (store *DatabaseStore) DeleteExpired(now time.Time, window time.Duration) (int, error) {
+	return store.DB.DeleteExpiredLoginThrottles(now, window)
+}
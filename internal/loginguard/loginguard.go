@@ -0,0 +1,247 @@
+// Package loginguard protects the login form against guessing passwords. It counts failed logins per account
+// and per IP address, makes every further attempt wait longer and locks an account or IP address for a while
+// once it has failed too often
+package loginguard
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+)
+
+// Stores of the failed logins
+const (
+	// Keeps the failed logins in memory, so they are lost on restart and not shared between servers
+	StoreMemory = "memory"
+	// Keeps the failed logins in the `login_throttles` table
+	StorePostgres = "postgres"
+)
+
+// Prefixes of the keys failed logins are counted under
+const (
+	KeyPrefixAccount = "account:"
+	KeyPrefixIP = "ip:"
+)
+
+// Keeps count of failed logins by key
+type Store interface {
+	// Returns the failed logins of a key, which has no failures if it isn't stored
+	Get(key string) (models.LoginThrottle, error)
+	// Counts a failed login of a key. Failures older than `window` are forgotten first
+	RecordFailure(key string, at time.Time, window time.Duration) (models.LoginThrottle, error)
+	// Locks a key until the given time
+	Lock(key string, until time.Time) error
+	// Forgets the failed logins of a key, which also unlocks it
+	Delete(key string) error
+	// Returns the keys which are locked at the given time
+	GetLocked(now time.Time) ([]models.LoginThrottle, error)
+	// Forgets the keys whose last failure is older than `window` and which aren't locked at the given time.
+	// Returns how many were forgotten
+	DeleteExpired(now time.Time, window time.Duration) (int, error)
+}
+
+// Limits of the guard
+type Config struct {
+	// Accounts are locked after this many failed logins
+	MaxAccountFailures int
+	// IP addresses are locked after this many failed logins, to any account
+	MaxIPFailures int
+	// How long accounts and IP addresses stay locked
+	LockoutDuration time.Duration
+	// Failed logins are forgotten after this long without another one
+	FailureWindow time.Duration
+	// Attempts have to wait once this many logins failed. The wait doubles with every further failure
+	DelayAfterFailures int
+	BaseDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// Limits used unless configured otherwise
+var DefaultConfig = Config{
+	MaxAccountFailures: 5,
+	MaxIPFailures: 20,
+	LockoutDuration: 15 * time.Minute,
+	FailureWindow: 15 * time.Minute,
+	DelayAfterFailures: 2,
+	BaseDelay: time.Second,
+	MaxDelay: 30 * time.Second,
+}
+
+// Outcome of checking whether a login may be attempted
+type Decision struct {
+	Allowed bool
+	// Whether the account or IP address is locked, rather than having to wait a little
+	Locked bool
+	// How long until the next attempt is allowed
+	RetryAfter time.Duration
+}
+
+// Tracks failed logins and decides whether a login may be attempted
+type Guard struct {
+	store Store
+	config Config
+	// Replaced in tests
+	now func() time.Time
+}
+
+// Creates a guard keeping the failed logins in the given store
+func New(store Store, config Config) *Guard {
+	return &Guard{
+		store: store,
+		config: config,
+		now: time.Now,
+	}
+}
+
+// Creates the store of the configured backend
+func NewStore(backend string, db repository.DatabaseRepository) (Store, error) {
+	switch backend {
+	case StoreMemory:
+		return NewMemoryStore(), nil
+	case StorePostgres:
+		return NewDatabaseStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown login store %q", backend)
+	}
+}
+
+// Returns the key failed logins to an account are counted under
+func AccountKey(email string) string {
+	return KeyPrefixAccount + strings.ToLower(strings.TrimSpace(email))
+}
+
+// Returns the key failed logins from an IP address are counted under
+func IPKey(ip string) string {
+	return KeyPrefixIP + ip
+}
+
+// Decides whether a login to the account with the given email may be attempted from the given IP address
+func (guard *Guard) Check(email, ip string) (Decision, error) {
+	now := guard.now()
+	decision := Decision{Allowed: true}
+
+	for _, key := range []string{AccountKey(email), IPKey(ip)} {
+		throttle, err := guard.store.Get(key)
+		if err != nil {
+			return decision, err
+		}
+
+		var retryAfter time.Duration
+		locked := now.Before(throttle.LockedUntil)
+
+		if locked {
+			retryAfter = throttle.LockedUntil.Sub(now)
+		} else if now.Sub(throttle.LastFailureAt) < guard.config.FailureWindow {
+			retryAfter = throttle.LastFailureAt.Add(guard.delay(throttle.Failures)).Sub(now)
+		}
+
+		if retryAfter > 0 {
+			decision.Allowed = false
+			decision.Locked = decision.Locked || locked
+
+			if retryAfter > decision.RetryAfter {
+				decision.RetryAfter = retryAfter
+			}
+		}
+	}
+
+	return decision, nil
+}
+
+// Counts a failed login to the account with the given email from the given IP address.
+// Returns whether the account has just been locked, so that its user can be told
+func (guard *Guard) Failure(email, ip string) (bool, error) {
+	now := guard.now()
+	accountLocked := false
+
+	limits := map[string]int{
+		AccountKey(email): guard.config.MaxAccountFailures,
+		IPKey(ip): guard.config.MaxIPFailures,
+	}
+
+	for key, maxFailures := range limits {
+		throttle, err := guard.store.RecordFailure(key, now, guard.config.FailureWindow)
+		if err != nil {
+			return false, err
+		}
+
+		if throttle.Failures < maxFailures || now.Before(throttle.LockedUntil) {
+			continue
+		}
+
+		err = guard.store.Lock(key, now.Add(guard.config.LockoutDuration))
+		if err != nil {
+			return false, err
+		}
+
+		if strings.HasPrefix(key, KeyPrefixAccount) {
+			accountLocked = true
+		}
+	}
+
+	return accountLocked, nil
+}
+
+// Forgets the failed logins to an account after its user logged in. Failures from the IP address are kept,
+// so that logging in to one account doesn't allow guessing the passwords of others
+func (guard *Guard) Success(email string) error {
+	return guard.store.Delete(AccountKey(email))
+}
+
+// Unlocks an account or IP address by the key its failed logins are counted under
+func (guard *Guard) Unlock(key string) error {
+	return guard.store.Delete(key)
+}
+
+// Returns the accounts and IP addresses which are locked
+func (guard *Guard) Locked() ([]models.LoginThrottle, error) {
+	return guard.store.GetLocked(guard.now())
+}
+
+// Returns how long accounts and IP addresses stay locked
+func (guard *Guard) LockoutDuration() time.Duration {
+	return guard.config.LockoutDuration
+}
+
+// Returns how long to wait after the given number of failed logins
+func (guard *Guard) delay(failures int) time.Duration {
+	if failures < guard.config.DelayAfterFailures {
+		return 0
+	}
+
+	delay := guard.config.BaseDelay
+	for i := guard.config.DelayAfterFailures; i < failures && delay < guard.config.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > guard.config.MaxDelay {
+		delay = guard.config.MaxDelay
+	}
+
+	return delay
+}
+
+// Forgets the failed logins which no longer count towards a delay or a lockout
+func (guard *Guard) Cleanup() (int, error) {
+	return guard.store.DeleteExpired(guard.now(), guard.config.FailureWindow)
+}
+
+// Forgets the expired failed logins once every interval, so that the store doesn't grow with every
+// address that ever failed to log in. Runs in the background
+func (guard *Guard) StartCleanup(interval time.Duration, errorLog *log.Logger) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			_, err := guard.Cleanup()
+			if err != nil {
+				errorLog.Println("Can't delete expired failed logins:", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,191 @@
+package loginguard
+
+import (
+	"testing"
+	"time"
+)
+
+// Creates a guard with a memory store and a clock which only moves when the test moves it
+func newTestGuard(now *time.Time) *Guard {
+	guard := New(NewMemoryStore(), DefaultConfig)
+	guard.now = func() time.Time { return *now }
+
+	return guard
+}
+
+func TestGuard_ProgressiveDelay(t *testing.T) {
+	now := time.Date(2050, time.January, 1, 12, 0, 0, 0, time.UTC)
+	guard := newTestGuard(&now)
+
+	// The first failure doesn't slow down the next attempt
+	guard.Failure("me@here.com", "192.0.2.1")
+
+	decision, _ := guard.Check("me@here.com", "192.0.2.1")
+	if !decision.Allowed {
+		t.Fatal("Attempt after one failure should be allowed")
+	}
+
+	// Further failures double the wait
+	for failures, expected := range map[int]time.Duration{2: time.Second, 3: 2 * time.Second, 4: 4 * time.Second} {
+		guard := newTestGuard(&now)
+		for i := 0; i < failures; i++ {
+			guard.Failure("me@here.com", "192.0.2.1")
+		}
+
+		decision, _ := guard.Check("me@here.com", "192.0.2.1")
+		if decision.Allowed || decision.Locked || decision.RetryAfter != expected {
+			t.Errorf("Wrong decision after %d failures: %+v, wanted to wait %s", failures, decision, expected)
+		}
+	}
+}
+
+func TestGuard_AccountLockout(t *testing.T) {
+	now := time.Date(2050, time.January, 1, 12, 0, 0, 0, time.UTC)
+	guard := newTestGuard(&now)
+
+	for i := 1; i <= DefaultConfig.MaxAccountFailures; i++ {
+		locked, err := guard.Failure("Me@Here.com", "192.0.2.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Only the last failure locks the account
+		if locked != (i == DefaultConfig.MaxAccountFailures) {
+			t.Errorf("Failure %d returned locked %t", i, locked)
+		}
+
+		now = now.Add(time.Minute)
+	}
+
+	// Emails are compared regardless of case, and the account is locked from every IP address
+	decision, _ := guard.Check("me@here.com", "198.51.100.7")
+	if decision.Allowed || !decision.Locked {
+		t.Fatalf("Locked account should not be allowed: %+v", decision)
+	}
+
+	locked, _ := guard.Locked()
+	if len(locked) != 1 || locked[0].Key != AccountKey("me@here.com") {
+		t.Errorf("Wrong locked keys: %+v", locked)
+	}
+
+	// The lock ends after the lockout duration
+	now = now.Add(DefaultConfig.LockoutDuration)
+
+	decision, _ = guard.Check("me@here.com", "198.51.100.7")
+	if !decision.Allowed {
+		t.Errorf("Account should be unlocked after the lockout: %+v", decision)
+	}
+}
+
+func TestGuard_IPLockout(t *testing.T) {
+	now := time.Date(2050, time.January, 1, 12, 0, 0, 0, time.UTC)
+	guard := newTestGuard(&now)
+
+	// Guessing a few passwords of many accounts locks the IP address
+	for i := 0; i < DefaultConfig.MaxIPFailures; i++ {
+		guard.Failure(string(rune('a' + i)) + "@here.com", "192.0.2.1")
+	}
+
+	decision, _ := guard.Check("me@here.com", "192.0.2.1")
+	if decision.Allowed || !decision.Locked {
+		t.Errorf("Locked IP address should not be allowed: %+v", decision)
+	}
+
+	decision, _ = guard.Check("me@here.com", "198.51.100.7")
+	if !decision.Allowed {
+		t.Errorf("Other IP addresses should be allowed: %+v", decision)
+	}
+
+	// Unlocking the IP address allows it again
+	guard.Unlock(IPKey("192.0.2.1"))
+
+	decision, _ = guard.Check("me@here.com", "192.0.2.1")
+	if !decision.Allowed {
+		t.Errorf("Unlocked IP address should be allowed: %+v", decision)
+	}
+}
+
+func TestGuard_Success(t *testing.T) {
+	now := time.Date(2050, time.January, 1, 12, 0, 0, 0, time.UTC)
+	guard := newTestGuard(&now)
+
+	for i := 0; i < 3; i++ {
+		guard.Failure("me@here.com", "192.0.2.1")
+	}
+
+	guard.Success("me@here.com")
+
+	// The failures of the account are forgotten, but not those of the IP address
+	account, _ := guard.store.Get(AccountKey("me@here.com"))
+	ip, _ := guard.store.Get(IPKey("192.0.2.1"))
+
+	if account.Failures != 0 || ip.Failures != 3 {
+		t.Errorf("Wrong failures after login: account %d, IP address %d", account.Failures, ip.Failures)
+	}
+}
+
+func TestMemoryStore_FailureWindow(t *testing.T) {
+	now := time.Date(2050, time.January, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+
+	store.RecordFailure("key", now, time.Minute)
+	throttle, _ := store.RecordFailure("key", now.Add(time.Minute), time.Minute)
+	if throttle.Failures != 2 {
+		t.Errorf("Failures within the window should add up: got %d", throttle.Failures)
+	}
+
+	throttle, _ = store.RecordFailure("key", now.Add(3 * time.Minute), time.Minute)
+	if throttle.Failures != 1 {
+		t.Errorf("Failures outside the window should be forgotten: got %d", throttle.Failures)
+	}
+}
+
+func TestGuard_Cleanup(t *testing.T) {
+	now := time.Date(2050, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	config := DefaultConfig
+	config.LockoutDuration = time.Hour
+
+	guard := New(NewMemoryStore(), config)
+	guard.now = func() time.Time { return now }
+
+	guard.Failure("old@here.com", "192.0.2.1")
+
+	for i := 0; i < config.MaxAccountFailures; i++ {
+		guard.Failure("locked@here.com", "192.0.2.2")
+	}
+
+	// Move past the failure window, but not past the lockout
+	now = now.Add(config.FailureWindow + time.Second)
+	guard.Failure("recent@here.com", "192.0.2.3")
+
+	deleted, err := guard.Cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first account and the first two IP addresses are forgotten
+	if deleted != 3 {
+		t.Errorf("Cleanup forgot %d keys, wanted 3", deleted)
+	}
+
+	locked, _ := guard.Locked()
+	if len(locked) != 1 || locked[0].Key != AccountKey("locked@here.com") {
+		t.Errorf("Locked keys should be kept until their lockout ends: %+v", locked)
+	}
+
+	throttle, _ := guard.store.Get(AccountKey("recent@here.com"))
+	if throttle.Failures != 1 {
+		t.Errorf("Recent failures should be kept: got %d", throttle.Failures)
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	if _, err := NewStore(StoreMemory, nil); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := NewStore("redis", nil); err == nil {
+		t.Error("Unknown store should return an error")
+	}
+}
@@ -0,0 +1,113 @@
+package loginguard
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+// Store keeping the failed logins in memory
+type MemoryStore struct {
+	mutex sync.Mutex
+	throttles map[string]models.LoginThrottle
+}
+
+// Creates an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		throttles: make(map[string]models.LoginThrottle),
+	}
+}
+
+// Returns the failed logins of a key
+func (store *MemoryStore) Get(key string) (models.LoginThrottle, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	throttle, ok := store.throttles[key]
+	if !ok {
+		return models.LoginThrottle{Key: key}, nil
+	}
+
+	return throttle, nil
+}
+
+// Counts a failed login of a key
+func (store *MemoryStore) RecordFailure(key string, at time.Time, window time.Duration) (models.LoginThrottle, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	throttle, ok := store.throttles[key]
+	if !ok || at.Sub(throttle.LastFailureAt) > window {
+		throttle.Key = key
+		throttle.Failures = 0
+	}
+
+	throttle.Failures++
+	throttle.LastFailureAt = at
+
+	store.throttles[key] = throttle
+
+	return throttle, nil
+}
+
+// Locks a key until the given time
+func (store *MemoryStore) Lock(key string, until time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	throttle := store.throttles[key]
+	throttle.Key = key
+	throttle.LockedUntil = until
+
+	store.throttles[key] = throttle
+
+	return nil
+}
+
+// Forgets the failed logins of a key
+func (store *MemoryStore) Delete(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.throttles, key)
+
+	return nil
+}
+
+// Returns the keys which are locked at the given time, sorted by key
+func (store *MemoryStore) GetLocked(now time.Time) ([]models.LoginThrottle, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var locked []models.LoginThrottle
+	for _, throttle := range store.throttles {
+		if now.Before(throttle.LockedUntil) {
+			locked = append(locked, throttle)
+		}
+	}
+
+	sort.Slice(locked, func(i, j int) bool {
+		return locked[i].Key < locked[j].Key
+	})
+
+	return locked, nil
+}
+
+// Forgets the keys whose last failure is older than `window` and which aren't locked at the given time
+func (store *MemoryStore) DeleteExpired(now time.Time, window time.Duration) (int, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	deleted := 0
+	for key, throttle := range store.throttles {
+		if now.Sub(throttle.LastFailureAt) > window && !now.Before(throttle.LockedUntil) {
+			delete(store.throttles, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+// Writes every email as an .eml file to a directory, e.g. to look at emails during development
+type FileMailer struct {
+	config Config
+}
+
+// Creates a mailer writing emails to the configured directory, creating the directory if needed
+func NewFileMailer(config Config) (*FileMailer, error) {
+	if config.Dir == "" {
+		return nil, errors.New("mail directory is required")
+	}
+
+	err := os.MkdirAll(config.Dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileMailer{
+		config: config,
+	}, nil
+}
+
+// Writes an email to a new file named after the time it was sent
+func (mailer *FileMailer) Send(mailData models.MailData) error {
+	email, err := mailer.config.message(mailData)
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(mailer.config.Dir, fmt.Sprintf("%s-*.eml", time.Now().Format("20060102-150405")))
+	if err != nil {
+		return err
+	}
+
+	_, err = file.WriteString(email.GetMessage())
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// Nothing to release
+func (mailer *FileMailer) Close() error {
+	return nil
+}
@@ -0,0 +1,101 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+// Mail backends
+const (
+	BackendSMTP = "smtp"
+	// Writes every email as an .eml file to a directory instead of sending it
+	BackendFile = "file"
+	// Keeps the emails in memory, so tests can assert on them
+	BackendMemory = "memory"
+)
+
+// Encryption of the connection to the SMTP server
+const (
+	EncryptionNone = "none"
+	// Upgrades a plain connection with the STARTTLS command, usually on port 587
+	EncryptionSTARTTLS = "starttls"
+	// Connects with TLS right away, usually on port 465
+	EncryptionTLS = "tls"
+)
+
+// Sends emails
+type Mailer interface {
+	Send(mailData models.MailData) error
+	// Releases the resources of the mailer, such as an open connection
+	Close() error
+}
+
+// Settings of the mail backends
+type Config struct {
+	Backend string
+	Host string
+	Port int
+	Username string
+	Password string
+	Encryption string
+	// Sender of emails which don't set one
+	From string
+	// Added as Reply-To header to every email, if set
+	ReplyTo string
+	// Directory the file backend writes to
+	Dir string
+}
+
+// Creates the mailer of the configured backend
+func New(config Config) (Mailer, error) {
+	switch config.Backend {
+	case BackendSMTP:
+		return NewSMTPMailer(config)
+	case BackendFile:
+		return NewFileMailer(config)
+	case BackendMemory:
+		return NewMemoryMailer(config), nil
+	default:
+		return nil, fmt.Errorf("unknown mail backend %q", config.Backend)
+	}
+}
+
+// Composes an email, filling in the configured sender and reply-to address
+func (config Config) message(mailData models.MailData) (*mail.Email, error) {
+	from := mailData.From
+	if from == "" {
+		from = config.From
+	}
+
+	if from == "" {
+		return nil, errors.New("email has no sender")
+	}
+
+	email := mail.NewMSG()
+	email.SetFrom(from).AddTo(mailData.To).SetSubject(mailData.Subject)
+
+	if config.ReplyTo != "" {
+		email.SetReplyTo(config.ReplyTo)
+	}
+
+	// Clients show the last alternative they support, so the HTML body comes after the plain-text one
+	if mailData.TextContent != "" {
+		email.SetBody(mail.TextPlain, mailData.TextContent)
+		email.AddAlternative(mail.TextHTML, mailData.Content)
+	} else {
+		email.SetBody(mail.TextHTML, mailData.Content)
+	}
+
+	for _, attachment := range mailData.Attachments {
+		email.Attach(&mail.File{
+			Name: attachment.Name,
+			MimeType: attachment.ContentType,
+			Data: attachment.Data,
+		})
+	}
+
+	return email, email.GetError()
+}
@@ -0,0 +1,115 @@
+package mailer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		config Config
+		valid bool
+	}{
+		{"SMTP", Config{Backend: BackendSMTP, Host: "localhost", Port: 1025}, true},
+		{"SMTP with STARTTLS", Config{Backend: BackendSMTP, Host: "smtp.example.com", Port: 587, Encryption: EncryptionSTARTTLS}, true},
+		{"SMTP with TLS", Config{Backend: BackendSMTP, Host: "smtp.example.com", Port: 465, Encryption: EncryptionTLS}, true},
+		{"SMTP with unknown encryption", Config{Backend: BackendSMTP, Host: "localhost", Port: 25, Encryption: "ssl3"}, false},
+		{"SMTP without host", Config{Backend: BackendSMTP, Port: 25}, false},
+		{"File", Config{Backend: BackendFile, Dir: t.TempDir()}, true},
+		{"File without directory", Config{Backend: BackendFile}, false},
+		{"Memory", Config{Backend: BackendMemory}, true},
+		{"Unknown backend", Config{Backend: "carrier-pigeon"}, false},
+	}
+
+	for _, test := range tests {
+		_, err := New(test.config)
+		if test.valid && err != nil {
+			t.Errorf("Test %s returned error: %s", test.name, err)
+		}
+
+		if !test.valid && err == nil {
+			t.Errorf("Test %s should return an error", test.name)
+		}
+	}
+}
+
+func TestFileMailer_Send(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+
+	mailer, err := NewFileMailer(Config{Dir: dir, From: "bookings@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mailer.Send(models.MailData{
+		To: "john@smith.com",
+		Subject: "Reservation confirmation",
+		Content: "<p>Hello John</p>",
+		TextContent: "Hello John",
+		Attachments: []models.Attachment{
+			{ Name: "reservation.ics", ContentType: "text/calendar; charset=utf-8; method=REQUEST", Data: []byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n") },
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 {
+		t.Fatalf("Wrong number of files: got %d, wanted 1", len(files))
+	}
+
+	data, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"From: <bookings@example.com>", "Subject: Reservation confirmation", "multipart/mixed", "multipart/alternative", "<p>Hello John</p>", "Hello John", "text/calendar; charset=utf-8; method=REQUEST", `filename="reservation.ics"`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("File is missing %q:\n%s", expected, data)
+		}
+	}
+}
+
+func TestMemoryMailer_Send(t *testing.T) {
+	mailer := NewMemoryMailer(Config{From: "bookings@example.com"})
+
+	err := mailer.Send(models.MailData{To: "john@smith.com", Subject: "Hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mailer.Send(models.MailData{To: "jane@smith.com", From: "owner@example.com", Subject: "Hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("Wrong number of messages: got %d, wanted 2", len(messages))
+	}
+
+	if messages[0].From != "bookings@example.com" || messages[1].From != "owner@example.com" {
+		t.Errorf("Wrong senders: %+v", messages)
+	}
+
+	// Invalid emails fail like they would with the other backends
+	err = mailer.Send(models.MailData{To: "john at smith.com", Subject: "Hello"})
+	if err == nil {
+		t.Error("Sending to an invalid address should fail")
+	}
+
+	mailer.Reset()
+	if len(mailer.Messages()) != 0 {
+		t.Error("Messages were not reset")
+	}
+}
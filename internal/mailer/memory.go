@@ -0,0 +1,65 @@
+package mailer
+
+import (
+	"sync"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+// Keeps sent emails in memory instead of sending them
+type MemoryMailer struct {
+	config Config
+
+	mu sync.Mutex
+	messages []models.MailData
+}
+
+// Creates a mailer keeping emails in memory
+func NewMemoryMailer(config Config) *MemoryMailer {
+	return &MemoryMailer{
+		config: config,
+	}
+}
+
+// Stores an email with the configured sender filled in
+func (mailer *MemoryMailer) Send(mailData models.MailData) error {
+	_, err := mailer.config.message(mailData)
+	if err != nil {
+		return err
+	}
+
+	if mailData.From == "" {
+		mailData.From = mailer.config.From
+	}
+
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+
+	mailer.messages = append(mailer.messages, mailData)
+
+	return nil
+}
+
+// Returns the emails sent so far
+func (mailer *MemoryMailer) Messages() []models.MailData {
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+
+	messages := make([]models.MailData, len(mailer.messages))
+	copy(messages, mailer.messages)
+
+	return messages
+}
+
+// Forgets the emails sent so far
+func (mailer *MemoryMailer) Reset() {
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+
+	mailer.messages = nil
+}
+
+// Nothing to release
+func (mailer *MemoryMailer) Close() error {
+	return nil
+}
@@ -0,0 +1,133 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+// An open connection is closed after being idle this long, since servers drop idle connections anyway
+const idleTimeout = 30 * time.Second
+
+// Sends emails through an SMTP server. The connection is kept open and reused for bursts of emails
+type SMTPMailer struct {
+	config Config
+	server *mail.SMTPServer
+
+	// Guards the connection, which can only send one email at a time
+	mu sync.Mutex
+	client *mail.SMTPClient
+	idleTimer *time.Timer
+}
+
+// Creates a mailer sending emails through the configured SMTP server
+func NewSMTPMailer(config Config) (*SMTPMailer, error) {
+	if config.Host == "" || config.Port == 0 {
+		return nil, errors.New("SMTP host and port are required")
+	}
+
+	server := mail.NewSMTPClient()
+	server.Host = config.Host
+	server.Port = config.Port
+	server.Username = config.Username
+	server.Password = config.Password
+	server.KeepAlive = true
+	server.ConnectTimeout = 10 * time.Second
+	server.SendTimeout = 10 * time.Second
+
+	switch config.Encryption {
+	case "", EncryptionNone:
+		server.Encryption = mail.EncryptionNone
+	case EncryptionSTARTTLS:
+		server.Encryption = mail.EncryptionSTARTTLS
+	case EncryptionTLS:
+		server.Encryption = mail.EncryptionSSLTLS
+	default:
+		return nil, fmt.Errorf("unknown SMTP encryption %q", config.Encryption)
+	}
+
+	return &SMTPMailer{
+		config: config,
+		server: server,
+	}, nil
+}
+
+// Sends an email, connecting to the server if there is no open connection
+func (mailer *SMTPMailer) Send(mailData models.MailData) error {
+	email, err := mailer.config.message(mailData)
+	if err != nil {
+		return err
+	}
+
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+
+	reused := mailer.client != nil
+
+	err = mailer.send(email)
+
+	// The server may have closed a reused connection, so try once more with a new one
+	if err != nil && reused {
+		err = mailer.send(email)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Close the connection if no other email is sent soon
+	if mailer.idleTimer != nil {
+		mailer.idleTimer.Stop()
+	}
+
+	mailer.idleTimer = time.AfterFunc(idleTimeout, func() {
+		mailer.Close()
+	})
+
+	return nil
+}
+
+// Closes the open connection, if any
+func (mailer *SMTPMailer) Close() error {
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+
+	return mailer.disconnect()
+}
+
+// Sends an email over the open connection or a new one. The connection is closed if sending fails
+func (mailer *SMTPMailer) send(email *mail.Email) error {
+	if mailer.client == nil {
+		client, err := mailer.server.Connect()
+		if err != nil {
+			return err
+		}
+
+		mailer.client = client
+	}
+
+	err := email.Send(mailer.client)
+	if err != nil {
+		mailer.disconnect()
+		return err
+	}
+
+	return nil
+}
+
+// Quits and closes the open connection, if any. Must be called with the lock held
+func (mailer *SMTPMailer) disconnect() error {
+	if mailer.client == nil {
+		return nil
+	}
+
+	client := mailer.client
+	mailer.client = nil
+
+	client.Quit()
+	return client.Close()
+}
@@ -0,0 +1,202 @@
+package mailer
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+// Minimal SMTP server keeping the messages it receives
+type fakeSMTPServer struct {
+	listener net.Listener
+	// Close the connection after every message, like a server dropping idle connections
+	dropAfterMessage bool
+
+	mu sync.Mutex
+	connections int
+	messages []string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &fakeSMTPServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			server.mu.Lock()
+			server.connections++
+			server.mu.Unlock()
+
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+func (server *fakeSMTPServer) port() int {
+	return server.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (server *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case command == "DATA":
+			reply("354 Go ahead")
+
+			var message strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+
+				if line == ".\r\n" {
+					break
+				}
+
+				message.WriteString(line)
+			}
+
+			server.mu.Lock()
+			server.messages = append(server.messages, message.String())
+			server.mu.Unlock()
+
+			reply("250 OK")
+
+			if server.dropAfterMessage {
+				return
+			}
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (server *fakeSMTPServer) stats() (int, []string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.connections, server.messages
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	server := newFakeSMTPServer(t)
+
+	mailer, err := NewSMTPMailer(Config{
+		Host: "127.0.0.1",
+		Port: server.port(),
+		From: "bookings@example.com",
+		ReplyTo: "owner@example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer mailer.Close()
+
+	for _, to := range []string{"john@smith.com", "jane@smith.com", "joe@smith.com"} {
+		err := mailer.Send(models.MailData{To: to, Subject: "Reservation confirmation", Content: "<p>Hello</p>"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	connections, messages := server.stats()
+
+	// A burst of emails is sent over a single connection
+	if connections != 1 {
+		t.Errorf("Wrong number of connections: got %d, wanted 1", connections)
+	}
+
+	if len(messages) != 3 {
+		t.Fatalf("Wrong number of messages: got %d, wanted 3", len(messages))
+	}
+
+	for _, header := range []string{"From: <bookings@example.com>", "Reply-To: <owner@example.com>", "To: <john@smith.com>", "Subject: Reservation confirmation"} {
+		if !strings.Contains(messages[0], header) {
+			t.Errorf("Message is missing header %q:\n%s", header, messages[0])
+		}
+	}
+}
+
+func TestSMTPMailer_Send_Reconnects(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	server.dropAfterMessage = true
+
+	mailer, err := NewSMTPMailer(Config{Host: "127.0.0.1", Port: server.port(), From: "bookings@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer mailer.Close()
+
+	// The second email finds the connection closed by the server and is sent over a new one
+	for i := 0; i < 2; i++ {
+		err := mailer.Send(models.MailData{To: "john@smith.com", Subject: "Hello", Content: "Hello"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	connections, messages := server.stats()
+	if connections != 2 || len(messages) != 2 {
+		t.Errorf("Wrong connections or messages: got %d connections and %d messages, wanted 2 and 2", connections, len(messages))
+	}
+}
+
+func TestSMTPMailer_Send_ConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	mailer, err := NewSMTPMailer(Config{Host: "127.0.0.1", Port: port, From: "bookings@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mailer.Send(models.MailData{To: "john@smith.com", Subject: "Hello", Content: "Hello"})
+	if err == nil {
+		t.Error("Sending without a server should fail")
+	}
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"math"
+	"strings"
 	"time"
 )
 
@@ -12,18 +15,169 @@ type User struct {
 	Email string
 	Password string
 	AccessLevel int
+	// Disabled users can't log in, but are kept so that their past actions can still be traced
+	Disabled bool
+	// The user has to choose a new password before using the admin dashboard again
+	PasswordResetRequired bool
+	// Increased whenever the password changes, which logs out the sessions started with an older version
+	SessionVersion int
+	// Secret shared with the authenticator app of the user, only used while two-factor authentication is enabled
+	TwoFactorSecret string
+	TwoFactorEnabled bool
+	// Step of the last accepted authenticator code, so that a code can't be used twice
+	TwoFactorLastStep int64
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
+// Roles of admin users, stored in `users.access_level`
+const (
+	// Sees reservations and the calendar, e.g. to prepare rooms, but can't change anything
+	AccessHousekeeping = 1
+	// Handles reservations, blocks and guest emails
+	AccessFrontDesk = 2
+	// Can do everything, including managing rooms and integrations
+	AccessOwner = 3
+)
+
+// All roles, in the order they are offered when editing a user
+var AccessLevels = []int{AccessHousekeeping, AccessFrontDesk, AccessOwner}
+
+// Permissions checked before admin users can use a page or an action
+const (
+	PermissionViewReservations = "reservations:view"
+	PermissionEditReservations = "reservations:edit"
+	PermissionDeleteReservations = "reservations:delete"
+	PermissionManageRooms = "rooms:manage"
+	PermissionManageEmails = "emails:manage"
+	// Calendar feeds, calendar imports, API keys and webhooks
+	PermissionManageIntegrations = "integrations:manage"
+	PermissionManageUsers = "users:manage"
+	// Who changed reservations, blocks and users, and what they changed
+	PermissionViewAuditLog = "audit:view"
+)
+
+// Permissions granted to each role
+var rolePermissions = map[int][]string{
+	AccessHousekeeping: {
+		PermissionViewReservations,
+	},
+	AccessFrontDesk: {
+		PermissionViewReservations,
+		PermissionEditReservations,
+		PermissionManageEmails,
+	},
+	AccessOwner: {
+		PermissionViewReservations,
+		PermissionEditReservations,
+		PermissionDeleteReservations,
+		PermissionManageRooms,
+		PermissionManageEmails,
+		PermissionManageIntegrations,
+		PermissionManageUsers,
+		PermissionViewAuditLog,
+	},
+}
+
+// Names of the roles, as shown in the admin dashboard
+var roleNames = map[int]string{
+	AccessHousekeeping: "Housekeeping",
+	AccessFrontDesk: "Front Desk",
+	AccessOwner: "Owner",
+}
+
+// Returns the name of the role of the user
+func (user User) RoleName() string {
+	name, ok := roleNames[user.AccessLevel]
+	if !ok {
+		return "Unknown"
+	}
+
+	return name
+}
+
+// Reports whether the role of the user grants the given permission.
+// Unknown access levels grant nothing
+func (user User) Can(permission string) bool {
+	for _, granted := range rolePermissions[user.AccessLevel] {
+		if granted == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Room database model
 type Room struct {
 	ID int
 	RoomName string
+	Slug string
+	Description string
+	// Maximum number of guests, children included
+	Capacity int
+	BedConfiguration string
+	Amenities string
+	Image string
+	Active bool
+	SortOrder int
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
+// Returns the amenities of the room, which are stored one per line
+func (room Room) AmenityList() []string {
+	var amenities []string
+
+	for _, amenity := range strings.Split(room.Amenities, "\n") {
+		amenity = strings.TrimSpace(amenity)
+		if amenity != "" {
+			amenities = append(amenities, amenity)
+		}
+	}
+
+	return amenities
+}
+
+// Restriction ids seeded in the `restrictions` table
+const (
+	RestrictionReservation = 1
+	RestrictionOwnerBlock = 2
+	RestrictionExternalBooking = 3
+)
+
+// Reasons the owner blocks a room for
+const (
+	BlockReasonMaintenance = "maintenance"
+	BlockReasonPersonalUse = "personal_use"
+	BlockReasonDeepClean = "deep_clean"
+)
+
+// Reasons of owner blocks, in the order they are offered in the block form
+var BlockReasons = []string{
+	BlockReasonMaintenance,
+	BlockReasonPersonalUse,
+	BlockReasonDeepClean,
+}
+
+// Names of the block reasons, as shown in the admin dashboard
+var blockReasonNames = map[string]string{
+	BlockReasonMaintenance: "Maintenance",
+	BlockReasonPersonalUse: "Personal use",
+	BlockReasonDeepClean: "Deep clean",
+}
+
+// Returns the name of a block reason. Blocks without a reason, e.g. the days ticked in the
+// reservations calendar, are shown as blocked
+func BlockReasonName(reason string) string {
+	name, ok := blockReasonNames[reason]
+	if !ok {
+		return "Blocked"
+	}
+
+	return name
+}
+
 // Restriction database model
 type Restriction struct {
 	ID int
@@ -42,12 +196,63 @@ type Reservation struct {
 	StartDate time.Time
 	EndDate time.Time
 	RoomID int
+	Adults int
+	Children int
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Processed bool
+	TotalPrice int
+	ConfirmationCode string
+	Cancelled bool
+	// Guest doesn't want to receive the scheduled reminder and follow-up emails
+	EmailOptOut bool
+	// Booking the reservation was made in together with other rooms, 0 if it was booked on its own
+	BookingID int
+	// Price breakdown the guest was quoted when booking, without nights for reservations made before it was stored
+	Quote Quote
 	Room Room
 }
 
+// Returns the number of guests staying, children included
+func (reservation Reservation) Guests() int {
+	return reservation.Adults + reservation.Children
+}
+
+// Returns the party size in words, e.g. "2 adults, 1 child"
+func (reservation Reservation) PartySize() string {
+	partySize := pluralize(reservation.Adults, "adult", "adults")
+	if reservation.Children > 0 {
+		partySize += ", " + pluralize(reservation.Children, "child", "children")
+	}
+
+	return partySize
+}
+
+// Booking database model
+// A guest booking several rooms at once gets a single booking, holding one reservation per room.
+// Every reservation keeps its own confirmation code, so that each room can still be changed or cancelled
+type Booking struct {
+	ID int
+	ConfirmationCode string
+	FirstName string
+	LastName string
+	Email string
+	Phone string
+	// Sum of the prices of the reservations which aren't cancelled, in cents
+	TotalPrice int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Reservations []Reservation
+}
+
+func pluralize(count int, singular string, plural string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, singular)
+	}
+
+	return fmt.Sprintf("%d %s", count, plural)
+}
+
 // Room restriction database model
 type RoomRestriction struct {
 	ID int
@@ -56,6 +261,11 @@ type RoomRestriction struct {
 	RoomID int
 	ReservationID int
 	RestrictionID int
+	SourceID int
+	ExternalUID string
+	// One of the block reasons for owner blocks, empty if no reason was given
+	Reason string
+	Note string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Room Room
@@ -63,11 +273,377 @@ type RoomRestriction struct {
 	Restriction Restriction
 }
 
+// Returns the name of the reason of an owner block
+func (restriction RoomRestriction) ReasonName() string {
+	return BlockReasonName(restriction.Reason)
+}
+
+// Returns the number of nights a restriction lasts. The room is free again on the end date
+func (restriction RoomRestriction) Nights() int {
+	// Rounded, since a day isn't 24 hours long when the clocks change
+	return int(math.Round(restriction.EndDate.Sub(restriction.StartDate).Hours() / 24))
+}
+
+// Room rate database model
+// All amounts are stored in cents
+type RoomRate struct {
+	ID int
+	RoomID int
+	NightlyRate int
+	WeekendSurcharge int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Seasonal rate database model
+// Overrides the base nightly rate for every night between StartDate and EndDate (inclusive)
+type SeasonalRate struct {
+	ID int
+	RoomID int
+	Name string
+	StartDate time.Time
+	EndDate time.Time
+	NightlyRate int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Length-of-stay discount database model
+type StayDiscount struct {
+	ID int
+	RoomID int
+	MinNights int
+	PercentOff int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Holds all the pricing rules of a room
+type RatePlan struct {
+	RoomRate RoomRate
+	SeasonalRates []SeasonalRate
+	StayDiscounts []StayDiscount
+}
+
+// Stay rule database model
+// Limits the stays of a room arriving between StartDate and EndDate (inclusive), or leaving in between for ClosedToDeparture.
+// Zero values don't limit anything, e.g. a MaxNights of 0 allows stays of any length
+type StayRule struct {
+	ID int
+	RoomID int
+	Room Room
+	Name string
+	StartDate time.Time
+	EndDate time.Time
+	MinNights int
+	MaxNights int
+	ClosedToArrival bool
+	ClosedToDeparture bool
+	ArrivalDays int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Reports whether the given date is between the start and end date of the rule
+func (rule StayRule) Covers(date time.Time) bool {
+	return !date.Before(rule.StartDate) && !date.After(rule.EndDate)
+}
+
+// Reports whether guests can arrive on the given weekday. ArrivalDays holds one bit per weekday,
+// Sunday being the lowest one, and allows arriving on any day when no bit is set
+func (rule StayRule) AllowsArrivalOn(day time.Weekday) bool {
+	return rule.ArrivalDays == 0 || rule.ArrivalDays & (1 << uint(day)) != 0
+}
+
+// Returns the weekdays guests can arrive on, or nil if they can arrive on any day
+func (rule StayRule) ArrivalWeekdays() []time.Weekday {
+	if rule.ArrivalDays == 0 {
+		return nil
+	}
+
+	var days []time.Weekday
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if rule.AllowsArrivalOn(day) {
+			days = append(days, day)
+		}
+	}
+
+	return days
+}
+
+// Price of a single night of a stay
+type NightlyPrice struct {
+	Date time.Time
+	Label string
+	Rate int
+	WeekendSurcharge int
+	Amount int
+}
+
+// Price breakdown of a stay
+type Quote struct {
+	Nights []NightlyPrice
+	Subtotal int
+	DiscountPercent int
+	Discount int
+	Total int
+}
+
 // Email message model
 type MailData struct {
 	To string
+	// The configured sender is used if empty
 	From string
 	Subject string
+	// HTML body
 	Content string
+	// Plain-text alternative of the HTML body
+	TextContent string
+	// Name of the email template the content was rendered from
 	Template string
-}
\ No newline at end of file
+	Attachments []Attachment
+}
+
+// File attached to an email
+type Attachment struct {
+	Name string
+	// MIME type, including parameters such as the charset
+	ContentType string
+	Data []byte
+}
+
+// Statuses of an email in the outbox
+const (
+	EmailPending = "pending"
+	EmailSent = "sent"
+	// The email could not be sent after all attempts and is kept until it is resent or cancelled
+	EmailDead = "dead"
+	EmailCancelled = "cancelled"
+)
+
+// Email stored in the outbox until it has been sent
+type OutboxEmail struct {
+	ID int
+	MailData
+	Status string
+	Attempts int
+	NextAttemptAt time.Time
+	LastAttemptAt time.Time
+	LastError string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Token giving access to the iCalendar feeds. Only a hash of the token is stored
+type CalendarFeedToken struct {
+	ID int
+	Name string
+	TokenHash string
+	Revoked bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Single-use link letting a user choose a new password. Only a hash of the token is stored
+type PasswordResetToken struct {
+	ID int
+	UserID int
+	TokenHash string
+	ExpiresAt time.Time
+	// Zero until the token has been used
+	UsedAt time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Reports whether the token can still be used to choose a new password
+func (token PasswordResetToken) IsUsable(now time.Time) bool {
+	return token.UsedAt.IsZero() && now.Before(token.ExpiresAt)
+}
+
+// Failed logins counted under a key, e.g. of an account or an IP address
+type LoginThrottle struct {
+	Key string
+	// Failed logins since the failures were last forgotten
+	Failures int
+	LastFailureAt time.Time
+	// Zero unless the key has been locked
+	LockedUntil time.Time
+}
+
+// Reasons why a login failed
+const (
+	LoginFailureInvalidCredentials = "invalid_credentials"
+	LoginFailureDisabled = "disabled"
+	// The account or IP address was locked or had to wait after failed logins
+	LoginFailureThrottled = "throttled"
+	LoginFailureTwoFactor = "two_factor"
+	// Wrong current password entered to change the password
+	LoginFailureCurrentPassword = "current_password"
+)
+
+// Record of a login, whether it succeeded or not
+type LoginEvent struct {
+	ID int
+	Email string
+	// Zero if no user has the email
+	UserID int
+	IPAddress string
+	UserAgent string
+	Success bool
+	// One of the LoginFailure reasons, empty if the login succeeded
+	Reason string
+	CreatedAt time.Time
+}
+
+// Change of a reservation, block or user made in the admin dashboard or through the admin API
+type AuditEntry struct {
+	ID int
+	// Zero unless a logged in user made the change
+	UserID int
+	// Name of the user, empty if the user has been deleted
+	UserName string
+	// Zero unless the change was made through the admin API
+	ApiKeyID int
+	Action string
+	EntityType string
+	// Zero if the id of the entity isn't known, e.g. for blocks added in the reservations calendar
+	EntityID int
+	// Fields of the entity which changed
+	Changes []AuditChange
+	IPAddress string
+	CreatedAt time.Time
+}
+
+// Value of a field before and after a change, empty if the entity didn't exist before or after it
+type AuditChange struct {
+	Field string `json:"field"`
+	Before string `json:"before"`
+	After string `json:"after"`
+}
+
+// Filter of the audit log. Zero values match every entry
+type AuditFilter struct {
+	EntityType string
+	EntityID int
+	UserID int
+	// Entries made from the start of `From` until the end of `To`
+	From time.Time
+	To time.Time
+	Limit int
+}
+
+// Session of a visitor kept by the session manager in the database
+type Session struct {
+	Token string
+	// Session values, encoded by the session manager
+	Data []byte
+	Expiry time.Time
+	// Zero while no user is logged in
+	UserID int
+	// Where the user logged in from
+	IPAddress string
+	UserAgent string
+	// When the session got its current token, which is renewed on login
+	CreatedAt time.Time
+	// When the session was last saved
+	UpdatedAt time.Time
+}
+
+// Calendar of an external booking site whose events block the dates of a room
+type CalendarImportSource struct {
+	ID int
+	RoomID int
+	Name string
+	URL string
+	LastSyncedAt time.Time
+	LastSyncStatus string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Room Room
+}
+
+// Key giving scripts access to the admin JSON API. Only a hash of the key is stored
+type ApiKey struct {
+	ID int
+	Name string
+	KeyHash string
+	// Space separated, e.g. "reservations:read calendar:write"
+	Scopes string
+	// Requests allowed per minute
+	RateLimit int
+	LastUsedAt time.Time
+	Revoked bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Returns the scopes granted to the key
+func (key ApiKey) ScopeList() []string {
+	return strings.Fields(key.Scopes)
+}
+
+// Reports whether the key has been granted the given scope
+func (key ApiKey) HasScope(scope string) bool {
+	for _, granted := range key.ScopeList() {
+		if granted == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// URL which is notified about reservation and block events
+type WebhookEndpoint struct {
+	ID int
+	URL string
+	Description string
+	// Space separated, e.g. "reservation.created reservation.deleted"
+	Events string
+	// Used to sign the payloads sent to the endpoint
+	Secret string
+	Active bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Returns the events the endpoint subscribed to
+func (endpoint WebhookEndpoint) EventList() []string {
+	return strings.Fields(endpoint.Events)
+}
+
+// Reports whether the endpoint subscribed to the given event
+func (endpoint WebhookEndpoint) HasEvent(event string) bool {
+	for _, subscribed := range endpoint.EventList() {
+		if subscribed == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Statuses of a webhook delivery
+const (
+	WebhookDeliveryPending = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed = "failed"
+)
+
+// Event queued to be sent to a webhook endpoint
+type WebhookDelivery struct {
+	ID int
+	EndpointID int
+	Event string
+	Payload string
+	Status string
+	Attempts int
+	NextAttemptAt time.Time
+	LastAttemptAt time.Time
+	ResponseStatus int
+	LastError string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Endpoint WebhookEndpoint
+}
@@ -14,4 +14,6 @@ type TemplateData struct {
 	Error string
 	Form *forms.Form
 	IsAuthenticated bool
+	// Admin user who is logged in, if any
+	User User
 }
\ No newline at end of file
@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+)
+
+// An email is moved to the dead-letter state after this many failed attempts
+const MaxAttempts = 6
+
+// Delay before the first retry, which doubles with every failed attempt
+const firstRetryDelay = time.Minute
+
+// Longest delay between two attempts
+const maxRetryDelay = 2 * time.Hour
+
+// Time a worker has to send a claimed email before other workers may claim it again
+const claimLease = 5 * time.Minute
+
+// Number of emails claimed by a worker at once
+const batchSize = 10
+
+// Sends the emails stored in the outbox with a pool of workers, retrying failed ones with exponential backoff
+type Worker struct {
+	DB repository.DatabaseRepository
+	App *config.AppConfig
+	// Sends a single email
+	Send func(models.MailData) error
+}
+
+// Creates a new outbox worker
+func NewWorker(db repository.DatabaseRepository, app *config.AppConfig, send func(models.MailData) error) *Worker {
+	return &Worker{
+		DB: db,
+		App: app,
+		Send: send,
+	}
+}
+
+// Runs the given number of workers in the background. Each worker sends due emails until there are none left
+// and then checks the outbox again after the interval
+func (worker *Worker) Start(workers int, interval time.Duration) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for {
+				if worker.SendDue() < batchSize {
+					time.Sleep(interval)
+				}
+			}
+		}()
+	}
+}
+
+// Claims a batch of due emails and sends them. Returns the number of emails claimed
+func (worker *Worker) SendDue() int {
+	emails, err := worker.DB.ClaimDueOutboxEmails(time.Now(), claimLease, batchSize)
+	if err != nil {
+		worker.App.ErrorLog.Println("Can't get emails from the outbox:", err)
+		return 0
+	}
+
+	for _, email := range emails {
+		worker.SendEmail(email)
+	}
+
+	return len(emails)
+}
+
+// Sends an email and stores the outcome. A failed email is retried later until it has failed
+// `MaxAttempts` times, after which it is kept as dead until it is resent or cancelled from the admin dashboard
+func (worker *Worker) SendEmail(email models.OutboxEmail) models.OutboxEmail {
+	now := time.Now()
+
+	email.Attempts++
+	email.LastAttemptAt = now
+
+	err := worker.Send(email.MailData)
+
+	switch {
+	case err == nil:
+		email.Status = models.EmailSent
+		email.LastError = ""
+	case email.Attempts >= MaxAttempts:
+		email.Status = models.EmailDead
+		email.LastError = err.Error()
+		worker.App.ErrorLog.Printf("Giving up on email %d to %s: %s", email.ID, email.To, err)
+	default:
+		email.NextAttemptAt = now.Add(Backoff(email.Attempts))
+		email.LastError = err.Error()
+	}
+
+	err = worker.DB.UpdateOutboxEmail(email)
+	if err != nil {
+		worker.App.ErrorLog.Println("Can't update email in the outbox:", err)
+	}
+
+	return email
+}
+
+// Delay before the next attempt after the given number of failed attempts: 1m, 2m, 4m, ... up to 2h
+func Backoff(attempts int) time.Duration {
+	delay := firstRetryDelay
+
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+
+	return delay
+}
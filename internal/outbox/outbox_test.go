@@ -0,0 +1,132 @@
+package outbox
+
+import (
+	"errors"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/mailer"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+)
+
+// Repository keeping the outbox in memory.
+// Calling any other method of `repository.DatabaseRepository` panics
+type fakeRepository struct {
+	repository.DatabaseRepository
+	due []models.OutboxEmail
+	updated []models.OutboxEmail
+}
+
+func (repo *fakeRepository) ClaimDueOutboxEmails(now time.Time, lease time.Duration, limit int) ([]models.OutboxEmail, error) {
+	emails := repo.due
+	repo.due = nil
+
+	return emails, nil
+}
+
+func (repo *fakeRepository) UpdateOutboxEmail(email models.OutboxEmail) error {
+	repo.updated = append(repo.updated, email)
+	return nil
+}
+
+var testApp = &config.AppConfig{
+	InfoLog: log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime),
+	ErrorLog: log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile),
+}
+
+func TestWorker_SendEmail(t *testing.T) {
+	var sendErr error
+	var sent []models.MailData
+
+	worker := NewWorker(&fakeRepository{}, testApp, func(mailData models.MailData) error {
+		sent = append(sent, mailData)
+		return sendErr
+	})
+
+	email := models.OutboxEmail{
+		ID: 1,
+		MailData: models.MailData{To: "john@smith.com", From: "me@here.com", Subject: "Hello"},
+		Status: models.EmailPending,
+	}
+
+	result := worker.SendEmail(email)
+	if result.Status != models.EmailSent || result.Attempts != 1 || result.LastAttemptAt.IsZero() {
+		t.Errorf("Wrong email after success: %+v", result)
+	}
+
+	if len(sent) != 1 || sent[0].To != "john@smith.com" {
+		t.Errorf("Wrong emails sent: %+v", sent)
+	}
+
+	// Failed attempts are retried later
+	sendErr = errors.New("connection refused")
+
+	before := time.Now()
+	result = worker.SendEmail(email)
+	if result.Status != models.EmailPending || result.LastError != "connection refused" {
+		t.Errorf("Wrong email after failure: %+v", result)
+	}
+
+	if result.NextAttemptAt.Before(before.Add(firstRetryDelay)) {
+		t.Errorf("Retry was not delayed: %v", result.NextAttemptAt)
+	}
+
+	// The last attempt moves the email to the dead-letter state
+	email.Attempts = MaxAttempts - 1
+	result = worker.SendEmail(email)
+	if result.Status != models.EmailDead || result.Attempts != MaxAttempts {
+		t.Errorf("Wrong email after last attempt: %+v", result)
+	}
+}
+
+func TestWorker_SendDue(t *testing.T) {
+	repo := &fakeRepository{
+		due: []models.OutboxEmail{
+			{ID: 1, MailData: models.MailData{To: "john@smith.com", Subject: "Reservation confirmation"}, Status: models.EmailPending},
+			{ID: 2, MailData: models.MailData{To: "me@here.com", Subject: "Reservation confirmation"}, Status: models.EmailPending},
+		},
+	}
+
+	memoryMailer := mailer.NewMemoryMailer(mailer.Config{From: "bookings@example.com"})
+	worker := NewWorker(repo, testApp, memoryMailer.Send)
+
+	if claimed := worker.SendDue(); claimed != 2 {
+		t.Errorf("Wrong number of emails claimed: got %d, wanted 2", claimed)
+	}
+
+	messages := memoryMailer.Messages()
+	if len(messages) != 2 || messages[0].To != "john@smith.com" || messages[1].To != "me@here.com" {
+		t.Errorf("Wrong emails sent: %+v", messages)
+	}
+
+	if len(repo.updated) != 2 || repo.updated[0].Status != models.EmailSent || repo.updated[1].Status != models.EmailSent {
+		t.Errorf("Wrong emails stored: %+v", repo.updated)
+	}
+
+	if claimed := worker.SendDue(); claimed != 0 {
+		t.Errorf("Wrong number of emails claimed from an empty outbox: got %d, wanted 0", claimed)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{7, 64 * time.Minute},
+		{8, 2 * time.Hour},
+	}
+
+	for _, test := range tests {
+		if delay := Backoff(test.attempts); delay != test.expected {
+			t.Errorf("Backoff(%d): got %v, wanted %v", test.attempts, delay, test.expected)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package pricing
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+// Label of nights charged at the base nightly rate
+const baseRateLabel = "Standard rate"
+
+// Calculates the price of a stay from the arrival date (`startDate`) to the departure date (`endDate`).
+// Every night is charged at the base nightly rate of the room, unless a seasonal rate covers that night.
+// Friday and Saturday nights get the weekend surcharge on top of it and the best length-of-stay
+// discount the stay qualifies for is taken off the subtotal
+func Calculate(plan models.RatePlan, startDate, endDate time.Time) models.Quote {
+	var quote models.Quote
+
+	for night := startDate; night.Before(endDate); night = night.AddDate(0, 0, 1) {
+		nightlyPrice := models.NightlyPrice{
+			Date: night,
+			Label: baseRateLabel,
+			Rate: plan.RoomRate.NightlyRate,
+		}
+
+		// Seasonal rates override the base nightly rate
+		if seasonalRate, ok := findSeasonalRate(plan.SeasonalRates, night); ok {
+			nightlyPrice.Label = seasonalRate.Name
+			nightlyPrice.Rate = seasonalRate.NightlyRate
+		}
+
+		if IsWeekendNight(night) {
+			nightlyPrice.WeekendSurcharge = plan.RoomRate.WeekendSurcharge
+		}
+
+		nightlyPrice.Amount = nightlyPrice.Rate + nightlyPrice.WeekendSurcharge
+
+		quote.Nights = append(quote.Nights, nightlyPrice)
+		quote.Subtotal += nightlyPrice.Amount
+	}
+
+	quote.DiscountPercent = findDiscountPercent(plan.StayDiscounts, len(quote.Nights))
+	quote.Discount = quote.Subtotal * quote.DiscountPercent / 100
+	quote.Total = quote.Subtotal - quote.Discount
+
+	return quote
+}
+
+// Returns true if the night starting at the given date is a Friday or Saturday night
+func IsWeekendNight(night time.Time) bool {
+	return night.Weekday() == time.Friday || night.Weekday() == time.Saturday
+}
+
+// Formats an amount in cents, e.g. 12050 becomes $120.50
+func FormatAmount(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+
+	return fmt.Sprintf("%s$%d.%02d", sign, cents / 100, cents % 100)
+}
+
+// Returns the first seasonal rate which covers the given night
+func findSeasonalRate(seasonalRates []models.SeasonalRate, night time.Time) (models.SeasonalRate, bool) {
+	for _, seasonalRate := range seasonalRates {
+		if !night.Before(seasonalRate.StartDate) && !night.After(seasonalRate.EndDate) {
+			return seasonalRate, true
+		}
+	}
+
+	return models.SeasonalRate{}, false
+}
+
+// Returns the percentage of the best discount available for the given number of nights
+func findDiscountPercent(stayDiscounts []models.StayDiscount, nights int) int {
+	percentOff := 0
+
+	for _, discount := range stayDiscounts {
+		if nights >= discount.MinNights && discount.PercentOff > percentOff {
+			percentOff = discount.PercentOff
+		}
+	}
+
+	return percentOff
+}
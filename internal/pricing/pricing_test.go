@@ -0,0 +1,132 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+var testRatePlan = models.RatePlan{
+	RoomRate: models.RoomRate{
+		NightlyRate: 10000,
+		WeekendSurcharge: 2000,
+	},
+	SeasonalRates: []models.SeasonalRate{
+		{
+			Name: "Christmas",
+			StartDate: time.Date(2050, time.December, 24, 0, 0, 0, 0, time.UTC),
+			EndDate: time.Date(2050, time.December, 26, 0, 0, 0, 0, time.UTC),
+			NightlyRate: 20000,
+		},
+	},
+	StayDiscounts: []models.StayDiscount{
+		{MinNights: 7, PercentOff: 10},
+		{MinNights: 14, PercentOff: 20},
+	},
+}
+
+var calculateTests = []struct {
+	name             string
+	startDate        time.Time
+	endDate          time.Time
+	expectedNights   int
+	expectedSubtotal int
+	expectedDiscount int
+	expectedTotal    int
+}{
+	{
+		// 2050-01-03 is a Monday
+		"Weekday nights",
+		time.Date(2050, time.January, 3, 0, 0, 0, 0, time.UTC),
+		time.Date(2050, time.January, 5, 0, 0, 0, 0, time.UTC),
+		2,
+		20000,
+		0,
+		20000,
+	},
+	{
+		// Friday and Saturday nights get the weekend surcharge
+		"Weekend nights",
+		time.Date(2050, time.January, 7, 0, 0, 0, 0, time.UTC),
+		time.Date(2050, time.January, 10, 0, 0, 0, 0, time.UTC),
+		3,
+		34000,
+		0,
+		34000,
+	},
+	{
+		// 24 to 26 December are charged at the seasonal rate, 2050-12-24 is a Saturday
+		"Seasonal rate",
+		time.Date(2050, time.December, 23, 0, 0, 0, 0, time.UTC),
+		time.Date(2050, time.December, 27, 0, 0, 0, 0, time.UTC),
+		4,
+		74000,
+		0,
+		74000,
+	},
+	{
+		"Length-of-stay discount",
+		time.Date(2050, time.January, 3, 0, 0, 0, 0, time.UTC),
+		time.Date(2050, time.January, 10, 0, 0, 0, 0, time.UTC),
+		7,
+		74000,
+		7400,
+		66600,
+	},
+	{
+		"Best length-of-stay discount is applied",
+		time.Date(2050, time.January, 3, 0, 0, 0, 0, time.UTC),
+		time.Date(2050, time.January, 17, 0, 0, 0, 0, time.UTC),
+		14,
+		148000,
+		29600,
+		118400,
+	},
+	{
+		"Departure before arrival",
+		time.Date(2050, time.January, 5, 0, 0, 0, 0, time.UTC),
+		time.Date(2050, time.January, 3, 0, 0, 0, 0, time.UTC),
+		0,
+		0,
+		0,
+		0,
+	},
+}
+
+func TestCalculate(t *testing.T) {
+	for _, test := range calculateTests {
+		quote := Calculate(testRatePlan, test.startDate, test.endDate)
+
+		if len(quote.Nights) != test.expectedNights {
+			t.Errorf("Test %s returns wrong number of nights: got %d, wanted %d", test.name, len(quote.Nights), test.expectedNights)
+		}
+
+		if quote.Subtotal != test.expectedSubtotal {
+			t.Errorf("Test %s returns wrong subtotal: got %d, wanted %d", test.name, quote.Subtotal, test.expectedSubtotal)
+		}
+
+		if quote.Discount != test.expectedDiscount {
+			t.Errorf("Test %s returns wrong discount: got %d, wanted %d", test.name, quote.Discount, test.expectedDiscount)
+		}
+
+		if quote.Total != test.expectedTotal {
+			t.Errorf("Test %s returns wrong total: got %d, wanted %d", test.name, quote.Total, test.expectedTotal)
+		}
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	amounts := map[int]string{
+		0: "$0.00",
+		5: "$0.05",
+		12050: "$120.50",
+		-2500: "-$25.00",
+	}
+
+	for cents, expected := range amounts {
+		if FormatAmount(cents) != expected {
+			t.Errorf("Wrong format for %d: got %s, wanted %s", cents, FormatAmount(cents), expected)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Token bucket rate limiter keeping a bucket per key in memory.
+// Each bucket holds up to `perMinute` tokens and refills continuously, so short bursts are allowed
+type Limiter struct {
+	mutex sync.Mutex
+	buckets map[string]*bucket
+	// Replaced in tests
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	updatedAt time.Time
+}
+
+// Creates a new limiter
+func New() *Limiter {
+	return &Limiter{
+		buckets: make(map[string]*bucket),
+		now: time.Now,
+	}
+}
+
+// Takes a token from the bucket of the given key. If the bucket is empty, the request is not allowed
+// and the time until the next token is available is returned
+func (limiter *Limiter) Allow(key string, perMinute int) (bool, time.Duration) {
+	if perMinute <= 0 {
+		return false, time.Minute
+	}
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := limiter.now()
+	capacity := float64(perMinute)
+	tokensPerSecond := capacity / 60
+
+	b, ok := limiter.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updatedAt: now}
+		limiter.buckets[key] = b
+	}
+
+	// Refill the bucket for the time passed since it was last used
+	b.tokens += now.Sub(b.updatedAt).Seconds() * tokensPerSecond
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / tokensPerSecond * float64(time.Second))
+		return false, wait
+	}
+
+	b.tokens--
+
+	return true, 0
+}
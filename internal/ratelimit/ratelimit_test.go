@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Date(2050, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	limiter := New()
+	limiter.now = func() time.Time { return now }
+
+	// A full bucket allows a burst of `perMinute` requests
+	for i := 0; i < 3; i++ {
+		allowed, _ := limiter.Allow("key", 3)
+		if !allowed {
+			t.Fatalf("Request %d should be allowed", i + 1)
+		}
+	}
+
+	allowed, wait := limiter.Allow("key", 3)
+	if allowed {
+		t.Fatal("Request over the limit should not be allowed")
+	}
+
+	if wait != 20 * time.Second {
+		t.Errorf("Wrong wait time: got %s, wanted 20s", wait)
+	}
+
+	// Other keys have their own bucket
+	allowed, _ = limiter.Allow("other key", 3)
+	if !allowed {
+		t.Error("Request with another key should be allowed")
+	}
+
+	// A token is added every 20 seconds
+	now = now.Add(20 * time.Second)
+
+	allowed, _ = limiter.Allow("key", 3)
+	if !allowed {
+		t.Error("Request should be allowed after the bucket refilled")
+	}
+
+	allowed, _ = limiter.Allow("key", 3)
+	if allowed {
+		t.Error("Bucket should be empty again")
+	}
+
+	// The bucket never holds more than `perMinute` tokens
+	now = now.Add(time.Hour)
+
+	for i := 0; i < 3; i++ {
+		limiter.Allow("key", 3)
+	}
+
+	allowed, _ = limiter.Allow("key", 3)
+	if allowed {
+		t.Error("Bucket should not hold more tokens than the limit")
+	}
+}
+
+func TestLimiter_AllowWithoutLimit(t *testing.T) {
+	allowed, _ := New().Allow("key", 0)
+	if allowed {
+		t.Error("A limit of 0 should not allow any request")
+	}
+}
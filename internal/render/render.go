@@ -11,7 +11,9 @@ import (
 	"time"
 
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/pricing"
 	"github.com/justinas/nosurf"
 )
 
@@ -20,6 +22,7 @@ var functions = template.FuncMap{
 	"formatDate": FormatDate,
 	"convertDateToFormat": ConvertDateToFormat,
 	"iterate": Iterate,
+	"formatAmount": pricing.FormatAmount,
 }
 
 var app *config.AppConfig
@@ -87,6 +90,20 @@ func GetTemplatePages() (map[string]*template.Template, error) {
 			}
 		}
 
+		// Find partial files
+		matches, err = filepath.Glob(fmt.Sprintf("%s/*.partial.tmpl", pathToTemplates))
+		if err != nil {
+			return templates, err
+		}
+
+		// If any partial files have been found, associate them with the created template page
+		if len(matches) > 0 {
+			template, err = template.ParseGlob(fmt.Sprintf("%s/*.partial.tmpl", pathToTemplates))
+			if err != nil {
+				return templates, err
+			}
+		}
+
 		templates[name] = template
 	}
 
@@ -103,6 +120,11 @@ func addDefaultData(templateData *models.TemplateData, r *http.Request) *models.
 		templateData.IsAuthenticated = true
 	}
 
+	// Lets templates hide the actions the user isn't allowed to perform
+	if user, ok := helpers.CurrentUser(r); ok {
+		templateData.User = user
+	}
+
 	return templateData
 }
 
@@ -2,13 +2,54 @@ package dbrepository
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"sort"
 	"time"
 
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+	"github.com/jackc/pgconn"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Postgres error code raised when a row violates an exclusion constraint
+const exclusionViolationCode = "23P01"
+
+// Postgres error code raised when a row violates a unique index
+const uniqueViolationCode = "23505"
+
+// Converts exclusion constraint violations on room restrictions into `repository.ErrRoomNotAvailable`
+func translateOverlapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == exclusionViolationCode {
+		return repository.ErrRoomNotAvailable
+	}
+
+	return err
+}
+
+// Converts unique index violations on the room slug into `repository.ErrSlugTaken`
+func translateSlugError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == "rooms_slug_idx" {
+		return repository.ErrSlugTaken
+	}
+
+	return err
+}
+
+// Converts unique index violations on the user email into `repository.ErrEmailTaken`
+func translateEmailError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == "users_email_idx" {
+		return repository.ErrEmailTaken
+	}
+
+	return err
+}
+
 // Inserts a reservation into the database
 func (pgRepo *postgresDBRepository) InsertReservation(reservation models.Reservation) (int, error) {
 	// Set timeout for this operation
@@ -16,14 +57,19 @@ func (pgRepo *postgresDBRepository) InsertReservation(reservation models.Reserva
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
+	priceBreakdown, err := json.Marshal(reservation.Quote)
+	if err != nil {
+		return 0, err
+	}
+
 	query := `INSERT INTO reservations (first_name, last_name, email, phone, start_date,
-						end_date, room_id, created_at, updated_at)
-						VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+						end_date, room_id, adults, children, total_price, price_breakdown, confirmation_code, created_at, updated_at)
+						VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 						RETURNING id`
 					
 	var reservationID int
 
-	err := pgRepo.DB.QueryRowContext(
+	err = pgRepo.DB.QueryRowContext(
 		ctx,
 		query,
 		reservation.FirstName,
@@ -33,6 +79,11 @@ func (pgRepo *postgresDBRepository) InsertReservation(reservation models.Reserva
 		reservation.StartDate,
 		reservation.EndDate,
 		reservation.RoomID,
+		reservation.Adults,
+		reservation.Children,
+		reservation.TotalPrice,
+		priceBreakdown,
+		reservation.ConfirmationCode,
 		time.Now(),
 		time.Now(),
 	).Scan(&reservationID)
@@ -73,20 +124,226 @@ func (pgRepo *postgresDBRepository) InsertRoomRestriction(roomRestriction models
 	return nil
 }
 
-// Queries for existing reservations on the given room and dates 
-// Returns true if there are reservations for the given room and dates, otherwise it returns false
-func (pgRepo *postgresDBRepository) SearchAvailabilityByDatesAndRoom(startDate time.Time, endDate time.Time, roomID int) (bool, error) {
+// Inserts a reservation, its room restriction and the emails confirming it in a single transaction.
+// Availability is checked again inside the transaction and the database rejects overlapping
+// restrictions, so `repository.ErrRoomNotAvailable` is returned if the room was booked in the meantime
+func (pgRepo *postgresDBRepository) InsertReservationWithRestriction(reservation models.Reservation, emails []models.MailData) (int, error) {
 	// Set timeout for this operation
 	// Cancel operation if it takes more than 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	query := `SELECT count(id)
+	tx, err := pgRepo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// Rollback is a no-op if the transaction has been committed
+	defer tx.Rollback()
+
+	reservationID, err := insertReservationInTx(ctx, tx, reservation)
+	if err != nil {
+		return 0, err
+	}
+
+	err = insertOutboxEmails(ctx, tx, emails)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, translateOverlapError(err)
+	}
+
+	return reservationID, nil
+}
+
+// Inserts a booking together with its reservations, their room restrictions and the emails confirming it
+// in a single transaction, so that either all rooms are booked or none is.
+// `repository.ErrRoomNotAvailable` is returned if any of the rooms was booked in the meantime
+func (pgRepo *postgresDBRepository) InsertBookingWithReservations(booking models.Booking, emails []models.MailData) (int, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	tx, err := pgRepo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// Rollback is a no-op if the transaction has been committed
+	defer tx.Rollback()
+
+	query := `INSERT INTO bookings (confirmation_code, first_name, last_name, email, phone, total_price,
+						created_at, updated_at)
+						VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+						RETURNING id`
+
+	var bookingID int
+
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		booking.ConfirmationCode,
+		booking.FirstName,
+		booking.LastName,
+		booking.Email,
+		booking.Phone,
+		booking.TotalPrice,
+		time.Now(),
+		time.Now(),
+	).Scan(&bookingID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Rooms are locked in the order of their ids, so that concurrent bookings of the same rooms can't deadlock
+	reservations := make([]models.Reservation, len(booking.Reservations))
+	copy(reservations, booking.Reservations)
+	sort.SliceStable(reservations, func(i, j int) bool {
+		return reservations[i].RoomID < reservations[j].RoomID
+	})
+
+	for _, reservation := range reservations {
+		reservation.BookingID = bookingID
+
+		_, err = insertReservationInTx(ctx, tx, reservation)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = insertOutboxEmails(ctx, tx, emails)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, translateOverlapError(err)
+	}
+
+	return bookingID, nil
+}
+
+// Inserts a reservation and its room restriction within the given transaction, after checking that the room
+// is still open for bookings and available. The room is locked until the transaction ends, so that concurrent
+// bookings of the same room are handled one at a time
+func insertReservationInTx(ctx context.Context, tx *sql.Tx, reservation models.Reservation) (int, error) {
+	query := `SELECT active FROM rooms WHERE id = $1 FOR UPDATE`
+
+	var active bool
+
+	err := tx.QueryRowContext(ctx, query, reservation.RoomID).Scan(&active)
+	if err != nil {
+		return 0, err
+	}
+
+	// Deactivated rooms can't be booked, even through old links to them
+	if !active {
+		return 0, repository.ErrRoomNotAvailable
+	}
+
+	// Make sure the room is still available for the given dates
+	query = `SELECT count(id)
 						FROM room_restrictions
 						WHERE room_id = $1
 						AND $2 < end_date AND $3 > start_date`
 
+	var numExistingRestrictions int
+
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		reservation.RoomID,
+		reservation.StartDate,
+		reservation.EndDate,
+	).Scan(&numExistingRestrictions)
+	if err != nil {
+		return 0, err
+	}
+
+	if numExistingRestrictions > 0 {
+		return 0, repository.ErrRoomNotAvailable
+	}
+
+	priceBreakdown, err := json.Marshal(reservation.Quote)
+	if err != nil {
+		return 0, err
+	}
+
+	// Reservations booked on their own don't belong to a booking
+	query = `INSERT INTO reservations (first_name, last_name, email, phone, start_date,
+						end_date, room_id, adults, children, total_price, price_breakdown, confirmation_code, booking_id, created_at, updated_at)
+						VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, 0), $14, $15)
+						RETURNING id`
+
+	var reservationID int
+
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		reservation.FirstName,
+		reservation.LastName,
+		reservation.Email,
+		reservation.Phone,
+		reservation.StartDate,
+		reservation.EndDate,
+		reservation.RoomID,
+		reservation.Adults,
+		reservation.Children,
+		reservation.TotalPrice,
+		priceBreakdown,
+		reservation.ConfirmationCode,
+		reservation.BookingID,
+		time.Now(),
+		time.Now(),
+	).Scan(&reservationID)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `INSERT INTO room_restrictions (start_date, end_date, room_id, reservation_id, restriction_id,
+						created_at, updated_at)
+						VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err = tx.ExecContext(
+		ctx,
+		query,
+		reservation.StartDate,
+		reservation.EndDate,
+		reservation.RoomID,
+		reservationID,
+		models.RestrictionReservation,
+		time.Now(),
+		time.Now(),
+	)
+	if err != nil {
+		return 0, translateOverlapError(err)
+	}
+
+	return reservationID, nil
+}
+
+// Queries for existing reservations on the given room and dates
+// Returns true if there are reservations for the given room and dates, otherwise it returns false
+func (pgRepo *postgresDBRepository) SearchAvailabilityByDatesAndRoom(startDate time.Time, endDate time.Time, roomID int) (bool, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	// Deactivated rooms are never available
+	query := `SELECT count(rr.id), rm.active
+						FROM rooms rm
+						LEFT JOIN room_restrictions rr ON (rr.room_id = rm.id AND $2 < rr.end_date AND $3 > rr.start_date)
+						WHERE rm.id = $1
+						GROUP BY rm.id`
+
 	var numExistingReservations int
+	var active bool
 
 	err := pgRepo.DB.QueryRowContext(
 		ctx,
@@ -94,11 +351,15 @@ func (pgRepo *postgresDBRepository) SearchAvailabilityByDatesAndRoom(startDate t
 		roomID,
 		startDate,
 		endDate,
-	).Scan(&numExistingReservations)
+	).Scan(&numExistingReservations, &active)
 	if err != nil {
 		return false, err
 	}
 
+	if !active {
+		return false, nil
+	}
+
 	if numExistingReservations == 0 {
 		return true, nil
 	}
@@ -107,15 +368,18 @@ func (pgRepo *postgresDBRepository) SearchAvailabilityByDatesAndRoom(startDate t
 }
 
 // Returns all rooms which are available during the given dates
-func (pgRepo *postgresDBRepository) SearchAvailabilityForAllRooms(startDate time.Time, endDate time.Time) ([]models.Room, error) {
+func (pgRepo *postgresDBRepository) SearchAvailabilityForAllRooms(startDate time.Time, endDate time.Time, guests int) ([]models.Room, error) {
 	// Set timeout for this operation
 	// Cancel operation if it takes more than 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	query := `SELECT r.id, r.room_name
+	query := `SELECT r.id, r.room_name, r.slug, r.capacity, r.bed_configuration
 						FROM rooms r
-						WHERE r.id NOT IN (SELECT room_id FROM room_restrictions rr WHERE $1 < end_date AND $2 > start_date)`
+						WHERE r.active = true
+						AND r.capacity >= $3
+						AND r.id NOT IN (SELECT room_id FROM room_restrictions rr WHERE $1 < end_date AND $2 > start_date)
+						ORDER BY r.sort_order, r.room_name`
 
 	var rooms []models.Room
 
@@ -124,6 +388,7 @@ func (pgRepo *postgresDBRepository) SearchAvailabilityForAllRooms(startDate time
 		query,
 		startDate,
 		endDate,
+		guests,
 	)
 	if err != nil {
 		return rooms, err
@@ -132,7 +397,7 @@ func (pgRepo *postgresDBRepository) SearchAvailabilityForAllRooms(startDate time
 	// Loop through each row returned from the query and add it to the `rooms` variable
 	for rows.Next() {
 		var room models.Room
-		err := rows.Scan(&room.ID, &room.RoomName)
+		err := rows.Scan(&room.ID, &room.RoomName, &room.Slug, &room.Capacity, &room.BedConfiguration)
 		if err != nil {
 			return rooms, err
 		}
@@ -158,7 +423,7 @@ func (pgRepo *postgresDBRepository) GetRoomByID(id int) (models.Room, error) {
 
 	var room models.Room
 
-	query := `SELECT id, room_name, created_at, updated_at
+	query := `SELECT id, room_name, slug, description, capacity, bed_configuration, amenities, image, active, sort_order, created_at, updated_at
 					FROM rooms
 					WHERE id = $1`
 				
@@ -166,7 +431,20 @@ func (pgRepo *postgresDBRepository) GetRoomByID(id int) (models.Room, error) {
 		ctx,
 		query,
 		id,
-	).Scan(&room.ID, &room.RoomName, &room.CreatedAt, &room.UpdatedAt)
+	).Scan(
+		&room.ID,
+		&room.RoomName,
+		&room.Slug,
+		&room.Description,
+		&room.Capacity,
+		&room.BedConfiguration,
+		&room.Amenities,
+		&room.Image,
+		&room.Active,
+		&room.SortOrder,
+		&room.CreatedAt,
+		&room.UpdatedAt,
+	)
 	if err != nil {
 		return room, err
 	}
@@ -174,279 +452,350 @@ func (pgRepo *postgresDBRepository) GetRoomByID(id int) (models.Room, error) {
 	return room, nil
 }
 
-// Gets user by id
-func (pgRepo *postgresDBRepository) GetUserByID(id int) (models.User, error) {
+// Gets room by slug
+func (pgRepo *postgresDBRepository) GetRoomBySlug(slug string) (models.Room, error) {
 	// Set timeout for this operation
 	// Cancel operation if it takes more than 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	query := `SELECT id, first_name, last_name, email, password, access_level
-		FROM users
-		WHERE id = $1`
+	var room models.Room
 
-	var user models.User
+	query := `SELECT id, room_name, slug, description, capacity, bed_configuration, amenities, image, active, sort_order, created_at, updated_at
+					FROM rooms
+					WHERE slug = $1`
+				
+	err := pgRepo.DB.QueryRowContext(
+		ctx,
+		query,
+		slug,
+	).Scan(
+		&room.ID,
+		&room.RoomName,
+		&room.Slug,
+		&room.Description,
+		&room.Capacity,
+		&room.BedConfiguration,
+		&room.Amenities,
+		&room.Image,
+		&room.Active,
+		&room.SortOrder,
+		&room.CreatedAt,
+		&room.UpdatedAt,
+	)
+	if err != nil {
+		return room, err
+	}
+
+	return room, nil
+}
+
+// Inserts a room into the database, placing it after all other rooms
+func (pgRepo *postgresDBRepository) InsertRoom(room models.Room) (int, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var newRoomID int
+
+	query := `INSERT INTO rooms (room_name, slug, description, capacity, bed_configuration, amenities, image, active, sort_order, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM rooms), $9, $10)
+		RETURNING id`
 
 	err := pgRepo.DB.QueryRowContext(
-		ctx, 
-		query, 
-		id,
-	).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Password, &user.AccessLevel, &user.CreatedAt, &user.UpdatedAt)
+		ctx,
+		query,
+		room.RoomName,
+		room.Slug,
+		room.Description,
+		room.Capacity,
+		room.BedConfiguration,
+		room.Amenities,
+		room.Image,
+		room.Active,
+		time.Now(),
+		time.Now(),
+	).Scan(&newRoomID)
 	if err != nil {
-		return user, err
+		return 0, translateSlugError(err)
 	}
 
-	return user, nil
+	return newRoomID, nil
 }
 
-// Updates a user
-func (pgRepo *postgresDBRepository) UpdateUser(user models.User) error {
+// Updates the details of a room
+func (pgRepo *postgresDBRepository) UpdateRoom(room models.Room) error {
 	// Set timeout for this operation
 	// Cancel operation if it takes more than 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	query := `UPDATE users
-		SET first_name = $1, last_name = $2, email = $3, access_level = $4, updated_at = $5`
+	query := `UPDATE rooms
+		SET room_name = $1, slug = $2, description = $3, capacity = $4, bed_configuration = $5, amenities = $6, image = $7, updated_at = $8
+		WHERE id = $9`
 
 	_, err := pgRepo.DB.ExecContext(
-		ctx, 
-		query, 
-		user.FirstName, 
-		user.LastName,
-		user.Email,
-		user.AccessLevel,
+		ctx,
+		query,
+		room.RoomName,
+		room.Slug,
+		room.Description,
+		room.Capacity,
+		room.BedConfiguration,
+		room.Amenities,
+		room.Image,
 		time.Now(),
+		room.ID,
 	)
 	if err != nil {
-		return err
+		return translateSlugError(err)
 	}
 
 	return nil
 }
 
-// Authenticates a user
-func (pgRepo *postgresDBRepository) Authenticate(email, password string) (int, string, error) {
+// Activates or deactivates a room. Inactive rooms are hidden from guests but keep their reservations
+func (pgRepo *postgresDBRepository) UpdateActiveForRoom(id int, active bool) error {
 	// Set timeout for this operation
 	// Cancel operation if it takes more than 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	var id int // ID of authenticated user
-	var hashedPassword string
-
-	// Retrieve stored password corresponding to received email
-	query := `SELECT id, password
-		FROM users
-		WHERE email = $1`
+	query := `UPDATE rooms
+		SET active = $1, updated_at = $2
+		WHERE id = $3`
 
-	err := pgRepo.DB.QueryRowContext(
-		ctx, 
-		query, 
-		email,
-	).Scan(&id, &hashedPassword)
+	_, err := pgRepo.DB.ExecContext(ctx, query, active, time.Now(), id)
 	if err != nil {
-		return id, "", err
-	}
-
-	// Compare received password with stored password in the database
-	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-	if err == bcrypt.ErrMismatchedHashAndPassword {
-		return 0, "", errors.New("incorrect password")
-	} else if err != nil {
-		return 0, "", err
+		return err
 	}
 
-	return id, hashedPassword, nil
+	return nil
 }
 
-// Gets a list of all reservations
-func (pgRepo *postgresDBRepository) GetAllReservations() ([]models.Reservation, error) {
+// Updates the display order of the given rooms (room id -> position) in a single transaction
+func (pgRepo *postgresDBRepository) UpdateSortOrderForRooms(sortOrders map[int]int) error {
 	// Set timeout for this operation
 	// Cancel operation if it takes more than 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	var reservations []models.Reservation
-
-	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, 
-		r.end_date, r.room_id, r.created_at, r.updated_at, r.processed, rm.id, rm.room_name
-		FROM reservations r
-		LEFT JOIN rooms rm ON (r.room_id = rm.id)
-		ORDER BY r.start_date ASC`
-
-	rows, err := pgRepo.DB.QueryContext(ctx, query)
+	tx, err := pgRepo.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return reservations, err
+		return err
 	}
 
-	defer rows.Close()
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
 
-	for rows.Next() {
-		var reservation models.Reservation
+	query := `UPDATE rooms
+		SET sort_order = $1, updated_at = $2
+		WHERE id = $3`
 
-		err := rows.Scan(
-			&reservation.ID,
-			&reservation.FirstName,
-			&reservation.LastName,
-			&reservation.Email,
-			&reservation.Phone,
-			&reservation.StartDate,
-			&reservation.EndDate,
-			&reservation.RoomID,
-			&reservation.CreatedAt,
-			&reservation.UpdatedAt,
-			&reservation.Processed,
-			&reservation.Room.ID,
-			&reservation.Room.RoomName,
-		)
+	for roomID, sortOrder := range sortOrders {
+		_, err = tx.ExecContext(ctx, query, sortOrder, time.Now(), roomID)
 		if err != nil {
-			return reservations, nil
+			return err
 		}
-
-		reservations = append(reservations, reservation)
-	}
-
-	if err = rows.Err(); err != nil {
-		return reservations, err
 	}
 
-	return reservations, nil
+	return tx.Commit()
 }
 
-// Gets a list of all new reservations (not yet processed)
-func (pgRepo *postgresDBRepository) GetNewReservations() ([]models.Reservation, error) {
+// Gets user by id
+func (pgRepo *postgresDBRepository) GetUserByID(id int) (models.User, error) {
 	// Set timeout for this operation
 	// Cancel operation if it takes more than 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	var reservations []models.Reservation
+	query := `SELECT id, first_name, last_name, email, password, access_level, disabled, password_reset_required,
+		session_version, two_factor_secret, two_factor_enabled, two_factor_last_step, created_at, updated_at
+		FROM users
+		WHERE id = $1`
 
-	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, 
-		r.end_date, r.room_id, r.created_at, r.updated_at, rm.id, rm.room_name
-		FROM reservations r
-		LEFT JOIN rooms rm ON (r.room_id = rm.id)
-		WHERE processed = false
-		ORDER BY r.start_date ASC`
+	return scanUser(pgRepo.DB.QueryRowContext(ctx, query, id))
+}
+
+// Gets user by email
+func (pgRepo *postgresDBRepository) GetUserByEmail(email string) (models.User, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `SELECT id, first_name, last_name, email, password, access_level, disabled, password_reset_required,
+		session_version, two_factor_secret, two_factor_enabled, two_factor_last_step, created_at, updated_at
+		FROM users
+		WHERE email = $1`
+
+	return scanUser(pgRepo.DB.QueryRowContext(ctx, query, email))
+}
+
+// Gets a list of all users, sorted by name
+func (pgRepo *postgresDBRepository) GetAllUsers() ([]models.User, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var users []models.User
+
+	query := `SELECT id, first_name, last_name, email, password, access_level, disabled, password_reset_required,
+		session_version, two_factor_secret, two_factor_enabled, two_factor_last_step, created_at, updated_at
+		FROM users
+		ORDER BY last_name, first_name, id`
 
 	rows, err := pgRepo.DB.QueryContext(ctx, query)
 	if err != nil {
-		return reservations, err
+		return users, err
 	}
 
 	defer rows.Close()
 
 	for rows.Next() {
-		var reservation models.Reservation
-
-		err := rows.Scan(
-			&reservation.ID,
-			&reservation.FirstName,
-			&reservation.LastName,
-			&reservation.Email,
-			&reservation.Phone,
-			&reservation.StartDate,
-			&reservation.EndDate,
-			&reservation.RoomID,
-			&reservation.CreatedAt,
-			&reservation.UpdatedAt,
-			&reservation.Room.ID,
-			&reservation.Room.RoomName,
-		)
+		user, err := scanUser(rows)
 		if err != nil {
-			return reservations, nil
+			return users, err
 		}
 
-		reservations = append(reservations, reservation)
+		users = append(users, user)
 	}
 
 	if err = rows.Err(); err != nil {
-		return reservations, err
+		return users, err
 	}
 
-	return reservations, nil
+	return users, nil
 }
 
-// Gets a reservation that matches given id
-func (pgRepo *postgresDBRepository) GetReservationByID(id int) (models.Reservation, error) {
+// Inserts a user with the given password and queues the given emails, e.g. the invitation, in the same transaction
+func (pgRepo *postgresDBRepository) InsertUser(user models.User, password string, emails []models.MailData) (int, error) {
 	// Set timeout for this operation
 	// Cancel operation if it takes more than 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	var reservation models.Reservation
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
 
-	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date,
-		r.end_date, r.room_id, r.created_at, r.updated_at, r.processed, rm.id, rm.room_name
-		FROM reservations r
-		LEFT JOIN rooms rm ON (r.room_id = rm.id)
-		WHERE r.id = $1`
+	tx, err := pgRepo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
 
-	err := pgRepo.DB.QueryRowContext(
-		ctx, 
+	// Rollback is a no-op if the transaction has been committed
+	defer tx.Rollback()
+
+	query := `INSERT INTO users (first_name, last_name, email, password, access_level, disabled,
+		password_reset_required, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`
+
+	var userID int
+
+	err = tx.QueryRowContext(
+		ctx,
 		query,
-		id,
-	).Scan(
-		&reservation.ID,
-		&reservation.FirstName,
-		&reservation.LastName,
-		&reservation.Email,
-		&reservation.Phone,
-		&reservation.StartDate,
-		&reservation.EndDate,
-		&reservation.RoomID,
-		&reservation.CreatedAt,
-		&reservation.UpdatedAt,
-		&reservation.Processed,
-		&reservation.Room.ID,
-		&reservation.Room.RoomName,
-	)
+		user.FirstName,
+		user.LastName,
+		user.Email,
+		string(hashedPassword),
+		user.AccessLevel,
+		user.Disabled,
+		user.PasswordResetRequired,
+		time.Now(),
+		time.Now(),
+	).Scan(&userID)
 	if err != nil {
-		return reservation, err
+		return 0, translateEmailError(err)
 	}
 
-	return reservation, nil
+	err = insertOutboxEmails(ctx, tx, emails)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
 }
 
-// Updates a reservation
-func (pgRepo *postgresDBRepository) UpdateReservation(reservation models.Reservation) error {
+// Updates the name, email and access level of a user
+func (pgRepo *postgresDBRepository) UpdateUser(user models.User) error {
 	// Set timeout for this operation
 	// Cancel operation if it takes more than 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	query := `UPDATE reservations
-		SET first_name = $1, last_name = $2, email = $3, phone = $4, updated_at = $5
+	query := `UPDATE users
+		SET first_name = $1, last_name = $2, email = $3, access_level = $4, updated_at = $5
 		WHERE id = $6`
 
 	_, err := pgRepo.DB.ExecContext(
 		ctx, 
 		query, 
-		reservation.FirstName, 
-		reservation.LastName,
-		reservation.Email,
-		reservation.Phone,
+		user.FirstName, 
+		user.LastName,
+		user.Email,
+		user.AccessLevel,
 		time.Now(),
-		reservation.ID,
+		user.ID,
 	)
 	if err != nil {
-		return err
+		return translateEmailError(err)
 	}
 
 	return nil
 }
 
-// Deletes a reservation
-func (pgRepo *postgresDBRepository) DeleteReservation(id int) error {
+// Hashes and stores a new password for a user, which no longer has to be reset.
+// Returns the new session version of the user, so that the current session can stay logged in
+func (pgRepo *postgresDBRepository) UpdateUserPassword(id int, password string) (int, error) {
 	// Set timeout for this operation
 	// Cancel operation if it takes more than 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	query := `DELETE FROM reservations WHERE id = $1`
+	tx, err := pgRepo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
 
-	_, err := pgRepo.DB.ExecContext(ctx, query, id)
+	// Rollback is a no-op if the transaction has been committed
+	defer tx.Rollback()
+
+	sessionVersion, err := setUserPassword(ctx, tx, id, password)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return sessionVersion, nil
+}
+
+// Disables or enables a user
+func (pgRepo *postgresDBRepository) UpdateDisabledForUser(id int, disabled bool) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE users
+		SET disabled = $1, updated_at = $2
+		WHERE id = $3`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, disabled, time.Now(), id)
 	if err != nil {
 		return err
 	}
@@ -454,23 +803,18 @@ func (pgRepo *postgresDBRepository) DeleteReservation(id int) error {
 	return nil
 }
 
-// Updates processed status of a reservation with given id
-func (pgRepo *postgresDBRepository) UpdateProcessedForReservation(id int, processed bool) error {
+// Sets whether a user has to choose a new password before using the admin dashboard again
+func (pgRepo *postgresDBRepository) UpdatePasswordResetRequiredForUser(id int, required bool) error {
 	// Set timeout for this operation
 	// Cancel operation if it takes more than 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	query := `UPDATE reservations
-		SET processed = $1
-		WHERE id = $2`
+	query := `UPDATE users
+		SET password_reset_required = $1, updated_at = $2
+		WHERE id = $3`
 
-	_, err := pgRepo.DB.ExecContext(
-		ctx, 
-		query, 
-		processed,
-		id,
-	)
+	_, err := pgRepo.DB.ExecContext(ctx, query, required, time.Now(), id)
 	if err != nil {
 		return err
 	}
@@ -478,126 +822,3659 @@ func (pgRepo *postgresDBRepository) UpdateProcessedForReservation(id int, proces
 	return nil
 }
 
-// Gets all rooms
-func (pgRepo *postgresDBRepository) GetAllRooms() ([]models.Room, error) {
+// Deletes a user
+func (pgRepo *postgresDBRepository) DeleteUser(id int) error {
 	// Set timeout for this operation
 	// Cancel operation if it takes more than 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	var rooms []models.Room
-
-	query := `SELECT id, room_name, created_at, updated_at
-		FROM rooms
-		ORDER BY room_name`
+	query := `DELETE FROM users WHERE id = $1`
 
-	rows, err := pgRepo.DB.QueryContext(ctx, query)
+	_, err := pgRepo.DB.ExecContext(ctx, query, id)
 	if err != nil {
-		return rooms, err
+		return err
 	}
 
-	defer rows.Close()
+	return nil
+}
 
-	for rows.Next() {
-		var room models.Room
+// Authenticates a user. Disabled users are rejected with `repository.ErrUserDisabled`,
+// which is only returned once the password has been checked
+func (pgRepo *postgresDBRepository) Authenticate(email, password string) (int, string, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
 
-		err := rows.Scan(
-			&room.ID,
-			&room.RoomName,
-			&room.CreatedAt,
-			&room.UpdatedAt,
-		)
-		if err != nil {
-			return rooms, err
-		}
+	var id int // ID of authenticated user
+	var hashedPassword string
+	var disabled bool
 
-		rooms = append(rooms, room)
+	// Retrieve stored password corresponding to received email
+	query := `SELECT id, password, disabled
+		FROM users
+		WHERE email = $1`
+
+	err := pgRepo.DB.QueryRowContext(
+		ctx, 
+		query, 
+		email,
+	).Scan(&id, &hashedPassword, &disabled)
+	if err != nil {
+		return id, "", err
 	}
 
-	if err = rows.Err(); err != nil {
-		return rooms, err
+	// Compare received password with stored password in the database
+	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return 0, "", errors.New("incorrect password")
+	} else if err != nil {
+		return 0, "", err
 	}
 
-	return rooms, nil
+	if disabled {
+		return 0, "", repository.ErrUserDisabled
+	}
+
+	return id, hashedPassword, nil
 }
 
-// Gets restrictions for a given room by date range
-func (pgRepo *postgresDBRepository) GetRestrictionsForRoomByDate(roomID int, startDate, endDate time.Time) ([]models.RoomRestriction, error) {
+// Stores a token for a password reset link and queues the email with the link
+func (pgRepo *postgresDBRepository) InsertPasswordResetToken(token models.PasswordResetToken, emails []models.MailData) error {
 	// Set timeout for this operation
 	// Cancel operation if it takes more than 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	var restrictions []models.RoomRestriction
-
-	query := `SELECT id, COALESCE(reservation_id, 0), restriction_id, room_id, start_date, end_date
-		FROM room_restrictions
-		WHERE $1 < end_date and $2 >= start_date and room_id = $3`
-
-	rows, err := pgRepo.DB.QueryContext(ctx, query, startDate, endDate, roomID)
+	tx, err := pgRepo.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return restrictions, err
+		return err
 	}
 
-	defer rows.Close()
-
-	for rows.Next() {
-		var restriction models.RoomRestriction
+	// Rollback is a no-op if the transaction has been committed
+	defer tx.Rollback()
 
-		err := rows.Scan(
-			&restriction.ID,
-			&restriction.ReservationID,
-			&restriction.RestrictionID,
-			&restriction.RoomID,
-			&restriction.StartDate,
-			&restriction.EndDate,
-		)
-		if err != nil {
-			return restrictions, err
-		}
+	query := `INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)`
 
-		restrictions = append(restrictions, restriction)
+	_, err = tx.ExecContext(ctx, query, token.UserID, token.TokenHash, token.ExpiresAt, time.Now(), time.Now())
+	if err != nil {
+		return err
 	}
 
-	if err = rows.Err(); err != nil {
-		return restrictions, err
+	err = insertOutboxEmails(ctx, tx, emails)
+	if err != nil {
+		return err
 	}
 
-	return restrictions, nil
+	return tx.Commit()
 }
 
-// Inserts an owner block as room restriction for given room
-func (pgRepo *postgresDBRepository) InsertBlockForRoom(id int, startDate time.Time) error {
+// Gets a password reset token by the hash of the token
+func (pgRepo *postgresDBRepository) GetPasswordResetTokenByHash(tokenHash string) (models.PasswordResetToken, error) {
 	// Set timeout for this operation
 	// Cancel operation if it takes more than 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	query := `INSERT INTO room_restrictions (start_date, end_date, room_id, restriction_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)`
+	var token models.PasswordResetToken
+	var usedAt sql.NullTime
+
+	query := `SELECT id, user_id, token_hash, expires_at, used_at, created_at, updated_at
+		FROM password_reset_tokens
+		WHERE token_hash = $1`
 
-	_, err := pgRepo.DB.ExecContext(ctx, query, startDate, startDate.AddDate(0, 0, 1), id, 2, time.Now(), time.Now())
+	err := pgRepo.DB.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&usedAt,
+		&token.CreatedAt,
+		&token.UpdatedAt,
+	)
 	if err != nil {
-		return err
+		return token, err
 	}
 
-	return nil
+	token.UsedAt = usedAt.Time
+
+	return token, nil
 }
 
-// Deletes an owner block from room restrictions
-func (pgRepo *postgresDBRepository) DeleteBlockByID(id int) error {
+// Stores a new password for the user of an unused and unexpired password reset token and uses up the token.
+// Returns the id of the user, or `repository.ErrResetTokenInvalid` if the token can't be used
+func (pgRepo *postgresDBRepository) ResetPasswordWithToken(tokenHash, password string) (int, error) {
 	// Set timeout for this operation
 	// Cancel operation if it takes more than 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
 	defer cancel()
 
-	query := `DELETE FROM room_restrictions
-		WHERE id = $1`
-
-	_, err := pgRepo.DB.ExecContext(ctx, query, id)
+	tx, err := pgRepo.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
-}
\ No newline at end of file
+	// Rollback is a no-op if the transaction has been committed
+	defer tx.Rollback()
+
+	// Lock the token so that it can't be used twice by concurrent requests
+	query := `SELECT user_id
+		FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+		FOR UPDATE`
+
+	var userID int
+
+	err = tx.QueryRowContext(ctx, query, tokenHash, time.Now()).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, repository.ErrResetTokenInvalid
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	// Also uses up the token, together with every other open token of the user
+	_, err = setUserPassword(ctx, tx, userID, password)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// Hashes and stores a new password for a user within a transaction. The session version is increased,
+// which logs out every session started with the old password, and open password reset links stop working
+func setUserPassword(ctx context.Context, tx *sql.Tx, id int, password string) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `UPDATE users
+		SET password = $1, password_reset_required = false, session_version = session_version + 1, updated_at = $2
+		WHERE id = $3
+		RETURNING session_version`
+
+	var sessionVersion int
+
+	err = tx.QueryRowContext(ctx, query, string(hashedPassword), time.Now(), id).Scan(&sessionVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `UPDATE password_reset_tokens
+		SET used_at = $1, updated_at = $1
+		WHERE user_id = $2 AND used_at IS NULL`
+
+	_, err = tx.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return 0, err
+	}
+
+	return sessionVersion, nil
+}
+
+// Turns on two-factor authentication for a user with the secret of the authenticator app
+// and replaces the recovery codes of the user
+func (pgRepo *postgresDBRepository) EnableTwoFactorForUser(id int, secret string, recoveryCodeHashes []string) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	tx, err := pgRepo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Rollback is a no-op if the transaction has been committed
+	defer tx.Rollback()
+
+	query := `UPDATE users
+		SET two_factor_secret = $1, two_factor_enabled = true, two_factor_last_step = 0, updated_at = $2
+		WHERE id = $3`
+
+	_, err = tx.ExecContext(ctx, query, secret, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	err = replaceRecoveryCodes(ctx, tx, id, recoveryCodeHashes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Turns off two-factor authentication for a user and deletes the recovery codes of the user
+func (pgRepo *postgresDBRepository) DisableTwoFactorForUser(id int) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	tx, err := pgRepo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Rollback is a no-op if the transaction has been committed
+	defer tx.Rollback()
+
+	query := `UPDATE users
+		SET two_factor_secret = '', two_factor_enabled = false, two_factor_last_step = 0, updated_at = $1
+		WHERE id = $2`
+
+	_, err = tx.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	err = replaceRecoveryCodes(ctx, tx, id, nil)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Stores the step of an accepted authenticator code. Returns `repository.ErrTwoFactorCodeUsed`
+// if a code of the same or a later step has already been accepted
+func (pgRepo *postgresDBRepository) UpdateTwoFactorLastStepForUser(id int, step int64) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE users
+		SET two_factor_last_step = $1, updated_at = $2
+		WHERE id = $3 AND two_factor_last_step < $1`
+
+	result, err := pgRepo.DB.ExecContext(ctx, query, step, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return repository.ErrTwoFactorCodeUsed
+	}
+
+	return nil
+}
+
+// Replaces the recovery codes of a user
+func (pgRepo *postgresDBRepository) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	tx, err := pgRepo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Rollback is a no-op if the transaction has been committed
+	defer tx.Rollback()
+
+	err = replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Marks an unused recovery code of a user as used. Returns `repository.ErrTwoFactorCodeUsed`
+// if the user has no such unused code
+func (pgRepo *postgresDBRepository) UseRecoveryCode(userID int, codeHash string) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE recovery_codes
+		SET used_at = $1, updated_at = $1
+		WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL`
+
+	result, err := pgRepo.DB.ExecContext(ctx, query, time.Now(), userID, codeHash)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return repository.ErrTwoFactorCodeUsed
+	}
+
+	return nil
+}
+
+// Counts the recovery codes a user has left
+func (pgRepo *postgresDBRepository) CountUnusedRecoveryCodes(userID int) (int, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `SELECT count(id)
+		FROM recovery_codes
+		WHERE user_id = $1 AND used_at IS NULL`
+
+	var count int
+
+	err := pgRepo.DB.QueryRowContext(ctx, query, userID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Deletes the recovery codes of a user and stores the given ones within a transaction
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, codeHashes []string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO recovery_codes (user_id, code_hash, created_at, updated_at)
+		VALUES ($1, $2, $3, $4)`
+
+	for _, codeHash := range codeHashes {
+		_, err = tx.ExecContext(ctx, query, userID, codeHash, time.Now(), time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Gets the failed logins counted under a key
+func (pgRepo *postgresDBRepository) GetLoginThrottle(key string) (models.LoginThrottle, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `SELECT key, failures, last_failure_at, locked_until
+		FROM login_throttles
+		WHERE key = $1`
+
+	return scanLoginThrottle(pgRepo.DB.QueryRowContext(ctx, query, key))
+}
+
+// Counts a failed login under a key. Failures are counted from 1 again if the last one
+// is older than `window`
+func (pgRepo *postgresDBRepository) RecordLoginFailure(key string, at time.Time, window time.Duration) (models.LoginThrottle, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `INSERT INTO login_throttles (key, failures, last_failure_at, created_at, updated_at)
+		VALUES ($1, 1, $2, $2, $2)
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE WHEN login_throttles.last_failure_at < $3 THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failure_at = $2, updated_at = $2
+		RETURNING key, failures, last_failure_at, locked_until`
+
+	return scanLoginThrottle(pgRepo.DB.QueryRowContext(ctx, query, key, at, at.Add(-window)))
+}
+
+// Locks a key until the given time
+func (pgRepo *postgresDBRepository) LockLoginThrottle(key string, until time.Time) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE login_throttles
+		SET locked_until = $1, updated_at = $2
+		WHERE key = $3`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, until, time.Now(), key)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Forgets the failed logins counted under a key, which also unlocks it
+func (pgRepo *postgresDBRepository) DeleteLoginThrottle(key string) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `DELETE FROM login_throttles WHERE key = $1`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, key)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Gets the keys which are locked at the given time, sorted by key
+func (pgRepo *postgresDBRepository) GetLockedLoginThrottles(now time.Time) ([]models.LoginThrottle, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var throttles []models.LoginThrottle
+
+	query := `SELECT key, failures, last_failure_at, locked_until
+		FROM login_throttles
+		WHERE locked_until > $1
+		ORDER BY key`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query, now)
+	if err != nil {
+		return throttles, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		throttle, err := scanLoginThrottle(rows)
+		if err != nil {
+			return throttles, err
+		}
+
+		throttles = append(throttles, throttle)
+	}
+
+	if err = rows.Err(); err != nil {
+		return throttles, err
+	}
+
+	return throttles, nil
+}
+
+// Forgets the failed logins whose last failure is older than `window` and which aren't locked at the given time.
+// Returns how many keys were forgotten
+func (pgRepo *postgresDBRepository) DeleteExpiredLoginThrottles(now time.Time, window time.Duration) (int, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `DELETE FROM login_throttles
+		WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until <= $2)`
+
+	result, err := pgRepo.DB.ExecContext(ctx, query, now.Add(-window), now)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(deleted), nil
+}
+
+// Records a login, whether it succeeded or not
+func (pgRepo *postgresDBRepository) InsertLoginEvent(event models.LoginEvent) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	// Logins to emails without a user are recorded without one
+	var userID sql.NullInt64
+	if event.UserID != 0 {
+		userID = sql.NullInt64{Int64: int64(event.UserID), Valid: true}
+	}
+
+	query := `INSERT INTO login_events (email, user_id, ip_address, user_agent, success, reason, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := pgRepo.DB.ExecContext(
+		ctx,
+		query,
+		event.Email,
+		userID,
+		event.IPAddress,
+		event.UserAgent,
+		event.Success,
+		event.Reason,
+		time.Now(),
+		time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Gets the latest logins, newest first
+func (pgRepo *postgresDBRepository) GetLoginEvents(limit int) ([]models.LoginEvent, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var events []models.LoginEvent
+
+	query := `SELECT id, email, user_id, ip_address, user_agent, success, reason, created_at
+		FROM login_events
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return events, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.LoginEvent
+		var userID sql.NullInt64
+
+		err := rows.Scan(
+			&event.ID,
+			&event.Email,
+			&userID,
+			&event.IPAddress,
+			&event.UserAgent,
+			&event.Success,
+			&event.Reason,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return events, err
+		}
+
+		event.UserID = int(userID.Int64)
+
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return events, err
+	}
+
+	return events, nil
+}
+
+// Stores an entry of the audit log
+func (pgRepo *postgresDBRepository) InsertAuditEntry(entry models.AuditEntry) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+
+	// Changes made through the admin API have no user, and changes made in the admin dashboard have no API key
+	var userID, apiKeyID sql.NullInt64
+	if entry.UserID != 0 {
+		userID = sql.NullInt64{Int64: int64(entry.UserID), Valid: true}
+	}
+
+	if entry.ApiKeyID != 0 {
+		apiKeyID = sql.NullInt64{Int64: int64(entry.ApiKeyID), Valid: true}
+	}
+
+	query := `INSERT INTO audit_log (user_id, api_key_id, action, entity_type, entity_id, changes, ip_address, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err = pgRepo.DB.ExecContext(
+		ctx,
+		query,
+		userID,
+		apiKeyID,
+		entry.Action,
+		entry.EntityType,
+		entry.EntityID,
+		string(changes),
+		entry.IPAddress,
+		time.Now(),
+		time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Gets the entries of the audit log matching the filter, newest first
+func (pgRepo *postgresDBRepository) GetAuditEntries(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var entries []models.AuditEntry
+
+	// Zero values of the filter are passed as NULL, which matches every entry
+	var from, to sql.NullTime
+	if !filter.From.IsZero() {
+		from = sql.NullTime{Time: filter.From, Valid: true}
+	}
+
+	if !filter.To.IsZero() {
+		to = sql.NullTime{Time: filter.To.AddDate(0, 0, 1), Valid: true}
+	}
+
+	var limit sql.NullInt64
+	if filter.Limit > 0 {
+		limit = sql.NullInt64{Int64: int64(filter.Limit), Valid: true}
+	}
+
+	query := `SELECT a.id, a.user_id, COALESCE(u.first_name || ' ' || u.last_name, ''), a.api_key_id,
+		a.action, a.entity_type, a.entity_id, a.changes, a.ip_address, a.created_at
+		FROM audit_log a
+		LEFT JOIN users u ON (u.id = a.user_id)
+		WHERE ($1::varchar = '' OR a.entity_type = $1)
+		AND ($2::integer = 0 OR a.entity_id = $2)
+		AND ($3::integer = 0 OR a.user_id = $3)
+		AND ($4::timestamp IS NULL OR a.created_at >= $4)
+		AND ($5::timestamp IS NULL OR a.created_at < $5)
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT $6`
+
+	rows, err := pgRepo.DB.QueryContext(
+		ctx,
+		query,
+		filter.EntityType,
+		filter.EntityID,
+		filter.UserID,
+		from,
+		to,
+		limit,
+	)
+	if err != nil {
+		return entries, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.AuditEntry
+		var userID, apiKeyID sql.NullInt64
+		var changes string
+
+		err := rows.Scan(
+			&entry.ID,
+			&userID,
+			&entry.UserName,
+			&apiKeyID,
+			&entry.Action,
+			&entry.EntityType,
+			&entry.EntityID,
+			&changes,
+			&entry.IPAddress,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return entries, err
+		}
+
+		entry.UserID = int(userID.Int64)
+		entry.ApiKeyID = int(apiKeyID.Int64)
+
+		err = json.Unmarshal([]byte(changes), &entry.Changes)
+		if err != nil {
+			return entries, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return entries, err
+	}
+
+	return entries, nil
+}
+
+// Gets a session by its token, whether it has expired or not
+func (pgRepo *postgresDBRepository) GetSession(token string) (models.Session, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `SELECT token, data, expiry, user_id, ip_address, user_agent, created_at, updated_at
+		FROM sessions
+		WHERE token = $1`
+
+	return scanSession(pgRepo.DB.QueryRowContext(ctx, query, token))
+}
+
+// Stores a session, replacing the data of a session with the same token
+func (pgRepo *postgresDBRepository) SaveSession(session models.Session) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	// Sessions of visitors who aren't logged in are stored without a user
+	var userID sql.NullInt64
+	if session.UserID != 0 {
+		userID = sql.NullInt64{Int64: int64(session.UserID), Valid: true}
+	}
+
+	// Revoked sessions aren't saved again, e.g. by a request of the session which was still running
+	query := `INSERT INTO sessions (token, data, expiry, user_id, ip_address, user_agent, created_at, updated_at)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $7
+		WHERE NOT EXISTS (SELECT 1 FROM revoked_sessions WHERE token = $1)
+		ON CONFLICT (token) DO UPDATE
+		SET data = $2, expiry = $3, user_id = $4, ip_address = $5, user_agent = $6, updated_at = $7`
+
+	_, err := pgRepo.DB.ExecContext(
+		ctx,
+		query,
+		session.Token,
+		session.Data,
+		session.Expiry,
+		userID,
+		session.IPAddress,
+		session.UserAgent,
+		time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Deletes a session, which logs its user out
+func (pgRepo *postgresDBRepository) DeleteSession(token string) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `DELETE FROM sessions WHERE token = $1`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, token)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Deletes a session and remembers its token until it expires, so that the session can't be saved again
+func (pgRepo *postgresDBRepository) RevokeSession(token string, expiry time.Time) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	tx, err := pgRepo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Rollback is a no-op if the transaction has been committed
+	defer tx.Rollback()
+
+	query := `INSERT INTO revoked_sessions (token, expiry, created_at, updated_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (token) DO NOTHING`
+
+	_, err = tx.ExecContext(ctx, query, token, expiry, time.Now())
+	if err != nil {
+		return err
+	}
+
+	query = `DELETE FROM sessions WHERE token = $1`
+
+	_, err = tx.ExecContext(ctx, query, token)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Deletes the sessions which expired before the given time. Returns the number of deleted sessions
+func (pgRepo *postgresDBRepository) DeleteExpiredSessions(now time.Time) (int, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `DELETE FROM sessions WHERE expiry <= $1`
+
+	result, err := pgRepo.DB.ExecContext(ctx, query, now)
+	if err != nil {
+		return 0, err
+	}
+
+	// Revoked tokens only have to be remembered while their session could still be used
+	query = `DELETE FROM revoked_sessions WHERE expiry <= $1`
+
+	_, err = pgRepo.DB.ExecContext(ctx, query, now)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(deleted), nil
+}
+
+// Gets the sessions of a user which haven't expired at the given time, most recently used first
+func (pgRepo *postgresDBRepository) GetSessionsForUser(userID int, now time.Time) ([]models.Session, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var sessions []models.Session
+
+	query := `SELECT token, data, expiry, user_id, ip_address, user_agent, created_at, updated_at
+		FROM sessions
+		WHERE user_id = $1 AND expiry > $2
+		ORDER BY updated_at DESC`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query, userID, now)
+	if err != nil {
+		return sessions, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return sessions, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return sessions, err
+	}
+
+	return sessions, nil
+}
+
+// Scans a row of the `sessions` table, selected in the order of `GetSession`
+func scanSession(row rowScanner) (models.Session, error) {
+	var session models.Session
+	var userID sql.NullInt64
+
+	err := row.Scan(
+		&session.Token,
+		&session.Data,
+		&session.Expiry,
+		&userID,
+		&session.IPAddress,
+		&session.UserAgent,
+		&session.CreatedAt,
+		&session.UpdatedAt,
+	)
+
+	session.UserID = int(userID.Int64)
+
+	return session, err
+}
+
+// Scans a row of the `login_throttles` table
+func scanLoginThrottle(row rowScanner) (models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	var lockedUntil sql.NullTime
+
+	err := row.Scan(
+		&throttle.Key,
+		&throttle.Failures,
+		&throttle.LastFailureAt,
+		&lockedUntil,
+	)
+
+	throttle.LockedUntil = lockedUntil.Time
+
+	return throttle, err
+}
+
+// Scans a row of the `users` table, selected in the order of `GetUserByID`
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+
+	err := row.Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.Email,
+		&user.Password,
+		&user.AccessLevel,
+		&user.Disabled,
+		&user.PasswordResetRequired,
+		&user.SessionVersion,
+		&user.TwoFactorSecret,
+		&user.TwoFactorEnabled,
+		&user.TwoFactorLastStep,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+
+	return user, err
+}
+
+// Gets a list of all reservations
+func (pgRepo *postgresDBRepository) GetAllReservations() ([]models.Reservation, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, 
+		r.end_date, r.room_id, r.adults, r.children, r.created_at, r.updated_at, r.processed, r.total_price, rm.id, rm.room_name
+		FROM reservations r
+		LEFT JOIN rooms rm ON (r.room_id = rm.id)
+		ORDER BY r.start_date ASC`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query)
+	if err != nil {
+		return reservations, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var reservation models.Reservation
+
+		err := rows.Scan(
+			&reservation.ID,
+			&reservation.FirstName,
+			&reservation.LastName,
+			&reservation.Email,
+			&reservation.Phone,
+			&reservation.StartDate,
+			&reservation.EndDate,
+			&reservation.RoomID,
+			&reservation.Adults,
+			&reservation.Children,
+			&reservation.CreatedAt,
+			&reservation.UpdatedAt,
+			&reservation.Processed,
+			&reservation.TotalPrice,
+			&reservation.Room.ID,
+			&reservation.Room.RoomName,
+		)
+		if err != nil {
+			return reservations, nil
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
+
+// Gets a list of all new reservations (not yet processed)
+func (pgRepo *postgresDBRepository) GetNewReservations() ([]models.Reservation, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, 
+		r.end_date, r.room_id, r.adults, r.children, r.created_at, r.updated_at, r.total_price, rm.id, rm.room_name
+		FROM reservations r
+		LEFT JOIN rooms rm ON (r.room_id = rm.id)
+		WHERE processed = false
+		ORDER BY r.start_date ASC`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query)
+	if err != nil {
+		return reservations, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var reservation models.Reservation
+
+		err := rows.Scan(
+			&reservation.ID,
+			&reservation.FirstName,
+			&reservation.LastName,
+			&reservation.Email,
+			&reservation.Phone,
+			&reservation.StartDate,
+			&reservation.EndDate,
+			&reservation.RoomID,
+			&reservation.Adults,
+			&reservation.Children,
+			&reservation.CreatedAt,
+			&reservation.UpdatedAt,
+			&reservation.TotalPrice,
+			&reservation.Room.ID,
+			&reservation.Room.RoomName,
+		)
+		if err != nil {
+			return reservations, nil
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
+
+// Gets a reservation that matches given id
+func (pgRepo *postgresDBRepository) GetReservationByID(id int) (models.Reservation, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var reservation models.Reservation
+	var priceBreakdown string
+
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date,
+		r.end_date, r.room_id, r.adults, r.children, r.created_at, r.updated_at, r.processed, r.total_price, r.price_breakdown,
+		r.confirmation_code, r.cancelled, r.email_opt_out, COALESCE(r.booking_id, 0), rm.id, rm.room_name
+		FROM reservations r
+		LEFT JOIN rooms rm ON (r.room_id = rm.id)
+		WHERE r.id = $1`
+
+	err := pgRepo.DB.QueryRowContext(
+		ctx, 
+		query,
+		id,
+	).Scan(
+		&reservation.ID,
+		&reservation.FirstName,
+		&reservation.LastName,
+		&reservation.Email,
+		&reservation.Phone,
+		&reservation.StartDate,
+		&reservation.EndDate,
+		&reservation.RoomID,
+		&reservation.Adults,
+		&reservation.Children,
+		&reservation.CreatedAt,
+		&reservation.UpdatedAt,
+		&reservation.Processed,
+		&reservation.TotalPrice,
+		&priceBreakdown,
+		&reservation.ConfirmationCode,
+		&reservation.Cancelled,
+		&reservation.EmailOptOut,
+		&reservation.BookingID,
+		&reservation.Room.ID,
+		&reservation.Room.RoomName,
+	)
+	if err != nil {
+		return reservation, err
+	}
+
+	err = json.Unmarshal([]byte(priceBreakdown), &reservation.Quote)
+	if err != nil {
+		return reservation, err
+	}
+
+	return reservation, nil
+}
+
+// Gets a reservation by its confirmation code and the email address of the guest
+func (pgRepo *postgresDBRepository) GetReservationByConfirmationCode(code, email string) (models.Reservation, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var reservation models.Reservation
+
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date,
+		r.end_date, r.room_id, r.adults, r.children, r.created_at, r.updated_at, r.processed, r.total_price, r.confirmation_code,
+		r.cancelled, r.email_opt_out, rm.id, rm.room_name
+		FROM reservations r
+		LEFT JOIN rooms rm ON (r.room_id = rm.id)
+		WHERE r.confirmation_code = upper($1) AND lower(r.email) = lower($2)`
+
+	err := pgRepo.DB.QueryRowContext(
+		ctx, 
+		query,
+		code,
+		email,
+	).Scan(
+		&reservation.ID,
+		&reservation.FirstName,
+		&reservation.LastName,
+		&reservation.Email,
+		&reservation.Phone,
+		&reservation.StartDate,
+		&reservation.EndDate,
+		&reservation.RoomID,
+		&reservation.Adults,
+		&reservation.Children,
+		&reservation.CreatedAt,
+		&reservation.UpdatedAt,
+		&reservation.Processed,
+		&reservation.TotalPrice,
+		&reservation.ConfirmationCode,
+		&reservation.Cancelled,
+		&reservation.EmailOptOut,
+		&reservation.Room.ID,
+		&reservation.Room.RoomName,
+	)
+	if err != nil {
+		return reservation, err
+	}
+
+	return reservation, nil
+}
+
+// Gets all bookings of several rooms, the most recent first, together with their reservations
+func (pgRepo *postgresDBRepository) GetBookings() ([]models.Booking, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var bookings []models.Booking
+
+	// The total is summed up from the reservations, so that cancelling or moving one of them is reflected in it
+	query := `SELECT b.id, b.confirmation_code, b.first_name, b.last_name, b.email, b.phone,
+		(SELECT COALESCE(SUM(r.total_price), 0) FROM reservations r WHERE r.booking_id = b.id AND NOT r.cancelled),
+		b.created_at, b.updated_at
+		FROM bookings b
+		ORDER BY b.created_at DESC, b.id DESC`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query)
+	if err != nil {
+		return bookings, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		booking, err := scanBooking(rows)
+		if err != nil {
+			return bookings, err
+		}
+
+		bookings = append(bookings, booking)
+	}
+
+	if err = rows.Err(); err != nil {
+		return bookings, err
+	}
+
+	// Get the reservations of all bookings at once and hand them out to their bookings
+	query = `SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date,
+		r.end_date, r.room_id, r.adults, r.children, r.created_at, r.updated_at, r.processed, r.total_price, r.confirmation_code,
+		r.cancelled, r.email_opt_out, rm.id, rm.room_name, r.booking_id
+		FROM reservations r
+		LEFT JOIN rooms rm ON (r.room_id = rm.id)
+		WHERE r.booking_id IS NOT NULL
+		ORDER BY r.start_date, rm.room_name`
+
+	rows, err = pgRepo.DB.QueryContext(ctx, query)
+	if err != nil {
+		return bookings, err
+	}
+
+	defer rows.Close()
+
+	reservationsByBooking := make(map[int][]models.Reservation)
+
+	for rows.Next() {
+		var reservation models.Reservation
+
+		err := rows.Scan(
+			&reservation.ID,
+			&reservation.FirstName,
+			&reservation.LastName,
+			&reservation.Email,
+			&reservation.Phone,
+			&reservation.StartDate,
+			&reservation.EndDate,
+			&reservation.RoomID,
+			&reservation.Adults,
+			&reservation.Children,
+			&reservation.CreatedAt,
+			&reservation.UpdatedAt,
+			&reservation.Processed,
+			&reservation.TotalPrice,
+			&reservation.ConfirmationCode,
+			&reservation.Cancelled,
+			&reservation.EmailOptOut,
+			&reservation.Room.ID,
+			&reservation.Room.RoomName,
+			&reservation.BookingID,
+		)
+		if err != nil {
+			return bookings, err
+		}
+
+		reservationsByBooking[reservation.BookingID] = append(reservationsByBooking[reservation.BookingID], reservation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return bookings, err
+	}
+
+	for i := range bookings {
+		bookings[i].Reservations = reservationsByBooking[bookings[i].ID]
+	}
+
+	return bookings, nil
+}
+
+// Gets a booking of several rooms by id, together with its reservations
+func (pgRepo *postgresDBRepository) GetBookingByID(id int) (models.Booking, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	// The total is summed up from the reservations, so that cancelling or moving one of them is reflected in it
+	query := `SELECT b.id, b.confirmation_code, b.first_name, b.last_name, b.email, b.phone,
+		(SELECT COALESCE(SUM(r.total_price), 0) FROM reservations r WHERE r.booking_id = b.id AND NOT r.cancelled),
+		b.created_at, b.updated_at
+		FROM bookings b
+		WHERE b.id = $1`
+
+	booking, err := scanBooking(pgRepo.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return booking, err
+	}
+
+	query = `SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date,
+		r.end_date, r.room_id, r.adults, r.children, r.created_at, r.updated_at, r.processed, r.total_price, r.confirmation_code,
+		r.cancelled, r.email_opt_out, rm.id, rm.room_name
+		FROM reservations r
+		LEFT JOIN rooms rm ON (r.room_id = rm.id)
+		WHERE r.booking_id = $1
+		ORDER BY r.start_date, rm.room_name`
+
+	booking.Reservations, err = queryReservations(ctx, pgRepo.DB, query, id)
+	if err != nil {
+		return booking, err
+	}
+
+	for i := range booking.Reservations {
+		booking.Reservations[i].BookingID = booking.ID
+	}
+
+	return booking, nil
+}
+
+// Moves a reservation and its room restriction to new dates and queues the given emails in a single transaction.
+// The reservation's own restriction is ignored when checking availability, so the new dates may
+// overlap the old ones. `repository.ErrRoomNotAvailable` is returned if the room is taken
+func (pgRepo *postgresDBRepository) UpdateReservationDates(reservation models.Reservation, emails []models.MailData) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	tx, err := pgRepo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Rollback is a no-op if the transaction has been committed
+	defer tx.Rollback()
+
+	// Lock the room so that concurrent bookings of the same room are handled one at a time
+	query := `SELECT id FROM rooms WHERE id = $1 FOR UPDATE`
+
+	var roomID int
+
+	err = tx.QueryRowContext(ctx, query, reservation.RoomID).Scan(&roomID)
+	if err != nil {
+		return err
+	}
+
+	// Make sure the room is available for the new dates
+	query = `SELECT count(id)
+						FROM room_restrictions
+						WHERE room_id = $1
+						AND $2 < end_date AND $3 > start_date
+						AND (reservation_id IS NULL OR reservation_id <> $4)`
+
+	var numExistingRestrictions int
+
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		reservation.RoomID,
+		reservation.StartDate,
+		reservation.EndDate,
+		reservation.ID,
+	).Scan(&numExistingRestrictions)
+	if err != nil {
+		return err
+	}
+
+	if numExistingRestrictions > 0 {
+		return repository.ErrRoomNotAvailable
+	}
+
+	priceBreakdown, err := json.Marshal(reservation.Quote)
+	if err != nil {
+		return err
+	}
+
+	query = `UPDATE reservations
+		SET start_date = $1, end_date = $2, total_price = $3, price_breakdown = $4, updated_at = $5
+		WHERE id = $6`
+
+	_, err = tx.ExecContext(
+		ctx,
+		query,
+		reservation.StartDate,
+		reservation.EndDate,
+		reservation.TotalPrice,
+		priceBreakdown,
+		time.Now(),
+		reservation.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	query = `UPDATE room_restrictions
+		SET start_date = $1, end_date = $2, updated_at = $3
+		WHERE reservation_id = $4`
+
+	_, err = tx.ExecContext(
+		ctx,
+		query,
+		reservation.StartDate,
+		reservation.EndDate,
+		time.Now(),
+		reservation.ID,
+	)
+	if err != nil {
+		return translateOverlapError(err)
+	}
+
+	err = insertOutboxEmails(ctx, tx, emails)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Marks a reservation as cancelled, frees its dates by removing its room restriction and queues the given emails
+func (pgRepo *postgresDBRepository) CancelReservation(id int, emails []models.MailData) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	tx, err := pgRepo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Rollback is a no-op if the transaction has been committed
+	defer tx.Rollback()
+
+	query := `UPDATE reservations
+		SET cancelled = true, updated_at = $1
+		WHERE id = $2`
+
+	_, err = tx.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	query = `DELETE FROM room_restrictions
+		WHERE reservation_id = $1`
+
+	_, err = tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	err = insertOutboxEmails(ctx, tx, emails)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Updates a reservation
+func (pgRepo *postgresDBRepository) UpdateReservation(reservation models.Reservation) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE reservations
+		SET first_name = $1, last_name = $2, email = $3, phone = $4, updated_at = $5
+		WHERE id = $6`
+
+	_, err := pgRepo.DB.ExecContext(
+		ctx, 
+		query, 
+		reservation.FirstName, 
+		reservation.LastName,
+		reservation.Email,
+		reservation.Phone,
+		time.Now(),
+		reservation.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Deletes a reservation
+func (pgRepo *postgresDBRepository) DeleteReservation(id int) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `DELETE FROM reservations WHERE id = $1`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Updates processed status of a reservation with given id
+func (pgRepo *postgresDBRepository) UpdateProcessedForReservation(id int, processed bool) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE reservations
+		SET processed = $1
+		WHERE id = $2`
+
+	_, err := pgRepo.DB.ExecContext(
+		ctx, 
+		query, 
+		processed,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Gets all rooms
+func (pgRepo *postgresDBRepository) GetAllRooms() ([]models.Room, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var rooms []models.Room
+
+	query := `SELECT id, room_name, slug, description, capacity, bed_configuration, amenities, image, active, sort_order, created_at, updated_at
+		FROM rooms
+		ORDER BY sort_order, room_name`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query)
+	if err != nil {
+		return rooms, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var room models.Room
+
+		err := rows.Scan(
+			&room.ID,
+			&room.RoomName,
+			&room.Slug,
+			&room.Description,
+			&room.Capacity,
+			&room.BedConfiguration,
+			&room.Amenities,
+			&room.Image,
+			&room.Active,
+			&room.SortOrder,
+			&room.CreatedAt,
+			&room.UpdatedAt,
+		)
+		if err != nil {
+			return rooms, err
+		}
+
+		rooms = append(rooms, room)
+	}
+
+	if err = rows.Err(); err != nil {
+		return rooms, err
+	}
+
+	return rooms, nil
+}
+
+// Gets all rooms which are shown to guests
+func (pgRepo *postgresDBRepository) GetActiveRooms() ([]models.Room, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var rooms []models.Room
+
+	query := `SELECT id, room_name, slug, description, capacity, bed_configuration, amenities, image, active, sort_order, created_at, updated_at
+		FROM rooms
+		WHERE active = true
+		ORDER BY sort_order, room_name`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query)
+	if err != nil {
+		return rooms, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var room models.Room
+
+		err := rows.Scan(
+			&room.ID,
+			&room.RoomName,
+			&room.Slug,
+			&room.Description,
+			&room.Capacity,
+			&room.BedConfiguration,
+			&room.Amenities,
+			&room.Image,
+			&room.Active,
+			&room.SortOrder,
+			&room.CreatedAt,
+			&room.UpdatedAt,
+		)
+		if err != nil {
+			return rooms, err
+		}
+
+		rooms = append(rooms, room)
+	}
+
+	if err = rows.Err(); err != nil {
+		return rooms, err
+	}
+
+	return rooms, nil
+}
+
+// Gets restrictions for a given room by date range
+func (pgRepo *postgresDBRepository) GetRestrictionsForRoomByDate(roomID int, startDate, endDate time.Time) ([]models.RoomRestriction, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var restrictions []models.RoomRestriction
+
+	// The guest and party size of reservations are shown in the calendar
+	query := `SELECT rr.id, COALESCE(rr.reservation_id, 0), rr.restriction_id, rr.room_id, rr.start_date, rr.end_date,
+		rr.reason, rr.note, COALESCE(r.first_name, ''), COALESCE(r.last_name, ''), COALESCE(r.adults, 0),
+		COALESCE(r.children, 0)
+		FROM room_restrictions rr
+		LEFT JOIN reservations r ON (rr.reservation_id = r.id)
+		WHERE $1 < rr.end_date and $2 >= rr.start_date and rr.room_id = $3`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query, startDate, endDate, roomID)
+	if err != nil {
+		return restrictions, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var restriction models.RoomRestriction
+
+		err := rows.Scan(
+			&restriction.ID,
+			&restriction.ReservationID,
+			&restriction.RestrictionID,
+			&restriction.RoomID,
+			&restriction.StartDate,
+			&restriction.EndDate,
+			&restriction.Reason,
+			&restriction.Note,
+			&restriction.Reservation.FirstName,
+			&restriction.Reservation.LastName,
+			&restriction.Reservation.Adults,
+			&restriction.Reservation.Children,
+		)
+		if err != nil {
+			return restrictions, err
+		}
+
+		restrictions = append(restrictions, restriction)
+	}
+
+	if err = rows.Err(); err != nil {
+		return restrictions, err
+	}
+
+	return restrictions, nil
+}
+
+// Inserts an owner block as room restriction for given room. Returns the id of the new block
+func (pgRepo *postgresDBRepository) InsertBlockForRoom(id int, startDate time.Time) (int, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `INSERT INTO room_restrictions (start_date, end_date, room_id, restriction_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+	var blockID int
+
+	err := pgRepo.DB.QueryRowContext(ctx, query, startDate, startDate.AddDate(0, 0, 1), id, models.RestrictionOwnerBlock, time.Now(), time.Now()).Scan(&blockID)
+	if err != nil {
+		return 0, translateOverlapError(err)
+	}
+
+	return blockID, nil
+}
+
+// Deletes an owner block from room restrictions
+func (pgRepo *postgresDBRepository) DeleteBlockByID(id int) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `DELETE FROM room_restrictions
+		WHERE id = $1`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Inserts an owner block of any number of nights. Returns the id of the new block
+func (pgRepo *postgresDBRepository) InsertOwnerBlock(block models.RoomRestriction) (int, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var id int
+
+	query := `INSERT INTO room_restrictions (start_date, end_date, room_id, restriction_id, reason, note, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
+
+	err := pgRepo.DB.QueryRowContext(
+		ctx,
+		query,
+		block.StartDate,
+		block.EndDate,
+		block.RoomID,
+		models.RestrictionOwnerBlock,
+		block.Reason,
+		block.Note,
+		time.Now(),
+		time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return 0, translateOverlapError(err)
+	}
+
+	return id, nil
+}
+
+// Updates the room, dates, reason and note of an owner block
+func (pgRepo *postgresDBRepository) UpdateOwnerBlock(block models.RoomRestriction) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE room_restrictions
+		SET start_date = $1, end_date = $2, room_id = $3, reason = $4, note = $5, updated_at = $6
+		WHERE id = $7 AND restriction_id = $8`
+
+	_, err := pgRepo.DB.ExecContext(
+		ctx,
+		query,
+		block.StartDate,
+		block.EndDate,
+		block.RoomID,
+		block.Reason,
+		block.Note,
+		time.Now(),
+		block.ID,
+		models.RestrictionOwnerBlock,
+	)
+	if err != nil {
+		return translateOverlapError(err)
+	}
+
+	return nil
+}
+
+// Gets the owner blocks of all rooms which end after the given date, with their room, sorted by start date
+func (pgRepo *postgresDBRepository) GetOwnerBlocks(from time.Time) ([]models.RoomRestriction, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var blocks []models.RoomRestriction
+
+	query := `SELECT rr.id, rr.restriction_id, rr.room_id, rr.start_date, rr.end_date, rr.reason, rr.note, r.room_name
+		FROM room_restrictions rr
+		JOIN rooms r ON (rr.room_id = r.id)
+		WHERE rr.restriction_id = $1 AND rr.end_date > $2
+		ORDER BY rr.start_date, r.sort_order, rr.id`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query, models.RestrictionOwnerBlock, from)
+	if err != nil {
+		return blocks, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var block models.RoomRestriction
+
+		err := rows.Scan(
+			&block.ID,
+			&block.RestrictionID,
+			&block.RoomID,
+			&block.StartDate,
+			&block.EndDate,
+			&block.Reason,
+			&block.Note,
+			&block.Room.RoomName,
+		)
+		if err != nil {
+			return blocks, err
+		}
+
+		block.Room.ID = block.RoomID
+
+		blocks = append(blocks, block)
+	}
+
+	if err = rows.Err(); err != nil {
+		return blocks, err
+	}
+
+	return blocks, nil
+}
+
+// Gets the pricing rules (base rate, seasonal rates and length-of-stay discounts) of a given room
+func (pgRepo *postgresDBRepository) GetRatePlanForRoom(roomID int) (models.RatePlan, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var plan models.RatePlan
+
+	query := `SELECT id, room_id, nightly_rate, weekend_surcharge, created_at, updated_at
+		FROM room_rates
+		WHERE room_id = $1`
+
+	err := pgRepo.DB.QueryRowContext(ctx, query, roomID).Scan(
+		&plan.RoomRate.ID,
+		&plan.RoomRate.RoomID,
+		&plan.RoomRate.NightlyRate,
+		&plan.RoomRate.WeekendSurcharge,
+		&plan.RoomRate.CreatedAt,
+		&plan.RoomRate.UpdatedAt,
+	)
+	// A room without a base rate is free until the owner sets one
+	if errors.Is(err, sql.ErrNoRows) {
+		plan.RoomRate.RoomID = roomID
+	} else if err != nil {
+		return plan, err
+	}
+
+	query = `SELECT id, room_id, name, start_date, end_date, nightly_rate, created_at, updated_at
+		FROM seasonal_rates
+		WHERE room_id = $1
+		ORDER BY start_date ASC`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query, roomID)
+	if err != nil {
+		return plan, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var seasonalRate models.SeasonalRate
+
+		err := rows.Scan(
+			&seasonalRate.ID,
+			&seasonalRate.RoomID,
+			&seasonalRate.Name,
+			&seasonalRate.StartDate,
+			&seasonalRate.EndDate,
+			&seasonalRate.NightlyRate,
+			&seasonalRate.CreatedAt,
+			&seasonalRate.UpdatedAt,
+		)
+		if err != nil {
+			return plan, err
+		}
+
+		plan.SeasonalRates = append(plan.SeasonalRates, seasonalRate)
+	}
+
+	if err = rows.Err(); err != nil {
+		return plan, err
+	}
+
+	query = `SELECT id, room_id, min_nights, percent_off, created_at, updated_at
+		FROM stay_discounts
+		WHERE room_id = $1
+		ORDER BY min_nights ASC`
+
+	discountRows, err := pgRepo.DB.QueryContext(ctx, query, roomID)
+	if err != nil {
+		return plan, err
+	}
+
+	defer discountRows.Close()
+
+	for discountRows.Next() {
+		var discount models.StayDiscount
+
+		err := discountRows.Scan(
+			&discount.ID,
+			&discount.RoomID,
+			&discount.MinNights,
+			&discount.PercentOff,
+			&discount.CreatedAt,
+			&discount.UpdatedAt,
+		)
+		if err != nil {
+			return plan, err
+		}
+
+		plan.StayDiscounts = append(plan.StayDiscounts, discount)
+	}
+
+	if err = discountRows.Err(); err != nil {
+		return plan, err
+	}
+
+	return plan, nil
+}
+
+// Gets the stay rules of a given room which cover any date from the arrival date (`startDate`)
+// to the departure date (`endDate`), sorted by start date
+func (pgRepo *postgresDBRepository) GetStayRulesForRoom(roomID int, startDate, endDate time.Time) ([]models.StayRule, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var rules []models.StayRule
+
+	query := `SELECT sr.id, sr.room_id, sr.name, sr.start_date, sr.end_date, sr.min_nights, sr.max_nights,
+		sr.closed_to_arrival, sr.closed_to_departure, sr.arrival_days, sr.created_at, sr.updated_at, r.room_name
+		FROM stay_rules sr
+		JOIN rooms r ON (sr.room_id = r.id)
+		WHERE sr.room_id = $1 AND sr.start_date <= $3 AND sr.end_date >= $2
+		ORDER BY sr.start_date, sr.id`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query, roomID, startDate, endDate)
+	if err != nil {
+		return rules, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		rule, err := scanStayRule(rows)
+		if err != nil {
+			return rules, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if err = rows.Err(); err != nil {
+		return rules, err
+	}
+
+	return rules, nil
+}
+
+// Gets the stay rules of all rooms which end on or after the given date, with their room, sorted by start date
+func (pgRepo *postgresDBRepository) GetStayRules(from time.Time) ([]models.StayRule, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var rules []models.StayRule
+
+	query := `SELECT sr.id, sr.room_id, sr.name, sr.start_date, sr.end_date, sr.min_nights, sr.max_nights,
+		sr.closed_to_arrival, sr.closed_to_departure, sr.arrival_days, sr.created_at, sr.updated_at, r.room_name
+		FROM stay_rules sr
+		JOIN rooms r ON (sr.room_id = r.id)
+		WHERE sr.end_date >= $1
+		ORDER BY sr.start_date, r.sort_order, sr.id`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query, from)
+	if err != nil {
+		return rules, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		rule, err := scanStayRule(rows)
+		if err != nil {
+			return rules, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if err = rows.Err(); err != nil {
+		return rules, err
+	}
+
+	return rules, nil
+}
+
+// Gets a stay rule by id, with its room
+func (pgRepo *postgresDBRepository) GetStayRuleByID(id int) (models.StayRule, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `SELECT sr.id, sr.room_id, sr.name, sr.start_date, sr.end_date, sr.min_nights, sr.max_nights,
+		sr.closed_to_arrival, sr.closed_to_departure, sr.arrival_days, sr.created_at, sr.updated_at, r.room_name
+		FROM stay_rules sr
+		JOIN rooms r ON (sr.room_id = r.id)
+		WHERE sr.id = $1`
+
+	return scanStayRule(pgRepo.DB.QueryRowContext(ctx, query, id))
+}
+
+// Inserts a stay rule into the database. Returns the id of the new rule
+func (pgRepo *postgresDBRepository) InsertStayRule(rule models.StayRule) (int, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var id int
+
+	query := `INSERT INTO stay_rules (room_id, name, start_date, end_date, min_nights, max_nights,
+		closed_to_arrival, closed_to_departure, arrival_days, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id`
+
+	err := pgRepo.DB.QueryRowContext(
+		ctx,
+		query,
+		rule.RoomID,
+		rule.Name,
+		rule.StartDate,
+		rule.EndDate,
+		rule.MinNights,
+		rule.MaxNights,
+		rule.ClosedToArrival,
+		rule.ClosedToDeparture,
+		rule.ArrivalDays,
+		time.Now(),
+		time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Updates a stay rule
+func (pgRepo *postgresDBRepository) UpdateStayRule(rule models.StayRule) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE stay_rules
+		SET room_id = $1, name = $2, start_date = $3, end_date = $4, min_nights = $5, max_nights = $6,
+		closed_to_arrival = $7, closed_to_departure = $8, arrival_days = $9, updated_at = $10
+		WHERE id = $11`
+
+	_, err := pgRepo.DB.ExecContext(
+		ctx,
+		query,
+		rule.RoomID,
+		rule.Name,
+		rule.StartDate,
+		rule.EndDate,
+		rule.MinNights,
+		rule.MaxNights,
+		rule.ClosedToArrival,
+		rule.ClosedToDeparture,
+		rule.ArrivalDays,
+		time.Now(),
+		rule.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Deletes a stay rule
+func (pgRepo *postgresDBRepository) DeleteStayRule(id int) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `DELETE FROM stay_rules
+		WHERE id = $1`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Scans a row of the `stay_rules` table joined with the name of its room, selected in the order of `GetStayRuleByID`
+func scanStayRule(row rowScanner) (models.StayRule, error) {
+	var rule models.StayRule
+
+	err := row.Scan(
+		&rule.ID,
+		&rule.RoomID,
+		&rule.Name,
+		&rule.StartDate,
+		&rule.EndDate,
+		&rule.MinNights,
+		&rule.MaxNights,
+		&rule.ClosedToArrival,
+		&rule.ClosedToDeparture,
+		&rule.ArrivalDays,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+		&rule.Room.RoomName,
+	)
+
+	rule.Room.ID = rule.RoomID
+
+	return rule, err
+}
+
+// Inserts a calendar feed token into the database
+func (pgRepo *postgresDBRepository) InsertCalendarFeedToken(token models.CalendarFeedToken) (int, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `INSERT INTO calendar_feed_tokens (name, token_hash, revoked, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	var tokenID int
+
+	err := pgRepo.DB.QueryRowContext(
+		ctx,
+		query,
+		token.Name,
+		token.TokenHash,
+		false,
+		time.Now(),
+		time.Now(),
+	).Scan(&tokenID)
+	if err != nil {
+		return 0, err
+	}
+
+	return tokenID, nil
+}
+
+// Gets all calendar feed tokens, newest first
+func (pgRepo *postgresDBRepository) GetAllCalendarFeedTokens() ([]models.CalendarFeedToken, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var tokens []models.CalendarFeedToken
+
+	query := `SELECT id, name, token_hash, revoked, created_at, updated_at
+		FROM calendar_feed_tokens
+		ORDER BY created_at DESC`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query)
+	if err != nil {
+		return tokens, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var token models.CalendarFeedToken
+
+		err := rows.Scan(
+			&token.ID,
+			&token.Name,
+			&token.TokenHash,
+			&token.Revoked,
+			&token.CreatedAt,
+			&token.UpdatedAt,
+		)
+		if err != nil {
+			return tokens, err
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return tokens, err
+	}
+
+	return tokens, nil
+}
+
+// Gets a calendar feed token by the hash of the token
+func (pgRepo *postgresDBRepository) GetCalendarFeedTokenByHash(tokenHash string) (models.CalendarFeedToken, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var token models.CalendarFeedToken
+
+	query := `SELECT id, name, token_hash, revoked, created_at, updated_at
+		FROM calendar_feed_tokens
+		WHERE token_hash = $1`
+
+	err := pgRepo.DB.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.Name,
+		&token.TokenHash,
+		&token.Revoked,
+		&token.CreatedAt,
+		&token.UpdatedAt,
+	)
+	if err != nil {
+		return token, err
+	}
+
+	return token, nil
+}
+
+// Gets a calendar feed token by id
+func (pgRepo *postgresDBRepository) GetCalendarFeedTokenByID(id int) (models.CalendarFeedToken, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var token models.CalendarFeedToken
+
+	query := `SELECT id, name, token_hash, revoked, created_at, updated_at
+		FROM calendar_feed_tokens
+		WHERE id = $1`
+
+	err := pgRepo.DB.QueryRowContext(ctx, query, id).Scan(
+		&token.ID,
+		&token.Name,
+		&token.TokenHash,
+		&token.Revoked,
+		&token.CreatedAt,
+		&token.UpdatedAt,
+	)
+	if err != nil {
+		return token, err
+	}
+
+	return token, nil
+}
+
+// Revokes a calendar feed token so that it can no longer be used to read the feeds
+func (pgRepo *postgresDBRepository) RevokeCalendarFeedToken(id int) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE calendar_feed_tokens
+		SET revoked = true, updated_at = $1
+		WHERE id = $2`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Gets all calendar import sources together with the name of their room
+func (pgRepo *postgresDBRepository) GetAllCalendarImportSources() ([]models.CalendarImportSource, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var sources []models.CalendarImportSource
+
+	query := `SELECT s.id, s.room_id, s.name, s.url, s.last_synced_at, s.last_sync_status, s.created_at, s.updated_at,
+		r.id, r.room_name
+		FROM calendar_import_sources s
+		LEFT JOIN rooms r ON (s.room_id = r.id)
+		ORDER BY r.sort_order, r.room_name, s.name`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query)
+	if err != nil {
+		return sources, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var source models.CalendarImportSource
+		var lastSyncedAt sql.NullTime
+
+		err := rows.Scan(
+			&source.ID,
+			&source.RoomID,
+			&source.Name,
+			&source.URL,
+			&lastSyncedAt,
+			&source.LastSyncStatus,
+			&source.CreatedAt,
+			&source.UpdatedAt,
+			&source.Room.ID,
+			&source.Room.RoomName,
+		)
+		if err != nil {
+			return sources, err
+		}
+
+		// Sources which have never been synced keep a zero time
+		source.LastSyncedAt = lastSyncedAt.Time
+
+		sources = append(sources, source)
+	}
+
+	if err = rows.Err(); err != nil {
+		return sources, err
+	}
+
+	return sources, nil
+}
+
+// Gets a calendar import source by id
+func (pgRepo *postgresDBRepository) GetCalendarImportSourceByID(id int) (models.CalendarImportSource, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var source models.CalendarImportSource
+	var lastSyncedAt sql.NullTime
+
+	query := `SELECT s.id, s.room_id, s.name, s.url, s.last_synced_at, s.last_sync_status, s.created_at, s.updated_at,
+		r.id, r.room_name
+		FROM calendar_import_sources s
+		LEFT JOIN rooms r ON (s.room_id = r.id)
+		WHERE s.id = $1`
+
+	err := pgRepo.DB.QueryRowContext(ctx, query, id).Scan(
+		&source.ID,
+		&source.RoomID,
+		&source.Name,
+		&source.URL,
+		&lastSyncedAt,
+		&source.LastSyncStatus,
+		&source.CreatedAt,
+		&source.UpdatedAt,
+		&source.Room.ID,
+		&source.Room.RoomName,
+	)
+	if err != nil {
+		return source, err
+	}
+
+	source.LastSyncedAt = lastSyncedAt.Time
+
+	return source, nil
+}
+
+// Inserts a calendar import source into the database
+func (pgRepo *postgresDBRepository) InsertCalendarImportSource(source models.CalendarImportSource) (int, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `INSERT INTO calendar_import_sources (room_id, name, url, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	var sourceID int
+
+	err := pgRepo.DB.QueryRowContext(
+		ctx,
+		query,
+		source.RoomID,
+		source.Name,
+		source.URL,
+		time.Now(),
+		time.Now(),
+	).Scan(&sourceID)
+	if err != nil {
+		return 0, err
+	}
+
+	return sourceID, nil
+}
+
+// Deletes a calendar import source. Its external bookings are deleted by the database
+func (pgRepo *postgresDBRepository) DeleteCalendarImportSource(id int) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `DELETE FROM calendar_import_sources
+		WHERE id = $1`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Stores the time and the outcome of the last sync of a calendar import source
+func (pgRepo *postgresDBRepository) UpdateCalendarImportSourceStatus(id int, syncedAt time.Time, status string) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE calendar_import_sources
+		SET last_synced_at = $1, last_sync_status = $2, updated_at = $3
+		WHERE id = $4`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, syncedAt, status, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Gets the external bookings imported from a calendar import source
+func (pgRepo *postgresDBRepository) GetRestrictionsForSource(sourceID int) ([]models.RoomRestriction, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var restrictions []models.RoomRestriction
+
+	query := `SELECT id, restriction_id, room_id, start_date, end_date, source_id, external_uid
+		FROM room_restrictions
+		WHERE source_id = $1`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query, sourceID)
+	if err != nil {
+		return restrictions, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var restriction models.RoomRestriction
+
+		err := rows.Scan(
+			&restriction.ID,
+			&restriction.RestrictionID,
+			&restriction.RoomID,
+			&restriction.StartDate,
+			&restriction.EndDate,
+			&restriction.SourceID,
+			&restriction.ExternalUID,
+		)
+		if err != nil {
+			return restrictions, err
+		}
+
+		restrictions = append(restrictions, restriction)
+	}
+
+	if err = rows.Err(); err != nil {
+		return restrictions, err
+	}
+
+	return restrictions, nil
+}
+
+// Inserts an external booking imported from a calendar import source.
+// Returns `repository.ErrRoomNotAvailable` if the dates overlap another restriction of the room
+func (pgRepo *postgresDBRepository) InsertExternalBlock(restriction models.RoomRestriction) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `INSERT INTO room_restrictions (start_date, end_date, room_id, restriction_id, source_id, external_uid,
+		created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := pgRepo.DB.ExecContext(
+		ctx,
+		query,
+		restriction.StartDate,
+		restriction.EndDate,
+		restriction.RoomID,
+		models.RestrictionExternalBooking,
+		restriction.SourceID,
+		restriction.ExternalUID,
+		time.Now(),
+		time.Now(),
+	)
+	if err != nil {
+		return translateOverlapError(err)
+	}
+
+	return nil
+}
+
+// Moves an external booking to new dates.
+// Returns `repository.ErrRoomNotAvailable` if the new dates overlap another restriction of the room
+func (pgRepo *postgresDBRepository) UpdateExternalBlockDates(id int, startDate, endDate time.Time) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE room_restrictions
+		SET start_date = $1, end_date = $2, updated_at = $3
+		WHERE id = $4 AND restriction_id = $5`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, startDate, endDate, time.Now(), id, models.RestrictionExternalBooking)
+	if err != nil {
+		return translateOverlapError(err)
+	}
+
+	return nil
+}
+
+// Gets a room restriction by id
+func (pgRepo *postgresDBRepository) GetRoomRestrictionByID(id int) (models.RoomRestriction, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var restriction models.RoomRestriction
+
+	query := `SELECT id, COALESCE(reservation_id, 0), restriction_id, room_id, start_date, end_date,
+		COALESCE(source_id, 0), external_uid, reason, note
+		FROM room_restrictions
+		WHERE id = $1`
+
+	err := pgRepo.DB.QueryRowContext(ctx, query, id).Scan(
+		&restriction.ID,
+		&restriction.ReservationID,
+		&restriction.RestrictionID,
+		&restriction.RoomID,
+		&restriction.StartDate,
+		&restriction.EndDate,
+		&restriction.SourceID,
+		&restriction.ExternalUID,
+		&restriction.Reason,
+		&restriction.Note,
+	)
+	if err != nil {
+		return restriction, err
+	}
+
+	return restriction, nil
+}
+
+// Inserts an API key into the database
+func (pgRepo *postgresDBRepository) InsertApiKey(key models.ApiKey) (int, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `INSERT INTO api_keys (name, key_hash, scopes, rate_limit, revoked, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`
+
+	var keyID int
+
+	err := pgRepo.DB.QueryRowContext(
+		ctx,
+		query,
+		key.Name,
+		key.KeyHash,
+		key.Scopes,
+		key.RateLimit,
+		false,
+		time.Now(),
+		time.Now(),
+	).Scan(&keyID)
+	if err != nil {
+		return 0, err
+	}
+
+	return keyID, nil
+}
+
+// Gets all API keys, newest first
+func (pgRepo *postgresDBRepository) GetAllApiKeys() ([]models.ApiKey, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var keys []models.ApiKey
+
+	query := `SELECT id, name, key_hash, scopes, rate_limit, last_used_at, revoked, created_at, updated_at
+		FROM api_keys
+		ORDER BY created_at DESC`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query)
+	if err != nil {
+		return keys, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key models.ApiKey
+		var lastUsedAt sql.NullTime
+
+		err := rows.Scan(
+			&key.ID,
+			&key.Name,
+			&key.KeyHash,
+			&key.Scopes,
+			&key.RateLimit,
+			&lastUsedAt,
+			&key.Revoked,
+			&key.CreatedAt,
+			&key.UpdatedAt,
+		)
+		if err != nil {
+			return keys, err
+		}
+
+		// Keys which have never been used keep a zero time
+		key.LastUsedAt = lastUsedAt.Time
+
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return keys, err
+	}
+
+	return keys, nil
+}
+
+// Gets an API key by the hash of the key
+func (pgRepo *postgresDBRepository) GetApiKeyByHash(keyHash string) (models.ApiKey, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var key models.ApiKey
+	var lastUsedAt sql.NullTime
+
+	query := `SELECT id, name, key_hash, scopes, rate_limit, last_used_at, revoked, created_at, updated_at
+		FROM api_keys
+		WHERE key_hash = $1`
+
+	err := pgRepo.DB.QueryRowContext(ctx, query, keyHash).Scan(
+		&key.ID,
+		&key.Name,
+		&key.KeyHash,
+		&key.Scopes,
+		&key.RateLimit,
+		&lastUsedAt,
+		&key.Revoked,
+		&key.CreatedAt,
+		&key.UpdatedAt,
+	)
+	if err != nil {
+		return key, err
+	}
+
+	key.LastUsedAt = lastUsedAt.Time
+
+	return key, nil
+}
+
+// Gets an API key by id
+func (pgRepo *postgresDBRepository) GetApiKeyByID(id int) (models.ApiKey, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var key models.ApiKey
+	var lastUsedAt sql.NullTime
+
+	query := `SELECT id, name, key_hash, scopes, rate_limit, last_used_at, revoked, created_at, updated_at
+		FROM api_keys
+		WHERE id = $1`
+
+	err := pgRepo.DB.QueryRowContext(ctx, query, id).Scan(
+		&key.ID,
+		&key.Name,
+		&key.KeyHash,
+		&key.Scopes,
+		&key.RateLimit,
+		&lastUsedAt,
+		&key.Revoked,
+		&key.CreatedAt,
+		&key.UpdatedAt,
+	)
+	if err != nil {
+		return key, err
+	}
+
+	key.LastUsedAt = lastUsedAt.Time
+
+	return key, nil
+}
+
+// Revokes an API key so that it can no longer be used
+func (pgRepo *postgresDBRepository) RevokeApiKey(id int) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE api_keys
+		SET revoked = true, updated_at = $1
+		WHERE id = $2`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Stores the last time an API key was used
+func (pgRepo *postgresDBRepository) UpdateLastUsedForApiKey(id int, usedAt time.Time) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE api_keys
+		SET last_used_at = $1
+		WHERE id = $2`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, usedAt, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Gets all webhook endpoints
+func (pgRepo *postgresDBRepository) GetAllWebhookEndpoints() ([]models.WebhookEndpoint, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var endpoints []models.WebhookEndpoint
+
+	query := `SELECT id, url, description, events, secret, active, created_at, updated_at
+		FROM webhook_endpoints
+		ORDER BY created_at`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query)
+	if err != nil {
+		return endpoints, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var endpoint models.WebhookEndpoint
+
+		err := rows.Scan(
+			&endpoint.ID,
+			&endpoint.URL,
+			&endpoint.Description,
+			&endpoint.Events,
+			&endpoint.Secret,
+			&endpoint.Active,
+			&endpoint.CreatedAt,
+			&endpoint.UpdatedAt,
+		)
+		if err != nil {
+			return endpoints, err
+		}
+
+		endpoints = append(endpoints, endpoint)
+	}
+
+	if err = rows.Err(); err != nil {
+		return endpoints, err
+	}
+
+	return endpoints, nil
+}
+
+// Gets a webhook endpoint by id
+func (pgRepo *postgresDBRepository) GetWebhookEndpointByID(id int) (models.WebhookEndpoint, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var endpoint models.WebhookEndpoint
+
+	query := `SELECT id, url, description, events, secret, active, created_at, updated_at
+		FROM webhook_endpoints
+		WHERE id = $1`
+
+	err := pgRepo.DB.QueryRowContext(ctx, query, id).Scan(
+		&endpoint.ID,
+		&endpoint.URL,
+		&endpoint.Description,
+		&endpoint.Events,
+		&endpoint.Secret,
+		&endpoint.Active,
+		&endpoint.CreatedAt,
+		&endpoint.UpdatedAt,
+	)
+	if err != nil {
+		return endpoint, err
+	}
+
+	return endpoint, nil
+}
+
+// Inserts a webhook endpoint into the database
+func (pgRepo *postgresDBRepository) InsertWebhookEndpoint(endpoint models.WebhookEndpoint) (int, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `INSERT INTO webhook_endpoints (url, description, events, secret, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`
+
+	var endpointID int
+
+	err := pgRepo.DB.QueryRowContext(
+		ctx,
+		query,
+		endpoint.URL,
+		endpoint.Description,
+		endpoint.Events,
+		endpoint.Secret,
+		true,
+		time.Now(),
+		time.Now(),
+	).Scan(&endpointID)
+	if err != nil {
+		return 0, err
+	}
+
+	return endpointID, nil
+}
+
+// Pauses or resumes the deliveries to a webhook endpoint
+func (pgRepo *postgresDBRepository) UpdateActiveForWebhookEndpoint(id int, active bool) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE webhook_endpoints
+		SET active = $1, updated_at = $2
+		WHERE id = $3`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, active, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Deletes a webhook endpoint. Its deliveries are deleted by the database
+func (pgRepo *postgresDBRepository) DeleteWebhookEndpoint(id int) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `DELETE FROM webhook_endpoints
+		WHERE id = $1`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Queues a webhook delivery
+func (pgRepo *postgresDBRepository) InsertWebhookDelivery(delivery models.WebhookDelivery) (int, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `INSERT INTO webhook_deliveries (endpoint_id, event, payload, status, attempts, next_attempt_at,
+		created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
+
+	var deliveryID int
+
+	err := pgRepo.DB.QueryRowContext(
+		ctx,
+		query,
+		delivery.EndpointID,
+		delivery.Event,
+		delivery.Payload,
+		models.WebhookDeliveryPending,
+		0,
+		delivery.NextAttemptAt,
+		time.Now(),
+		time.Now(),
+	).Scan(&deliveryID)
+	if err != nil {
+		return 0, err
+	}
+
+	return deliveryID, nil
+}
+
+// Claims pending webhook deliveries whose next attempt is due, oldest first, together with their endpoint.
+// Their next attempt is moved forward by `lease`, so other workers skip them while they are being sent
+// and they are retried if the worker stops before storing the outcome
+func (pgRepo *postgresDBRepository) ClaimDueWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var deliveries []models.WebhookDelivery
+
+	query := `UPDATE webhook_deliveries d
+		SET next_attempt_at = $1, updated_at = $2
+		FROM webhook_endpoints e
+		WHERE d.endpoint_id = e.id AND d.id IN (
+			SELECT pd.id FROM webhook_deliveries pd
+			LEFT JOIN webhook_endpoints pe ON (pd.endpoint_id = pe.id)
+			WHERE pd.status = $3 AND pd.next_attempt_at <= $2 AND pe.active = true
+			ORDER BY pd.next_attempt_at, pd.id
+			LIMIT $4
+			FOR UPDATE OF pd SKIP LOCKED
+		)
+		RETURNING d.id, d.endpoint_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+		d.created_at, d.updated_at, e.id, e.url, e.secret, e.active`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query, now.Add(lease), now, models.WebhookDeliveryPending, limit)
+	if err != nil {
+		return deliveries, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.EndpointID,
+			&delivery.Event,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.CreatedAt,
+			&delivery.UpdatedAt,
+			&delivery.Endpoint.ID,
+			&delivery.Endpoint.URL,
+			&delivery.Endpoint.Secret,
+			&delivery.Endpoint.Active,
+		)
+		if err != nil {
+			return deliveries, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return deliveries, err
+	}
+
+	return deliveries, nil
+}
+
+// Gets the latest deliveries of a webhook endpoint, newest first
+func (pgRepo *postgresDBRepository) GetWebhookDeliveriesForEndpoint(endpointID int, limit int) ([]models.WebhookDelivery, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var deliveries []models.WebhookDelivery
+
+	query := `SELECT id, endpoint_id, event, payload, status, attempts, next_attempt_at, last_attempt_at,
+		response_status, last_error, created_at, updated_at
+		FROM webhook_deliveries
+		WHERE endpoint_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query, endpointID, limit)
+	if err != nil {
+		return deliveries, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		var lastAttemptAt sql.NullTime
+
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.EndpointID,
+			&delivery.Event,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&lastAttemptAt,
+			&delivery.ResponseStatus,
+			&delivery.LastError,
+			&delivery.CreatedAt,
+			&delivery.UpdatedAt,
+		)
+		if err != nil {
+			return deliveries, err
+		}
+
+		// Deliveries which have not been attempted yet keep a zero time
+		delivery.LastAttemptAt = lastAttemptAt.Time
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return deliveries, err
+	}
+
+	return deliveries, nil
+}
+
+// Stores the outcome of an attempt to send a webhook delivery
+func (pgRepo *postgresDBRepository) UpdateWebhookDelivery(delivery models.WebhookDelivery) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, next_attempt_at = $3, last_attempt_at = $4, response_status = $5,
+		last_error = $6, updated_at = $7
+		WHERE id = $8`
+
+	_, err := pgRepo.DB.ExecContext(
+		ctx,
+		query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.LastAttemptAt,
+		delivery.ResponseStatus,
+		delivery.LastError,
+		time.Now(),
+		delivery.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Stores emails in the outbox within the given transaction, so they are only sent if the transaction is committed
+func insertOutboxEmails(ctx context.Context, tx *sql.Tx, emails []models.MailData) error {
+	query := `INSERT INTO email_outbox (to_address, from_address, subject, content, text_content, template,
+		status, next_attempt_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id`
+
+	attachmentQuery := `INSERT INTO email_attachments (email_id, name, content_type, data, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	for _, email := range emails {
+		var emailID int
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			email.To,
+			email.From,
+			email.Subject,
+			email.Content,
+			email.TextContent,
+			email.Template,
+			models.EmailPending,
+			time.Now(),
+			time.Now(),
+			time.Now(),
+		).Scan(&emailID)
+		if err != nil {
+			return err
+		}
+
+		for _, attachment := range email.Attachments {
+			_, err = tx.ExecContext(
+				ctx,
+				attachmentQuery,
+				emailID,
+				attachment.Name,
+				attachment.ContentType,
+				attachment.Data,
+				time.Now(),
+				time.Now(),
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Gets the attachments of an email of the outbox
+func getOutboxAttachments(ctx context.Context, db *sql.DB, emailID int) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+
+	query := `SELECT name, content_type, data FROM email_attachments WHERE email_id = $1 ORDER BY id`
+
+	rows, err := db.QueryContext(ctx, query, emailID)
+	if err != nil {
+		return attachments, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var attachment models.Attachment
+
+		err := rows.Scan(
+			&attachment.Name,
+			&attachment.ContentType,
+			&attachment.Data,
+		)
+		if err != nil {
+			return attachments, err
+		}
+
+		attachments = append(attachments, attachment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return attachments, err
+	}
+
+	return attachments, nil
+}
+
+// Stores emails in the outbox. They are sent in the background by the outbox worker
+func (pgRepo *postgresDBRepository) InsertOutboxEmails(emails []models.MailData) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	tx, err := pgRepo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Rollback is a no-op if the transaction has been committed
+	defer tx.Rollback()
+
+	err = insertOutboxEmails(ctx, tx, emails)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Claims pending emails which are due to be sent. Their next attempt is moved forward by `lease`, so other
+// workers skip them while they are being sent and they are retried if the worker stops before storing the outcome
+func (pgRepo *postgresDBRepository) ClaimDueOutboxEmails(now time.Time, lease time.Duration, limit int) ([]models.OutboxEmail, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var emails []models.OutboxEmail
+
+	query := `UPDATE email_outbox
+		SET next_attempt_at = $1, updated_at = $2
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE status = $3 AND next_attempt_at <= $2
+			ORDER BY next_attempt_at, id
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, to_address, from_address, subject, content, text_content, template, status, attempts,
+		next_attempt_at, created_at, updated_at`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query, now.Add(lease), now, models.EmailPending, limit)
+	if err != nil {
+		return emails, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var email models.OutboxEmail
+
+		err := rows.Scan(
+			&email.ID,
+			&email.To,
+			&email.From,
+			&email.Subject,
+			&email.Content,
+			&email.TextContent,
+			&email.Template,
+			&email.Status,
+			&email.Attempts,
+			&email.NextAttemptAt,
+			&email.CreatedAt,
+			&email.UpdatedAt,
+		)
+		if err != nil {
+			return emails, err
+		}
+
+		emails = append(emails, email)
+	}
+
+	if err = rows.Err(); err != nil {
+		return emails, err
+	}
+
+	// Attachments are loaded once all claimed emails have been read
+	for i := range emails {
+		emails[i].Attachments, err = getOutboxAttachments(ctx, pgRepo.DB, emails[i].ID)
+		if err != nil {
+			return emails, err
+		}
+	}
+
+	return emails, nil
+}
+
+// Stores the outcome of an attempt to send an email
+func (pgRepo *postgresDBRepository) UpdateOutboxEmail(email models.OutboxEmail) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE email_outbox
+		SET status = $1, attempts = $2, next_attempt_at = $3, last_attempt_at = $4, last_error = $5, updated_at = $6
+		WHERE id = $7`
+
+	_, err := pgRepo.DB.ExecContext(
+		ctx,
+		query,
+		email.Status,
+		email.Attempts,
+		email.NextAttemptAt,
+		email.LastAttemptAt,
+		email.LastError,
+		time.Now(),
+		email.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Gets the latest emails of the outbox, newest first. All statuses are returned if `status` is empty
+func (pgRepo *postgresDBRepository) GetOutboxEmails(status string, limit int) ([]models.OutboxEmail, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	var emails []models.OutboxEmail
+
+	query := `SELECT id, to_address, from_address, subject, content, text_content, template, status, attempts,
+		next_attempt_at, last_attempt_at, last_error, created_at, updated_at
+		FROM email_outbox
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+
+	rows, err := pgRepo.DB.QueryContext(ctx, query, status, limit)
+	if err != nil {
+		return emails, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		email, err := scanOutboxEmail(rows)
+		if err != nil {
+			return emails, err
+		}
+
+		emails = append(emails, email)
+	}
+
+	if err = rows.Err(); err != nil {
+		return emails, err
+	}
+
+	return emails, nil
+}
+
+// Gets an email of the outbox by id
+func (pgRepo *postgresDBRepository) GetOutboxEmailByID(id int) (models.OutboxEmail, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `SELECT id, to_address, from_address, subject, content, text_content, template, status, attempts,
+		next_attempt_at, last_attempt_at, last_error, created_at, updated_at
+		FROM email_outbox
+		WHERE id = $1`
+
+	email, err := scanOutboxEmail(pgRepo.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return email, err
+	}
+
+	email.Attachments, err = getOutboxAttachments(ctx, pgRepo.DB, id)
+	if err != nil {
+		return email, err
+	}
+
+	return email, nil
+}
+
+// Queues an email which was sent, cancelled or given up on to be sent again right away
+func (pgRepo *postgresDBRepository) ResendOutboxEmail(id int) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE email_outbox
+		SET status = $1, attempts = 0, next_attempt_at = $2, last_error = '', updated_at = $2
+		WHERE id = $3 AND status <> $1`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, models.EmailPending, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Cancels an email which is still queued or was given up on, so it is not sent
+func (pgRepo *postgresDBRepository) CancelOutboxEmail(id int) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE email_outbox
+		SET status = $1, updated_at = $2
+		WHERE id = $3 AND status IN ($4, $5)`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, models.EmailCancelled, time.Now(), id, models.EmailPending, models.EmailDead)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Row returned by `sql.DB.QueryRowContext` or `sql.DB.QueryContext`
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Scans a row of the bookings table selected with all its columns
+func scanBooking(row rowScanner) (models.Booking, error) {
+	var booking models.Booking
+
+	err := row.Scan(
+		&booking.ID,
+		&booking.ConfirmationCode,
+		&booking.FirstName,
+		&booking.LastName,
+		&booking.Email,
+		&booking.Phone,
+		&booking.TotalPrice,
+		&booking.CreatedAt,
+		&booking.UpdatedAt,
+	)
+
+	return booking, err
+}
+
+// Scans a row of the email outbox selected with all its columns
+func scanOutboxEmail(row rowScanner) (models.OutboxEmail, error) {
+	var email models.OutboxEmail
+	var lastAttemptAt sql.NullTime
+
+	err := row.Scan(
+		&email.ID,
+		&email.To,
+		&email.From,
+		&email.Subject,
+		&email.Content,
+		&email.TextContent,
+		&email.Template,
+		&email.Status,
+		&email.Attempts,
+		&email.NextAttemptAt,
+		&lastAttemptAt,
+		&email.LastError,
+		&email.CreatedAt,
+		&email.UpdatedAt,
+	)
+	if err != nil {
+		return email, err
+	}
+
+	// Emails which have not been attempted yet keep a zero time
+	email.LastAttemptAt = lastAttemptAt.Time
+
+	return email, nil
+}
+
+// Gets the active reservations of guests who haven't opted out of scheduled emails, arriving between the given dates
+// (inclusive) and which the given job hasn't run for yet
+func (pgRepo *postgresDBRepository) GetReservationsArrivingBetween(startDate, endDate time.Time, job string) ([]models.Reservation, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date,
+		r.end_date, r.room_id, r.adults, r.children, r.created_at, r.updated_at, r.processed, r.total_price, r.confirmation_code,
+		r.cancelled, r.email_opt_out, rm.id, rm.room_name
+		FROM reservations r
+		LEFT JOIN rooms rm ON (r.room_id = rm.id)
+		WHERE r.start_date BETWEEN $1 AND $2 AND NOT r.cancelled AND NOT r.email_opt_out
+		AND NOT EXISTS (SELECT 1 FROM job_runs j WHERE j.job = $3 AND j.run_key = 'reservation-' || r.id)
+		ORDER BY r.start_date, r.id`
+
+	return queryReservations(ctx, pgRepo.DB, query, startDate, endDate, job)
+}
+
+// Gets the active reservations of guests who haven't opted out of scheduled emails, departing between the given dates
+// (inclusive) and which the given job hasn't run for yet
+func (pgRepo *postgresDBRepository) GetReservationsDepartingBetween(startDate, endDate time.Time, job string) ([]models.Reservation, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date,
+		r.end_date, r.room_id, r.adults, r.children, r.created_at, r.updated_at, r.processed, r.total_price, r.confirmation_code,
+		r.cancelled, r.email_opt_out, rm.id, rm.room_name
+		FROM reservations r
+		LEFT JOIN rooms rm ON (r.room_id = rm.id)
+		WHERE r.end_date BETWEEN $1 AND $2 AND NOT r.cancelled AND NOT r.email_opt_out
+		AND NOT EXISTS (SELECT 1 FROM job_runs j WHERE j.job = $3 AND j.run_key = 'reservation-' || r.id)
+		ORDER BY r.end_date, r.id`
+
+	return queryReservations(ctx, pgRepo.DB, query, startDate, endDate, job)
+}
+
+// Gets the active reservations arriving and the ones departing on the given date
+func (pgRepo *postgresDBRepository) GetArrivalsAndDepartures(date time.Time) ([]models.Reservation, []models.Reservation, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date,
+		r.end_date, r.room_id, r.adults, r.children, r.created_at, r.updated_at, r.processed, r.total_price, r.confirmation_code,
+		r.cancelled, r.email_opt_out, rm.id, rm.room_name
+		FROM reservations r
+		LEFT JOIN rooms rm ON (r.room_id = rm.id)
+		WHERE r.start_date = $1 AND NOT r.cancelled
+		ORDER BY rm.room_name, r.id`
+
+	arrivals, err := queryReservations(ctx, pgRepo.DB, query, date)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query = `SELECT r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date,
+		r.end_date, r.room_id, r.adults, r.children, r.created_at, r.updated_at, r.processed, r.total_price, r.confirmation_code,
+		r.cancelled, r.email_opt_out, rm.id, rm.room_name
+		FROM reservations r
+		LEFT JOIN rooms rm ON (r.room_id = rm.id)
+		WHERE r.end_date = $1 AND NOT r.cancelled
+		ORDER BY rm.room_name, r.id`
+
+	departures, err := queryReservations(ctx, pgRepo.DB, query, date)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return arrivals, departures, nil
+}
+
+// Records that a job has run for the given key and queues its emails in a single transaction.
+// Returns false without queueing the emails if the job has already run for the key,
+// so that the emails of a job are never sent twice
+func (pgRepo *postgresDBRepository) InsertJobRun(job, runKey string, emails []models.MailData) (bool, error) {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	tx, err := pgRepo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	// Rollback is a no-op if the transaction has been committed
+	defer tx.Rollback()
+
+	query := `INSERT INTO job_runs (job, run_key, created_at, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (job, run_key) DO NOTHING`
+
+	result, err := tx.ExecContext(ctx, query, job, runKey, time.Now(), time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	err = insertOutboxEmails(ctx, tx, emails)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// Sets whether the guest of a reservation receives the scheduled reminder and follow-up emails
+func (pgRepo *postgresDBRepository) UpdateEmailOptOutForReservation(id int, optOut bool) error {
+	// Set timeout for this operation
+	// Cancel operation if it takes more than 3 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 3 * time.Second)
+	defer cancel()
+
+	query := `UPDATE reservations SET email_opt_out = $1, updated_at = $2 WHERE id = $3`
+
+	_, err := pgRepo.DB.ExecContext(ctx, query, optOut, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Runs a query selecting reservations with their confirmation code, cancellation, email opt-out and room name
+func queryReservations(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]models.Reservation, error) {
+	var reservations []models.Reservation
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return reservations, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var reservation models.Reservation
+
+		err := rows.Scan(
+			&reservation.ID,
+			&reservation.FirstName,
+			&reservation.LastName,
+			&reservation.Email,
+			&reservation.Phone,
+			&reservation.StartDate,
+			&reservation.EndDate,
+			&reservation.RoomID,
+			&reservation.Adults,
+			&reservation.Children,
+			&reservation.CreatedAt,
+			&reservation.UpdatedAt,
+			&reservation.Processed,
+			&reservation.TotalPrice,
+			&reservation.ConfirmationCode,
+			&reservation.Cancelled,
+			&reservation.EmailOptOut,
+			&reservation.Room.ID,
+			&reservation.Room.RoomName,
+		)
+		if err != nil {
+			return reservations, err
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
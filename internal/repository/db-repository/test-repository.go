@@ -1,11 +1,15 @@
 package dbrepository
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
 	"log"
 	"time"
 
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
 )
 
 // Inserts a reservation into the database
@@ -28,6 +32,39 @@ func (pgRepo *testDBRepository) InsertRoomRestriction(roomRestriction models.Roo
 	return nil
 }
 
+// Inserts a reservation, its room restriction and the emails confirming it in a single transaction
+func (pgRepo *testDBRepository) InsertReservationWithRestriction(reservation models.Reservation, emails []models.MailData) (int, error) {
+	// If room id is 2 or 1000 then fail, otherwise pass
+	if reservation.RoomID == 2 || reservation.RoomID == 1000 {
+		return 0, errors.New("invalid room id")
+	}
+
+	// If the start date is after 2099-12-31, fake the room being booked by someone else in the meantime
+	limitDate, err := time.Parse("2006-01-02", "2099-12-31")
+	if err != nil {
+		log.Println(err)
+	}
+
+	if reservation.StartDate.After(limitDate) {
+		return 0, repository.ErrRoomNotAvailable
+	}
+
+	return 1, nil
+}
+
+// Inserts a booking together with its reservations, their room restrictions and the emails confirming it
+func (pgRepo *testDBRepository) InsertBookingWithReservations(booking models.Booking, emails []models.MailData) (int, error) {
+	// Booking fails like a single reservation would for any of its rooms
+	for _, reservation := range booking.Reservations {
+		_, err := pgRepo.InsertReservationWithRestriction(reservation, emails)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return 1, nil
+}
+
 // Queries for existing reservations on the given room and dates 
 // Returns true if there are reservations for the given room and dates, otherwise it returns false
 func (pgRepo *testDBRepository) SearchAvailabilityByDatesAndRoom(startDate time.Time, endDate time.Time, roomID int) (bool, error) {		
@@ -60,7 +97,7 @@ func (pgRepo *testDBRepository) SearchAvailabilityByDatesAndRoom(startDate time.
 }
 
 // Returns all rooms which are available during the given dates
-func (pgRepo *testDBRepository) SearchAvailabilityForAllRooms(startDate time.Time, endDate time.Time) ([]models.Room, error) {
+func (pgRepo *testDBRepository) SearchAvailabilityForAllRooms(startDate time.Time, endDate time.Time, guests int) ([]models.Room, error) {
 	var rooms []models.Room
 	
 	// If the start date is after 2049-12-31, then return empty slice
@@ -89,8 +126,14 @@ func (pgRepo *testDBRepository) SearchAvailabilityForAllRooms(startDate time.Tim
 
 	// If start date is valid, then put an entry into the `rooms` slice, indicating that some room is
 	// available for search dates
+	// The room sleeps 2, so bigger parties don't find any room
+	if guests > 2 {
+		return rooms, nil
+	}
+
 	room := models.Room{
 		ID: 1,
+		Capacity: 2,
 	}
 	rooms = append(rooms, room)
 
@@ -101,23 +144,178 @@ func (pgRepo *testDBRepository) SearchAvailabilityForAllRooms(startDate time.Tim
 func (pgRepo *testDBRepository) GetRoomByID(id int) (models.Room, error) {
 	var room models.Room
 
+	// Room 4 has been deactivated
+	if id == 4 {
+		return models.Room{ ID: 4, RoomName: "Closed Room", Capacity: 2, Active: false }, nil
+	}
+
 	// There are only 2 rooms with ID 1 and 2
 	if id > 2 {
 		return room, errors.New("can't find room with given id")
 	}
 
+	room.ID = id
+	room.Capacity = 2
+	room.Active = true
+
+	return room, nil
+}
+
+// Gets room by slug
+func (pgRepo *testDBRepository) GetRoomBySlug(slug string) (models.Room, error) {
+	var room models.Room
+
+	switch slug {
+	case "generals-quarters":
+		room = models.Room{ID: 1, RoomName: "General's Quarters", Slug: slug, Capacity: 2, Active: true}
+	case "majors-suite":
+		room = models.Room{ID: 2, RoomName: "Major's Suite", Slug: slug, Capacity: 2, Active: true}
+	case "closed-room":
+		// A room which has been deactivated by the owner
+		room = models.Room{ID: 3, RoomName: "Closed Room", Slug: slug, Capacity: 2, Active: false}
+	default:
+		return room, errors.New("can't find room with given slug")
+	}
+
 	return room, nil
 }
 
+// Inserts a room into the database
+func (pgRepo *testDBRepository) InsertRoom(room models.Room) (int, error) {
+	if room.Slug == "taken-slug" {
+		return 0, repository.ErrSlugTaken
+	}
+
+	return 3, nil
+}
+
+// Updates the details of a room
+func (pgRepo *testDBRepository) UpdateRoom(room models.Room) error {
+	if room.Slug == "taken-slug" {
+		return repository.ErrSlugTaken
+	}
+
+	return nil
+}
+
+// Activates or deactivates a room
+func (pgRepo *testDBRepository) UpdateActiveForRoom(id int, active bool) error {
+	if id > 2 {
+		return errors.New("room not found")
+	}
+
+	return nil
+}
+
+// Updates the display order of the given rooms
+func (pgRepo *testDBRepository) UpdateSortOrderForRooms(sortOrders map[int]int) error {
+	if _, ok := sortOrders[1000]; ok {
+		return errors.New("room not found")
+	}
+
+	return nil
+}
+
+// Secret of the authenticator app of the test user with two-factor authentication
+const TestTwoFactorSecret = "JBSWY3DPEHPK3PXP"
+
+// Users of the test repository, with the owner, front desk and housekeeping roles,
+// a disabled user, a user who has to reset the password and a user with two-factor authentication
+var testUsers = map[int]models.User{
+	1: { ID: 1, FirstName: "Admin", LastName: "User", Email: "me@here.com", AccessLevel: models.AccessOwner },
+	2: { ID: 2, FirstName: "Front", LastName: "Desk", Email: "desk@here.com", AccessLevel: models.AccessFrontDesk },
+	3: { ID: 3, FirstName: "House", LastName: "Keeping", Email: "housekeeping@here.com", AccessLevel: models.AccessHousekeeping },
+	4: { ID: 4, FirstName: "Former", LastName: "Staff", Email: "former@here.com", AccessLevel: models.AccessFrontDesk, Disabled: true },
+	5: { ID: 5, FirstName: "New", LastName: "Staff", Email: "new@here.com", AccessLevel: models.AccessFrontDesk, PasswordResetRequired: true },
+	6: { ID: 6, FirstName: "Two", LastName: "Factor", Email: "twofactor@here.com", AccessLevel: models.AccessOwner, TwoFactorSecret: TestTwoFactorSecret, TwoFactorEnabled: true, TwoFactorLastStep: 1000 },
+}
+
 // Gets user by id
 func (pgRepo *testDBRepository) GetUserByID(id int) (models.User, error) {
-	var user models.User
+	// Other users don't exist
+	user, ok := testUsers[id]
+	if !ok {
+		return user, sql.ErrNoRows
+	}
 
 	return user, nil
 }
 
-// Updates a user
+// Gets user by email
+func (pgRepo *testDBRepository) GetUserByEmail(email string) (models.User, error) {
+	for _, user := range testUsers {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+
+	return models.User{}, sql.ErrNoRows
+}
+
+// Gets a list of all users, sorted by name
+func (pgRepo *testDBRepository) GetAllUsers() ([]models.User, error) {
+	return []models.User{testUsers[1], testUsers[2], testUsers[3], testUsers[4], testUsers[5], testUsers[6]}, nil
+}
+
+// Inserts a user with the given password and queues the given emails
+func (pgRepo *testDBRepository) InsertUser(user models.User, password string, emails []models.MailData) (int, error) {
+	// Emails of the existing users are taken
+	_, err := pgRepo.GetUserByEmail(user.Email)
+	if err == nil {
+		return 0, repository.ErrEmailTaken
+	}
+
+	return 7, nil
+}
+
+// Updates the name, email and access level of a user
 func (pgRepo *testDBRepository) UpdateUser(user models.User) error {
+	if _, ok := testUsers[user.ID]; !ok {
+		return errors.New("user not found")
+	}
+
+	existing, err := pgRepo.GetUserByEmail(user.Email)
+	if err == nil && existing.ID != user.ID {
+		return repository.ErrEmailTaken
+	}
+
+	return nil
+}
+
+// Hashes and stores a new password for a user and returns the new session version
+func (pgRepo *testDBRepository) UpdateUserPassword(id int, password string) (int, error) {
+	user, ok := testUsers[id]
+	if !ok {
+		return 0, errors.New("user not found")
+	}
+
+	return user.SessionVersion + 1, nil
+}
+
+// Disables or enables a user
+func (pgRepo *testDBRepository) UpdateDisabledForUser(id int, disabled bool) error {
+	if _, ok := testUsers[id]; !ok {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// Sets whether a user has to choose a new password
+func (pgRepo *testDBRepository) UpdatePasswordResetRequiredForUser(id int, required bool) error {
+	if _, ok := testUsers[id]; !ok {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// Deletes a user
+func (pgRepo *testDBRepository) DeleteUser(id int) error {
+	if _, ok := testUsers[id]; !ok {
+		return errors.New("user not found")
+	}
+
 	return nil
 }
 
@@ -127,9 +325,236 @@ func (pgRepo *testDBRepository) Authenticate(email, password string) (int, strin
 		return 1, "", nil
 	}
 
+	if email == "twofactor@here.com" {
+		return 6, "", nil
+	}
+
+	if email == "former@here.com" {
+		return 0, "", repository.ErrUserDisabled
+	}
+
 	return 0, "", errors.New("not authenticated")
 }
 
+// Password reset tokens of the test repository: a valid, a used and an expired token of the owner
+var testPasswordResetTokens = []models.PasswordResetToken{
+	{ ID: 1, UserID: 1, TokenHash: helpers.HashToken("valid-reset-token"), ExpiresAt: time.Now().Add(time.Hour) },
+	{ ID: 2, UserID: 1, TokenHash: helpers.HashToken("used-reset-token"), ExpiresAt: time.Now().Add(time.Hour), UsedAt: time.Now() },
+	{ ID: 3, UserID: 1, TokenHash: helpers.HashToken("expired-reset-token"), ExpiresAt: time.Now().Add(-time.Hour) },
+}
+
+// Stores a token for a password reset link and queues the email with the link
+func (pgRepo *testDBRepository) InsertPasswordResetToken(token models.PasswordResetToken, emails []models.MailData) error {
+	if _, ok := testUsers[token.UserID]; !ok {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// Gets a password reset token by the hash of the token
+func (pgRepo *testDBRepository) GetPasswordResetTokenByHash(tokenHash string) (models.PasswordResetToken, error) {
+	for _, token := range testPasswordResetTokens {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+
+	return models.PasswordResetToken{}, sql.ErrNoRows
+}
+
+// Stores a new password for the user of a usable password reset token
+func (pgRepo *testDBRepository) ResetPasswordWithToken(tokenHash, password string) (int, error) {
+	token, err := pgRepo.GetPasswordResetTokenByHash(tokenHash)
+	if err != nil || !token.IsUsable(time.Now()) {
+		return 0, repository.ErrResetTokenInvalid
+	}
+
+	return token.UserID, nil
+}
+
+// Recovery codes of the test user with two-factor authentication
+var testRecoveryCodes = map[string]bool{
+	helpers.HashToken("ABCDE23456"): false,
+	helpers.HashToken("USED234567"): true,
+}
+
+// Turns on two-factor authentication for a user
+func (pgRepo *testDBRepository) EnableTwoFactorForUser(id int, secret string, recoveryCodeHashes []string) error {
+	if _, ok := testUsers[id]; !ok {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// Turns off two-factor authentication for a user
+func (pgRepo *testDBRepository) DisableTwoFactorForUser(id int) error {
+	if _, ok := testUsers[id]; !ok {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// Stores the step of an accepted authenticator code
+func (pgRepo *testDBRepository) UpdateTwoFactorLastStepForUser(id int, step int64) error {
+	user, ok := testUsers[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+
+	if step <= user.TwoFactorLastStep {
+		return repository.ErrTwoFactorCodeUsed
+	}
+
+	return nil
+}
+
+// Replaces the recovery codes of a user
+func (pgRepo *testDBRepository) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	if _, ok := testUsers[userID]; !ok {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// Marks an unused recovery code of a user as used
+func (pgRepo *testDBRepository) UseRecoveryCode(userID int, codeHash string) error {
+	used, ok := testRecoveryCodes[codeHash]
+	if userID != 6 || !ok || used {
+		return repository.ErrTwoFactorCodeUsed
+	}
+
+	return nil
+}
+
+// Counts the recovery codes a user has left
+func (pgRepo *testDBRepository) CountUnusedRecoveryCodes(userID int) (int, error) {
+	if userID == 6 {
+		return 9, nil
+	}
+
+	return 0, nil
+}
+
+// Gets the failed logins counted under a key. No failures are stored in the test repository
+func (pgRepo *testDBRepository) GetLoginThrottle(key string) (models.LoginThrottle, error) {
+	return models.LoginThrottle{}, sql.ErrNoRows
+}
+
+// Counts a failed login under a key
+func (pgRepo *testDBRepository) RecordLoginFailure(key string, at time.Time, window time.Duration) (models.LoginThrottle, error) {
+	return models.LoginThrottle{ Key: key, Failures: 1, LastFailureAt: at }, nil
+}
+
+// Locks a key until the given time
+func (pgRepo *testDBRepository) LockLoginThrottle(key string, until time.Time) error {
+	return nil
+}
+
+// Forgets the failed logins counted under a key
+func (pgRepo *testDBRepository) DeleteLoginThrottle(key string) error {
+	return nil
+}
+
+// Gets the keys which are locked at the given time
+func (pgRepo *testDBRepository) GetLockedLoginThrottles(now time.Time) ([]models.LoginThrottle, error) {
+	var throttles []models.LoginThrottle
+
+	return throttles, nil
+}
+
+// Forgets the failed logins which are no longer counted
+func (pgRepo *testDBRepository) DeleteExpiredLoginThrottles(now time.Time, window time.Duration) (int, error) {
+	return 0, nil
+}
+
+// Records a login, whether it succeeded or not
+func (pgRepo *testDBRepository) InsertLoginEvent(event models.LoginEvent) error {
+	return nil
+}
+
+// Gets the latest logins, newest first
+func (pgRepo *testDBRepository) GetLoginEvents(limit int) ([]models.LoginEvent, error) {
+	var events []models.LoginEvent
+	events = append(events, models.LoginEvent{ ID: 2, Email: "me@here.com", UserID: 1, IPAddress: "192.0.2.1", UserAgent: "Firefox", Success: true, CreatedAt: time.Now() })
+	events = append(events, models.LoginEvent{ ID: 1, Email: "me@here.com", UserID: 1, IPAddress: "192.0.2.1", UserAgent: "Firefox", Reason: models.LoginFailureInvalidCredentials, CreatedAt: time.Now() })
+
+	return events, nil
+}
+
+// Stores an entry of the audit log
+func (pgRepo *testDBRepository) InsertAuditEntry(entry models.AuditEntry) error {
+	return nil
+}
+
+// Gets the entries of the audit log matching the filter, newest first
+func (pgRepo *testDBRepository) GetAuditEntries(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	entries := []models.AuditEntry{
+		{ ID: 2, UserID: 1, UserName: "Admin User", Action: "updated", EntityType: "reservation", EntityID: 1, Changes: []models.AuditChange{{ Field: "phone", Before: "555-0100", After: "555-0199" }}, IPAddress: "192.0.2.1", CreatedAt: time.Now() },
+		{ ID: 1, UserID: 1, UserName: "Admin User", Action: "disabled", EntityType: "user", EntityID: 2, Changes: []models.AuditChange{{ Field: "disabled", Before: "false", After: "true" }}, IPAddress: "192.0.2.1", CreatedAt: time.Now() },
+	}
+
+	var matching []models.AuditEntry
+	for _, entry := range entries {
+		if filter.EntityType != "" && entry.EntityType != filter.EntityType {
+			continue
+		}
+
+		if filter.EntityID != 0 && entry.EntityID != filter.EntityID {
+			continue
+		}
+
+		if filter.UserID != 0 && entry.UserID != filter.UserID {
+			continue
+		}
+
+		matching = append(matching, entry)
+	}
+
+	return matching, nil
+}
+
+// Gets a session by its token, whether it has expired or not
+func (pgRepo *testDBRepository) GetSession(token string) (models.Session, error) {
+	return models.Session{}, sql.ErrNoRows
+}
+
+// Stores a session, replacing the data of a session with the same token
+func (pgRepo *testDBRepository) SaveSession(session models.Session) error {
+	return nil
+}
+
+// Deletes a session, which logs its user out
+func (pgRepo *testDBRepository) DeleteSession(token string) error {
+	return nil
+}
+
+// Deletes a session and remembers its token until it expires
+func (pgRepo *testDBRepository) RevokeSession(token string, expiry time.Time) error {
+	return nil
+}
+
+// Deletes the sessions which expired before the given time. Returns the number of deleted sessions
+func (pgRepo *testDBRepository) DeleteExpiredSessions(now time.Time) (int, error) {
+	return 0, nil
+}
+
+// Gets the sessions of a user which haven't expired at the given time, most recently used first
+func (pgRepo *testDBRepository) GetSessionsForUser(userID int, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	if userID != 1 {
+		return sessions, nil
+	}
+
+	sessions = append(sessions, models.Session{ Token: "laptop-session-token", Expiry: now.Add(time.Hour), UserID: 1, IPAddress: "192.0.2.1", UserAgent: "Firefox", CreatedAt: now, UpdatedAt: now })
+	sessions = append(sessions, models.Session{ Token: "phone-session-token", Expiry: now.Add(time.Hour), UserID: 1, IPAddress: "198.51.100.7", UserAgent: "Safari", CreatedAt: now, UpdatedAt: now })
+
+	return sessions, nil
+}
+
 // Gets a list of all reservations
 func (pgRepo *testDBRepository) GetAllReservations() ([]models.Reservation, error) {
 	var reservations []models.Reservation
@@ -153,9 +578,123 @@ func (pgRepo *testDBRepository) GetReservationByID(id int) (models.Reservation,
 		return reservation, errors.New("reservation not found")
 	}
 
+	// Reservations used to test the changes done by guests
+	switch id {
+	case 2:
+		reservation = testGuestReservation(2, time.Now().AddDate(0, 1, 0), false)
+	case 3:
+		reservation = testGuestReservation(3, time.Now().AddDate(0, 0, 1), false)
+	case 4:
+		reservation = testGuestReservation(4, time.Now().AddDate(0, 1, 0), true)
+	case 5:
+		// A reservation made in a booking of several rooms
+		reservation = testBooking().Reservations[0]
+	case 7:
+		// A reservation which has already been processed
+		reservation = testGuestReservation(7, time.Now().AddDate(0, 1, 0), false)
+		reservation.Processed = true
+	}
+
 	return reservation, nil
 }
 
+// Gets a reservation by its confirmation code and the email address of the guest
+func (pgRepo *testDBRepository) GetReservationByConfirmationCode(code, email string) (models.Reservation, error) {
+	if code != "ABCDEFGHJK" || email != "john@smith.com" {
+		return models.Reservation{}, errors.New("reservation not found")
+	}
+
+	return testGuestReservation(2, time.Now().AddDate(0, 1, 0), false), nil
+}
+
+// Gets all bookings of several rooms, together with their reservations
+func (pgRepo *testDBRepository) GetBookings() ([]models.Booking, error) {
+	return []models.Booking{testBooking()}, nil
+}
+
+// Gets a booking of several rooms by id, together with its reservations
+func (pgRepo *testDBRepository) GetBookingByID(id int) (models.Booking, error) {
+	if id != 1 {
+		return models.Booking{}, errors.New("booking not found")
+	}
+
+	return testBooking(), nil
+}
+
+// Booking of both rooms for a family event
+func testBooking() models.Booking {
+	startDate := time.Now().AddDate(0, 1, 0).Truncate(24 * time.Hour)
+
+	booking := models.Booking{
+		ID: 1,
+		ConfirmationCode: "BOOKING234",
+		FirstName: "John",
+		LastName: "Smith",
+		Email: "john@smith.com",
+		TotalPrice: 50000,
+	}
+
+	for i, room := range []models.Room{{ ID: 1, RoomName: "General's Quarters" }, { ID: 2, RoomName: "Major's Suite" }} {
+		reservation := testGuestReservation(5 + i, startDate, false)
+		reservation.RoomID = room.ID
+		reservation.Room = room
+		reservation.Adults = 2
+		reservation.TotalPrice = 25000
+		reservation.ConfirmationCode = fmt.Sprintf("BOOKING23%d", 5 + i)
+		reservation.BookingID = booking.ID
+
+		booking.Reservations = append(booking.Reservations, reservation)
+	}
+
+	return booking
+}
+
+// Moves a reservation and its room restriction to new dates
+func (pgRepo *testDBRepository) UpdateReservationDates(reservation models.Reservation, emails []models.MailData) error {
+	// If the start date is after 2099-12-31, then fake that the room is not available for the new dates
+	limitDate := time.Date(2099, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if reservation.StartDate.After(limitDate) {
+		return repository.ErrRoomNotAvailable
+	}
+
+	return nil
+}
+
+// Marks a reservation as cancelled and frees its dates
+func (pgRepo *testDBRepository) CancelReservation(id int, emails []models.MailData) error {
+	if id > 10 {
+		return errors.New("reservation not found")
+	}
+
+	return nil
+}
+
+// Creates a two night reservation of room 1 made by John Smith
+func testGuestReservation(id int, startDate time.Time, cancelled bool) models.Reservation {
+	return models.Reservation{
+		ID: id,
+		FirstName: "John",
+		LastName: "Smith",
+		Email: "john@smith.com",
+		StartDate: startDate,
+		EndDate: startDate.AddDate(0, 0, 2),
+		RoomID: 1,
+		TotalPrice: 16000,
+		ConfirmationCode: "ABCDEFGHJK",
+		Cancelled: cancelled,
+		// Quoted at a promotional rate which the current rates don't have anymore
+		Quote: models.Quote{
+			Nights: []models.NightlyPrice{
+				{ Date: startDate, Label: "Spring promotion", Rate: 8000, Amount: 8000 },
+				{ Date: startDate.AddDate(0, 0, 1), Label: "Spring promotion", Rate: 8000, Amount: 8000 },
+			},
+			Subtotal: 16000,
+			Total: 16000,
+		},
+		Room: models.Room{ID: 1, RoomName: "General's Quarters"},
+	}
+}
+
 // Updates a reservation
 func (pgRepo *testDBRepository) UpdateReservation(reservation models.Reservation) error {
 	// Fake failing to update reservation
@@ -192,6 +731,14 @@ func (pgRepo *testDBRepository) GetAllRooms() ([]models.Room, error) {
 	return rooms, nil
 }
 
+// Gets all rooms which are shown to guests
+func (pgRepo *testDBRepository) GetActiveRooms() ([]models.Room, error) {
+	var rooms []models.Room
+	rooms = append(rooms, models.Room{ ID: 1, Slug: "generals-quarters", Active: true })
+
+	return rooms, nil
+}
+
 
 // Gets restrictions for a given room by date range
 func (pgRepo *testDBRepository) GetRestrictionsForRoomByDate(roomID int, startDate, endDate time.Time) ([]models.RoomRestriction, error) {
@@ -215,17 +762,589 @@ func (pgRepo *testDBRepository) GetRestrictionsForRoomByDate(roomID int, startDa
 		RoomID:        1,
 		ReservationID: 1,
 		RestrictionID: 1,
+		Reservation:   models.Reservation{FirstName: "John", LastName: "Smith", Adults: 2, Children: 1},
+	})
+
+	// Add a block of several nights
+	restrictions = append(restrictions, models.RoomRestriction{
+		ID:            3,
+		StartDate:     time.Now().AddDate(0, 0, 5),
+		EndDate:       time.Now().AddDate(0, 0, 9),
+		RoomID:        1,
+		ReservationID: 0,
+		RestrictionID: 2,
+		Reason:        models.BlockReasonMaintenance,
+		Note:          "New bathroom",
 	})
 
 	return restrictions, nil
 }
 
-// Inserts an owner block as room restriction for given room
-func (pgRepo *testDBRepository) InsertBlockForRoom(id int, startDate time.Time) error {
-	return nil
+// Inserts an owner block as room restriction for given room. Returns the id of the new block
+func (pgRepo *testDBRepository) InsertBlockForRoom(id int, startDate time.Time) (int, error) {
+	// If the start date is after 2099-12-31, then fake that the room is not available
+	limitDate := time.Date(2099, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if startDate.After(limitDate) {
+		return 0, repository.ErrRoomNotAvailable
+	}
+
+	return 4, nil
 }
 
 // Deletes an owner block from room restrictions
 func (pgRepo *testDBRepository) DeleteBlockByID(id int) error {
 	return nil
-}
\ No newline at end of file
+}
+
+// Inserts an owner block of any number of nights. Returns the id of the new block
+func (pgRepo *testDBRepository) InsertOwnerBlock(block models.RoomRestriction) (int, error) {
+	// If the start date is after 2099-12-31, then fake that the room is not available
+	limitDate := time.Date(2099, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if block.StartDate.After(limitDate) {
+		return 0, repository.ErrRoomNotAvailable
+	}
+
+	return 4, nil
+}
+
+// Updates the room, dates, reason and note of an owner block
+func (pgRepo *testDBRepository) UpdateOwnerBlock(block models.RoomRestriction) error {
+	// If the start date is after 2099-12-31, then fake that the room is not available
+	limitDate := time.Date(2099, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if block.StartDate.After(limitDate) {
+		return repository.ErrRoomNotAvailable
+	}
+
+	return nil
+}
+
+// Gets the owner blocks of all rooms which end after the given date, with their room, sorted by start date
+func (pgRepo *testDBRepository) GetOwnerBlocks(from time.Time) ([]models.RoomRestriction, error) {
+	restrictions, _ := pgRepo.GetRestrictionsForRoomByDate(1, from, from)
+
+	var blocks []models.RoomRestriction
+	for _, restriction := range restrictions {
+		if restriction.RestrictionID == models.RestrictionOwnerBlock {
+			restriction.Room = models.Room{ ID: 1, RoomName: "General's Quarters" }
+			blocks = append(blocks, restriction)
+		}
+	}
+
+	return blocks, nil
+}
+
+// Gets a room restriction by id
+func (pgRepo *testDBRepository) GetRoomRestrictionByID(id int) (models.RoomRestriction, error) {
+	restrictions, _ := pgRepo.GetRestrictionsForRoomByDate(1, time.Now(), time.Now())
+
+	for _, restriction := range restrictions {
+		if restriction.ID == id {
+			return restriction, nil
+		}
+	}
+
+	return models.RoomRestriction{}, errors.New("room restriction not found")
+}
+
+// Gets the pricing rules of a given room
+func (pgRepo *testDBRepository) GetRatePlanForRoom(roomID int) (models.RatePlan, error) {
+	var plan models.RatePlan
+
+	// There are only 2 rooms with ID 1 and 2
+	if roomID > 2 {
+		return plan, errors.New("can't find rate plan for room with given id")
+	}
+
+	plan.RoomRate = models.RoomRate{
+		RoomID: roomID,
+		NightlyRate: 10000,
+		WeekendSurcharge: 2000,
+	}
+	plan.StayDiscounts = append(plan.StayDiscounts, models.StayDiscount{
+		RoomID: roomID,
+		MinNights: 7,
+		PercentOff: 10,
+	})
+
+	return plan, nil
+}
+
+// Stay rules of room 1. Summer stays have to be 3 to 14 nights long and start on a Friday or Saturday,
+// guests can't arrive nor leave at Christmas
+func testStayRules() []models.StayRule {
+	room := models.Room{ ID: 1, RoomName: "General's Quarters" }
+
+	return []models.StayRule{
+		{
+			ID: 1,
+			RoomID: 1,
+			Room: room,
+			Name: "Summer",
+			StartDate: time.Date(2049, time.July, 1, 0, 0, 0, 0, time.UTC),
+			EndDate: time.Date(2049, time.August, 31, 0, 0, 0, 0, time.UTC),
+			MinNights: 3,
+			MaxNights: 14,
+			ArrivalDays: 1 << uint(time.Friday) | 1 << uint(time.Saturday),
+		},
+		{
+			ID: 2,
+			RoomID: 1,
+			Room: room,
+			Name: "Christmas",
+			StartDate: time.Date(2049, time.December, 24, 0, 0, 0, 0, time.UTC),
+			EndDate: time.Date(2049, time.December, 26, 0, 0, 0, 0, time.UTC),
+			ClosedToArrival: true,
+			ClosedToDeparture: true,
+		},
+	}
+}
+
+// Gets the stay rules of a given room which cover any date of a stay
+func (pgRepo *testDBRepository) GetStayRulesForRoom(roomID int, startDate, endDate time.Time) ([]models.StayRule, error) {
+	var rules []models.StayRule
+
+	for _, rule := range testStayRules() {
+		if rule.RoomID == roomID && !rule.StartDate.After(endDate) && !rule.EndDate.Before(startDate) {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+// Gets the stay rules of all rooms which end on or after the given date
+func (pgRepo *testDBRepository) GetStayRules(from time.Time) ([]models.StayRule, error) {
+	return testStayRules(), nil
+}
+
+// Gets a stay rule by id
+func (pgRepo *testDBRepository) GetStayRuleByID(id int) (models.StayRule, error) {
+	for _, rule := range testStayRules() {
+		if rule.ID == id {
+			return rule, nil
+		}
+	}
+
+	return models.StayRule{}, errors.New("stay rule not found")
+}
+
+// Inserts a stay rule into the database
+func (pgRepo *testDBRepository) InsertStayRule(rule models.StayRule) (int, error) {
+	if rule.Name == "Invalid" {
+		return 0, errors.New("stay rule not inserted")
+	}
+
+	return 3, nil
+}
+
+// Updates a stay rule
+func (pgRepo *testDBRepository) UpdateStayRule(rule models.StayRule) error {
+	return nil
+}
+
+// Deletes a stay rule
+func (pgRepo *testDBRepository) DeleteStayRule(id int) error {
+	return nil
+}
+
+// Inserts a calendar feed token into the database
+func (pgRepo *testDBRepository) InsertCalendarFeedToken(token models.CalendarFeedToken) (int, error) {
+	if token.Name == "Invalid" {
+		return 0, errors.New("calendar feed token not inserted")
+	}
+
+	return 1, nil
+}
+
+// Gets all calendar feed tokens
+func (pgRepo *testDBRepository) GetAllCalendarFeedTokens() ([]models.CalendarFeedToken, error) {
+	var tokens []models.CalendarFeedToken
+	tokens = append(tokens, models.CalendarFeedToken{ ID: 1, Name: "Booking channel", TokenHash: helpers.HashToken("valid-token") })
+	tokens = append(tokens, models.CalendarFeedToken{ ID: 2, Name: "Old phone", TokenHash: helpers.HashToken("revoked-token"), Revoked: true })
+
+	return tokens, nil
+}
+
+// Gets a calendar feed token by the hash of the token
+func (pgRepo *testDBRepository) GetCalendarFeedTokenByHash(tokenHash string) (models.CalendarFeedToken, error) {
+	tokens, _ := pgRepo.GetAllCalendarFeedTokens()
+
+	for _, token := range tokens {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+
+	return models.CalendarFeedToken{}, errors.New("calendar feed token not found")
+}
+
+// Gets a calendar feed token by id
+func (pgRepo *testDBRepository) GetCalendarFeedTokenByID(id int) (models.CalendarFeedToken, error) {
+	tokens, _ := pgRepo.GetAllCalendarFeedTokens()
+
+	for _, token := range tokens {
+		if token.ID == id {
+			return token, nil
+		}
+	}
+
+	return models.CalendarFeedToken{}, errors.New("calendar feed token not found")
+}
+
+// Revokes a calendar feed token
+func (pgRepo *testDBRepository) RevokeCalendarFeedToken(id int) error {
+	if id > 2 {
+		return errors.New("calendar feed token not found")
+	}
+
+	return nil
+}
+
+// Gets all calendar import sources. The URLs are relative to the package directories in `internal`
+func (pgRepo *testDBRepository) GetAllCalendarImportSources() ([]models.CalendarImportSource, error) {
+	var sources []models.CalendarImportSource
+	sources = append(sources, models.CalendarImportSource{ ID: 1, RoomID: 1, Name: "Booking site", URL: "file://../channelsync/testdata/external.ics", Room: models.Room{ ID: 1, RoomName: "General's Quarters" } })
+	sources = append(sources, models.CalendarImportSource{ ID: 2, RoomID: 2, Name: "Broken feed", URL: "file://../channelsync/testdata/missing.ics", Room: models.Room{ ID: 2, RoomName: "Major's Suite" } })
+
+	return sources, nil
+}
+
+// Gets a calendar import source by id
+func (pgRepo *testDBRepository) GetCalendarImportSourceByID(id int) (models.CalendarImportSource, error) {
+	sources, _ := pgRepo.GetAllCalendarImportSources()
+
+	for _, source := range sources {
+		if source.ID == id {
+			return source, nil
+		}
+	}
+
+	return models.CalendarImportSource{}, errors.New("calendar import source not found")
+}
+
+// Inserts a calendar import source into the database
+func (pgRepo *testDBRepository) InsertCalendarImportSource(source models.CalendarImportSource) (int, error) {
+	if source.Name == "Invalid" {
+		return 0, errors.New("calendar import source not inserted")
+	}
+
+	return 3, nil
+}
+
+// Deletes a calendar import source
+func (pgRepo *testDBRepository) DeleteCalendarImportSource(id int) error {
+	if id > 2 {
+		return errors.New("calendar import source not found")
+	}
+
+	return nil
+}
+
+// Stores the time and the outcome of the last sync of a calendar import source
+func (pgRepo *testDBRepository) UpdateCalendarImportSourceStatus(id int, syncedAt time.Time, status string) error {
+	return nil
+}
+
+// Gets the external bookings imported from a calendar import source
+func (pgRepo *testDBRepository) GetRestrictionsForSource(sourceID int) ([]models.RoomRestriction, error) {
+	var restrictions []models.RoomRestriction
+
+	if sourceID != 1 {
+		return restrictions, nil
+	}
+
+	// Unchanged in the test calendar
+	restrictions = append(restrictions, models.RoomRestriction{
+		ID: 10,
+		StartDate: time.Date(2050, time.January, 3, 0, 0, 0, 0, time.UTC),
+		EndDate: time.Date(2050, time.January, 5, 0, 0, 0, 0, time.UTC),
+		RoomID: 1,
+		RestrictionID: models.RestrictionExternalBooking,
+		SourceID: 1,
+		ExternalUID: "booking-1@external",
+	})
+
+	// Moved in the test calendar
+	restrictions = append(restrictions, models.RoomRestriction{
+		ID: 11,
+		StartDate: time.Date(2050, time.February, 1, 0, 0, 0, 0, time.UTC),
+		EndDate: time.Date(2050, time.February, 3, 0, 0, 0, 0, time.UTC),
+		RoomID: 1,
+		RestrictionID: models.RestrictionExternalBooking,
+		SourceID: 1,
+		ExternalUID: "booking-2@external",
+	})
+
+	// No longer in the test calendar
+	restrictions = append(restrictions, models.RoomRestriction{
+		ID: 12,
+		StartDate: time.Date(2050, time.April, 1, 0, 0, 0, 0, time.UTC),
+		EndDate: time.Date(2050, time.April, 2, 0, 0, 0, 0, time.UTC),
+		RoomID: 1,
+		RestrictionID: models.RestrictionExternalBooking,
+		SourceID: 1,
+		ExternalUID: "booking-old@external",
+	})
+
+	return restrictions, nil
+}
+
+// Inserts an external booking imported from a calendar import source
+func (pgRepo *testDBRepository) InsertExternalBlock(restriction models.RoomRestriction) error {
+	// If the start date is after 2099-12-31, then fake that the room is not available
+	limitDate := time.Date(2099, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if restriction.StartDate.After(limitDate) {
+		return repository.ErrRoomNotAvailable
+	}
+
+	return nil
+}
+
+// Moves an external booking to new dates
+func (pgRepo *testDBRepository) UpdateExternalBlockDates(id int, startDate, endDate time.Time) error {
+	// If the start date is after 2099-12-31, then fake that the room is not available for the new dates
+	limitDate := time.Date(2099, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if startDate.After(limitDate) {
+		return repository.ErrRoomNotAvailable
+	}
+
+	return nil
+}
+
+// Inserts an API key into the database
+func (pgRepo *testDBRepository) InsertApiKey(key models.ApiKey) (int, error) {
+	if key.Name == "Invalid" {
+		return 0, errors.New("api key not inserted")
+	}
+
+	return 4, nil
+}
+
+// Gets all API keys
+func (pgRepo *testDBRepository) GetAllApiKeys() ([]models.ApiKey, error) {
+	var keys []models.ApiKey
+	keys = append(keys, models.ApiKey{ ID: 1, Name: "Reports", KeyHash: helpers.HashToken("read-key"), Scopes: "reservations:read rooms:read", RateLimit: 1000 })
+	keys = append(keys, models.ApiKey{ ID: 2, Name: "Channel manager", KeyHash: helpers.HashToken("write-key"), Scopes: "reservations:read reservations:write calendar:write", RateLimit: 1000 })
+	keys = append(keys, models.ApiKey{ ID: 3, Name: "Old script", KeyHash: helpers.HashToken("revoked-key"), Scopes: "reservations:read", RateLimit: 1000, Revoked: true })
+	keys = append(keys, models.ApiKey{ ID: 4, Name: "Slow script", KeyHash: helpers.HashToken("limited-key"), Scopes: "rooms:read", RateLimit: 1 })
+
+	return keys, nil
+}
+
+// Gets an API key by the hash of the key
+func (pgRepo *testDBRepository) GetApiKeyByHash(keyHash string) (models.ApiKey, error) {
+	keys, _ := pgRepo.GetAllApiKeys()
+
+	for _, key := range keys {
+		if key.KeyHash == keyHash {
+			return key, nil
+		}
+	}
+
+	return models.ApiKey{}, errors.New("api key not found")
+}
+
+// Gets an API key by id
+func (pgRepo *testDBRepository) GetApiKeyByID(id int) (models.ApiKey, error) {
+	keys, _ := pgRepo.GetAllApiKeys()
+
+	for _, key := range keys {
+		if key.ID == id {
+			return key, nil
+		}
+	}
+
+	return models.ApiKey{}, errors.New("api key not found")
+}
+
+// Revokes an API key
+func (pgRepo *testDBRepository) RevokeApiKey(id int) error {
+	if id > 4 {
+		return errors.New("api key not found")
+	}
+
+	return nil
+}
+
+// Stores the last time an API key was used
+func (pgRepo *testDBRepository) UpdateLastUsedForApiKey(id int, usedAt time.Time) error {
+	return nil
+}
+
+// Gets all webhook endpoints
+func (pgRepo *testDBRepository) GetAllWebhookEndpoints() ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	endpoints = append(endpoints, models.WebhookEndpoint{ ID: 1, URL: "https://example.com/hooks", Description: "Channel manager", Events: "reservation.created reservation.deleted", Secret: "secret", Active: true })
+	endpoints = append(endpoints, models.WebhookEndpoint{ ID: 2, URL: "https://example.com/old-hooks", Description: "Old integration", Events: "block.created", Secret: "secret", Active: false })
+
+	return endpoints, nil
+}
+
+// Gets a webhook endpoint by id
+func (pgRepo *testDBRepository) GetWebhookEndpointByID(id int) (models.WebhookEndpoint, error) {
+	endpoints, _ := pgRepo.GetAllWebhookEndpoints()
+
+	for _, endpoint := range endpoints {
+		if endpoint.ID == id {
+			return endpoint, nil
+		}
+	}
+
+	return models.WebhookEndpoint{}, errors.New("webhook endpoint not found")
+}
+
+// Inserts a webhook endpoint into the database
+func (pgRepo *testDBRepository) InsertWebhookEndpoint(endpoint models.WebhookEndpoint) (int, error) {
+	if endpoint.Description == "Invalid" {
+		return 0, errors.New("webhook endpoint not inserted")
+	}
+
+	return 3, nil
+}
+
+// Activates or deactivates a webhook endpoint
+func (pgRepo *testDBRepository) UpdateActiveForWebhookEndpoint(id int, active bool) error {
+	if id > 2 {
+		return errors.New("webhook endpoint not found")
+	}
+
+	return nil
+}
+
+// Deletes a webhook endpoint
+func (pgRepo *testDBRepository) DeleteWebhookEndpoint(id int) error {
+	if id > 2 {
+		return errors.New("webhook endpoint not found")
+	}
+
+	return nil
+}
+
+// Queues a webhook delivery
+func (pgRepo *testDBRepository) InsertWebhookDelivery(delivery models.WebhookDelivery) (int, error) {
+	return 1, nil
+}
+
+// Claims pending webhook deliveries which are due
+func (pgRepo *testDBRepository) ClaimDueWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+
+	return deliveries, nil
+}
+
+// Gets the latest deliveries of a webhook endpoint
+func (pgRepo *testDBRepository) GetWebhookDeliveriesForEndpoint(endpointID int, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+
+	if endpointID == 1 {
+		deliveries = append(deliveries, models.WebhookDelivery{ ID: 2, EndpointID: 1, Event: "reservation.deleted", Payload: `{"event":"reservation.deleted"}`, Status: models.WebhookDeliveryFailed, Attempts: 8, LastAttemptAt: time.Now(), ResponseStatus: 500, LastError: "endpoint responded with status 500" })
+		deliveries = append(deliveries, models.WebhookDelivery{ ID: 1, EndpointID: 1, Event: "reservation.created", Payload: `{"event":"reservation.created"}`, Status: models.WebhookDeliveryDelivered, Attempts: 1, LastAttemptAt: time.Now(), ResponseStatus: 200 })
+	}
+
+	return deliveries, nil
+}
+
+// Stores the outcome of a webhook delivery attempt
+func (pgRepo *testDBRepository) UpdateWebhookDelivery(delivery models.WebhookDelivery) error {
+	return nil
+}
+
+// Stores emails in the outbox
+func (pgRepo *testDBRepository) InsertOutboxEmails(emails []models.MailData) error {
+	return nil
+}
+
+// Claims pending emails which are due to be sent
+func (pgRepo *testDBRepository) ClaimDueOutboxEmails(now time.Time, lease time.Duration, limit int) ([]models.OutboxEmail, error) {
+	var emails []models.OutboxEmail
+
+	return emails, nil
+}
+
+// Stores the outcome of an attempt to send an email
+func (pgRepo *testDBRepository) UpdateOutboxEmail(email models.OutboxEmail) error {
+	return nil
+}
+
+// Gets the latest emails of the outbox
+func (pgRepo *testDBRepository) GetOutboxEmails(status string, limit int) ([]models.OutboxEmail, error) {
+	var emails []models.OutboxEmail
+
+	all := []models.OutboxEmail{
+		{ ID: 4, MailData: models.MailData{ To: "john@smith.com", From: "me@here.com", Subject: "Reservation cancelled", Content: "Cancelled" }, Status: models.EmailCancelled },
+		{ ID: 3, MailData: models.MailData{ To: "john@smith.com", From: "me@here.com", Subject: "Reservation changed", Content: "Changed" }, Status: models.EmailDead, Attempts: 6, LastAttemptAt: time.Now(), LastError: "dial tcp: connection refused" },
+		{ ID: 2, MailData: models.MailData{ To: "me@here.com", From: "me@here.com", Subject: "Reservation notification", Content: "Booked" }, Status: models.EmailSent, Attempts: 1, LastAttemptAt: time.Now() },
+		{ ID: 1, MailData: models.MailData{ To: "john@smith.com", From: "me@here.com", Subject: "Reservation confirmation", Content: "Confirmed" }, Status: models.EmailPending, NextAttemptAt: time.Now() },
+	}
+
+	for _, email := range all {
+		if status == "" || email.Status == status {
+			emails = append(emails, email)
+		}
+	}
+
+	return emails, nil
+}
+
+// Gets an email of the outbox by id
+func (pgRepo *testDBRepository) GetOutboxEmailByID(id int) (models.OutboxEmail, error) {
+	emails, _ := pgRepo.GetOutboxEmails("", 100)
+
+	for _, email := range emails {
+		if email.ID == id {
+			return email, nil
+		}
+	}
+
+	return models.OutboxEmail{}, errors.New("email not found")
+}
+
+// Queues an email to be sent again
+func (pgRepo *testDBRepository) ResendOutboxEmail(id int) error {
+	if id > 4 {
+		return errors.New("email not found")
+	}
+
+	return nil
+}
+
+// Cancels an email
+func (pgRepo *testDBRepository) CancelOutboxEmail(id int) error {
+	if id > 4 {
+		return errors.New("email not found")
+	}
+
+	return nil
+}
+
+// Gets the reservations arriving between the given dates which the given job hasn't run for yet
+func (pgRepo *testDBRepository) GetReservationsArrivingBetween(startDate, endDate time.Time, job string) ([]models.Reservation, error) {
+	return []models.Reservation{testGuestReservation(2, startDate, false)}, nil
+}
+
+// Gets the reservations departing between the given dates which the given job hasn't run for yet
+func (pgRepo *testDBRepository) GetReservationsDepartingBetween(startDate, endDate time.Time, job string) ([]models.Reservation, error) {
+	return []models.Reservation{testGuestReservation(2, endDate.AddDate(0, 0, -2), false)}, nil
+}
+
+// Gets the reservations arriving and the ones departing on the given date
+func (pgRepo *testDBRepository) GetArrivalsAndDepartures(date time.Time) ([]models.Reservation, []models.Reservation, error) {
+	arrivals := []models.Reservation{testGuestReservation(2, date, false)}
+	departures := []models.Reservation{testGuestReservation(3, date.AddDate(0, 0, -2), false)}
+
+	return arrivals, departures, nil
+}
+
+// Records that a job has run for the given key
+func (pgRepo *testDBRepository) InsertJobRun(job, runKey string, emails []models.MailData) (bool, error) {
+	return true, nil
+}
+
+// Sets whether the guest of a reservation receives the scheduled emails
+func (pgRepo *testDBRepository) UpdateEmailOptOutForReservation(id int, optOut bool) error {
+	if id > 2 {
+		return errors.New("reservation not found")
+	}
+
+	return nil
+}
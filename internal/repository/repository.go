@@ -1,28 +1,143 @@
 package repository
 
 import (
+	"errors"
 	"time"
 
 	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
 )
 
+// Returned when a room has been booked by someone else for overlapping dates
+var ErrRoomNotAvailable = errors.New("room is no longer available for the selected dates")
+
+// Returned when another room already uses the given slug
+var ErrSlugTaken = errors.New("slug is already used by another room")
+
+// Returned when another user already uses the given email
+var ErrEmailTaken = errors.New("email is already used by another user")
+
+// Returned when a disabled user tries to log in
+var ErrUserDisabled = errors.New("user has been disabled")
+
+// Returned when a password reset token doesn't exist, has expired or has already been used
+var ErrResetTokenInvalid = errors.New("password reset token is invalid or has expired")
+
+// Returned when an authenticator or recovery code has already been used or doesn't exist
+var ErrTwoFactorCodeUsed = errors.New("two-factor code has already been used")
+
 type DatabaseRepository interface {
 	InsertReservation(reservation models.Reservation) (int, error)
+	InsertReservationWithRestriction(reservation models.Reservation, emails []models.MailData) (int, error)
+	InsertBookingWithReservations(booking models.Booking, emails []models.MailData) (int, error)
 	InsertRoomRestriction(roomRestriction models.RoomRestriction) error
 	SearchAvailabilityByDatesAndRoom(startDate time.Time, endDate time.Time, roomID int) (bool, error)
-	SearchAvailabilityForAllRooms(startDate time.Time, endDate time.Time) ([]models.Room, error)
+	SearchAvailabilityForAllRooms(startDate time.Time, endDate time.Time, guests int) ([]models.Room, error)
 	GetRoomByID(id int) (models.Room, error)
+	GetRoomBySlug(slug string) (models.Room, error)
+	GetActiveRooms() ([]models.Room, error)
+	InsertRoom(room models.Room) (int, error)
+	UpdateRoom(room models.Room) error
+	UpdateActiveForRoom(id int, active bool) error
+	UpdateSortOrderForRooms(sortOrders map[int]int) error
 	GetUserByID(id int) (models.User, error)
+	GetUserByEmail(email string) (models.User, error)
+	GetAllUsers() ([]models.User, error)
+	InsertUser(user models.User, password string, emails []models.MailData) (int, error)
 	UpdateUser(user models.User) error
+	UpdateUserPassword(id int, password string) (int, error)
+	UpdateDisabledForUser(id int, disabled bool) error
+	UpdatePasswordResetRequiredForUser(id int, required bool) error
+	DeleteUser(id int) error
 	Authenticate(email, password string) (int, string, error)
+	InsertPasswordResetToken(token models.PasswordResetToken, emails []models.MailData) error
+	GetPasswordResetTokenByHash(tokenHash string) (models.PasswordResetToken, error)
+	ResetPasswordWithToken(tokenHash, password string) (int, error)
+	EnableTwoFactorForUser(id int, secret string, recoveryCodeHashes []string) error
+	DisableTwoFactorForUser(id int) error
+	UpdateTwoFactorLastStepForUser(id int, step int64) error
+	ReplaceRecoveryCodes(userID int, codeHashes []string) error
+	UseRecoveryCode(userID int, codeHash string) error
+	CountUnusedRecoveryCodes(userID int) (int, error)
+	GetLoginThrottle(key string) (models.LoginThrottle, error)
+	RecordLoginFailure(key string, at time.Time, window time.Duration) (models.LoginThrottle, error)
+	LockLoginThrottle(key string, until time.Time) error
+	DeleteLoginThrottle(key string) error
+	GetLockedLoginThrottles(now time.Time) ([]models.LoginThrottle, error)
+	DeleteExpiredLoginThrottles(now time.Time, window time.Duration) (int, error)
+	InsertLoginEvent(event models.LoginEvent) error
+	GetLoginEvents(limit int) ([]models.LoginEvent, error)
+	InsertAuditEntry(entry models.AuditEntry) error
+	GetAuditEntries(filter models.AuditFilter) ([]models.AuditEntry, error)
+	GetSession(token string) (models.Session, error)
+	SaveSession(session models.Session) error
+	DeleteSession(token string) error
+	RevokeSession(token string, expiry time.Time) error
+	DeleteExpiredSessions(now time.Time) (int, error)
+	GetSessionsForUser(userID int, now time.Time) ([]models.Session, error)
 	GetAllReservations() ([]models.Reservation, error)
 	GetNewReservations() ([]models.Reservation, error)
 	GetReservationByID(id int) (models.Reservation, error)
+	GetReservationByConfirmationCode(code, email string) (models.Reservation, error)
+	GetBookings() ([]models.Booking, error)
+	GetBookingByID(id int) (models.Booking, error)
+	UpdateReservationDates(reservation models.Reservation, emails []models.MailData) error
+	CancelReservation(id int, emails []models.MailData) error
 	UpdateReservation(reservation models.Reservation) error
 	DeleteReservation(id int) error
 	UpdateProcessedForReservation(id int, processed bool) error
 	GetAllRooms() ([]models.Room, error)
 	GetRestrictionsForRoomByDate(roomID int, startDate, endDate time.Time) ([]models.RoomRestriction, error)
-	InsertBlockForRoom(id int, startDate time.Time) error
+	InsertBlockForRoom(id int, startDate time.Time) (int, error)
 	DeleteBlockByID(id int) error
+	InsertOwnerBlock(block models.RoomRestriction) (int, error)
+	UpdateOwnerBlock(block models.RoomRestriction) error
+	GetOwnerBlocks(from time.Time) ([]models.RoomRestriction, error)
+	GetRoomRestrictionByID(id int) (models.RoomRestriction, error)
+	GetRatePlanForRoom(roomID int) (models.RatePlan, error)
+	GetStayRulesForRoom(roomID int, startDate, endDate time.Time) ([]models.StayRule, error)
+	GetStayRules(from time.Time) ([]models.StayRule, error)
+	GetStayRuleByID(id int) (models.StayRule, error)
+	InsertStayRule(rule models.StayRule) (int, error)
+	UpdateStayRule(rule models.StayRule) error
+	DeleteStayRule(id int) error
+	InsertCalendarFeedToken(token models.CalendarFeedToken) (int, error)
+	GetAllCalendarFeedTokens() ([]models.CalendarFeedToken, error)
+	GetCalendarFeedTokenByHash(tokenHash string) (models.CalendarFeedToken, error)
+	GetCalendarFeedTokenByID(id int) (models.CalendarFeedToken, error)
+	RevokeCalendarFeedToken(id int) error
+	GetAllCalendarImportSources() ([]models.CalendarImportSource, error)
+	GetCalendarImportSourceByID(id int) (models.CalendarImportSource, error)
+	InsertCalendarImportSource(source models.CalendarImportSource) (int, error)
+	DeleteCalendarImportSource(id int) error
+	UpdateCalendarImportSourceStatus(id int, syncedAt time.Time, status string) error
+	GetRestrictionsForSource(sourceID int) ([]models.RoomRestriction, error)
+	InsertExternalBlock(restriction models.RoomRestriction) error
+	UpdateExternalBlockDates(id int, startDate, endDate time.Time) error
+	InsertApiKey(key models.ApiKey) (int, error)
+	GetAllApiKeys() ([]models.ApiKey, error)
+	GetApiKeyByHash(keyHash string) (models.ApiKey, error)
+	GetApiKeyByID(id int) (models.ApiKey, error)
+	RevokeApiKey(id int) error
+	UpdateLastUsedForApiKey(id int, usedAt time.Time) error
+	GetAllWebhookEndpoints() ([]models.WebhookEndpoint, error)
+	GetWebhookEndpointByID(id int) (models.WebhookEndpoint, error)
+	InsertWebhookEndpoint(endpoint models.WebhookEndpoint) (int, error)
+	UpdateActiveForWebhookEndpoint(id int, active bool) error
+	DeleteWebhookEndpoint(id int) error
+	InsertWebhookDelivery(delivery models.WebhookDelivery) (int, error)
+	ClaimDueWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error)
+	GetWebhookDeliveriesForEndpoint(endpointID int, limit int) ([]models.WebhookDelivery, error)
+	UpdateWebhookDelivery(delivery models.WebhookDelivery) error
+	InsertOutboxEmails(emails []models.MailData) error
+	ClaimDueOutboxEmails(now time.Time, lease time.Duration, limit int) ([]models.OutboxEmail, error)
+	UpdateOutboxEmail(email models.OutboxEmail) error
+	GetOutboxEmails(status string, limit int) ([]models.OutboxEmail, error)
+	GetOutboxEmailByID(id int) (models.OutboxEmail, error)
+	ResendOutboxEmail(id int) error
+	CancelOutboxEmail(id int) error
+	GetReservationsArrivingBetween(startDate, endDate time.Time, job string) ([]models.Reservation, error)
+	GetReservationsDepartingBetween(startDate, endDate time.Time, job string) ([]models.Reservation, error)
+	GetArrivalsAndDepartures(date time.Time) ([]models.Reservation, []models.Reservation, error)
+	InsertJobRun(job, runKey string, emails []models.MailData) (bool, error)
+	UpdateEmailOptOutForReservation(id int, optOut bool) error
 }
\ No newline at end of file
@@ -0,0 +1,156 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/emails"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+)
+
+// Names of the email jobs, under which their runs are recorded
+const (
+	JobReminder = "reminder"
+	JobFollowUp = "follow-up"
+	JobOwnerDigest = "owner-digest"
+)
+
+// Follow-ups are still sent this many days late, e.g. when the scheduler hasn't run for a while.
+// Older stays are skipped, so guests of past years don't get a follow-up when the job is enabled
+const followUpCatchUpDays = 3
+
+// Jobs sending the scheduled emails to guests and to the owner
+type GuestEmails struct {
+	DB repository.DatabaseRepository
+	App *config.AppConfig
+}
+
+// Creates the guest email jobs
+func NewGuestEmails(db repository.DatabaseRepository, app *config.AppConfig) *GuestEmails {
+	return &GuestEmails{
+		DB: db,
+		App: app,
+	}
+}
+
+// Adds the jobs which are enabled in the app configuration to the scheduler
+func (guestEmails *GuestEmails) Register(scheduler *Scheduler) {
+	if guestEmails.App.ReminderDaysBefore > 0 {
+		scheduler.Add(JobReminder, guestEmails.SendReminders)
+	}
+
+	if guestEmails.App.FollowUpDaysAfter > 0 {
+		scheduler.Add(JobFollowUp, guestEmails.SendFollowUps)
+	}
+
+	if guestEmails.App.OwnerDigestHour >= 0 {
+		scheduler.Add(JobOwnerDigest, guestEmails.SendOwnerDigest)
+	}
+}
+
+// Reminds the guests arriving within the configured number of days of their stay.
+// Reservations made within that time get the reminder right away
+func (guestEmails *GuestEmails) SendReminders(now time.Time) error {
+	today := dateOf(now)
+
+	reservations, err := guestEmails.DB.GetReservationsArrivingBetween(today, today.AddDate(0, 0, guestEmails.App.ReminderDaysBefore), JobReminder)
+	if err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		mailData, err := emails.NewMail(emails.Reminder, reservation.Email, emails.ReminderData{
+			Reservation: reservation,
+			OptOutURL: guestEmails.optOutURL(reservation),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = guestEmails.DB.InsertJobRun(JobReminder, reservationRunKey(reservation), []models.MailData{mailData})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Asks the guests who departed the configured number of days ago how their stay was
+func (guestEmails *GuestEmails) SendFollowUps(now time.Time) error {
+	lastEndDate := dateOf(now).AddDate(0, 0, -guestEmails.App.FollowUpDaysAfter)
+
+	reservations, err := guestEmails.DB.GetReservationsDepartingBetween(lastEndDate.AddDate(0, 0, -followUpCatchUpDays), lastEndDate, JobFollowUp)
+	if err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		mailData, err := emails.NewMail(emails.FollowUp, reservation.Email, emails.FollowUpData{
+			Reservation: reservation,
+			OptOutURL: guestEmails.optOutURL(reservation),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = guestEmails.DB.InsertJobRun(JobFollowUp, reservationRunKey(reservation), []models.MailData{mailData})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sends the owner the arrivals and departures of the day, once the configured hour has been reached
+func (guestEmails *GuestEmails) SendOwnerDigest(now time.Time) error {
+	if now.Hour() < guestEmails.App.OwnerDigestHour {
+		return nil
+	}
+
+	today := dateOf(now)
+
+	arrivals, departures, err := guestEmails.DB.GetArrivalsAndDepartures(today)
+	if err != nil {
+		return err
+	}
+
+	mailData, err := emails.NewMail(emails.OwnerDigest, guestEmails.App.OwnerEmail, emails.OwnerDigestData{
+		Date: today,
+		Arrivals: arrivals,
+		Departures: departures,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The digest is sent once a day, so the run is recorded under the date
+	_, err = guestEmails.DB.InsertJobRun(JobOwnerDigest, today.Format("2006-01-02"), []models.MailData{mailData})
+
+	return err
+}
+
+// Returns the link guests follow to stop receiving scheduled emails about a reservation.
+// The link contains the confirmation code and email the guest also looks the reservation up with
+func (guestEmails *GuestEmails) optOutURL(reservation models.Reservation) string {
+	query := url.Values{}
+	query.Set("code", reservation.ConfirmationCode)
+	query.Set("email", reservation.Email)
+
+	return fmt.Sprintf("%s/my-reservation/email-opt-out?%s", strings.TrimSuffix(guestEmails.App.BaseURL, "/"), query.Encode())
+}
+
+// Runs of the guest email jobs are recorded per reservation
+func reservationRunKey(reservation models.Reservation) string {
+	return fmt.Sprintf("reservation-%d", reservation.ID)
+}
+
+// Returns the date of the given time, the way reservation dates are stored
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+)
+
+// A job run periodically by the scheduler. Jobs decide themselves whether there is anything due at the given time
+// and record what they did in the database, so they can run any number of times and across restarts
+type Job struct {
+	Name string
+	Run func(now time.Time) error
+}
+
+// Runs jobs in the background
+type Scheduler struct {
+	App *config.AppConfig
+	Jobs []Job
+}
+
+// Creates a new scheduler
+func NewScheduler(app *config.AppConfig) *Scheduler {
+	return &Scheduler{
+		App: app,
+	}
+}
+
+// Adds a job to the scheduler
+func (scheduler *Scheduler) Add(name string, run func(now time.Time) error) {
+	scheduler.Jobs = append(scheduler.Jobs, Job{
+		Name: name,
+		Run: run,
+	})
+}
+
+// Runs all jobs right away and then every time the interval has passed
+func (scheduler *Scheduler) Start(interval time.Duration) {
+	go func() {
+		for {
+			scheduler.RunAll(time.Now())
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// Runs all jobs one after the other. A failing job is logged and doesn't stop the other jobs
+func (scheduler *Scheduler) RunAll(now time.Time) {
+	for _, job := range scheduler.Jobs {
+		err := job.Run(now)
+		if err != nil {
+			scheduler.App.ErrorLog.Printf("Job %s failed: %s", job.Name, err)
+		}
+	}
+}
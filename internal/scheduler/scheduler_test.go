@@ -0,0 +1,269 @@
+package scheduler
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/emails"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+)
+
+// Repository keeping reservations and job runs in memory.
+// Calling any other method of `repository.DatabaseRepository` panics
+type fakeRepository struct {
+	repository.DatabaseRepository
+	reservations []models.Reservation
+	runs map[string]bool
+	queued []models.MailData
+}
+
+func newFakeRepository(reservations ...models.Reservation) *fakeRepository {
+	return &fakeRepository{
+		reservations: reservations,
+		runs: make(map[string]bool),
+	}
+}
+
+func (repo *fakeRepository) GetReservationsArrivingBetween(startDate, endDate time.Time, job string) ([]models.Reservation, error) {
+	var reservations []models.Reservation
+
+	for _, reservation := range repo.reservations {
+		if reservation.StartDate.Before(startDate) || reservation.StartDate.After(endDate) {
+			continue
+		}
+
+		if reservation.Cancelled || reservation.EmailOptOut || repo.runs[job + reservationRunKey(reservation)] {
+			continue
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	return reservations, nil
+}
+
+func (repo *fakeRepository) GetReservationsDepartingBetween(startDate, endDate time.Time, job string) ([]models.Reservation, error) {
+	var reservations []models.Reservation
+
+	for _, reservation := range repo.reservations {
+		if reservation.EndDate.Before(startDate) || reservation.EndDate.After(endDate) {
+			continue
+		}
+
+		if reservation.Cancelled || reservation.EmailOptOut || repo.runs[job + reservationRunKey(reservation)] {
+			continue
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	return reservations, nil
+}
+
+func (repo *fakeRepository) GetArrivalsAndDepartures(date time.Time) ([]models.Reservation, []models.Reservation, error) {
+	var arrivals, departures []models.Reservation
+
+	for _, reservation := range repo.reservations {
+		if reservation.StartDate.Equal(date) {
+			arrivals = append(arrivals, reservation)
+		}
+
+		if reservation.EndDate.Equal(date) {
+			departures = append(departures, reservation)
+		}
+	}
+
+	return arrivals, departures, nil
+}
+
+func (repo *fakeRepository) InsertJobRun(job, runKey string, emails []models.MailData) (bool, error) {
+	if repo.runs[job + runKey] {
+		return false, nil
+	}
+
+	repo.runs[job + runKey] = true
+	repo.queued = append(repo.queued, emails...)
+
+	return true, nil
+}
+
+var testApp = &config.AppConfig{
+	InfoLog: log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime),
+	ErrorLog: log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile),
+	ReminderDaysBefore: 3,
+	FollowUpDaysAfter: 1,
+	OwnerDigestHour: 7,
+	BaseURL: "https://example.com/",
+	OwnerEmail: "owner@example.com",
+}
+
+// 2021-12-20 08:30
+var now = time.Date(2021, time.December, 20, 8, 30, 0, 0, time.Local)
+
+func TestMain(m *testing.M) {
+	err := emails.LoadTemplates("../../email-templates")
+	if err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+func day(dayOfMonth int) time.Time {
+	return time.Date(2021, time.December, dayOfMonth, 0, 0, 0, 0, time.UTC)
+}
+
+func reservation(id int, startDate, endDate time.Time) models.Reservation {
+	return models.Reservation{
+		ID: id,
+		FirstName: "John",
+		LastName: "Smith",
+		Email: "john@smith.com",
+		StartDate: startDate,
+		EndDate: endDate,
+		ConfirmationCode: "ABCDEFGHJK",
+		Room: models.Room{ID: 1, RoomName: "General's Quarters"},
+	}
+}
+
+func TestGuestEmails_SendReminders(t *testing.T) {
+	cancelled := reservation(4, day(21), day(23))
+	cancelled.Cancelled = true
+
+	optedOut := reservation(5, day(21), day(23))
+	optedOut.EmailOptOut = true
+
+	repo := newFakeRepository(
+		reservation(1, day(23), day(25)),
+		reservation(2, day(24), day(26)),
+		reservation(3, day(19), day(22)),
+		cancelled,
+		optedOut,
+	)
+
+	guestEmails := NewGuestEmails(repo, testApp)
+
+	err := guestEmails.SendReminders(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the reservation arriving within 3 days gets a reminder
+	if len(repo.queued) != 1 || !repo.runs[JobReminder + "reservation-1"] {
+		t.Fatalf("Wrong reminders: %+v", repo.queued)
+	}
+
+	reminder := repo.queued[0]
+	if reminder.To != "john@smith.com" || reminder.Template != emails.Reminder {
+		t.Errorf("Wrong reminder: %+v", reminder)
+	}
+
+	expectedURL := "https://example.com/my-reservation/email-opt-out?code=ABCDEFGHJK&amp;email=john%40smith.com"
+	if !strings.Contains(reminder.Content, expectedURL) {
+		t.Errorf("Reminder doesn't link to %s", expectedURL)
+	}
+
+	// Running the job again doesn't send the reminder twice
+	err = guestEmails.SendReminders(now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(repo.queued) != 1 {
+		t.Errorf("Reminder was sent again: %+v", repo.queued)
+	}
+}
+
+func TestGuestEmails_SendFollowUps(t *testing.T) {
+	repo := newFakeRepository(
+		reservation(1, day(17), day(19)),
+		reservation(2, day(18), day(20)),
+		reservation(3, day(10), day(12)),
+	)
+
+	guestEmails := NewGuestEmails(repo, testApp)
+
+	err := guestEmails.SendFollowUps(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The guests who left today are asked tomorrow, stays which ended too long ago are skipped
+	if len(repo.queued) != 1 || !repo.runs[JobFollowUp + "reservation-1"] {
+		t.Errorf("Wrong follow-ups: %+v", repo.queued)
+	}
+}
+
+func TestGuestEmails_SendOwnerDigest(t *testing.T) {
+	repo := newFakeRepository(
+		reservation(1, day(20), day(22)),
+		reservation(2, day(18), day(20)),
+	)
+
+	guestEmails := NewGuestEmails(repo, testApp)
+
+	// The digest isn't sent before the configured hour
+	err := guestEmails.SendOwnerDigest(now.Add(-2 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(repo.queued) != 0 {
+		t.Fatalf("Digest was sent too early: %+v", repo.queued)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = guestEmails.SendOwnerDigest(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(repo.queued) != 1 || !repo.runs[JobOwnerDigest + "2021-12-20"] {
+		t.Fatalf("Wrong digests: %+v", repo.queued)
+	}
+
+	if repo.queued[0].To != testApp.OwnerEmail || !strings.Contains(repo.queued[0].TextContent, "General's Quarters: John Smith") {
+		t.Errorf("Wrong digest: %+v", repo.queued[0])
+	}
+}
+
+func TestGuestEmails_Register(t *testing.T) {
+	app := *testApp
+	app.FollowUpDaysAfter = 0
+	app.OwnerDigestHour = -1
+
+	scheduler := NewScheduler(&app)
+	NewGuestEmails(newFakeRepository(), &app).Register(scheduler)
+
+	if len(scheduler.Jobs) != 1 || scheduler.Jobs[0].Name != JobReminder {
+		t.Errorf("Wrong jobs: %+v", scheduler.Jobs)
+	}
+}
+
+func TestScheduler_RunAll(t *testing.T) {
+	var ran []string
+
+	scheduler := NewScheduler(testApp)
+	scheduler.Add("failing", func(now time.Time) error {
+		ran = append(ran, "failing")
+		return errors.New("failed")
+	})
+	scheduler.Add("working", func(now time.Time) error {
+		ran = append(ran, "working")
+		return nil
+	})
+
+	// A failing job doesn't stop the jobs after it
+	scheduler.RunAll(now)
+
+	if strings.Join(ran, ",") != "failing,working" {
+		t.Errorf("Wrong jobs ran: %v", ran)
+	}
+}
@@ -0,0 +1,94 @@
+package sessionstore
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+	"github.com/alexedwards/scs/v2"
+)
+
+// Store keeping the sessions in the database
+type DatabaseStore struct {
+	DB repository.DatabaseRepository
+	Codec scs.Codec
+	// Replaced in tests
+	now func() time.Time
+}
+
+// Creates a store on top of the given repository
+func NewDatabaseStore(db repository.DatabaseRepository, codec scs.Codec) *DatabaseStore {
+	return &DatabaseStore{
+		DB: db,
+		Codec: codec,
+		now: time.Now,
+	}
+}
+
+// Returns the data of a session. Sessions which don't exist or have expired aren't found
+func (store *DatabaseStore) Find(token string) ([]byte, bool, error) {
+	session, err := store.DB.GetSession(token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !session.Expiry.After(store.now()) {
+		return nil, false, nil
+	}
+
+	return session.Data, true, nil
+}
+
+// Stores the data of a session until it expires. The logged in user and where they logged in from
+// are read from the session values, so that the sessions of a user can be listed and revoked
+func (store *DatabaseStore) Commit(token string, data []byte, expiry time.Time) error {
+	_, values, err := store.Codec.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	userID, _ := values[KeyUserID].(int)
+	ipAddress, _ := values[KeyIPAddress].(string)
+	userAgent, _ := values[KeyUserAgent].(string)
+
+	return store.DB.SaveSession(models.Session{
+		Token: token,
+		Data: data,
+		Expiry: expiry.UTC(),
+		UserID: userID,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	})
+}
+
+// Deletes a session
+func (store *DatabaseStore) Delete(token string) error {
+	return store.DB.DeleteSession(token)
+}
+
+// Deletes the expired sessions. Returns the number of deleted sessions
+func (store *DatabaseStore) DeleteExpired() (int, error) {
+	return store.DB.DeleteExpiredSessions(store.now().UTC())
+}
+
+// Deletes the expired sessions once every interval. Runs in the background
+func (store *DatabaseStore) StartCleanup(interval time.Duration, errorLog *log.Logger) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			_, err := store.DeleteExpired()
+			if err != nil {
+				errorLog.Println("Can't delete expired sessions:", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,38 @@
+// Package sessionstore keeps the sessions of the session manager in the database, so that staff stay logged in
+// and guests keep their reservation in progress across restarts, and several servers can share the sessions
+package sessionstore
+
+import (
+	"fmt"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+// Stores of the sessions
+const (
+	// Keeps the sessions in memory, so they are lost on restart and not shared between servers
+	StoreMemory = "memory"
+	// Keeps the sessions in the `sessions` table
+	StorePostgres = "postgres"
+)
+
+// Session values which are also stored in columns of the `sessions` table, so that the sessions of a user can be listed
+const (
+	KeyUserID = "user_id"
+	KeyIPAddress = "ip_address"
+	KeyUserAgent = "user_agent"
+)
+
+// Creates the store of the configured backend. Sessions are encoded with `codec`, which has to be the codec of the session manager
+func New(backend string, db repository.DatabaseRepository, codec scs.Codec) (scs.Store, error) {
+	switch backend {
+	case StoreMemory:
+		return memstore.New(), nil
+	case StorePostgres:
+		return NewDatabaseStore(db, codec), nil
+	default:
+		return nil, fmt.Errorf("unknown session store %q", backend)
+	}
+}
@@ -0,0 +1,165 @@
+package sessionstore
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+	"github.com/alexedwards/scs/v2"
+)
+
+// Test repository keeping the sessions in a map
+type sessionRepository struct {
+	repository.DatabaseRepository
+	sessions map[string]models.Session
+}
+
+func (repo *sessionRepository) GetSession(token string) (models.Session, error) {
+	session, ok := repo.sessions[token]
+	if !ok {
+		return models.Session{}, sql.ErrNoRows
+	}
+
+	return session, nil
+}
+
+func (repo *sessionRepository) SaveSession(session models.Session) error {
+	repo.sessions[session.Token] = session
+
+	return nil
+}
+
+func (repo *sessionRepository) DeleteSession(token string) error {
+	delete(repo.sessions, token)
+
+	return nil
+}
+
+func (repo *sessionRepository) DeleteExpiredSessions(now time.Time) (int, error) {
+	deleted := 0
+	for token, session := range repo.sessions {
+		if !session.Expiry.After(now) {
+			delete(repo.sessions, token)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// Creates a store with an empty repository and a clock which only moves when the test moves it
+func newTestStore(now *time.Time) (*DatabaseStore, *sessionRepository) {
+	repo := &sessionRepository{sessions: make(map[string]models.Session)}
+
+	store := NewDatabaseStore(repo, scs.GobCodec{})
+	store.now = func() time.Time { return *now }
+
+	return store, repo
+}
+
+// Encodes session values the way the session manager does
+func encode(t *testing.T, values map[string]interface{}) []byte {
+	data, err := scs.GobCodec{}.Encode(time.Now(), values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestDatabaseStore_CommitAndFind(t *testing.T) {
+	now := time.Date(2050, time.January, 1, 12, 0, 0, 0, time.UTC)
+	store, repo := newTestStore(&now)
+
+	data := encode(t, map[string]interface{}{
+		KeyUserID: 1,
+		KeyIPAddress: "192.0.2.1",
+		KeyUserAgent: "Firefox",
+		"success": "Logged in successfully",
+	})
+
+	err := store.Commit("token", data, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := repo.sessions["token"]
+	if session.UserID != 1 || session.IPAddress != "192.0.2.1" || session.UserAgent != "Firefox" {
+		t.Errorf("Committed session has wrong user details: %+v", session)
+	}
+
+	found, ok, err := store.Find("token")
+	if err != nil || !ok || string(found) != string(data) {
+		t.Errorf("Committed session should be found: ok %t, error %v", ok, err)
+	}
+
+	// Sessions of visitors who aren't logged in have no user
+	err = store.Commit("guest-token", encode(t, map[string]interface{}{"reservation": "in progress"}), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if repo.sessions["guest-token"].UserID != 0 {
+		t.Error("Session without a logged in user should have no user")
+	}
+
+	if _, ok, _ := store.Find("unknown-token"); ok {
+		t.Error("Unknown session should not be found")
+	}
+}
+
+func TestDatabaseStore_Expiry(t *testing.T) {
+	now := time.Date(2050, time.January, 1, 12, 0, 0, 0, time.UTC)
+	store, repo := newTestStore(&now)
+
+	store.Commit("expiring-token", encode(t, nil), now.Add(time.Minute))
+	store.Commit("lasting-token", encode(t, nil), now.Add(time.Hour))
+
+	now = now.Add(time.Minute)
+
+	if _, ok, _ := store.Find("expiring-token"); ok {
+		t.Error("Expired session should not be found")
+	}
+
+	deleted, err := store.DeleteExpired()
+	if err != nil || deleted != 1 {
+		t.Errorf("Cleanup deleted %d sessions, wanted 1 (error %v)", deleted, err)
+	}
+
+	if _, ok := repo.sessions["lasting-token"]; !ok {
+		t.Error("Cleanup should keep the sessions which haven't expired")
+	}
+}
+
+func TestDatabaseStore_Delete(t *testing.T) {
+	now := time.Date(2050, time.January, 1, 12, 0, 0, 0, time.UTC)
+	store, _ := newTestStore(&now)
+
+	store.Commit("token", encode(t, nil), now.Add(time.Hour))
+
+	err := store.Delete("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, _ := store.Find("token"); ok {
+		t.Error("Deleted session should not be found")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(StoreMemory, nil, scs.GobCodec{}); err != nil {
+		t.Error(err)
+	}
+
+	store, err := New(StorePostgres, nil, scs.GobCodec{})
+	if _, ok := store.(*DatabaseStore); err != nil || !ok {
+		t.Errorf("Postgres store should be a database store, got %T (error %v)", store, err)
+	}
+
+	if _, err := New("redis", nil, scs.GobCodec{}); err == nil {
+		t.Error("Unknown store should return an error")
+	}
+}
@@ -0,0 +1,85 @@
+// Package stayrules checks stays against the minimum and maximum stay, closed to arrival,
+// closed to departure and check-in day rules of a room
+package stayrules
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+// Format of the dates in the reasons
+const dateFormat = "Jan 2, 2006"
+
+// Returns the reasons why a stay from the arrival date (`startDate`) to the departure date (`endDate`) can't be booked,
+// or nil if the stay follows all the rules. The length of the stay, closed to arrival and the check-in days are checked
+// against the rules covering the arrival date, closed to departure against the rules covering the departure date
+func Check(rules []models.StayRule, startDate, endDate time.Time) []string {
+	var reasons []string
+
+	// Rounded, since a day isn't 24 hours long when the clocks change
+	nights := int(math.Round(endDate.Sub(startDate).Hours() / 24))
+
+	for _, rule := range rules {
+		period := fmt.Sprintf("between %s and %s", rule.StartDate.Format(dateFormat), rule.EndDate.Format(dateFormat))
+
+		if rule.Covers(startDate) {
+			if rule.MinNights > 0 && nights < rule.MinNights {
+				reasons = addReason(reasons, fmt.Sprintf("Stays arriving %s must be at least %s", period, formatNights(rule.MinNights)))
+			}
+
+			if rule.MaxNights > 0 && nights > rule.MaxNights {
+				reasons = addReason(reasons, fmt.Sprintf("Stays arriving %s can't be longer than %s", period, formatNights(rule.MaxNights)))
+			}
+
+			if rule.ClosedToArrival {
+				reasons = addReason(reasons, fmt.Sprintf("Guests can't arrive on %s", startDate.Format("Monday, " + dateFormat)))
+			} else if !rule.AllowsArrivalOn(startDate.Weekday()) {
+				reasons = addReason(reasons, fmt.Sprintf("Stays %s have to start on a %s", period, formatWeekdays(rule.ArrivalWeekdays())))
+			}
+		}
+
+		if rule.ClosedToDeparture && rule.Covers(endDate) {
+			reasons = addReason(reasons, fmt.Sprintf("Guests can't leave on %s", endDate.Format("Monday, " + dateFormat)))
+		}
+	}
+
+	return reasons
+}
+
+// Adds a reason unless an overlapping rule already gave the same one
+func addReason(reasons []string, reason string) []string {
+	for _, existing := range reasons {
+		if existing == reason {
+			return reasons
+		}
+	}
+
+	return append(reasons, reason)
+}
+
+// Formats a number of nights, e.g. "1 night" or "3 nights"
+func formatNights(nights int) string {
+	if nights == 1 {
+		return "1 night"
+	}
+
+	return fmt.Sprintf("%d nights", nights)
+}
+
+// Formats weekdays as a list, e.g. "Friday or Saturday"
+func formatWeekdays(days []time.Weekday) string {
+	var names []string
+	for _, day := range days {
+		names = append(names, day.String())
+	}
+
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+
+	return strings.Join(names[:len(names) - 1], ", ") + " or " + names[len(names) - 1]
+}
@@ -0,0 +1,126 @@
+package stayrules
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+var testRules = []models.StayRule{
+	{
+		Name: "Summer",
+		StartDate: time.Date(2050, time.July, 1, 0, 0, 0, 0, time.UTC),
+		EndDate: time.Date(2050, time.August, 31, 0, 0, 0, 0, time.UTC),
+		MinNights: 3,
+		MaxNights: 14,
+		// Friday and Saturday
+		ArrivalDays: 1 << uint(time.Friday) | 1 << uint(time.Saturday),
+	},
+	{
+		Name: "Christmas",
+		StartDate: time.Date(2050, time.December, 24, 0, 0, 0, 0, time.UTC),
+		EndDate: time.Date(2050, time.December, 26, 0, 0, 0, 0, time.UTC),
+		ClosedToArrival: true,
+		ClosedToDeparture: true,
+	},
+}
+
+var checkTests = []struct {
+	name            string
+	startDate       time.Time
+	endDate         time.Time
+	expectedReasons []string
+}{
+	{
+		"No rule covers the stay",
+		time.Date(2050, time.January, 3, 0, 0, 0, 0, time.UTC),
+		time.Date(2050, time.January, 4, 0, 0, 0, 0, time.UTC),
+		nil,
+	},
+	{
+		// 2050-07-02 is a Saturday
+		"Stay follows the rules",
+		time.Date(2050, time.July, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2050, time.July, 9, 0, 0, 0, 0, time.UTC),
+		nil,
+	},
+	{
+		"Stay too short",
+		time.Date(2050, time.July, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2050, time.July, 3, 0, 0, 0, 0, time.UTC),
+		[]string{"Stays arriving between Jul 1, 2050 and Aug 31, 2050 must be at least 3 nights"},
+	},
+	{
+		"Stay too long",
+		time.Date(2050, time.July, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2050, time.July, 30, 0, 0, 0, 0, time.UTC),
+		[]string{"Stays arriving between Jul 1, 2050 and Aug 31, 2050 can't be longer than 14 nights"},
+	},
+	{
+		// 2050-07-04 is a Monday
+		"Wrong check-in day",
+		time.Date(2050, time.July, 4, 0, 0, 0, 0, time.UTC),
+		time.Date(2050, time.July, 8, 0, 0, 0, 0, time.UTC),
+		[]string{"Stays between Jul 1, 2050 and Aug 31, 2050 have to start on a Friday or Saturday"},
+	},
+	{
+		// Rules only limit stays arriving in their period
+		"Stay arriving before the rule",
+		time.Date(2050, time.June, 30, 0, 0, 0, 0, time.UTC),
+		time.Date(2050, time.July, 1, 0, 0, 0, 0, time.UTC),
+		nil,
+	},
+	{
+		"Closed to arrival",
+		time.Date(2050, time.December, 24, 0, 0, 0, 0, time.UTC),
+		time.Date(2050, time.December, 28, 0, 0, 0, 0, time.UTC),
+		[]string{"Guests can't arrive on Saturday, Dec 24, 2050"},
+	},
+	{
+		"Closed to departure",
+		time.Date(2050, time.December, 20, 0, 0, 0, 0, time.UTC),
+		time.Date(2050, time.December, 26, 0, 0, 0, 0, time.UTC),
+		[]string{"Guests can't leave on Monday, Dec 26, 2050"},
+	},
+	{
+		"Closed to arrival and departure",
+		time.Date(2050, time.December, 24, 0, 0, 0, 0, time.UTC),
+		time.Date(2050, time.December, 25, 0, 0, 0, 0, time.UTC),
+		[]string{"Guests can't arrive on Saturday, Dec 24, 2050", "Guests can't leave on Sunday, Dec 25, 2050"},
+	},
+}
+
+func TestCheck(t *testing.T) {
+	for _, test := range checkTests {
+		reasons := Check(testRules, test.startDate, test.endDate)
+
+		if !reflect.DeepEqual(reasons, test.expectedReasons) {
+			t.Errorf("Test %s returned reasons %q, wanted %q", test.name, reasons, test.expectedReasons)
+		}
+	}
+}
+
+func TestCheck_OverlappingRules(t *testing.T) {
+	rules := append([]models.StayRule{}, testRules[0], testRules[0])
+
+	reasons := Check(rules, time.Date(2050, time.July, 2, 0, 0, 0, 0, time.UTC), time.Date(2050, time.July, 3, 0, 0, 0, 0, time.UTC))
+	if len(reasons) != 1 {
+		t.Errorf("Rules giving the same reason should only give it once, got %q", reasons)
+	}
+}
+
+func TestFormatWeekdays(t *testing.T) {
+	tests := map[string][]time.Weekday{
+		"Saturday": {time.Saturday},
+		"Friday or Saturday": {time.Friday, time.Saturday},
+		"Sunday, Friday or Saturday": {time.Sunday, time.Friday, time.Saturday},
+	}
+
+	for expected, days := range tests {
+		if formatted := formatWeekdays(days); formatted != expected {
+			t.Errorf("Weekdays %v formatted as %s, wanted %s", days, formatted, expected)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Secret of the SHA1 test vectors of RFC 6238
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// Last 6 digits of the 8 digit codes of RFC 6238, appendix B
+	codes := map[int64]string{
+		59: "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+		20000000000: "353130",
+	}
+
+	for unix, expected := range codes {
+		code, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code != expected {
+			t.Errorf("Wrong code at %d: got %s, wanted %s", unix, code, expected)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		valid    bool
+	}{
+		{"Current step", now, true},
+		{"Previous step", now.Add(-StepDuration), true},
+		{"Next step", now.Add(StepDuration), true},
+		{"Two steps ago", now.Add(-2 * StepDuration), false},
+	}
+
+	for _, test := range tests {
+		code, _ := Code(rfcSecret, Step(test.at))
+
+		step, valid := Validate(rfcSecret, code, now)
+		if valid != test.valid {
+			t.Errorf("Test %s: got %t, wanted %t", test.name, valid, test.valid)
+		}
+
+		if valid && step != Step(test.at) {
+			t.Errorf("Test %s returned wrong step %d", test.name, step)
+		}
+	}
+
+	if _, valid := Validate(rfcSecret, "050 471", now); !valid {
+		t.Error("Code with a space is not accepted")
+	}
+
+	if _, valid := Validate(rfcSecret, "12345", now); valid {
+		t.Error("Short code is accepted")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(secret) != 32 {
+		t.Errorf("Wrong secret length: %d", len(secret))
+	}
+
+	if _, err := Code(secret, 1); err != nil {
+		t.Errorf("Generated secret can't be used: %s", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Bed and Breakfast", "me@here.com", "JBSWY3DPEHPK3PXP")
+
+	for _, part := range []string{"otpauth://totp/Bed%20and%20Breakfast:me@here.com?", "secret=JBSWY3DPEHPK3PXP", "issuer=Bed%20and%20Breakfast"} {
+		if !strings.Contains(uri, part) {
+			t.Errorf("URI %s is missing %s", uri, part)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Package usercommand implements the `user` subcommand, which manages admin users without touching SQL,
+// e.g. to create the first owner of a new installation
+package usercommand
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/forms"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+)
+
+const Usage = `Usage: bed-and-breakfast user <create|passwd|disable> [flags]
+
+  create   Creates a user, e.g. the first owner: user create -email=me@here.com -first=Jane -last=Doe -role=owner
+  passwd   Sets a new password for the user with the given -email
+  disable  Stops the user with the given -email from logging in
+
+Passwords are read from standard input. The database is given with the same flags as for the server`
+
+// Roles accepted by the -role flag
+var Roles = map[string]int{
+	"owner": models.AccessOwner,
+	"front-desk": models.AccessFrontDesk,
+	"housekeeping": models.AccessHousekeeping,
+}
+
+// Options of the `user` subcommand
+type Options struct {
+	Email string
+	FirstName string
+	LastName string
+	Role string
+}
+
+// Runs the `user` subcommands against a database
+type Runner struct {
+	DB repository.DatabaseRepository
+	App *config.AppConfig
+}
+
+// Creates a new runner
+func NewRunner(db repository.DatabaseRepository, app *config.AppConfig) *Runner {
+	return &Runner{
+		DB: db,
+		App: app,
+	}
+}
+
+// Runs a `user` subcommand. Passwords are read from `stdin` and the outcome is printed to `stdout`
+func (runner *Runner) Run(command string, options Options, stdin io.Reader, stdout io.Writer) error {
+	if options.Email == "" {
+		return errors.New("the -email flag is required")
+	}
+
+	input := bufio.NewScanner(stdin)
+
+	switch command {
+	case "create":
+		accessLevel, ok := Roles[options.Role]
+		if !ok {
+			return fmt.Errorf("unknown role %q", options.Role)
+		}
+
+		if options.FirstName == "" || options.LastName == "" {
+			return errors.New("the -first and -last flags are required")
+		}
+
+		password, err := readPassword(input, stdout)
+		if err != nil {
+			return err
+		}
+
+		id, err := runner.DB.InsertUser(models.User{
+			FirstName: options.FirstName,
+			LastName: options.LastName,
+			Email: options.Email,
+			AccessLevel: accessLevel,
+		}, password, nil)
+		if err != nil {
+			return err
+		}
+
+		runner.audit(audit.ActionCreated, id, nil, map[string]interface{}{"email": options.Email, "first_name": options.FirstName, "last_name": options.LastName, "access_level": accessLevel})
+
+		fmt.Fprintf(stdout, "Created user %d (%s)\n", id, options.Email)
+	case "passwd":
+		user, err := runner.DB.GetUserByEmail(options.Email)
+		if err != nil {
+			return fmt.Errorf("cannot find user %s: %w", options.Email, err)
+		}
+
+		password, err := readPassword(input, stdout)
+		if err != nil {
+			return err
+		}
+
+		_, err = runner.DB.UpdateUserPassword(user.ID, password)
+		if err != nil {
+			return err
+		}
+
+		runner.audit(audit.ActionPasswordChanged, user.ID, nil, nil)
+
+		fmt.Fprintf(stdout, "Changed password of %s\n", options.Email)
+	case "disable":
+		user, err := runner.DB.GetUserByEmail(options.Email)
+		if err != nil {
+			return fmt.Errorf("cannot find user %s: %w", options.Email, err)
+		}
+
+		err = runner.DB.UpdateDisabledForUser(user.ID, true)
+		if err != nil {
+			return err
+		}
+
+		runner.audit(audit.ActionDisabled, user.ID, map[string]bool{"disabled": user.Disabled}, map[string]bool{"disabled": true})
+
+		fmt.Fprintf(stdout, "Disabled %s\n", options.Email)
+	default:
+		return errors.New(Usage)
+	}
+
+	return nil
+}
+
+// Records a change made by the `user` subcommand in the audit log. Such changes have no user,
+// a failure is only logged since the change itself is already saved
+func (runner *Runner) audit(action string, userID int, before, after interface{}) {
+	err := audit.Record(runner.DB, models.AuditEntry{
+		Action: action,
+		EntityType: audit.EntityUser,
+		EntityID: userID,
+	}, before, after)
+	if err != nil {
+		runner.App.ErrorLog.Println("Can't record audit entry:", err)
+	}
+}
+
+// Reads a new password and its confirmation, one per line
+func readPassword(input *bufio.Scanner, stdout io.Writer) (string, error) {
+	var lines []string
+
+	for _, prompt := range []string{"Password: ", "Repeat password: "} {
+		fmt.Fprint(stdout, prompt)
+
+		if !input.Scan() {
+			return "", errors.New("no password given")
+		}
+
+		lines = append(lines, strings.TrimRight(input.Text(), "\r"))
+	}
+
+	fmt.Fprintln(stdout)
+
+	if lines[0] != lines[1] {
+		return "", errors.New("passwords don't match")
+	}
+
+	problem := forms.PasswordProblem(lines[0])
+	if problem != "" {
+		return "", errors.New(problem)
+	}
+
+	return lines[0], nil
+}
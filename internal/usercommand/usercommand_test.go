@@ -0,0 +1,85 @@
+package usercommand
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/audit"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+	dbrepository "github.com/LuisBarroso37/bed-and-breakfast/internal/repository/db-repository"
+)
+
+var userCommandTests = []struct {
+	name           string
+	command        string
+	options        Options
+	input          string
+	expectedOutput string
+	expectedError  string
+}{
+	{"Creates user", "create", Options{Email: "jane@doe.com", FirstName: "Jane", LastName: "Doe", Role: "owner"}, "Sunny room 4\nSunny room 4\n", "Created user 7 (jane@doe.com)", ""},
+	{"Create user with taken email", "create", Options{Email: "me@here.com", FirstName: "Jane", LastName: "Doe", Role: "owner"}, "Sunny room 4\nSunny room 4\n", "", "email is already used"},
+	{"Create user with unknown role", "create", Options{Email: "jane@doe.com", FirstName: "Jane", LastName: "Doe", Role: "manager"}, "", "", "unknown role"},
+	{"Create user without name", "create", Options{Email: "jane@doe.com", Role: "owner"}, "", "", "-first and -last"},
+	{"Create user with mismatched passwords", "create", Options{Email: "jane@doe.com", FirstName: "Jane", LastName: "Doe", Role: "owner"}, "Sunny room 4\nSunny room 5\n", "", "don't match"},
+	{"Create user with weak password", "create", Options{Email: "jane@doe.com", FirstName: "Jane", LastName: "Doe", Role: "owner"}, "password\npassword\n", "", "too common"},
+	{"Create user with short password", "create", Options{Email: "jane@doe.com", FirstName: "Jane", LastName: "Doe", Role: "owner"}, "short\nshort\n", "", "at least 8 characters"},
+	{"Changes password", "passwd", Options{Email: "me@here.com"}, "Sunny room 4\nSunny room 4\n", "Changed password of me@here.com", ""},
+	{"Change password of unknown user", "passwd", Options{Email: "jane@doe.com"}, "Sunny room 4\nSunny room 4\n", "", "cannot find user"},
+	{"Disables user", "disable", Options{Email: "desk@here.com"}, "", "Disabled desk@here.com", ""},
+	{"Missing email", "disable", Options{}, "", "", "-email flag is required"},
+	{"Unknown command", "rename", Options{Email: "me@here.com"}, "", "", "Usage:"},
+}
+
+func TestUserCommand(t *testing.T) {
+	var app config.AppConfig
+	runner := NewRunner(dbrepository.NewTestRepository(&app), &app)
+
+	for _, test := range userCommandTests {
+		var output bytes.Buffer
+
+		err := runner.Run(test.command, test.options, strings.NewReader(test.input), &output)
+
+		if test.expectedError == "" && err != nil {
+			t.Errorf("Test %s returned error: %s", test.name, err)
+		}
+
+		if test.expectedError != "" && (err == nil || !strings.Contains(err.Error(), test.expectedError)) {
+			t.Errorf("Test %s returned wrong error: got %v, wanted %q", test.name, err, test.expectedError)
+		}
+
+		if !strings.Contains(output.String(), test.expectedOutput) {
+			t.Errorf("Test %s printed %q, wanted %q", test.name, output.String(), test.expectedOutput)
+		}
+	}
+}
+
+// Test repository which keeps the audit entries instead of dropping them
+type auditRecorder struct {
+	repository.DatabaseRepository
+	entries []models.AuditEntry
+}
+
+func (recorder *auditRecorder) InsertAuditEntry(entry models.AuditEntry) error {
+	recorder.entries = append(recorder.entries, entry)
+
+	return nil
+}
+
+func TestUserCommand_Audit(t *testing.T) {
+	var app config.AppConfig
+	recorder := &auditRecorder{DatabaseRepository: dbrepository.NewTestRepository(&app)}
+
+	err := NewRunner(recorder, &app).Run("disable", Options{Email: "desk@here.com"}, strings.NewReader(""), &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes from the command line have no user
+	if len(recorder.entries) != 1 || recorder.entries[0].Action != audit.ActionDisabled || recorder.entries[0].EntityType != audit.EntityUser || recorder.entries[0].UserID != 0 {
+		t.Errorf("Disabling a user recorded wrong audit entries: %+v", recorder.entries)
+	}
+}
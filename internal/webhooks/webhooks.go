@@ -0,0 +1,118 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+)
+
+// Events which webhook endpoints can subscribe to
+const (
+	EventReservationCreated = "reservation.created"
+	EventReservationUpdated = "reservation.updated"
+	EventReservationProcessed = "reservation.processed"
+	EventReservationDeleted = "reservation.deleted"
+	EventBlockCreated = "block.created"
+	EventBlockUpdated = "block.updated"
+	EventBlockDeleted = "block.deleted"
+)
+
+// All events, in the order they are shown in the admin dashboard
+var Events = []string{
+	EventReservationCreated,
+	EventReservationUpdated,
+	EventReservationProcessed,
+	EventReservationDeleted,
+	EventBlockCreated,
+	EventBlockUpdated,
+	EventBlockDeleted,
+}
+
+// Body of the requests sent to webhook endpoints
+type Payload struct {
+	// Unique id of the event, the same for every endpoint and every retry
+	ID string `json:"id"`
+	Event string `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data interface{} `json:"data"`
+}
+
+// Reports whether webhook endpoints can subscribe to the given event
+func IsEvent(name string) bool {
+	for _, event := range Events {
+		if event == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Queues a delivery of the event for every endpoint which subscribed to it.
+// The deliveries are stored in the database and sent by the `Worker`. Deliveries of paused endpoints wait
+// in the queue until the endpoint is activated again
+func Enqueue(db repository.DatabaseRepository, event string, data interface{}) error {
+	endpoints, err := db.GetAllWebhookEndpoints()
+	if err != nil {
+		return err
+	}
+
+	var subscribers []models.WebhookEndpoint
+	for _, endpoint := range endpoints {
+		if endpoint.HasEvent(event) {
+			subscribers = append(subscribers, endpoint)
+		}
+	}
+
+	if len(subscribers) == 0 {
+		return nil
+	}
+
+	id, err := helpers.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	body, err := json.Marshal(Payload{
+		ID: id,
+		Event: event,
+		CreatedAt: now.UTC(),
+		Data: data,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range subscribers {
+		_, err := db.InsertWebhookDelivery(models.WebhookDelivery{
+			EndpointID: endpoint.ID,
+			Event: event,
+			Payload: string(body),
+			NextAttemptAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Signs a payload with the secret of an endpoint. The signature is the hex encoded HMAC-SHA256
+// of the timestamp and the body joined by a dot, e.g. "1639900800.{...}"
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%d.", timestamp)))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
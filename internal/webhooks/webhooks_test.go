@@ -0,0 +1,123 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/helpers"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+)
+
+// Repository keeping webhook endpoints and deliveries in memory.
+// Calling any other method of `repository.DatabaseRepository` panics
+type fakeRepository struct {
+	repository.DatabaseRepository
+	endpoints []models.WebhookEndpoint
+	inserted []models.WebhookDelivery
+	updated []models.WebhookDelivery
+}
+
+func (repo *fakeRepository) GetAllWebhookEndpoints() ([]models.WebhookEndpoint, error) {
+	return repo.endpoints, nil
+}
+
+func (repo *fakeRepository) InsertWebhookDelivery(delivery models.WebhookDelivery) (int, error) {
+	repo.inserted = append(repo.inserted, delivery)
+	return len(repo.inserted), nil
+}
+
+func (repo *fakeRepository) UpdateWebhookDelivery(delivery models.WebhookDelivery) error {
+	repo.updated = append(repo.updated, delivery)
+	return nil
+}
+
+var testApp = &config.AppConfig{
+	InfoLog: log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime),
+	ErrorLog: log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile),
+}
+
+func TestMain(m *testing.M) {
+	helpers.StoreAppConfig(testApp)
+
+	os.Exit(m.Run())
+}
+
+func TestEnqueue(t *testing.T) {
+	repo := &fakeRepository{
+		endpoints: []models.WebhookEndpoint{
+			{ID: 1, Events: "reservation.created reservation.deleted", Active: true},
+			{ID: 2, Events: "reservation.created", Active: false},
+			{ID: 3, Events: "block.created", Active: true},
+			{ID: 4, Events: "reservation.created", Active: true},
+		},
+	}
+
+	err := Enqueue(repo, EventReservationCreated, map[string]int{"id": 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every endpoint which subscribed to the event gets a delivery, paused ones included
+	if len(repo.inserted) != 3 || repo.inserted[0].EndpointID != 1 || repo.inserted[1].EndpointID != 2 || repo.inserted[2].EndpointID != 4 {
+		t.Fatalf("Wrong deliveries queued: %+v", repo.inserted)
+	}
+
+	var payload struct {
+		ID string `json:"id"`
+		Event string `json:"event"`
+		Data map[string]int `json:"data"`
+	}
+
+	err = json.Unmarshal([]byte(repo.inserted[0].Payload), &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payload.ID == "" || payload.Event != EventReservationCreated || payload.Data["id"] != 7 {
+		t.Errorf("Wrong payload: %s", repo.inserted[0].Payload)
+	}
+
+	// Every endpoint receives the same event
+	if repo.inserted[0].Payload != repo.inserted[1].Payload || repo.inserted[0].Payload != repo.inserted[2].Payload {
+		t.Error("Endpoints received different payloads for the same event")
+	}
+}
+
+func TestEnqueue_WithoutSubscribers(t *testing.T) {
+	repo := &fakeRepository{
+		endpoints: []models.WebhookEndpoint{
+			{ID: 1, Events: "block.created", Active: true},
+		},
+	}
+
+	err := Enqueue(repo, EventReservationDeleted, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(repo.inserted) != 0 {
+		t.Errorf("No delivery should be queued: %+v", repo.inserted)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Computed with: printf '1639900800.{"event":"block.created"}' | openssl dgst -sha256 -hmac secret
+	expected := "8d2019188c2155ba78e36e3763af1c463bd9de38f28a311e00957b623219d957"
+
+	signature := Sign("secret", 1639900800, []byte(`{"event":"block.created"}`))
+	if signature != expected {
+		t.Fatalf("Wrong signature: got %s, wanted %s", signature, expected)
+	}
+
+	if Sign("other secret", 1639900800, []byte(`{"event":"block.created"}`)) == signature {
+		t.Error("Different secrets gave the same signature")
+	}
+
+	if Sign("secret", 1639900801, []byte(`{"event":"block.created"}`)) == signature {
+		t.Error("Different timestamps gave the same signature")
+	}
+}
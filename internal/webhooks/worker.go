@@ -0,0 +1,152 @@
+package webhooks
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/config"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/repository"
+)
+
+// A delivery is given up after this many failed attempts
+const MaxAttempts = 8
+
+// Delay before the first retry, which doubles with every failed attempt
+const firstRetryDelay = 30 * time.Second
+
+// Longest delay between two attempts
+const maxRetryDelay = 12 * time.Hour
+
+// Number of deliveries sent on every tick of the worker
+const batchSize = 50
+
+// Time a worker has to send a claimed batch before other workers may claim it again.
+// Covers a whole batch of endpoints which time out
+const claimLease = 15 * time.Minute
+
+// Sends the queued webhook deliveries, retrying failed ones with exponential backoff
+type Worker struct {
+	DB repository.DatabaseRepository
+	App *config.AppConfig
+	Client *http.Client
+}
+
+// Creates a new worker
+func NewWorker(db repository.DatabaseRepository, app *config.AppConfig) *Worker {
+	return &Worker{
+		DB: db,
+		App: app,
+		Client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Sends due deliveries once every interval. Runs in the background
+func (worker *Worker) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			worker.DeliverDue()
+		}
+	}()
+}
+
+// Claims and sends the deliveries whose next attempt is due
+func (worker *Worker) DeliverDue() {
+	deliveries, err := worker.DB.ClaimDueWebhookDeliveries(time.Now(), claimLease, batchSize)
+	if err != nil {
+		worker.App.ErrorLog.Println("Can't get webhook deliveries:", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		worker.Deliver(delivery)
+	}
+}
+
+// Sends a delivery to its endpoint and stores the outcome. A delivery succeeds when the endpoint
+// responds with a 2xx status; otherwise it is retried later until it has failed `MaxAttempts` times
+func (worker *Worker) Deliver(delivery models.WebhookDelivery) models.WebhookDelivery {
+	now := time.Now()
+
+	delivery.Attempts++
+	delivery.LastAttemptAt = now
+
+	status, err := worker.send(delivery, now)
+	delivery.ResponseStatus = status
+
+	switch {
+	case err == nil:
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.LastError = ""
+	case delivery.Attempts >= MaxAttempts:
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.NextAttemptAt = now.Add(Backoff(delivery.Attempts))
+		delivery.LastError = err.Error()
+	}
+
+	err = worker.DB.UpdateWebhookDelivery(delivery)
+	if err != nil {
+		worker.App.ErrorLog.Println("Can't update webhook delivery:", err)
+	}
+
+	return delivery
+}
+
+// Delay before the next attempt after the given number of failed attempts: 30s, 1m, 2m, 4m, ... up to 12h
+func Backoff(attempts int) time.Duration {
+	delay := firstRetryDelay
+
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+
+	return delay
+}
+
+// Posts the payload of a delivery to its endpoint. Returns the status code of the response, if any
+func (worker *Worker) send(delivery models.WebhookDelivery, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := now.Unix()
+
+	req, err := http.NewRequest("POST", delivery.Endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bed-and-breakfast-webhooks")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.Itoa(delivery.ID))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", "sha256=" + Sign(delivery.Endpoint.Secret, timestamp, body))
+
+	res, err := worker.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer res.Body.Close()
+
+	// Drain the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64 << 10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("endpoint responded with status %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
@@ -0,0 +1,120 @@
+package webhooks
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/bed-and-breakfast/internal/models"
+)
+
+func TestWorker_Deliver(t *testing.T) {
+	var received *http.Request
+	var receivedBody []byte
+
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	repo := &fakeRepository{}
+	worker := NewWorker(repo, testApp)
+
+	delivery := models.WebhookDelivery{
+		ID: 5,
+		EndpointID: 1,
+		Event: EventBlockCreated,
+		Payload: `{"event":"block.created"}`,
+		Status: models.WebhookDeliveryPending,
+		Endpoint: models.WebhookEndpoint{ID: 1, URL: server.URL, Secret: "secret", Active: true},
+	}
+
+	result := worker.Deliver(delivery)
+	if result.Status != models.WebhookDeliveryDelivered || result.Attempts != 1 || result.ResponseStatus != http.StatusOK {
+		t.Errorf("Wrong delivery after success: %+v", result)
+	}
+
+	if len(repo.updated) != 1 {
+		t.Fatalf("Delivery was not stored")
+	}
+
+	if string(receivedBody) != delivery.Payload {
+		t.Errorf("Wrong body: %s", receivedBody)
+	}
+
+	if received.Header.Get("X-Webhook-Event") != EventBlockCreated || received.Header.Get("X-Webhook-Delivery") != "5" {
+		t.Errorf("Wrong headers: %v", received.Header)
+	}
+
+	// The receiver can verify the signature with the timestamp header and its copy of the secret
+	timestamp, err := strconv.ParseInt(received.Header.Get("X-Webhook-Timestamp"), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if received.Header.Get("X-Webhook-Signature") != "sha256=" + Sign("secret", timestamp, receivedBody) {
+		t.Errorf("Wrong signature: %s", received.Header.Get("X-Webhook-Signature"))
+	}
+
+	// Failed attempts are retried later
+	status = http.StatusInternalServerError
+
+	before := time.Now()
+	result = worker.Deliver(delivery)
+	if result.Status != models.WebhookDeliveryPending || result.ResponseStatus != http.StatusInternalServerError || result.LastError == "" {
+		t.Errorf("Wrong delivery after failure: %+v", result)
+	}
+
+	if result.NextAttemptAt.Before(before.Add(firstRetryDelay)) {
+		t.Errorf("Retry was not delayed: %v", result.NextAttemptAt)
+	}
+
+	// The last attempt gives up
+	delivery.Attempts = MaxAttempts - 1
+	result = worker.Deliver(delivery)
+	if result.Status != models.WebhookDeliveryFailed || result.Attempts != MaxAttempts {
+		t.Errorf("Wrong delivery after last attempt: %+v", result)
+	}
+}
+
+func TestWorker_Deliver_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	worker := NewWorker(&fakeRepository{}, testApp)
+
+	result := worker.Deliver(models.WebhookDelivery{
+		Payload: "{}",
+		Status: models.WebhookDeliveryPending,
+		Endpoint: models.WebhookEndpoint{URL: url},
+	})
+	if result.Status != models.WebhookDeliveryPending || result.ResponseStatus != 0 || result.LastError == "" {
+		t.Errorf("Wrong delivery: %+v", result)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{20, 12 * time.Hour},
+	}
+
+	for _, test := range tests {
+		if delay := Backoff(test.attempts); delay != test.expected {
+			t.Errorf("Backoff(%d): got %v, wanted %v", test.attempts, delay, test.expected)
+		}
+	}
+}
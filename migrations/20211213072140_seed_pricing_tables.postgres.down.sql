@@ -0,0 +1,2 @@
+DELETE FROM stay_discounts;
+DELETE FROM room_rates;
@@ -0,0 +1,7 @@
+INSERT INTO room_rates (room_id, nightly_rate, weekend_surcharge, created_at, updated_at) VALUES
+(1, 12000, 2500, '2021-12-13 07:21:40.000', '2021-12-13 07:21:40.000'),
+(2, 15000, 3000, '2021-12-13 07:21:40.000', '2021-12-13 07:21:40.000');
+
+INSERT INTO stay_discounts (room_id, min_nights, percent_off, created_at, updated_at) VALUES
+(1, 7, 10, '2021-12-13 07:21:40.000', '2021-12-13 07:21:40.000'),
+(2, 7, 10, '2021-12-13 07:21:40.000', '2021-12-13 07:21:40.000');
@@ -0,0 +1 @@
+UPDATE rooms SET slug = '', description = '', amenities = '', image = '', sort_order = 0;
\ No newline at end of file
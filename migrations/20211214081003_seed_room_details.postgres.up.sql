@@ -0,0 +1,17 @@
+UPDATE rooms SET
+  slug = 'generals-quarters',
+  description = 'Your home away form home, set on the majestic waters of the Atlantic Ocean, this will be a vacation to remember.',
+  capacity = 2,
+  amenities = E'Queen size bed\nOcean view\nPrivate bathroom',
+  image = 'generals-quarters.png',
+  sort_order = 1
+WHERE room_name = 'General''s Quarters';
+
+UPDATE rooms SET
+  slug = 'majors-suite',
+  description = 'Your home away form home, set on the majestic waters of the Atlantic Ocean, this will be a vacation to remember.',
+  capacity = 2,
+  amenities = E'King size bed\nOcean view\nPrivate bathroom\nSitting area',
+  image = 'marjors-suite.png',
+  sort_order = 2
+WHERE room_name = 'Major''s Suite';
@@ -0,0 +1 @@
+UPDATE reservations SET confirmation_code = '';
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- Existing reservations get a random code so that guests can look them up as well
+UPDATE reservations SET confirmation_code = upper(substr(md5(random()::text || id::text), 1, 12))
+WHERE confirmation_code = '';
@@ -0,0 +1 @@
+DELETE FROM restrictions WHERE restriction_name = 'External booking';
\ No newline at end of file
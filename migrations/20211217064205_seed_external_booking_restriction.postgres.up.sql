@@ -0,0 +1,2 @@
+INSERT INTO restrictions (restriction_name, created_at, updated_at) VALUES
+('External booking', '2021-12-17 07:09:00.000', '2021-12-17 07:09:00.000');
\ No newline at end of file
@@ -0,0 +1 @@
+UPDATE rooms SET bed_configuration = '';
\ No newline at end of file
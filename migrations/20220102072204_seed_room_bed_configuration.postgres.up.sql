@@ -0,0 +1,3 @@
+UPDATE rooms SET bed_configuration = '1 queen bed' WHERE room_name = 'General''s Quarters';
+
+UPDATE rooms SET bed_configuration = '1 king bed' WHERE room_name = 'Major''s Suite';
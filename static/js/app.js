@@ -106,6 +106,16 @@ function checkAvailabilityByRoom(roomId) {
                             </div>
 
                         </div>
+                        <div class="row mt-3">
+                            <div class="col">
+                                <label for="adults">Adults</label>
+                                <input required class="form-control" type="number" min="1" name="adults" id="adults" value="1">
+                            </div>
+                            <div class="col">
+                                <label for="children">Children</label>
+                                <input required class="form-control" type="number" min="0" name="children" id="children" value="0">
+                            </div>
+                        </div>
                     </div>
                 </div>
             </form>
@@ -154,12 +164,16 @@ function checkAvailabilityByRoom(roomId) {
                     data.start_date +
                     '&end_date=' +
                     data.end_date +
+                    '&adults=' +
+                    data.adults +
+                    '&children=' +
+                    data.children +
                     '" class="btn btn-primary">' +
                     'Book now!</a></p>',
                 });
               } else {
                 attention.error({
-                  msg: 'Room is not available',
+                  msg: data.message || 'Room is not available',
                 });
               }
             });